@@ -21,6 +21,7 @@ package app
 // contrib/mesos/pkg/executor/service/.
 
 import (
+	"crypto/rsa"
 	"crypto/tls"
 	"fmt"
 	"math/rand"
@@ -40,6 +41,7 @@ import (
 	clientcmdapi "github.com/GoogleCloudPlatform/kubernetes/pkg/client/clientcmd/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/clientauth"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/controller/serviceaccount"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/credentialprovider"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/healthz"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet"
@@ -120,6 +122,8 @@ type KubeletServer struct {
 	PodCIDR                        string
 	MaxPods                        int
 	DockerExecHandlerName          string
+	SecretVolumeVerifyingKeyFile   string
+	SecretVolumePodCredentials     bool
 
 	// Flags intended for testing
 
@@ -248,6 +252,8 @@ func (s *KubeletServer) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&s.MaxPods, "max-pods", 40, "Number of Pods that can run on this Kubelet.")
 	fs.StringVar(&s.DockerExecHandlerName, "docker-exec-handler", s.DockerExecHandlerName, "Handler to use when executing a command in a container. Valid values are 'native' and 'nsenter'. Defaults to 'native'.")
 	fs.StringVar(&s.PodCIDR, "pod-cidr", "", "The CIDR to use for pod IP addresses, only used in standalone mode.  In cluster mode, this is obtained from the master.")
+	fs.StringVar(&s.SecretVolumeVerifyingKeyFile, "secret-volume-verifying-key-file", s.SecretVolumeVerifyingKeyFile, "File containing the PEM-encoded RSA public key that secrets must be signed with to be mounted by secret volumes that set verifySignature. Empty for no key, in which case such volumes fail to set up.")
+	fs.BoolVar(&s.SecretVolumePodCredentials, "secret-volume-pod-credentials", s.SecretVolumePodCredentials, "If true, secret volumes read the secrets of a pod with the token of the pod's service account rather than the kubelet's credentials, falling back to the kubelet's credentials for pods without a token. [default=false]")
 	// Flags intended for testing, not recommended used in production environments.
 	fs.BoolVar(&s.ReallyCrashForTesting, "really-crash-for-testing", s.ReallyCrashForTesting, "If true, when panics occur crash. Intended for testing.")
 	fs.Float64Var(&s.ChaosChance, "chaos-chance", s.ChaosChance, "If > 0.0, introduce random client errors and latency. Intended for testing. [default=0.0]")
@@ -316,6 +322,15 @@ func (s *KubeletServer) Run(_ []string) error {
 		return err
 	}
 
+	secretVolumeVerifyingKey, err := s.ReadSecretVolumeVerifyingKey()
+	if err != nil {
+		return err
+	}
+	var secretVolumeClientConfig *client.Config
+	if s.SecretVolumePodCredentials {
+		secretVolumeClientConfig = clientConfig
+	}
+
 	mounter := mount.New()
 	if s.Containerized {
 		glog.V(2).Info("Running kubelet in containerized mode (experimental)")
@@ -383,6 +398,8 @@ func (s *KubeletServer) Run(_ []string) error {
 		PodCIDR:                   s.PodCIDR,
 		MaxPods:                   s.MaxPods,
 		DockerExecHandler:         dockerExecHandler,
+		SecretVolumeVerifyingKey:  secretVolumeVerifyingKey,
+		SecretVolumeClientConfig:  secretVolumeClientConfig,
 	}
 
 	if err := RunKubelet(&kcfg, nil); err != nil {
@@ -431,6 +448,19 @@ func (s *KubeletServer) InitializeTLS() (*kubelet.TLSOptions, error) {
 	return tlsOptions, nil
 }
 
+// ReadSecretVolumeVerifyingKey reads the key configured with
+// SecretVolumeVerifyingKeyFile.  Returns nil if no file is configured.
+func (s *KubeletServer) ReadSecretVolumeVerifyingKey() (*rsa.PublicKey, error) {
+	if s.SecretVolumeVerifyingKeyFile == "" {
+		return nil, nil
+	}
+	key, err := serviceaccount.ReadPublicKey(s.SecretVolumeVerifyingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the secret volume verifying key: %v", err)
+	}
+	return key, nil
+}
+
 func (s *KubeletServer) authPathClientConfig(useDefaults bool) (*client.Config, error) {
 	authInfo, err := clientauth.LoadFromFile(s.AuthPath.Value())
 	if err != nil && !useDefaults {
@@ -738,6 +768,8 @@ type KubeletConfig struct {
 	PodCIDR                        string
 	MaxPods                        int
 	DockerExecHandler              dockertools.ExecHandler
+	SecretVolumeVerifyingKey       *rsa.PublicKey
+	SecretVolumeClientConfig       *client.Config
 }
 
 func createAndInitKubelet(kc *KubeletConfig) (k KubeletBootstrap, pc *config.PodConfig, err error) {
@@ -795,7 +827,9 @@ func createAndInitKubelet(kc *KubeletConfig) (k KubeletBootstrap, pc *config.Pod
 		kc.ConfigureCBR0,
 		kc.PodCIDR,
 		kc.MaxPods,
-		kc.DockerExecHandler)
+		kc.DockerExecHandler,
+		kc.SecretVolumeVerifyingKey,
+		kc.SecretVolumeClientConfig)
 
 	if err != nil {
 		return nil, nil, err
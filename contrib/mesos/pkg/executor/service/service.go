@@ -186,6 +186,14 @@ func (s *KubeletExecutorServer) Run(hks hyperkube.Interface, _ []string) error {
 	if err != nil {
 		return err
 	}
+	secretVolumeVerifyingKey, err := s.ReadSecretVolumeVerifyingKey()
+	if err != nil {
+		return err
+	}
+	var secretVolumeClientConfig *client.Config
+	if s.SecretVolumePodCredentials {
+		secretVolumeClientConfig = clientConfig
+	}
 	mounter := mount.New()
 	if s.Containerized {
 		log.V(2).Info("Running kubelet in containerized mode (experimental)")
@@ -251,6 +259,8 @@ func (s *KubeletExecutorServer) Run(hks hyperkube.Interface, _ []string) error {
 		ConfigureCBR0:             s.ConfigureCBR0,
 		MaxPods:                   s.MaxPods,
 		DockerExecHandler:         dockerExecHandler,
+		SecretVolumeVerifyingKey:  secretVolumeVerifyingKey,
+		SecretVolumeClientConfig:  secretVolumeClientConfig,
 	}
 
 	kcfg.NodeName = kcfg.Hostname
@@ -351,6 +361,8 @@ func (ks *KubeletExecutorServer) createAndInitKubelet(
 		kc.PodCIDR,
 		kc.MaxPods,
 		kc.DockerExecHandler,
+		kc.SecretVolumeVerifyingKey,
+		kc.SecretVolumeClientConfig,
 	)
 	if err != nil {
 		return nil, nil, err
@@ -391,6 +391,19 @@ func deepCopy_api_EndpointsList(in EndpointsList, out *EndpointsList, c *convers
 	return nil
 }
 
+func deepCopy_api_EnvFileProjection(in EnvFileProjection, out *EnvFileProjection, c *conversion.Cloner) error {
+	out.Path = in.Path
+	if in.Keys != nil {
+		out.Keys = make([]string, len(in.Keys))
+		for i := range in.Keys {
+			out.Keys[i] = in.Keys[i]
+		}
+	} else {
+		out.Keys = nil
+	}
+	return nil
+}
+
 func deepCopy_api_EnvVar(in EnvVar, out *EnvVar, c *conversion.Cloner) error {
 	out.Name = in.Name
 	out.Value = in.Value
@@ -553,6 +566,47 @@ func deepCopy_api_ISCSIVolumeSource(in ISCSIVolumeSource, out *ISCSIVolumeSource
 	return nil
 }
 
+func deepCopy_api_KeyToPath(in KeyToPath, out *KeyToPath, c *conversion.Cloner) error {
+	out.Key = in.Key
+	out.KeyPattern = in.KeyPattern
+	out.Path = in.Path
+	if in.Mode != nil {
+		out.Mode = new(int)
+		*out.Mode = *in.Mode
+	} else {
+		out.Mode = nil
+	}
+	if in.UID != nil {
+		out.UID = new(int64)
+		*out.UID = *in.UID
+	} else {
+		out.UID = nil
+	}
+	if in.GID != nil {
+		out.GID = new(int64)
+		*out.GID = *in.GID
+	} else {
+		out.GID = nil
+	}
+	out.Encoding = in.Encoding
+	out.NormalizeLineEndings = in.NormalizeLineEndings
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	if in.ExtendedAttributes != nil {
+		out.ExtendedAttributes = make(map[string]string)
+		for key, val := range in.ExtendedAttributes {
+			out.ExtendedAttributes[key] = val
+		}
+	} else {
+		out.ExtendedAttributes = nil
+	}
+	return nil
+}
+
 func deepCopy_api_Lifecycle(in Lifecycle, out *Lifecycle, c *conversion.Cloner) error {
 	if in.PostStart != nil {
 		out.PostStart = new(Handler)
@@ -1439,6 +1493,20 @@ func deepCopy_api_Probe(in Probe, out *Probe, c *conversion.Cloner) error {
 	return nil
 }
 
+func deepCopy_api_ProjectedVolumeSource(in ProjectedVolumeSource, out *ProjectedVolumeSource, c *conversion.Cloner) error {
+	if in.Sources != nil {
+		out.Sources = make([]VolumeProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := deepCopy_api_VolumeProjection(in.Sources[i], &out.Sources[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
+	return nil
+}
+
 func deepCopy_api_RBDVolumeSource(in RBDVolumeSource, out *RBDVolumeSource, c *conversion.Cloner) error {
 	if in.CephMonitors != nil {
 		out.CephMonitors = make([]string, len(in.CephMonitors))
@@ -1683,6 +1751,12 @@ func deepCopy_api_Secret(in Secret, out *Secret, c *conversion.Cloner) error {
 		out.Data = nil
 	}
 	out.Type = in.Type
+	if in.Immutable != nil {
+		out.Immutable = new(bool)
+		*out.Immutable = *in.Immutable
+	} else {
+		out.Immutable = nil
+	}
 	return nil
 }
 
@@ -1706,8 +1780,99 @@ func deepCopy_api_SecretList(in SecretList, out *SecretList, c *conversion.Clone
 	return nil
 }
 
+func deepCopy_api_SecretProjection(in SecretProjection, out *SecretProjection, c *conversion.Cloner) error {
+	out.SecretName = in.SecretName
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_api_KeyToPath(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	return nil
+}
+
 func deepCopy_api_SecretVolumeSource(in SecretVolumeSource, out *SecretVolumeSource, c *conversion.Cloner) error {
 	out.SecretName = in.SecretName
+	if in.SecretSelector != nil {
+		out.SecretSelector = make(map[string]string)
+		for key, val := range in.SecretSelector {
+			out.SecretSelector[key] = val
+		}
+	} else {
+		out.SecretSelector = nil
+	}
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_api_KeyToPath(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.DefaultMode != nil {
+		out.DefaultMode = new(int)
+		*out.DefaultMode = *in.DefaultMode
+	} else {
+		out.DefaultMode = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	out.WipeOnTeardown = in.WipeOnTeardown
+	out.SanitizeKeyNames = in.SanitizeKeyNames
+	out.SingleKeyTargetPath = in.SingleKeyTargetPath
+	out.BundlePath = in.BundlePath
+	if in.BundleSeparator != nil {
+		out.BundleSeparator = new(string)
+		*out.BundleSeparator = *in.BundleSeparator
+	} else {
+		out.BundleSeparator = nil
+	}
+	out.ArchivePath = in.ArchivePath
+	if in.EnvFile != nil {
+		out.EnvFile = new(EnvFileProjection)
+		if err := deepCopy_api_EnvFileProjection(*in.EnvFile, out.EnvFile, c); err != nil {
+			return err
+		}
+	} else {
+		out.EnvFile = nil
+	}
+	out.WriteContentHash = in.WriteContentHash
+	out.WriteManifest = in.WriteManifest
+	out.PlainDirectory = in.PlainDirectory
+	out.HostDirectory = in.HostDirectory
+	out.RequireNonEmpty = in.RequireNonEmpty
+	out.StrictKeys = in.StrictKeys
+	out.BestEffort = in.BestEffort
+	out.TypeCheck = in.TypeCheck
+	out.ProjectionStrategy = in.ProjectionStrategy
+	out.VerifySignature = in.VerifySignature
+	out.StrictExtendedAttributes = in.StrictExtendedAttributes
+	if in.Sources != nil {
+		out.Sources = make([]SecretProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := deepCopy_api_SecretProjection(in.Sources[i], &out.Sources[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
 	return nil
 }
 
@@ -1964,6 +2129,18 @@ func deepCopy_api_VolumeMount(in VolumeMount, out *VolumeMount, c *conversion.Cl
 	return nil
 }
 
+func deepCopy_api_VolumeProjection(in VolumeProjection, out *VolumeProjection, c *conversion.Cloner) error {
+	if in.Secret != nil {
+		out.Secret = new(SecretVolumeSource)
+		if err := deepCopy_api_SecretVolumeSource(*in.Secret, out.Secret, c); err != nil {
+			return err
+		}
+	} else {
+		out.Secret = nil
+	}
+	return nil
+}
+
 func deepCopy_api_VolumeSource(in VolumeSource, out *VolumeSource, c *conversion.Cloner) error {
 	if in.HostPath != nil {
 		out.HostPath = new(HostPathVolumeSource)
@@ -2053,6 +2230,14 @@ func deepCopy_api_VolumeSource(in VolumeSource, out *VolumeSource, c *conversion
 	} else {
 		out.RBD = nil
 	}
+	if in.Projected != nil {
+		out.Projected = new(ProjectedVolumeSource)
+		if err := deepCopy_api_ProjectedVolumeSource(*in.Projected, out.Projected, c); err != nil {
+			return err
+		}
+	} else {
+		out.Projected = nil
+	}
 	return nil
 }
 
@@ -2108,6 +2293,7 @@ func init() {
 		deepCopy_api_EndpointSubset,
 		deepCopy_api_Endpoints,
 		deepCopy_api_EndpointsList,
+		deepCopy_api_EnvFileProjection,
 		deepCopy_api_EnvVar,
 		deepCopy_api_EnvVarSource,
 		deepCopy_api_Event,
@@ -2121,6 +2307,7 @@ func init() {
 		deepCopy_api_Handler,
 		deepCopy_api_HostPathVolumeSource,
 		deepCopy_api_ISCSIVolumeSource,
+		deepCopy_api_KeyToPath,
 		deepCopy_api_Lifecycle,
 		deepCopy_api_LimitRange,
 		deepCopy_api_LimitRangeItem,
@@ -2171,6 +2358,7 @@ func init() {
 		deepCopy_api_PodTemplateList,
 		deepCopy_api_PodTemplateSpec,
 		deepCopy_api_Probe,
+		deepCopy_api_ProjectedVolumeSource,
 		deepCopy_api_RBDVolumeSource,
 		deepCopy_api_RangeAllocation,
 		deepCopy_api_ReplicationController,
@@ -2185,6 +2373,7 @@ func init() {
 		deepCopy_api_SELinuxOptions,
 		deepCopy_api_Secret,
 		deepCopy_api_SecretList,
+		deepCopy_api_SecretProjection,
 		deepCopy_api_SecretVolumeSource,
 		deepCopy_api_SecurityContext,
 		deepCopy_api_SerializedReference,
@@ -2202,6 +2391,7 @@ func init() {
 		deepCopy_api_TypeMeta,
 		deepCopy_api_Volume,
 		deepCopy_api_VolumeMount,
+		deepCopy_api_VolumeProjection,
 		deepCopy_api_VolumeSource,
 		deepCopy_resource_Quantity,
 		deepCopy_util_IntOrString,
@@ -219,6 +219,8 @@ type VolumeSource struct {
 	PersistentVolumeClaim *PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
 	// RBD represents a Rados Block Device mount on the host that shares a pod's lifetime
 	RBD *RBDVolumeSource `json:"rbd,omitempty"`
+	// Projected represents several sources projected into one volume.
+	Projected *ProjectedVolumeSource `json:"projected,omitempty"`
 }
 
 // Similar to VolumeSource but meant for the administrator who creates PVs.
@@ -499,8 +501,254 @@ type GitRepoVolumeSource struct {
 type SecretVolumeSource struct {
 	// Name of the secret in the pod's namespace to use
 	SecretName string `json:"secretName"`
+	// Optional: Labels selecting the secret in the pod's namespace to use,
+	// instead of naming it with SecretName, so that the secret can be
+	// replaced without changing the pod.  The selector is resolved again
+	// on every refresh of the volume, and the volume will not be set up
+	// while it matches no secret or more than one.  May not be set
+	// together with SecretName.
+	SecretSelector map[string]string `json:"secretSelector,omitempty"`
+	// Optional: If unspecified, each key-value pair in the Data field of the
+	// referenced Secret will be projected into the volume as a file whose
+	// name is the key and content is the value.  If specified, the listed
+	// keys will be projected into the specified paths, and unlisted keys
+	// will not be present.  If a key is specified which is not present in
+	// the Secret, the volume setup will error.
+	Items []KeyToPath `json:"items,omitempty"`
+	// Optional: Mode bits to use on created files by default. Must be a
+	// value between 0 and 0777.  Defaults to 0444.
+	DefaultMode *int `json:"defaultMode,omitempty"`
+	// Optional: If true, the volume is set up empty when the Secret does
+	// not exist, and populated once the Secret is created.  Defaults to
+	// false.
+	Optional *bool `json:"optional,omitempty"`
+	// Optional: If true, the contents of the files are overwritten with
+	// zeros before the volume is torn down.  Defaults to false.
+	WipeOnTeardown bool `json:"wipeOnTeardown,omitempty"`
+	// Optional: If true, files named after a key have characters that
+	// are awkward on some filesystems replaced and are shortened to a
+	// safe length.  A suffix derived from the key keeps the names of
+	// different keys distinct.  Explicit item paths are not changed.
+	// Defaults to false.
+	SanitizeKeyNames bool `json:"sanitizeKeyNames,omitempty"`
+	// Optional: The relative path to project the only key of the Secret
+	// to, whatever its name.  The volume setup will error if the Secret
+	// has more than one key.  May not be set together with Items.
+	SingleKeyTargetPath string `json:"singleKeyTargetPath,omitempty"`
+	// Optional: Relative path of a single file holding the values of all
+	// of the keys of the Secret, concatenated in the order of their keys,
+	// instead of a file per key.  May not be set together with Items or
+	// SingleKeyTargetPath.
+	BundlePath string `json:"bundlePath,omitempty"`
+	// Optional: String written between the values in the BundlePath file.
+	// Defaults to a newline.
+	BundleSeparator *string `json:"bundleSeparator,omitempty"`
+	// Optional: Relative path of a single gzip-compressed tar archive
+	// holding a file per key of the Secret, named after the key, instead
+	// of the files themselves, to save space on disk-backed volumes.  The
+	// archive is not unpacked for the containers, which must unpack it
+	// themselves.  May not be set together with Items, SingleKeyTargetPath
+	// or BundlePath.
+	ArchivePath string `json:"archivePath,omitempty"`
+	// Optional: A single file holding keys of the Secret as KEY=value
+	// lines, for applications that read dotenv files.  It is written
+	// alongside the files the keys are projected to, not instead of them.
+	EnvFile *EnvFileProjection `json:"envFile,omitempty"`
+	// Optional: If true, a file named ".secret-hash" holding a hash of
+	// the contents of the Secret is written along with its keys.  The hash
+	// changes whenever the Secret does, so it is a single file to watch
+	// for updates.  Defaults to false.
+	WriteContentHash bool `json:"writeContentHash,omitempty"`
+	// Optional: If true, a file named ".manifest.json" listing each of
+	// the other files of the volume, with the secret and key it holds,
+	// its size in bytes and its mode, is written along with them.
+	// Defaults to false.
+	WriteManifest bool `json:"writeManifest,omitempty"`
+	// Optional: If true, the files are written to a plain directory in
+	// the pod's directory rather than to a tmpfs mount of their own.  This
+	// saves a mount for small, short-lived volumes, but leaves the secret
+	// on the node's disk.  Defaults to false.
+	PlainDirectory bool `json:"plainDirectory,omitempty"`
+	// Optional: Experimental: The name of a directory on the node that the
+	// node operator configured for host directory volumes, for migrating
+	// from software that reads secrets from a fixed host path.  The files
+	// are written into a directory of their own beneath it, named after
+	// the pod's UID and the volume, instead of the volume's own directory.
+	// The kubelet must allow host directories.  Other files in the
+	// directory are left alone, as is the directory itself on teardown.
+	// May not be set together with PlainDirectory.
+	HostDirectory string `json:"hostDirectory,omitempty"`
+	// Optional: If true, the volume will not be set up while the Secret
+	// holds no data.  Ignored if the volume is optional.  Defaults to
+	// false, in which case an empty Secret yields an empty volume.
+	RequireNonEmpty bool `json:"requireNonEmpty,omitempty"`
+	// Optional: If true, the volume will not be set up while the Secret
+	// holds a key that is not selected by any of Items.  Applies to each
+	// secret of the volume, including Sources, that lists items.  Defaults
+	// to false, in which case other keys are ignored.
+	StrictKeys bool `json:"strictKeys,omitempty"`
+	// Optional: Whether an item whose key is missing from its secret, or
+	// whose KeyPattern matches no key, is skipped with a warning rather
+	// than failing the volume setup, as if it were optional.  The other
+	// items are still projected.  Defaults to false, in which case the
+	// setup fails unless every item that is not optional is projected.
+	BestEffort bool `json:"bestEffort,omitempty"`
+	// Optional: Whether to check that each secret of the volume holds,
+	// and the volume projects, the keys its type calls for, such as
+	// ".dockercfg" for a secret of type kubernetes.io/dockercfg.  Secrets
+	// of other types are not checked.  Defaults to None.
+	TypeCheck SecretTypeCheck `json:"typeCheck,omitempty"`
+	// Optional: How the files are projected into the volume.  Defaults to
+	// AtomicSymlink, in which case each file is reached through a symlink
+	// into a data directory that is replaced as a whole, so that readers
+	// see an update all at once.  Copy writes plain files instead, for
+	// runtimes that do not allow symlinks; each file is replaced on its
+	// own, so readers may see an update partway through.
+	ProjectionStrategy SecretProjectionStrategy `json:"projectionStrategy,omitempty"`
+	// Optional: Whether to verify that each secret of the volume is signed
+	// by the key the kubelet is configured with before anything of it is
+	// written.  The signature is held by the ".sig" key of the secret, as
+	// an RSA PKCS #1 v1.5 signature of the SHA-256 hash of its other keys
+	// and values, which is not projected into the volume.  Defaults to
+	// false.
+	VerifySignature bool `json:"verifySignature,omitempty"`
+	// Optional: Whether setup fails, rather than logs a warning, when the
+	// extended attributes of an item cannot be set because the filesystem
+	// of the volume or the node does not support them.  Defaults to false.
+	StrictExtendedAttributes bool `json:"strictExtendedAttributes,omitempty"`
+	// Optional: More secrets to project into the same volume, alongside
+	// the one named by SecretName.  The volume will not be set up if two
+	// secrets would be projected to the same path.
+	Sources []SecretProjection `json:"sources,omitempty"`
+}
+
+// SecretTypeCheck defines what a secret volume does with a secret that
+// lacks a key its type calls for.
+type SecretTypeCheck string
+
+const (
+	SecretTypeCheckNone  SecretTypeCheck = "None"  // do not check the keys
+	SecretTypeCheckWarn  SecretTypeCheck = "Warn"  // set up the volume, but log and record an event
+	SecretTypeCheckError SecretTypeCheck = "Error" // do not set up the volume
+)
+
+// SecretProjectionStrategy defines how a secret volume projects its files.
+type SecretProjectionStrategy string
+
+const (
+	SecretProjectionAtomicSymlink SecretProjectionStrategy = "AtomicSymlink" // symlinks into a data directory that is swapped atomically
+	SecretProjectionCopy          SecretProjectionStrategy = "Copy"          // plain files, each replaced on its own
+)
+
+// SecretVolumeOptionAnnotationPrefix is the prefix of the experimental pod
+// annotations that set options of the pod's secret volumes.  The annotation
+// <prefix><volume name>.<option> holds the value of the option, "true" or
+// "false" for most, and only applies to an option that the volume source
+// leaves unset.
+const SecretVolumeOptionAnnotationPrefix = "secret.volume.alpha.kubernetes.io/"
+
+// SecretProjection describes a secret whose keys are projected into a
+// secret volume along with those of the volume's own secret.
+type SecretProjection struct {
+	// Name of the secret in the pod's namespace to use.
+	SecretName string `json:"secretName"`
+	// Optional: The keys to project and the paths to project them to, as
+	// for the Items of a SecretVolumeSource.  If unspecified, every key is
+	// projected to a file named after it.
+	Items []KeyToPath `json:"items,omitempty"`
+	// Optional: If true, the secret is skipped when it does not exist.
+	// Defaults to false.
+	Optional *bool `json:"optional,omitempty"`
 }
 
+// EnvFileProjection describes a file of a secret volume that holds keys
+// of the volume's Secret as KEY=value lines, one per key, sorted by key.
+//
+// Each value is written between double quotes, with a backslash before
+// each backslash, double quote, dollar sign and backquote, and each
+// newline written as the two characters \n.  The volume will not be set
+// up while a rendered value is not valid UTF-8 or holds a control
+// character other than a newline or a tab.
+type EnvFileProjection struct {
+	// The relative path of the file.  May not be an absolute path, may
+	// not contain the path element '..' and may not start with the
+	// string '..'.
+	Path string `json:"path"`
+	// Optional: The keys to write to the file, each of which must be a C
+	// identifier.  If unspecified, every key of the Secret is written,
+	// and the volume will not be set up while a key is not a C
+	// identifier.  If a listed key is not present in the Secret, the
+	// volume setup will error.
+	Keys []string `json:"keys,omitempty"`
+}
+
+// KeyToPath maps a string key to a path within a volume.
+type KeyToPath struct {
+	// The key to project.  Exactly one of Key and KeyPattern must be set.
+	Key string `json:"key,omitempty"`
+	// A glob pattern, in the syntax of path.Match, selecting the keys to
+	// project.  Each matching key is projected to a file named after the
+	// key, inside the directory given by Path.
+	KeyPattern string `json:"keyPattern,omitempty"`
+	// The relative path of the file to map the key to, or of the
+	// directory to hold the keys matching KeyPattern.  May not be an
+	// absolute path, may not contain the path element '..' and may not
+	// start with the string '..'.
+	Path string `json:"path"`
+	// Optional: Mode bits to use on this file.  Must be a value between 0
+	// and 0777.  If not specified, the volume defaultMode will be used.
+	Mode *int `json:"mode,omitempty"`
+	// Optional: The user that owns this file.  If not specified, the file
+	// is owned by the kubelet's user.
+	UID *int64 `json:"uid,omitempty"`
+	// Optional: The group that owns this file.  If not specified, the
+	// file is owned by the pod's fsGroup, if it has one.
+	GID *int64 `json:"gid,omitempty"`
+	// Optional: How the value of the key is encoded.  The value is decoded
+	// before it is written to the file.  The default is "raw", which
+	// writes the value unchanged.
+	Encoding KeyEncoding `json:"encoding,omitempty"`
+	// Optional: If true, CRLF line endings in the value are converted to
+	// LF when it is written, after it is decoded.  Only set it for text
+	// values.  Defaults to false, which writes the value unchanged.
+	NormalizeLineEndings bool `json:"normalizeLineEndings,omitempty"`
+	// Optional: If true, the item is skipped when its key is not present
+	// in the Secret, or when its KeyPattern matches no key, rather than
+	// failing the volume setup.  Defaults to false.
+	Optional *bool `json:"optional,omitempty"`
+	// Optional: Extended attributes to set on the file, by name, for tools
+	// that recognize files by them.  Names must be in the "user."
+	// namespace.  Where the filesystem of the volume does not support
+	// them, they are left unset unless the volume sets
+	// StrictExtendedAttributes.
+	ExtendedAttributes map[string]string `json:"extendedAttributes,omitempty"`
+}
+
+// ProjectedVolumeSource adapts several sources into one VolumeSource.
+//
+// Only secrets can be projected so far, and the kubelet sets up a projected
+// volume that projects exactly one secret as it does a secret volume.
+type ProjectedVolumeSource struct {
+	// Required: The sources to project into the volume.
+	Sources []VolumeProjection `json:"sources"`
+}
+
+// VolumeProjection is a source projected into a projected volume.  Exactly
+// one of its members must be set.
+type VolumeProjection struct {
+	// Secret represents a secret whose keys are projected into the volume.
+	Secret *SecretVolumeSource `json:"secret,omitempty"`
+}
+
+// KeyEncoding defines ways that the value of a key projected into a file
+// can be encoded.
+type KeyEncoding string
+
+const (
+	KeyEncodingRaw    KeyEncoding = "raw"    // write the value unchanged
+	KeyEncodingBase64 KeyEncoding = "base64" // decode the value as standard base64
+)
+
 // NFSVolumeSource represents an NFS Mount that lasts the lifetime of a pod
 type NFSVolumeSource struct {
 	// Server is the hostname or IP address of the NFS server
@@ -1984,6 +2232,11 @@ type Secret struct {
 
 	// Used to facilitate programmatic handling of secret data.
 	Type SecretType `json:"type,omitempty"`
+
+	// Optional: If true, the data of the secret cannot be updated, and the
+	// secret cannot be made mutable again.  Consumers such as the kubelet
+	// may then stop watching the secret for changes.  Defaults to false.
+	Immutable *bool `json:"immutable,omitempty"`
 }
 
 const MaxSecretSize = 1 * 1024 * 1024
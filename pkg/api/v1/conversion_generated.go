@@ -447,6 +447,22 @@ func convert_api_EndpointsList_To_v1_EndpointsList(in *api.EndpointsList, out *E
 	return nil
 }
 
+func convert_api_EnvFileProjection_To_v1_EnvFileProjection(in *api.EnvFileProjection, out *EnvFileProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*api.EnvFileProjection))(in)
+	}
+	out.Path = in.Path
+	if in.Keys != nil {
+		out.Keys = make([]string, len(in.Keys))
+		for i := range in.Keys {
+			out.Keys[i] = in.Keys[i]
+		}
+	} else {
+		out.Keys = nil
+	}
+	return nil
+}
+
 func convert_api_EnvVar_To_v1_EnvVar(in *api.EnvVar, out *EnvVar, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*api.EnvVar))(in)
@@ -648,6 +664,50 @@ func convert_api_ISCSIVolumeSource_To_v1_ISCSIVolumeSource(in *api.ISCSIVolumeSo
 	return nil
 }
 
+func convert_api_KeyToPath_To_v1_KeyToPath(in *api.KeyToPath, out *KeyToPath, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*api.KeyToPath))(in)
+	}
+	out.Key = in.Key
+	out.KeyPattern = in.KeyPattern
+	out.Path = in.Path
+	if in.Mode != nil {
+		out.Mode = new(int)
+		*out.Mode = *in.Mode
+	} else {
+		out.Mode = nil
+	}
+	if in.UID != nil {
+		out.UID = new(int64)
+		*out.UID = *in.UID
+	} else {
+		out.UID = nil
+	}
+	if in.GID != nil {
+		out.GID = new(int64)
+		*out.GID = *in.GID
+	} else {
+		out.GID = nil
+	}
+	out.Encoding = KeyEncoding(in.Encoding)
+	out.NormalizeLineEndings = in.NormalizeLineEndings
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	if in.ExtendedAttributes != nil {
+		out.ExtendedAttributes = make(map[string]string)
+		for key, val := range in.ExtendedAttributes {
+			out.ExtendedAttributes[key] = val
+		}
+	} else {
+		out.ExtendedAttributes = nil
+	}
+	return nil
+}
+
 func convert_api_Lifecycle_To_v1_Lifecycle(in *api.Lifecycle, out *Lifecycle, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*api.Lifecycle))(in)
@@ -1607,6 +1667,23 @@ func convert_api_Probe_To_v1_Probe(in *api.Probe, out *Probe, s conversion.Scope
 	return nil
 }
 
+func convert_api_ProjectedVolumeSource_To_v1_ProjectedVolumeSource(in *api.ProjectedVolumeSource, out *ProjectedVolumeSource, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*api.ProjectedVolumeSource))(in)
+	}
+	if in.Sources != nil {
+		out.Sources = make([]VolumeProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := convert_api_VolumeProjection_To_v1_VolumeProjection(&in.Sources[i], &out.Sources[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
+	return nil
+}
+
 func convert_api_RBDVolumeSource_To_v1_RBDVolumeSource(in *api.RBDVolumeSource, out *RBDVolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*api.RBDVolumeSource))(in)
@@ -1861,6 +1938,12 @@ func convert_api_Secret_To_v1_Secret(in *api.Secret, out *Secret, s conversion.S
 		out.Data = nil
 	}
 	out.Type = SecretType(in.Type)
+	if in.Immutable != nil {
+		out.Immutable = new(bool)
+		*out.Immutable = *in.Immutable
+	} else {
+		out.Immutable = nil
+	}
 	return nil
 }
 
@@ -1887,11 +1970,105 @@ func convert_api_SecretList_To_v1_SecretList(in *api.SecretList, out *SecretList
 	return nil
 }
 
+func convert_api_SecretProjection_To_v1_SecretProjection(in *api.SecretProjection, out *SecretProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*api.SecretProjection))(in)
+	}
+	out.SecretName = in.SecretName
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := convert_api_KeyToPath_To_v1_KeyToPath(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	return nil
+}
+
 func convert_api_SecretVolumeSource_To_v1_SecretVolumeSource(in *api.SecretVolumeSource, out *SecretVolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*api.SecretVolumeSource))(in)
 	}
 	out.SecretName = in.SecretName
+	if in.SecretSelector != nil {
+		out.SecretSelector = make(map[string]string)
+		for key, val := range in.SecretSelector {
+			out.SecretSelector[key] = val
+		}
+	} else {
+		out.SecretSelector = nil
+	}
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := convert_api_KeyToPath_To_v1_KeyToPath(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.DefaultMode != nil {
+		out.DefaultMode = new(int)
+		*out.DefaultMode = *in.DefaultMode
+	} else {
+		out.DefaultMode = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	out.WipeOnTeardown = in.WipeOnTeardown
+	out.SanitizeKeyNames = in.SanitizeKeyNames
+	out.SingleKeyTargetPath = in.SingleKeyTargetPath
+	out.BundlePath = in.BundlePath
+	if in.BundleSeparator != nil {
+		out.BundleSeparator = new(string)
+		*out.BundleSeparator = *in.BundleSeparator
+	} else {
+		out.BundleSeparator = nil
+	}
+	out.ArchivePath = in.ArchivePath
+	if in.EnvFile != nil {
+		out.EnvFile = new(EnvFileProjection)
+		if err := convert_api_EnvFileProjection_To_v1_EnvFileProjection(in.EnvFile, out.EnvFile, s); err != nil {
+			return err
+		}
+	} else {
+		out.EnvFile = nil
+	}
+	out.WriteContentHash = in.WriteContentHash
+	out.WriteManifest = in.WriteManifest
+	out.PlainDirectory = in.PlainDirectory
+	out.HostDirectory = in.HostDirectory
+	out.RequireNonEmpty = in.RequireNonEmpty
+	out.StrictKeys = in.StrictKeys
+	out.BestEffort = in.BestEffort
+	out.TypeCheck = SecretTypeCheck(in.TypeCheck)
+	out.ProjectionStrategy = SecretProjectionStrategy(in.ProjectionStrategy)
+	out.VerifySignature = in.VerifySignature
+	out.StrictExtendedAttributes = in.StrictExtendedAttributes
+	if in.Sources != nil {
+		out.Sources = make([]SecretProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := convert_api_SecretProjection_To_v1_SecretProjection(&in.Sources[i], &out.Sources[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
 	return nil
 }
 
@@ -2196,6 +2373,21 @@ func convert_api_VolumeMount_To_v1_VolumeMount(in *api.VolumeMount, out *VolumeM
 	return nil
 }
 
+func convert_api_VolumeProjection_To_v1_VolumeProjection(in *api.VolumeProjection, out *VolumeProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*api.VolumeProjection))(in)
+	}
+	if in.Secret != nil {
+		out.Secret = new(SecretVolumeSource)
+		if err := convert_api_SecretVolumeSource_To_v1_SecretVolumeSource(in.Secret, out.Secret, s); err != nil {
+			return err
+		}
+	} else {
+		out.Secret = nil
+	}
+	return nil
+}
+
 func convert_api_VolumeSource_To_v1_VolumeSource(in *api.VolumeSource, out *VolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*api.VolumeSource))(in)
@@ -2288,6 +2480,14 @@ func convert_api_VolumeSource_To_v1_VolumeSource(in *api.VolumeSource, out *Volu
 	} else {
 		out.RBD = nil
 	}
+	if in.Projected != nil {
+		out.Projected = new(ProjectedVolumeSource)
+		if err := convert_api_ProjectedVolumeSource_To_v1_ProjectedVolumeSource(in.Projected, out.Projected, s); err != nil {
+			return err
+		}
+	} else {
+		out.Projected = nil
+	}
 	return nil
 }
 
@@ -2714,6 +2914,22 @@ func convert_v1_EndpointsList_To_api_EndpointsList(in *EndpointsList, out *api.E
 	return nil
 }
 
+func convert_v1_EnvFileProjection_To_api_EnvFileProjection(in *EnvFileProjection, out *api.EnvFileProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*EnvFileProjection))(in)
+	}
+	out.Path = in.Path
+	if in.Keys != nil {
+		out.Keys = make([]string, len(in.Keys))
+		for i := range in.Keys {
+			out.Keys[i] = in.Keys[i]
+		}
+	} else {
+		out.Keys = nil
+	}
+	return nil
+}
+
 func convert_v1_EnvVar_To_api_EnvVar(in *EnvVar, out *api.EnvVar, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*EnvVar))(in)
@@ -2915,6 +3131,50 @@ func convert_v1_ISCSIVolumeSource_To_api_ISCSIVolumeSource(in *ISCSIVolumeSource
 	return nil
 }
 
+func convert_v1_KeyToPath_To_api_KeyToPath(in *KeyToPath, out *api.KeyToPath, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*KeyToPath))(in)
+	}
+	out.Key = in.Key
+	out.KeyPattern = in.KeyPattern
+	out.Path = in.Path
+	if in.Mode != nil {
+		out.Mode = new(int)
+		*out.Mode = *in.Mode
+	} else {
+		out.Mode = nil
+	}
+	if in.UID != nil {
+		out.UID = new(int64)
+		*out.UID = *in.UID
+	} else {
+		out.UID = nil
+	}
+	if in.GID != nil {
+		out.GID = new(int64)
+		*out.GID = *in.GID
+	} else {
+		out.GID = nil
+	}
+	out.Encoding = api.KeyEncoding(in.Encoding)
+	out.NormalizeLineEndings = in.NormalizeLineEndings
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	if in.ExtendedAttributes != nil {
+		out.ExtendedAttributes = make(map[string]string)
+		for key, val := range in.ExtendedAttributes {
+			out.ExtendedAttributes[key] = val
+		}
+	} else {
+		out.ExtendedAttributes = nil
+	}
+	return nil
+}
+
 func convert_v1_Lifecycle_To_api_Lifecycle(in *Lifecycle, out *api.Lifecycle, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*Lifecycle))(in)
@@ -3874,6 +4134,23 @@ func convert_v1_Probe_To_api_Probe(in *Probe, out *api.Probe, s conversion.Scope
 	return nil
 }
 
+func convert_v1_ProjectedVolumeSource_To_api_ProjectedVolumeSource(in *ProjectedVolumeSource, out *api.ProjectedVolumeSource, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*ProjectedVolumeSource))(in)
+	}
+	if in.Sources != nil {
+		out.Sources = make([]api.VolumeProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := convert_v1_VolumeProjection_To_api_VolumeProjection(&in.Sources[i], &out.Sources[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
+	return nil
+}
+
 func convert_v1_RBDVolumeSource_To_api_RBDVolumeSource(in *RBDVolumeSource, out *api.RBDVolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*RBDVolumeSource))(in)
@@ -4128,6 +4405,12 @@ func convert_v1_Secret_To_api_Secret(in *Secret, out *api.Secret, s conversion.S
 		out.Data = nil
 	}
 	out.Type = api.SecretType(in.Type)
+	if in.Immutable != nil {
+		out.Immutable = new(bool)
+		*out.Immutable = *in.Immutable
+	} else {
+		out.Immutable = nil
+	}
 	return nil
 }
 
@@ -4154,11 +4437,105 @@ func convert_v1_SecretList_To_api_SecretList(in *SecretList, out *api.SecretList
 	return nil
 }
 
+func convert_v1_SecretProjection_To_api_SecretProjection(in *SecretProjection, out *api.SecretProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*SecretProjection))(in)
+	}
+	out.SecretName = in.SecretName
+	if in.Items != nil {
+		out.Items = make([]api.KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := convert_v1_KeyToPath_To_api_KeyToPath(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	return nil
+}
+
 func convert_v1_SecretVolumeSource_To_api_SecretVolumeSource(in *SecretVolumeSource, out *api.SecretVolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*SecretVolumeSource))(in)
 	}
 	out.SecretName = in.SecretName
+	if in.SecretSelector != nil {
+		out.SecretSelector = make(map[string]string)
+		for key, val := range in.SecretSelector {
+			out.SecretSelector[key] = val
+		}
+	} else {
+		out.SecretSelector = nil
+	}
+	if in.Items != nil {
+		out.Items = make([]api.KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := convert_v1_KeyToPath_To_api_KeyToPath(&in.Items[i], &out.Items[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.DefaultMode != nil {
+		out.DefaultMode = new(int)
+		*out.DefaultMode = *in.DefaultMode
+	} else {
+		out.DefaultMode = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	out.WipeOnTeardown = in.WipeOnTeardown
+	out.SanitizeKeyNames = in.SanitizeKeyNames
+	out.SingleKeyTargetPath = in.SingleKeyTargetPath
+	out.BundlePath = in.BundlePath
+	if in.BundleSeparator != nil {
+		out.BundleSeparator = new(string)
+		*out.BundleSeparator = *in.BundleSeparator
+	} else {
+		out.BundleSeparator = nil
+	}
+	out.ArchivePath = in.ArchivePath
+	if in.EnvFile != nil {
+		out.EnvFile = new(api.EnvFileProjection)
+		if err := convert_v1_EnvFileProjection_To_api_EnvFileProjection(in.EnvFile, out.EnvFile, s); err != nil {
+			return err
+		}
+	} else {
+		out.EnvFile = nil
+	}
+	out.WriteContentHash = in.WriteContentHash
+	out.WriteManifest = in.WriteManifest
+	out.PlainDirectory = in.PlainDirectory
+	out.HostDirectory = in.HostDirectory
+	out.RequireNonEmpty = in.RequireNonEmpty
+	out.StrictKeys = in.StrictKeys
+	out.BestEffort = in.BestEffort
+	out.TypeCheck = api.SecretTypeCheck(in.TypeCheck)
+	out.ProjectionStrategy = api.SecretProjectionStrategy(in.ProjectionStrategy)
+	out.VerifySignature = in.VerifySignature
+	out.StrictExtendedAttributes = in.StrictExtendedAttributes
+	if in.Sources != nil {
+		out.Sources = make([]api.SecretProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := convert_v1_SecretProjection_To_api_SecretProjection(&in.Sources[i], &out.Sources[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
 	return nil
 }
 
@@ -4463,6 +4840,21 @@ func convert_v1_VolumeMount_To_api_VolumeMount(in *VolumeMount, out *api.VolumeM
 	return nil
 }
 
+func convert_v1_VolumeProjection_To_api_VolumeProjection(in *VolumeProjection, out *api.VolumeProjection, s conversion.Scope) error {
+	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
+		defaulting.(func(*VolumeProjection))(in)
+	}
+	if in.Secret != nil {
+		out.Secret = new(api.SecretVolumeSource)
+		if err := convert_v1_SecretVolumeSource_To_api_SecretVolumeSource(in.Secret, out.Secret, s); err != nil {
+			return err
+		}
+	} else {
+		out.Secret = nil
+	}
+	return nil
+}
+
 func convert_v1_VolumeSource_To_api_VolumeSource(in *VolumeSource, out *api.VolumeSource, s conversion.Scope) error {
 	if defaulting, found := s.DefaultingInterface(reflect.TypeOf(*in)); found {
 		defaulting.(func(*VolumeSource))(in)
@@ -4555,6 +4947,14 @@ func convert_v1_VolumeSource_To_api_VolumeSource(in *VolumeSource, out *api.Volu
 	} else {
 		out.RBD = nil
 	}
+	if in.Projected != nil {
+		out.Projected = new(api.ProjectedVolumeSource)
+		if err := convert_v1_ProjectedVolumeSource_To_api_ProjectedVolumeSource(in.Projected, out.Projected, s); err != nil {
+			return err
+		}
+	} else {
+		out.Projected = nil
+	}
 	return nil
 }
 
@@ -4580,6 +4980,7 @@ func init() {
 		convert_api_EndpointSubset_To_v1_EndpointSubset,
 		convert_api_EndpointsList_To_v1_EndpointsList,
 		convert_api_Endpoints_To_v1_Endpoints,
+		convert_api_EnvFileProjection_To_v1_EnvFileProjection,
 		convert_api_EnvVarSource_To_v1_EnvVarSource,
 		convert_api_EnvVar_To_v1_EnvVar,
 		convert_api_EventList_To_v1_EventList,
@@ -4593,6 +4994,7 @@ func init() {
 		convert_api_Handler_To_v1_Handler,
 		convert_api_HostPathVolumeSource_To_v1_HostPathVolumeSource,
 		convert_api_ISCSIVolumeSource_To_v1_ISCSIVolumeSource,
+		convert_api_KeyToPath_To_v1_KeyToPath,
 		convert_api_Lifecycle_To_v1_Lifecycle,
 		convert_api_LimitRangeItem_To_v1_LimitRangeItem,
 		convert_api_LimitRangeList_To_v1_LimitRangeList,
@@ -4642,6 +5044,7 @@ func init() {
 		convert_api_PodTemplate_To_v1_PodTemplate,
 		convert_api_Pod_To_v1_Pod,
 		convert_api_Probe_To_v1_Probe,
+		convert_api_ProjectedVolumeSource_To_v1_ProjectedVolumeSource,
 		convert_api_RBDVolumeSource_To_v1_RBDVolumeSource,
 		convert_api_RangeAllocation_To_v1_RangeAllocation,
 		convert_api_ReplicationControllerList_To_v1_ReplicationControllerList,
@@ -4654,6 +5057,7 @@ func init() {
 		convert_api_ResourceRequirements_To_v1_ResourceRequirements,
 		convert_api_SELinuxOptions_To_v1_SELinuxOptions,
 		convert_api_SecretList_To_v1_SecretList,
+		convert_api_SecretProjection_To_v1_SecretProjection,
 		convert_api_SecretVolumeSource_To_v1_SecretVolumeSource,
 		convert_api_Secret_To_v1_Secret,
 		convert_api_SecurityContext_To_v1_SecurityContext,
@@ -4671,6 +5075,7 @@ func init() {
 		convert_api_TCPSocketAction_To_v1_TCPSocketAction,
 		convert_api_TypeMeta_To_v1_TypeMeta,
 		convert_api_VolumeMount_To_v1_VolumeMount,
+		convert_api_VolumeProjection_To_v1_VolumeProjection,
 		convert_api_VolumeSource_To_v1_VolumeSource,
 		convert_api_Volume_To_v1_Volume,
 		convert_v1_AWSElasticBlockStoreVolumeSource_To_api_AWSElasticBlockStoreVolumeSource,
@@ -4693,6 +5098,7 @@ func init() {
 		convert_v1_EndpointSubset_To_api_EndpointSubset,
 		convert_v1_EndpointsList_To_api_EndpointsList,
 		convert_v1_Endpoints_To_api_Endpoints,
+		convert_v1_EnvFileProjection_To_api_EnvFileProjection,
 		convert_v1_EnvVarSource_To_api_EnvVarSource,
 		convert_v1_EnvVar_To_api_EnvVar,
 		convert_v1_EventList_To_api_EventList,
@@ -4706,6 +5112,7 @@ func init() {
 		convert_v1_Handler_To_api_Handler,
 		convert_v1_HostPathVolumeSource_To_api_HostPathVolumeSource,
 		convert_v1_ISCSIVolumeSource_To_api_ISCSIVolumeSource,
+		convert_v1_KeyToPath_To_api_KeyToPath,
 		convert_v1_Lifecycle_To_api_Lifecycle,
 		convert_v1_LimitRangeItem_To_api_LimitRangeItem,
 		convert_v1_LimitRangeList_To_api_LimitRangeList,
@@ -4755,6 +5162,7 @@ func init() {
 		convert_v1_PodTemplate_To_api_PodTemplate,
 		convert_v1_Pod_To_api_Pod,
 		convert_v1_Probe_To_api_Probe,
+		convert_v1_ProjectedVolumeSource_To_api_ProjectedVolumeSource,
 		convert_v1_RBDVolumeSource_To_api_RBDVolumeSource,
 		convert_v1_RangeAllocation_To_api_RangeAllocation,
 		convert_v1_ReplicationControllerList_To_api_ReplicationControllerList,
@@ -4767,6 +5175,7 @@ func init() {
 		convert_v1_ResourceRequirements_To_api_ResourceRequirements,
 		convert_v1_SELinuxOptions_To_api_SELinuxOptions,
 		convert_v1_SecretList_To_api_SecretList,
+		convert_v1_SecretProjection_To_api_SecretProjection,
 		convert_v1_SecretVolumeSource_To_api_SecretVolumeSource,
 		convert_v1_Secret_To_api_Secret,
 		convert_v1_SecurityContext_To_api_SecurityContext,
@@ -4784,6 +5193,7 @@ func init() {
 		convert_v1_TCPSocketAction_To_api_TCPSocketAction,
 		convert_v1_TypeMeta_To_api_TypeMeta,
 		convert_v1_VolumeMount_To_api_VolumeMount,
+		convert_v1_VolumeProjection_To_api_VolumeProjection,
 		convert_v1_VolumeSource_To_api_VolumeSource,
 		convert_v1_Volume_To_api_Volume,
 	)
@@ -404,6 +404,19 @@ func deepCopy_v1_EndpointsList(in EndpointsList, out *EndpointsList, c *conversi
 	return nil
 }
 
+func deepCopy_v1_EnvFileProjection(in EnvFileProjection, out *EnvFileProjection, c *conversion.Cloner) error {
+	out.Path = in.Path
+	if in.Keys != nil {
+		out.Keys = make([]string, len(in.Keys))
+		for i := range in.Keys {
+			out.Keys[i] = in.Keys[i]
+		}
+	} else {
+		out.Keys = nil
+	}
+	return nil
+}
+
 func deepCopy_v1_EnvVar(in EnvVar, out *EnvVar, c *conversion.Cloner) error {
 	out.Name = in.Name
 	out.Value = in.Value
@@ -566,6 +579,47 @@ func deepCopy_v1_ISCSIVolumeSource(in ISCSIVolumeSource, out *ISCSIVolumeSource,
 	return nil
 }
 
+func deepCopy_v1_KeyToPath(in KeyToPath, out *KeyToPath, c *conversion.Cloner) error {
+	out.Key = in.Key
+	out.KeyPattern = in.KeyPattern
+	out.Path = in.Path
+	if in.Mode != nil {
+		out.Mode = new(int)
+		*out.Mode = *in.Mode
+	} else {
+		out.Mode = nil
+	}
+	if in.UID != nil {
+		out.UID = new(int64)
+		*out.UID = *in.UID
+	} else {
+		out.UID = nil
+	}
+	if in.GID != nil {
+		out.GID = new(int64)
+		*out.GID = *in.GID
+	} else {
+		out.GID = nil
+	}
+	out.Encoding = in.Encoding
+	out.NormalizeLineEndings = in.NormalizeLineEndings
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	if in.ExtendedAttributes != nil {
+		out.ExtendedAttributes = make(map[string]string)
+		for key, val := range in.ExtendedAttributes {
+			out.ExtendedAttributes[key] = val
+		}
+	} else {
+		out.ExtendedAttributes = nil
+	}
+	return nil
+}
+
 func deepCopy_v1_Lifecycle(in Lifecycle, out *Lifecycle, c *conversion.Cloner) error {
 	if in.PostStart != nil {
 		out.PostStart = new(Handler)
@@ -1443,6 +1497,20 @@ func deepCopy_v1_Probe(in Probe, out *Probe, c *conversion.Cloner) error {
 	return nil
 }
 
+func deepCopy_v1_ProjectedVolumeSource(in ProjectedVolumeSource, out *ProjectedVolumeSource, c *conversion.Cloner) error {
+	if in.Sources != nil {
+		out.Sources = make([]VolumeProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := deepCopy_v1_VolumeProjection(in.Sources[i], &out.Sources[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
+	return nil
+}
+
 func deepCopy_v1_RBDVolumeSource(in RBDVolumeSource, out *RBDVolumeSource, c *conversion.Cloner) error {
 	if in.CephMonitors != nil {
 		out.CephMonitors = make([]string, len(in.CephMonitors))
@@ -1692,6 +1760,12 @@ func deepCopy_v1_Secret(in Secret, out *Secret, c *conversion.Cloner) error {
 		out.Data = nil
 	}
 	out.Type = in.Type
+	if in.Immutable != nil {
+		out.Immutable = new(bool)
+		*out.Immutable = *in.Immutable
+	} else {
+		out.Immutable = nil
+	}
 	return nil
 }
 
@@ -1715,8 +1789,99 @@ func deepCopy_v1_SecretList(in SecretList, out *SecretList, c *conversion.Cloner
 	return nil
 }
 
+func deepCopy_v1_SecretProjection(in SecretProjection, out *SecretProjection, c *conversion.Cloner) error {
+	out.SecretName = in.SecretName
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_v1_KeyToPath(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	return nil
+}
+
 func deepCopy_v1_SecretVolumeSource(in SecretVolumeSource, out *SecretVolumeSource, c *conversion.Cloner) error {
 	out.SecretName = in.SecretName
+	if in.SecretSelector != nil {
+		out.SecretSelector = make(map[string]string)
+		for key, val := range in.SecretSelector {
+			out.SecretSelector[key] = val
+		}
+	} else {
+		out.SecretSelector = nil
+	}
+	if in.Items != nil {
+		out.Items = make([]KeyToPath, len(in.Items))
+		for i := range in.Items {
+			if err := deepCopy_v1_KeyToPath(in.Items[i], &out.Items[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	if in.DefaultMode != nil {
+		out.DefaultMode = new(int)
+		*out.DefaultMode = *in.DefaultMode
+	} else {
+		out.DefaultMode = nil
+	}
+	if in.Optional != nil {
+		out.Optional = new(bool)
+		*out.Optional = *in.Optional
+	} else {
+		out.Optional = nil
+	}
+	out.WipeOnTeardown = in.WipeOnTeardown
+	out.SanitizeKeyNames = in.SanitizeKeyNames
+	out.SingleKeyTargetPath = in.SingleKeyTargetPath
+	out.BundlePath = in.BundlePath
+	if in.BundleSeparator != nil {
+		out.BundleSeparator = new(string)
+		*out.BundleSeparator = *in.BundleSeparator
+	} else {
+		out.BundleSeparator = nil
+	}
+	out.ArchivePath = in.ArchivePath
+	if in.EnvFile != nil {
+		out.EnvFile = new(EnvFileProjection)
+		if err := deepCopy_v1_EnvFileProjection(*in.EnvFile, out.EnvFile, c); err != nil {
+			return err
+		}
+	} else {
+		out.EnvFile = nil
+	}
+	out.WriteContentHash = in.WriteContentHash
+	out.WriteManifest = in.WriteManifest
+	out.PlainDirectory = in.PlainDirectory
+	out.HostDirectory = in.HostDirectory
+	out.RequireNonEmpty = in.RequireNonEmpty
+	out.StrictKeys = in.StrictKeys
+	out.BestEffort = in.BestEffort
+	out.TypeCheck = in.TypeCheck
+	out.ProjectionStrategy = in.ProjectionStrategy
+	out.VerifySignature = in.VerifySignature
+	out.StrictExtendedAttributes = in.StrictExtendedAttributes
+	if in.Sources != nil {
+		out.Sources = make([]SecretProjection, len(in.Sources))
+		for i := range in.Sources {
+			if err := deepCopy_v1_SecretProjection(in.Sources[i], &out.Sources[i], c); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Sources = nil
+	}
 	return nil
 }
 
@@ -1973,6 +2138,18 @@ func deepCopy_v1_VolumeMount(in VolumeMount, out *VolumeMount, c *conversion.Clo
 	return nil
 }
 
+func deepCopy_v1_VolumeProjection(in VolumeProjection, out *VolumeProjection, c *conversion.Cloner) error {
+	if in.Secret != nil {
+		out.Secret = new(SecretVolumeSource)
+		if err := deepCopy_v1_SecretVolumeSource(*in.Secret, out.Secret, c); err != nil {
+			return err
+		}
+	} else {
+		out.Secret = nil
+	}
+	return nil
+}
+
 func deepCopy_v1_VolumeSource(in VolumeSource, out *VolumeSource, c *conversion.Cloner) error {
 	if in.HostPath != nil {
 		out.HostPath = new(HostPathVolumeSource)
@@ -2062,6 +2239,14 @@ func deepCopy_v1_VolumeSource(in VolumeSource, out *VolumeSource, c *conversion.
 	} else {
 		out.RBD = nil
 	}
+	if in.Projected != nil {
+		out.Projected = new(ProjectedVolumeSource)
+		if err := deepCopy_v1_ProjectedVolumeSource(*in.Projected, out.Projected, c); err != nil {
+			return err
+		}
+	} else {
+		out.Projected = nil
+	}
 	return nil
 }
 
@@ -2116,6 +2301,7 @@ func init() {
 		deepCopy_v1_EndpointSubset,
 		deepCopy_v1_Endpoints,
 		deepCopy_v1_EndpointsList,
+		deepCopy_v1_EnvFileProjection,
 		deepCopy_v1_EnvVar,
 		deepCopy_v1_EnvVarSource,
 		deepCopy_v1_Event,
@@ -2129,6 +2315,7 @@ func init() {
 		deepCopy_v1_Handler,
 		deepCopy_v1_HostPathVolumeSource,
 		deepCopy_v1_ISCSIVolumeSource,
+		deepCopy_v1_KeyToPath,
 		deepCopy_v1_Lifecycle,
 		deepCopy_v1_LimitRange,
 		deepCopy_v1_LimitRangeItem,
@@ -2179,6 +2366,7 @@ func init() {
 		deepCopy_v1_PodTemplateList,
 		deepCopy_v1_PodTemplateSpec,
 		deepCopy_v1_Probe,
+		deepCopy_v1_ProjectedVolumeSource,
 		deepCopy_v1_RBDVolumeSource,
 		deepCopy_v1_RangeAllocation,
 		deepCopy_v1_ReplicationController,
@@ -2193,6 +2381,7 @@ func init() {
 		deepCopy_v1_SELinuxOptions,
 		deepCopy_v1_Secret,
 		deepCopy_v1_SecretList,
+		deepCopy_v1_SecretProjection,
 		deepCopy_v1_SecretVolumeSource,
 		deepCopy_v1_SecurityContext,
 		deepCopy_v1_SerializedReference,
@@ -2210,6 +2399,7 @@ func init() {
 		deepCopy_v1_TypeMeta,
 		deepCopy_v1_Volume,
 		deepCopy_v1_VolumeMount,
+		deepCopy_v1_VolumeProjection,
 		deepCopy_v1_VolumeSource,
 		deepCopy_runtime_RawExtension,
 		deepCopy_util_IntOrString,
@@ -202,6 +202,8 @@ type VolumeSource struct {
 	PersistentVolumeClaim *PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty" description:"a reference to a PersistentVolumeClaim in the same namespace; see http://releases.k8s.io/HEAD/docs/user-guide/persistent-volumes.md#persistentvolumeclaims"`
 	// RBD represents a Rados Block Device mount on the host that shares a pod's lifetime
 	RBD *RBDVolumeSource `json:"rbd,omitempty" description:"rados block volume that will be mounted on the host machine; see http://releases.k8s.io/HEAD/examples/rbd/README.md"`
+	// Projected represents several sources projected into one volume.
+	Projected *ProjectedVolumeSource `json:"projected,omitempty" description:"several sources projected into one volume; only secrets can be projected so far"`
 }
 
 type PersistentVolumeClaimVolumeSource struct {
@@ -493,7 +495,136 @@ type GitRepoVolumeSource struct {
 type SecretVolumeSource struct {
 	// Name of the secret in the pod's namespace to use
 	SecretName string `json:"secretName" description:"secretName is the name of a secret in the pod's namespace; see http://releases.k8s.io/HEAD/docs/user-guide/volumes.md#secrets"`
-}
+	// Optional: Labels selecting the secret to use instead of SecretName
+	SecretSelector map[string]string `json:"secretSelector,omitempty" description:"label keys and values selecting the secret in the pod's namespace to use instead of secretName; resolved again on every refresh of the volume, which is not set up while no secret or more than one matches; may not be set together with secretName"`
+	// Optional: Keys to project and the paths to project them into
+	Items []KeyToPath `json:"items,omitempty" description:"if specified, the listed keys will be projected into the specified paths and unlisted keys will not be present; if a listed key is not present in the secret, the volume setup will error"`
+	// Optional: Mode bits to use on created files by default
+	DefaultMode *int `json:"defaultMode,omitempty" description:"mode bits to use on created files by default; must be a value between 0 and 0777; defaults to 0444"`
+	// Optional: Whether the secret must exist
+	Optional *bool `json:"optional,omitempty" description:"if true, the volume is set up empty when the secret does not exist and populated once it is created; defaults to false"`
+	// Optional: Whether to zero the files before teardown
+	WipeOnTeardown bool `json:"wipeOnTeardown,omitempty" description:"if true, the contents of the files are overwritten with zeros before the volume is torn down; defaults to false"`
+	// Optional: Whether to sanitize file names derived from keys
+	SanitizeKeyNames bool `json:"sanitizeKeyNames,omitempty" description:"if true, files named after a key have awkward characters replaced and are shortened to a safe length, with a suffix derived from the key to keep names distinct; explicit item paths are unchanged; defaults to false"`
+	// Optional: Path to project the only key of the secret to
+	SingleKeyTargetPath string `json:"singleKeyTargetPath,omitempty" description:"the relative path to project the only key of the secret to, whatever its name; setup fails if the secret has more than one key; may not be set together with items"`
+	// Optional: Path of a single file holding the values of all keys
+	BundlePath string `json:"bundlePath,omitempty" description:"the relative path of a single file holding the values of all keys of the secret, concatenated in the order of their keys, instead of a file per key; may not be set together with items or singleKeyTargetPath"`
+	// Optional: Separator of the values in the bundle file
+	BundleSeparator *string `json:"bundleSeparator,omitempty" description:"the string written between the values in the bundlePath file; defaults to a newline"`
+	// Optional: Relative path of a gzip-compressed tar archive of the keys
+	ArchivePath string `json:"archivePath,omitempty" description:"the relative path of a single gzip-compressed tar archive holding a file per key of the secret, named after the key, instead of a file per key; containers must unpack it themselves; may not be set together with items, singleKeyTargetPath or bundlePath"`
+	// Optional: A dotenv file holding keys of the secret
+	EnvFile *EnvFileProjection `json:"envFile,omitempty" description:"a single file holding keys of the secret as KEY=value lines, sorted by key, written alongside the files the keys are projected to"`
+	// Optional: Whether to write a hash of the secret's contents
+	WriteContentHash bool `json:"writeContentHash,omitempty" description:"if true, a file named .secret-hash holding a hash of the contents of the secret is written along with its keys, and changes whenever the secret does; defaults to false"`
+	// Optional: Whether to write a manifest of the files of the volume
+	WriteManifest bool `json:"writeManifest,omitempty" description:"if true, a file named .manifest.json listing each of the other files of the volume, with the secret and key it holds, its size in bytes and its mode, is written along with them; defaults to false"`
+	// Optional: Whether to skip the tmpfs mount of the volume
+	PlainDirectory bool `json:"plainDirectory,omitempty" description:"if true, the files are written to a plain directory in the pod's directory instead of a tmpfs mount of their own, which leaves the secret on the node's disk; defaults to false"`
+	// Optional: Experimental: The name of a host directory configured on the node to write the files into
+	HostDirectory string `json:"hostDirectory,omitempty" description:"experimental: name of a directory on the node, configured by the node operator, to write the files into, in a directory of their own named after the pod UID and the volume, instead of the volume's own directory; the kubelet must allow host directories; other files in the directory, and the directory itself, are left alone on teardown; may not be set together with plainDirectory"`
+	// Optional: Whether the secret must hold data
+	RequireNonEmpty bool `json:"requireNonEmpty,omitempty" description:"if true, setup fails while the secret holds no data, unless the volume is optional; defaults to false, in which case an empty secret yields an empty volume"`
+	// Optional: Whether keys not listed in items are an error
+	StrictKeys bool `json:"strictKeys,omitempty" description:"if true, setup fails while a secret that lists items, including sources, holds a key not selected by any of its items; defaults to false, in which case other keys are ignored"`
+	// Optional: Whether to skip items whose keys are missing
+	BestEffort bool `json:"bestEffort,omitempty" description:"if true, an item whose key is missing from its secret, or whose keyPattern matches no key, is skipped with a warning as if it were optional, and the other items are still projected; defaults to false, in which case setup fails unless every item that is not optional is projected"`
+	// Optional: Whether to check the keys of secrets against their type
+	TypeCheck SecretTypeCheck `json:"typeCheck,omitempty" description:"whether to check that each secret of the volume holds, and the volume projects, the keys its type calls for, such as .dockercfg for a secret of type kubernetes.io/dockercfg; secrets of other types are not checked; must be None (default), Warn to log and record an event, or Error to fail the setup"`
+	// Optional: How the files are projected into the volume
+	ProjectionStrategy SecretProjectionStrategy `json:"projectionStrategy,omitempty" description:"how the files are projected into the volume; must be AtomicSymlink (default), in which case each file is a symlink into a data directory that is replaced as a whole so that readers see an update all at once, or Copy to write plain files, each replaced on its own, for runtimes that do not allow symlinks"`
+	// Optional: Whether to verify the signature of each secret of the volume
+	VerifySignature bool `json:"verifySignature,omitempty" description:"if true, each secret of the volume must hold, as its .sig key, an RSA PKCS #1 v1.5 signature by the key the kubelet is configured with of the SHA-256 hash of its other keys and values, or setup fails; the signature is not projected into the volume; defaults to false"`
+	// Optional: Whether unsupported extended attributes fail the setup
+	StrictExtendedAttributes bool `json:"strictExtendedAttributes,omitempty" description:"if true, setup fails when the extendedAttributes of an item cannot be set because the filesystem or node does not support them; defaults to false, in which case a warning is logged"`
+	// Optional: More secrets to project into the volume
+	Sources []SecretProjection `json:"sources,omitempty" description:"more secrets to project into the same volume alongside secretName; setup fails if two secrets would be projected to the same path"`
+}
+
+// SecretTypeCheck defines what a secret volume does with a secret that
+// lacks a key its type calls for.
+type SecretTypeCheck string
+
+const (
+	SecretTypeCheckNone  SecretTypeCheck = "None"  // do not check the keys
+	SecretTypeCheckWarn  SecretTypeCheck = "Warn"  // set up the volume, but log and record an event
+	SecretTypeCheckError SecretTypeCheck = "Error" // do not set up the volume
+)
+
+// SecretProjectionStrategy defines how a secret volume projects its files.
+type SecretProjectionStrategy string
+
+const (
+	SecretProjectionAtomicSymlink SecretProjectionStrategy = "AtomicSymlink" // symlinks into a data directory that is swapped atomically
+	SecretProjectionCopy          SecretProjectionStrategy = "Copy"          // plain files, each replaced on its own
+)
+
+// SecretProjection describes a secret whose keys are projected into a
+// secret volume along with those of the volume's own secret.
+type SecretProjection struct {
+	// Name of the secret in the pod's namespace to use
+	SecretName string `json:"secretName" description:"secretName is the name of a secret in the pod's namespace"`
+	// Optional: The keys to project and their paths
+	Items []KeyToPath `json:"items,omitempty" description:"the keys to project and the paths to project them to, as for the items of the volume; if unspecified, every key is projected to a file named after it"`
+	// Optional: Whether the secret may be missing
+	Optional *bool `json:"optional,omitempty" description:"if true, the secret is skipped when it does not exist; defaults to false"`
+}
+
+// EnvFileProjection describes a file of a secret volume that holds keys
+// of the volume's secret as KEY=value lines.
+type EnvFileProjection struct {
+	// The relative path of the file
+	Path string `json:"path" description:"the relative path of the file; may not be an absolute path, contain the path element '..' or start with '..'"`
+	// Optional: The keys to write to the file
+	Keys []string `json:"keys,omitempty" description:"the keys to write to the file, each of which must be a C identifier; if unspecified, every key of the secret is written and setup fails while a key is not a C identifier; values are double-quoted, with backslash, double quote, dollar sign and backquote escaped by a backslash and each newline written as backslash n; setup fails while a value is not valid UTF-8 or holds a control character other than newline or tab"`
+}
+
+// KeyToPath maps a string key to a path within a volume.
+type KeyToPath struct {
+	// The key to project
+	Key string `json:"key,omitempty" description:"the key to project; exactly one of key and keyPattern must be set"`
+	// A glob pattern selecting the keys to project
+	KeyPattern string `json:"keyPattern,omitempty" description:"a glob pattern selecting the keys to project; each matching key is projected to a file named after the key inside the directory given by path"`
+	// The relative path of the file to map the key to
+	Path string `json:"path" description:"the relative path of the file to map the key to, or of the directory holding the keys matching keyPattern; may not be an absolute path, contain the path element '..' or start with '..'"`
+	// Optional: Mode bits to use on this file
+	Mode *int `json:"mode,omitempty" description:"mode bits to use on this file; must be a value between 0 and 0777; defaults to the volume defaultMode"`
+	// Optional: The user that owns this file
+	UID *int64 `json:"uid,omitempty" description:"the user that owns this file; defaults to the kubelet's user"`
+	// Optional: The group that owns this file
+	GID *int64 `json:"gid,omitempty" description:"the group that owns this file; defaults to the pod's fsGroup, if any"`
+	// Optional: How the value of the key is encoded
+	Encoding KeyEncoding `json:"encoding,omitempty" description:"how the value of the key is encoded; the value is decoded before it is written; must be raw (default) or base64"`
+	// Optional: Whether to convert CRLF line endings to LF
+	NormalizeLineEndings bool `json:"normalizeLineEndings,omitempty" description:"if true, CRLF line endings in the value are converted to LF when it is written, after it is decoded; only for text values; defaults to false"`
+	// Optional: Skip the item if nothing in the secret matches it
+	Optional *bool `json:"optional,omitempty" description:"if true, the item is skipped when its key is not in the secret or its keyPattern matches no key, instead of failing the volume setup; defaults to false"`
+	// Optional: Extended attributes to set on the file
+	ExtendedAttributes map[string]string `json:"extendedAttributes,omitempty" description:"extended attributes to set on the file, by name; names must be in the user. namespace; they are left unset where the filesystem does not support them unless the volume sets strictExtendedAttributes"`
+}
+
+// ProjectedVolumeSource adapts several sources into one VolumeSource.
+type ProjectedVolumeSource struct {
+	// Required: The sources to project into the volume
+	Sources []VolumeProjection `json:"sources" description:"the sources to project into the volume; a volume projecting exactly one secret is set up as a secret volume is"`
+}
+
+// VolumeProjection is a source projected into a projected volume.
+type VolumeProjection struct {
+	// Secret represents a secret whose keys are projected into the volume
+	Secret *SecretVolumeSource `json:"secret,omitempty" description:"secret whose keys are projected into the volume; exactly one source must be set"`
+}
+
+// KeyEncoding defines ways that the value of a key projected into a file
+// can be encoded.
+type KeyEncoding string
+
+const (
+	KeyEncodingRaw    KeyEncoding = "raw"    // write the value unchanged
+	KeyEncodingBase64 KeyEncoding = "base64" // decode the value as standard base64
+)
 
 // NFSVolumeSource represents an NFS mount that lasts the lifetime of a pod
 type NFSVolumeSource struct {
@@ -1886,6 +2017,9 @@ type Secret struct {
 
 	// Used to facilitate programmatic handling of secret data.
 	Type SecretType `json:"type,omitempty" description:"type facilitates programmatic handling of secret data"`
+
+	// Optional: If true, the data of the secret cannot be updated
+	Immutable *bool `json:"immutable,omitempty" description:"if true, the data of the secret cannot be updated and the secret cannot be made mutable again; consumers may stop watching it for changes; defaults to false"`
 }
 
 const MaxSecretSize = 1 * 1024 * 1024
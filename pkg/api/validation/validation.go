@@ -24,6 +24,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
@@ -49,6 +50,8 @@ var dns1123LabelErrorMsg string = fmt.Sprintf(`must be a DNS label (at most %d c
 var dns952LabelErrorMsg string = fmt.Sprintf(`must be a DNS 952 label (at most %d characters, matching regex %s): e.g. "my-name"`, util.DNS952LabelMaxLength, util.DNS952LabelFmt)
 var pdPartitionErrorMsg string = intervalErrorMsg(0, 255)
 var portRangeErrorMsg string = intervalErrorMsg(0, 65536)
+var fileModeErrorMsg string = `must be a number between 0 and 0777 (octal), both inclusive`
+var relativePathErrorMsg string = `must be a relative path and may not contain '..'`
 var portNameErrorMsg string = fmt.Sprintf(`must be an IANA_SVC_NAME (at most 15 characters, matching regex %s, it must contain at least one letter [a-z], and hyphens cannot be adjacent to other hyphens): e.g. "http"`, util.IdentifierNoHyphensBeginEndFmt)
 
 const totalAnnotationSizeLimitB int = 64 * (1 << 10) // 64 kB
@@ -349,6 +352,10 @@ func validateSource(source *api.VolumeSource) errs.ValidationErrorList {
 		numVolumes++
 		allErrs = append(allErrs, validateRBD(source.RBD).Prefix("rbd")...)
 	}
+	if source.Projected != nil {
+		numVolumes++
+		allErrs = append(allErrs, validateProjectedVolumeSource(source.Projected).Prefix("projected")...)
+	}
 	if numVolumes != 1 {
 		allErrs = append(allErrs, errs.NewFieldInvalid("", source, "exactly 1 volume type is required"))
 	}
@@ -419,12 +426,253 @@ func validateAWSElasticBlockStoreVolumeSource(PD *api.AWSElasticBlockStoreVolume
 
 func validateSecretVolumeSource(secretSource *api.SecretVolumeSource) errs.ValidationErrorList {
 	allErrs := errs.ValidationErrorList{}
-	if secretSource.SecretName == "" {
+	if len(secretSource.SecretSelector) > 0 {
+		if secretSource.SecretName != "" {
+			allErrs = append(allErrs, errs.NewFieldInvalid("secretSelector", secretSource.SecretSelector, "may not be set together with secretName"))
+		}
+		allErrs = append(allErrs, ValidateLabels(secretSource.SecretSelector, "secretSelector")...)
+	} else if secretSource.SecretName == "" {
 		allErrs = append(allErrs, errs.NewFieldRequired("secretName"))
 	}
+	for i, item := range secretSource.Items {
+		allErrs = append(allErrs, validateKeyToPath(&item).PrefixIndex(i).Prefix("items")...)
+	}
+	if secretSource.DefaultMode != nil && !IsValidFileMode(*secretSource.DefaultMode) {
+		allErrs = append(allErrs, errs.NewFieldInvalid("defaultMode", *secretSource.DefaultMode, fileModeErrorMsg))
+	}
+	if p := secretSource.SingleKeyTargetPath; p != "" {
+		if len(secretSource.Items) > 0 {
+			allErrs = append(allErrs, errs.NewFieldInvalid("singleKeyTargetPath", p, "may not be set together with items"))
+		} else if !IsValidRelativePath(p) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("singleKeyTargetPath", p, relativePathErrorMsg))
+		} else if strings.HasPrefix(p, "..") {
+			allErrs = append(allErrs, errs.NewFieldInvalid("singleKeyTargetPath", p, "must not start with '..'"))
+		}
+	}
+	if p := secretSource.BundlePath; p != "" {
+		if len(secretSource.Items) > 0 {
+			allErrs = append(allErrs, errs.NewFieldInvalid("bundlePath", p, "may not be set together with items"))
+		} else if secretSource.SingleKeyTargetPath != "" {
+			allErrs = append(allErrs, errs.NewFieldInvalid("bundlePath", p, "may not be set together with singleKeyTargetPath"))
+		} else if !IsValidRelativePath(p) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("bundlePath", p, relativePathErrorMsg))
+		} else if strings.HasPrefix(p, "..") {
+			allErrs = append(allErrs, errs.NewFieldInvalid("bundlePath", p, "must not start with '..'"))
+		}
+	} else if secretSource.BundleSeparator != nil {
+		allErrs = append(allErrs, errs.NewFieldInvalid("bundleSeparator", *secretSource.BundleSeparator, "may only be set together with bundlePath"))
+	}
+	if p := secretSource.ArchivePath; p != "" {
+		if len(secretSource.Items) > 0 {
+			allErrs = append(allErrs, errs.NewFieldInvalid("archivePath", p, "may not be set together with items"))
+		} else if secretSource.SingleKeyTargetPath != "" {
+			allErrs = append(allErrs, errs.NewFieldInvalid("archivePath", p, "may not be set together with singleKeyTargetPath"))
+		} else if secretSource.BundlePath != "" {
+			allErrs = append(allErrs, errs.NewFieldInvalid("archivePath", p, "may not be set together with bundlePath"))
+		} else if !IsValidRelativePath(p) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("archivePath", p, relativePathErrorMsg))
+		} else if strings.HasPrefix(p, "..") {
+			allErrs = append(allErrs, errs.NewFieldInvalid("archivePath", p, "must not start with '..'"))
+		}
+	}
+	if secretSource.TypeCheck != "" && !supportedSecretTypeChecks.Has(string(secretSource.TypeCheck)) {
+		allErrs = append(allErrs, errs.NewFieldValueNotSupported("typeCheck", secretSource.TypeCheck, supportedSecretTypeChecks.List()))
+	}
+	if p := secretSource.HostDirectory; p != "" {
+		if secretSource.PlainDirectory {
+			allErrs = append(allErrs, errs.NewFieldInvalid("hostDirectory", p, "may not be set together with plainDirectory"))
+		} else if !util.IsDNS1123Label(p) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("hostDirectory", p, dns1123LabelErrorMsg))
+		}
+	}
+	if secretSource.ProjectionStrategy != "" && !supportedSecretProjectionStrategies.Has(string(secretSource.ProjectionStrategy)) {
+		allErrs = append(allErrs, errs.NewFieldValueNotSupported("projectionStrategy", secretSource.ProjectionStrategy, supportedSecretProjectionStrategies.List()))
+	}
+	if secretSource.EnvFile != nil {
+		allErrs = append(allErrs, validateEnvFileProjection(secretSource.EnvFile).Prefix("envFile")...)
+	}
+	for i, source := range secretSource.Sources {
+		allErrs = append(allErrs, validateSecretProjection(&source).PrefixIndex(i).Prefix("sources")...)
+	}
+	return allErrs
+}
+
+func validateProjectedVolumeSource(projected *api.ProjectedVolumeSource) errs.ValidationErrorList {
+	allErrs := errs.ValidationErrorList{}
+	if len(projected.Sources) == 0 {
+		allErrs = append(allErrs, errs.NewFieldRequired("sources"))
+	}
+	for i, source := range projected.Sources {
+		allErrs = append(allErrs, validateVolumeProjection(&source).PrefixIndex(i).Prefix("sources")...)
+	}
+	return allErrs
+}
+
+func validateVolumeProjection(projection *api.VolumeProjection) errs.ValidationErrorList {
+	allErrs := errs.ValidationErrorList{}
+	if projection.Secret == nil {
+		allErrs = append(allErrs, errs.NewFieldInvalid("", projection, "exactly 1 source type is required"))
+		return allErrs
+	}
+	allErrs = append(allErrs, validateSecretVolumeSource(projection.Secret).Prefix("secret")...)
+	return allErrs
+}
+
+func validateEnvFileProjection(envFile *api.EnvFileProjection) errs.ValidationErrorList {
+	allErrs := errs.ValidationErrorList{}
+	if envFile.Path == "" {
+		allErrs = append(allErrs, errs.NewFieldRequired("path"))
+	} else if !IsValidRelativePath(envFile.Path) {
+		allErrs = append(allErrs, errs.NewFieldInvalid("path", envFile.Path, relativePathErrorMsg))
+	} else if strings.HasPrefix(envFile.Path, "..") {
+		allErrs = append(allErrs, errs.NewFieldInvalid("path", envFile.Path, "must not start with '..'"))
+	}
+	for i, key := range envFile.Keys {
+		if !util.IsCIdentifier(key) {
+			allErrs = append(allErrs, errs.NewFieldInvalid(fmt.Sprintf("keys[%d]", i), key, cIdentifierErrorMsg))
+		}
+	}
+	return allErrs
+}
+
+func validateSecretProjection(projection *api.SecretProjection) errs.ValidationErrorList {
+	allErrs := errs.ValidationErrorList{}
+	if projection.SecretName == "" {
+		allErrs = append(allErrs, errs.NewFieldRequired("secretName"))
+	}
+	for i, item := range projection.Items {
+		allErrs = append(allErrs, validateKeyToPath(&item).PrefixIndex(i).Prefix("items")...)
+	}
+	return allErrs
+}
+
+func validateKeyToPath(kp *api.KeyToPath) errs.ValidationErrorList {
+	allErrs := errs.ValidationErrorList{}
+	if kp.Key == "" && kp.KeyPattern == "" {
+		allErrs = append(allErrs, errs.NewFieldRequired("key"))
+	} else if kp.Key != "" && kp.KeyPattern != "" {
+		allErrs = append(allErrs, errs.NewFieldInvalid("keyPattern", kp.KeyPattern, "may not be set together with key"))
+	} else if kp.KeyPattern != "" {
+		if !IsValidGlobPattern(kp.KeyPattern) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("keyPattern", kp.KeyPattern, "must be a valid glob pattern"))
+		}
+	}
+	if kp.Path == "" {
+		allErrs = append(allErrs, errs.NewFieldRequired("path"))
+	} else if !IsValidRelativePath(kp.Path) {
+		allErrs = append(allErrs, errs.NewFieldInvalid("path", kp.Path, relativePathErrorMsg))
+	} else if strings.HasPrefix(kp.Path, "..") {
+		allErrs = append(allErrs, errs.NewFieldInvalid("path", kp.Path, "must not start with '..'"))
+	}
+	if kp.Mode != nil && !IsValidFileMode(*kp.Mode) {
+		allErrs = append(allErrs, errs.NewFieldInvalid("mode", *kp.Mode, fileModeErrorMsg))
+	}
+	if kp.UID != nil && *kp.UID < 0 {
+		allErrs = append(allErrs, errs.NewFieldInvalid("uid", *kp.UID, "must not be negative"))
+	}
+	if kp.GID != nil && *kp.GID < 0 {
+		allErrs = append(allErrs, errs.NewFieldInvalid("gid", *kp.GID, "must not be negative"))
+	}
+	if kp.Encoding != "" && !supportedKeyEncodings.Has(string(kp.Encoding)) {
+		allErrs = append(allErrs, errs.NewFieldValueNotSupported("encoding", kp.Encoding, supportedKeyEncodings.List()))
+	}
+	for name, value := range kp.ExtendedAttributes {
+		if !strings.HasPrefix(name, xattrUserPrefix) || len(name) == len(xattrUserPrefix) || len(name) > maxXattrNameLen {
+			allErrs = append(allErrs, errs.NewFieldInvalid("extendedAttributes", name, fmt.Sprintf("must be a name of at most %d bytes in the %q namespace", maxXattrNameLen, xattrUserPrefix)))
+		} else if len(value) > maxXattrValueLen {
+			allErrs = append(allErrs, errs.NewFieldInvalid("extendedAttributes", name, fmt.Sprintf("must have a value of at most %d bytes", maxXattrValueLen)))
+		}
+	}
 	return allErrs
 }
 
+// The namespace that the extended attributes of secret files must be in,
+// and the limits Linux puts on their names and values.
+const (
+	xattrUserPrefix  = "user."
+	maxXattrNameLen  = 255
+	maxXattrValueLen = 65536
+)
+
+var supportedKeyEncodings = util.NewStringSet(string(api.KeyEncodingRaw), string(api.KeyEncodingBase64))
+
+var supportedSecretTypeChecks = util.NewStringSet(string(api.SecretTypeCheckNone), string(api.SecretTypeCheckWarn), string(api.SecretTypeCheckError))
+
+var supportedSecretProjectionStrategies = util.NewStringSet(string(api.SecretProjectionAtomicSymlink), string(api.SecretProjectionCopy))
+
+// IsValidRelativePath tests that the argument is a relative path that
+// does not contain the path element '..'.
+func IsValidRelativePath(p string) bool {
+	if path.IsAbs(p) {
+		return false
+	}
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidGlobPattern tests that the argument is a well-formed path.Match
+// pattern.  The pattern is scanned rather than matched against a probe,
+// since path.Match only reports the malformed parts of a pattern that
+// matching reaches on older versions of Go.
+func IsValidGlobPattern(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return false
+			}
+		case '[':
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+			// A class holds at least one character or range before its
+			// closing ']'.
+			for ranges := 0; i >= len(pattern) || pattern[i] != ']' || ranges == 0; ranges++ {
+				var ok bool
+				if i, ok = scanGlobClassChar(pattern, i); !ok {
+					return false
+				}
+				if i < len(pattern) && pattern[i] == '-' {
+					if i, ok = scanGlobClassChar(pattern, i+1); !ok {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// scanGlobClassChar scans the character of a glob character class at index
+// i of pattern, and returns the index after it.  It returns false if the
+// class is unterminated or holds an unescaped '-' or ']' where a character
+// is expected.
+func scanGlobClassChar(pattern string, i int) (int, bool) {
+	if i >= len(pattern) || pattern[i] == '-' || pattern[i] == ']' {
+		return i, false
+	}
+	if pattern[i] == '\\' {
+		i++
+		if i == len(pattern) {
+			return i, false
+		}
+	}
+	_, size := utf8.DecodeRuneInString(pattern[i:])
+	return i + size, true
+}
+
+// IsValidFileMode tests that the argument is a legal set of permission bits
+// for a file projected into a volume.
+func IsValidFileMode(mode int) bool {
+	return mode >= 0 && mode <= 0777
+}
+
 func validatePersistentClaimVolumeSource(claim *api.PersistentVolumeClaimVolumeSource) errs.ValidationErrorList {
 	allErrs := errs.ValidationErrorList{}
 	if claim.ClaimName == "" {
@@ -1473,6 +1721,15 @@ func ValidateSecretUpdate(oldSecret, newSecret *api.Secret) errs.ValidationError
 		allErrs = append(allErrs, errs.NewFieldInvalid("type", newSecret.Type, "field is immutable"))
 	}
 
+	if oldSecret.Immutable != nil && *oldSecret.Immutable {
+		if newSecret.Immutable == nil || !*newSecret.Immutable {
+			allErrs = append(allErrs, errs.NewFieldInvalid("immutable", newSecret.Immutable, "field is immutable when the secret is immutable"))
+		}
+		if (len(newSecret.Data) != 0 || len(oldSecret.Data) != 0) && !reflect.DeepEqual(newSecret.Data, oldSecret.Data) {
+			allErrs = append(allErrs, errs.NewFieldInvalid("data", "<secret contents redacted>", "field is immutable when the secret is immutable"))
+		}
+	}
+
 	allErrs = append(allErrs, ValidateSecret(newSecret)...)
 	return allErrs
 }
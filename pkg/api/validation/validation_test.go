@@ -456,9 +456,10 @@ func TestValidateVolumes(t *testing.T) {
 		{Name: "awsebs", VolumeSource: api.VolumeSource{AWSElasticBlockStore: &api.AWSElasticBlockStoreVolumeSource{"my-PD", "ext4", 1, false}}},
 		{Name: "gitrepo", VolumeSource: api.VolumeSource{GitRepo: &api.GitRepoVolumeSource{"my-repo", "hashstring"}}},
 		{Name: "iscsidisk", VolumeSource: api.VolumeSource{ISCSI: &api.ISCSIVolumeSource{"127.0.0.1", "iqn.2015-02.example.com:test", 1, "ext4", false}}},
-		{Name: "secret", VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{"my-secret"}}},
+		{Name: "secret", VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{SecretName: "my-secret"}}},
 		{Name: "glusterfs", VolumeSource: api.VolumeSource{Glusterfs: &api.GlusterfsVolumeSource{"host1", "path", false}}},
 		{Name: "rbd", VolumeSource: api.VolumeSource{RBD: &api.RBDVolumeSource{CephMonitors: []string{"foo"}, RBDImage: "bar", FSType: "ext4"}}},
+		{Name: "projected", VolumeSource: api.VolumeSource{Projected: &api.ProjectedVolumeSource{Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{SecretName: "my-secret"}}}}}},
 	}
 	names, errs := validateVolumes(successCase)
 	if len(errs) != 0 {
@@ -511,6 +512,374 @@ func TestValidateVolumes(t *testing.T) {
 	}
 }
 
+func TestValidateSecretVolumeSourceMode(t *testing.T) {
+	for _, mode := range []int{0, 0400, 0444, 0777} {
+		m := mode
+		source := &api.SecretVolumeSource{SecretName: "my-secret", DefaultMode: &m}
+		if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+			t.Errorf("expected success for mode %o: %v", mode, errs)
+		}
+	}
+	for _, mode := range []int{-1, 01000, 07777} {
+		m := mode
+		source := &api.SecretVolumeSource{SecretName: "my-secret", DefaultMode: &m}
+		errs := validateSecretVolumeSource(source)
+		if len(errs) != 1 {
+			t.Errorf("expected one failure for mode %o, got: %v", mode, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "defaultMode" {
+			t.Errorf("expected error on defaultMode for mode %o, got: %v", mode, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceItems(t *testing.T) {
+	mode := 0400
+	badMode := 01000
+	owner := int64(1000)
+	badOwner := int64(-1)
+	optional := true
+	successCase := []api.KeyToPath{
+		{Key: "tls.key", Path: "server.key"},
+		{Key: "tls.key", Path: "certs/server.key", Mode: &mode},
+		{Key: "tls.key", Path: "server.key", UID: &owner, GID: &owner},
+		{Key: "tls.key", Path: "server.key", Encoding: api.KeyEncodingRaw},
+		{Key: "tls.key", Path: "server.key", Encoding: api.KeyEncodingBase64},
+		{KeyPattern: "cert-*.pem", Path: "certs"},
+		{KeyPattern: "cert-?.pem", Path: "certs", Optional: &optional},
+		{KeyPattern: "cert-[0-9a-f].pem", Path: "certs"},
+		{KeyPattern: "cert-[^\\]].pem", Path: "certs"},
+		{KeyPattern: "cert\\*", Path: "certs"},
+		{KeyPattern: "cert-]", Path: "certs"},
+	}
+	for _, item := range successCase {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", Items: []api.KeyToPath{item}}
+		if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+			t.Errorf("expected success for %+v: %v", item, errs)
+		}
+	}
+
+	errorCases := map[string]struct {
+		item  api.KeyToPath
+		field string
+	}{
+		"empty key":       {api.KeyToPath{Path: "foo"}, "items[0].key"},
+		"empty path":      {api.KeyToPath{Key: "foo"}, "items[0].path"},
+		"absolute path":   {api.KeyToPath{Key: "foo", Path: "/etc/foo"}, "items[0].path"},
+		"dot-dot path":    {api.KeyToPath{Key: "foo", Path: "a/../../foo"}, "items[0].path"},
+		"reserved path":   {api.KeyToPath{Key: "foo", Path: "..data"}, "items[0].path"},
+		"bad mode":        {api.KeyToPath{Key: "foo", Path: "foo", Mode: &badMode}, "items[0].mode"},
+		"bad uid":         {api.KeyToPath{Key: "foo", Path: "foo", UID: &badOwner}, "items[0].uid"},
+		"bad gid":         {api.KeyToPath{Key: "foo", Path: "foo", GID: &badOwner}, "items[0].gid"},
+		"bad encoding":    {api.KeyToPath{Key: "foo", Path: "foo", Encoding: "rot13"}, "items[0].encoding"},
+		"key and pattern": {api.KeyToPath{Key: "foo", KeyPattern: "foo*", Path: "foo"}, "items[0].keyPattern"},
+		"bad pattern":     {api.KeyToPath{KeyPattern: "foo[", Path: "foo"}, "items[0].keyPattern"},
+		"open class":      {api.KeyToPath{KeyPattern: "foo[a-", Path: "foo"}, "items[0].keyPattern"},
+		"empty class":     {api.KeyToPath{KeyPattern: "foo[]", Path: "foo"}, "items[0].keyPattern"},
+		"bad range":       {api.KeyToPath{KeyPattern: "foo[a-]", Path: "foo"}, "items[0].keyPattern"},
+		"trailing escape": {api.KeyToPath{KeyPattern: "foo\\", Path: "foo"}, "items[0].keyPattern"},
+		"late bad class":  {api.KeyToPath{KeyPattern: "foo*[", Path: "foo"}, "items[0].keyPattern"},
+	}
+	for k, v := range errorCases {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", Items: []api.KeyToPath{v.item}}
+		errs := validateSecretVolumeSource(source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceTypeCheck(t *testing.T) {
+	for _, check := range []api.SecretTypeCheck{"", api.SecretTypeCheckNone, api.SecretTypeCheckWarn, api.SecretTypeCheckError} {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", TypeCheck: check}
+		if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+			t.Errorf("expected success for %q: %v", check, errs)
+		}
+	}
+
+	source := &api.SecretVolumeSource{SecretName: "my-secret", TypeCheck: "Strict"}
+	errs := validateSecretVolumeSource(source)
+	if len(errs) != 1 {
+		t.Fatalf("expected one failure, got: %v", errs)
+	}
+	if errs[0].(*errors.ValidationError).Field != "typeCheck" {
+		t.Errorf("expected error on field typeCheck, got: %v", errs[0])
+	}
+}
+
+func TestValidateSecretVolumeSourceProjectionStrategy(t *testing.T) {
+	for _, strategy := range []api.SecretProjectionStrategy{"", api.SecretProjectionAtomicSymlink, api.SecretProjectionCopy} {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", ProjectionStrategy: strategy}
+		if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+			t.Errorf("expected success for %q: %v", strategy, errs)
+		}
+	}
+
+	source := &api.SecretVolumeSource{SecretName: "my-secret", ProjectionStrategy: "Hardlink"}
+	errs := validateSecretVolumeSource(source)
+	if len(errs) != 1 {
+		t.Fatalf("expected one failure, got: %v", errs)
+	}
+	if errs[0].(*errors.ValidationError).Field != "projectionStrategy" {
+		t.Errorf("expected error on field projectionStrategy, got: %v", errs[0])
+	}
+}
+
+func TestValidateSecretVolumeSourceHostDirectory(t *testing.T) {
+	source := &api.SecretVolumeSource{SecretName: "my-secret", HostDirectory: "migration"}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]*api.SecretVolumeSource{
+		"absolute path":          {SecretName: "my-secret", HostDirectory: "/srv/secrets"},
+		"relative path":          {SecretName: "my-secret", HostDirectory: "srv/secrets"},
+		"upper case":             {SecretName: "my-secret", HostDirectory: "Migration"},
+		"plainDirectory as well": {SecretName: "my-secret", HostDirectory: "migration", PlainDirectory: true},
+	}
+	for k, v := range errorCases {
+		errs := validateSecretVolumeSource(v)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "hostDirectory" {
+			t.Errorf("%s: expected error on field hostDirectory, got: %v", k, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceSecretSelector(t *testing.T) {
+	source := &api.SecretVolumeSource{SecretSelector: map[string]string{"app": "db", "active": "true"}}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]*api.SecretVolumeSource{
+		"secretName as well": {SecretName: "my-secret", SecretSelector: map[string]string{"app": "db"}},
+		"invalid key":        {SecretSelector: map[string]string{"app/db/x": "db"}},
+		"invalid value":      {SecretSelector: map[string]string{"app": "db?"}},
+	}
+	for k, v := range errorCases {
+		errs := validateSecretVolumeSource(v)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "secretSelector" {
+			t.Errorf("%s: expected error on field secretSelector, got: %v", k, errs[0])
+		}
+	}
+
+	if errs := validateSecretVolumeSource(&api.SecretVolumeSource{SecretSelector: map[string]string{}}); len(errs) != 1 || errs[0].(*errors.ValidationError).Field != "secretName" {
+		t.Errorf("expected an empty selector to leave secretName required, got: %v", errs)
+	}
+}
+
+func TestValidateSecretVolumeSourceSingleKeyTargetPath(t *testing.T) {
+	source := &api.SecretVolumeSource{SecretName: "my-secret", SingleKeyTargetPath: "certs/server.key"}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]api.SecretVolumeSource{
+		"with items":    {SecretName: "my-secret", SingleKeyTargetPath: "key", Items: []api.KeyToPath{{Key: "foo", Path: "foo"}}},
+		"absolute path": {SecretName: "my-secret", SingleKeyTargetPath: "/etc/key"},
+		"dot-dot path":  {SecretName: "my-secret", SingleKeyTargetPath: "a/../../key"},
+		"reserved path": {SecretName: "my-secret", SingleKeyTargetPath: "..data"},
+	}
+	for k, source := range errorCases {
+		errs := validateSecretVolumeSource(&source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "singleKeyTargetPath" {
+			t.Errorf("%s: expected error on field singleKeyTargetPath, got: %v", k, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceBundlePath(t *testing.T) {
+	separator := "\n---\n"
+	source := &api.SecretVolumeSource{SecretName: "my-secret", BundlePath: "certs/bundle.pem", BundleSeparator: &separator}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]struct {
+		source api.SecretVolumeSource
+		field  string
+	}{
+		"with items":          {api.SecretVolumeSource{SecretName: "my-secret", BundlePath: "bundle", Items: []api.KeyToPath{{Key: "foo", Path: "foo"}}}, "bundlePath"},
+		"with single key":     {api.SecretVolumeSource{SecretName: "my-secret", BundlePath: "bundle", SingleKeyTargetPath: "key"}, "bundlePath"},
+		"absolute path":       {api.SecretVolumeSource{SecretName: "my-secret", BundlePath: "/etc/bundle"}, "bundlePath"},
+		"dot-dot path":        {api.SecretVolumeSource{SecretName: "my-secret", BundlePath: "a/../../bundle"}, "bundlePath"},
+		"separator no bundle": {api.SecretVolumeSource{SecretName: "my-secret", BundleSeparator: &separator}, "bundleSeparator"},
+	}
+	for k, v := range errorCases {
+		errs := validateSecretVolumeSource(&v.source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceArchivePath(t *testing.T) {
+	source := &api.SecretVolumeSource{SecretName: "my-secret", ArchivePath: "keys.tar.gz"}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]api.SecretVolumeSource{
+		"with items":      {SecretName: "my-secret", ArchivePath: "keys.tar.gz", Items: []api.KeyToPath{{Key: "foo", Path: "foo"}}},
+		"with single key": {SecretName: "my-secret", ArchivePath: "keys.tar.gz", SingleKeyTargetPath: "key"},
+		"with bundle":     {SecretName: "my-secret", ArchivePath: "keys.tar.gz", BundlePath: "bundle"},
+		"absolute path":   {SecretName: "my-secret", ArchivePath: "/keys.tar.gz"},
+		"dot-dot path":    {SecretName: "my-secret", ArchivePath: "a/../../keys.tar.gz"},
+		"reserved path":   {SecretName: "my-secret", ArchivePath: "..keys.tar.gz"},
+	}
+	for k, source := range errorCases {
+		errs := validateSecretVolumeSource(&source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "archivePath" {
+			t.Errorf("%s: expected error on field archivePath, got: %v", k, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceExtendedAttributes(t *testing.T) {
+	source := &api.SecretVolumeSource{
+		SecretName: "my-secret",
+		Items:      []api.KeyToPath{{Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"user.credential": "true", "user.owner": ""}}},
+	}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]api.KeyToPath{
+		"no namespace":    {Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"credential": "true"}},
+		"other namespace": {Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"security.selinux": "unconfined_u"}},
+		"empty name":      {Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"user.": "true"}},
+		"long name":       {Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"user." + strings.Repeat("a", 251): "true"}},
+		"long value":      {Key: "foo", Path: "foo", ExtendedAttributes: map[string]string{"user.credential": strings.Repeat("a", 65537)}},
+	}
+	for k, item := range errorCases {
+		errs := validateSecretVolumeSource(&api.SecretVolumeSource{SecretName: "my-secret", Items: []api.KeyToPath{item}})
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != "items[0].extendedAttributes" {
+			t.Errorf("%s: expected error on field items[0].extendedAttributes, got: %v", k, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceEnvFile(t *testing.T) {
+	source := &api.SecretVolumeSource{
+		SecretName: "my-secret",
+		Items:      []api.KeyToPath{{Key: "DB_PASSWORD", Path: "password"}},
+		EnvFile:    &api.EnvFileProjection{Path: "config/.env", Keys: []string{"DB_USER", "DB_PASSWORD"}},
+	}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]struct {
+		envFile api.EnvFileProjection
+		field   string
+	}{
+		"empty path":    {api.EnvFileProjection{}, "envFile.path"},
+		"absolute path": {api.EnvFileProjection{Path: "/etc/.env"}, "envFile.path"},
+		"dot-dot path":  {api.EnvFileProjection{Path: "..env"}, "envFile.path"},
+		"bad key":       {api.EnvFileProjection{Path: ".env", Keys: []string{"DB_USER", "db-password"}}, "envFile.keys[1]"},
+	}
+	for k, v := range errorCases {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", EnvFile: &v.envFile}
+		errs := validateSecretVolumeSource(source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
+func TestValidateSecretVolumeSourceSources(t *testing.T) {
+	source := &api.SecretVolumeSource{
+		SecretName: "my-secret",
+		Sources: []api.SecretProjection{
+			{SecretName: "tls"},
+			{SecretName: "creds", Items: []api.KeyToPath{{Key: "password", Path: "creds/password"}}},
+		},
+	}
+	if errs := validateSecretVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]struct {
+		source api.SecretProjection
+		field  string
+	}{
+		"empty secretName": {api.SecretProjection{}, "sources[0].secretName"},
+		"bad item":         {api.SecretProjection{SecretName: "tls", Items: []api.KeyToPath{{Key: "foo", Path: "/etc/foo"}}}, "sources[0].items[0].path"},
+	}
+	for k, v := range errorCases {
+		source := &api.SecretVolumeSource{SecretName: "my-secret", Sources: []api.SecretProjection{v.source}}
+		errs := validateSecretVolumeSource(source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
+func TestValidateProjectedVolumeSource(t *testing.T) {
+	source := &api.ProjectedVolumeSource{
+		Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{SecretName: "my-secret"}}},
+	}
+	if errs := validateProjectedVolumeSource(source); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]struct {
+		source api.ProjectedVolumeSource
+		field  string
+	}{
+		"no sources":       {api.ProjectedVolumeSource{}, "sources"},
+		"empty source":     {api.ProjectedVolumeSource{Sources: []api.VolumeProjection{{}}}, "sources[0]"},
+		"empty secretName": {api.ProjectedVolumeSource{Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{}}}}, "sources[0].secret.secretName"},
+	}
+	for k, v := range errorCases {
+		errs := validateProjectedVolumeSource(&v.source)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
 func TestValidatePorts(t *testing.T) {
 	successCase := []api.ContainerPort{
 		{Name: "abc", ContainerPort: 80, HostPort: 80, Protocol: "TCP"},
@@ -3248,6 +3617,50 @@ func TestValidateSecret(t *testing.T) {
 	}
 }
 
+func TestValidateSecretUpdate(t *testing.T) {
+	immutable, mutable := true, false
+	secret := func(data string, immutable *bool) api.Secret {
+		return api.Secret{
+			ObjectMeta: api.ObjectMeta{Name: "foo", Namespace: "bar", ResourceVersion: "1"},
+			Data:       map[string][]byte{"data-1": []byte(data)},
+			Immutable:  immutable,
+		}
+	}
+
+	successCases := map[string]struct {
+		old, new api.Secret
+	}{
+		"update mutable data":         {secret("bar", nil), secret("baz", nil)},
+		"make immutable":              {secret("bar", &mutable), secret("baz", &immutable)},
+		"keep immutable unchanged":    {secret("bar", &immutable), secret("bar", &immutable)},
+		"make mutable secret mutable": {secret("bar", &mutable), secret("bar", nil)},
+	}
+	for k, v := range successCases {
+		if errs := ValidateSecretUpdate(&v.old, &v.new); len(errs) != 0 {
+			t.Errorf("%s: expected success: %v", k, errs)
+		}
+	}
+
+	errorCases := map[string]struct {
+		old, new api.Secret
+		field    string
+	}{
+		"update immutable data": {secret("bar", &immutable), secret("baz", &immutable), "data"},
+		"make mutable":          {secret("bar", &immutable), secret("bar", &mutable), "immutable"},
+		"unset immutable":       {secret("bar", &immutable), secret("bar", nil), "immutable"},
+	}
+	for k, v := range errorCases {
+		errs := ValidateSecretUpdate(&v.old, &v.new)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got: %v", k, errs)
+			continue
+		}
+		if errs[0].(*errors.ValidationError).Field != v.field {
+			t.Errorf("%s: expected error on field %s, got: %v", k, v.field, errs[0])
+		}
+	}
+}
+
 func TestValidateDockerConfigSecret(t *testing.T) {
 	validDockerSecret := func() api.Secret {
 		return api.Secret{
@@ -20,6 +20,7 @@ package kubelet
 // contrib/mesos/pkg/executor/.
 
 import (
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"io"
@@ -150,7 +151,9 @@ func NewMainKubelet(
 	configureCBR0 bool,
 	podCIDR string,
 	pods int,
-	dockerExecHandler dockertools.ExecHandler) (*Kubelet, error) {
+	dockerExecHandler dockertools.ExecHandler,
+	secretVolumeVerifyingKey *rsa.PublicKey,
+	secretVolumeClientConfig *client.Config) (*Kubelet, error) {
 	if rootDirectory == "" {
 		return nil, fmt.Errorf("invalid root directory %q", rootDirectory)
 	}
@@ -266,6 +269,8 @@ func NewMainKubelet(
 		podCIDR:                        podCIDR,
 		pods:                           pods,
 		syncLoopMonitor:                util.AtomicValue{},
+		secretVolumeVerifyingKey:       secretVolumeVerifyingKey,
+		secretVolumeClientConfig:       secretVolumeClientConfig,
 	}
 
 	if plug, err := network.InitNetworkPlugin(networkPlugins, networkPluginName, &networkHost{klet}); err != nil {
@@ -511,6 +516,20 @@ type Kubelet struct {
 
 	// Monitor Kubelet's sync loop
 	syncLoopMonitor util.AtomicValue
+
+	// The key that secrets must be signed with to be projected by secret
+	// volumes that verify signatures, or nil if there is none.
+	secretVolumeVerifyingKey *rsa.PublicKey
+
+	// The config of the client that secret volumes read the secrets of a
+	// pod with, using the token of the pod's service account in place of
+	// the kubelet's credentials, or nil to read them with kubeClient.
+	secretVolumeClientConfig *client.Config
+
+	// Clients made from secretVolumeClientConfig, by the namespace and name
+	// of the service account whose token they use.
+	podClientsLock sync.Mutex
+	podClients     map[string]podClient
 }
 
 // getRootDir returns the full path to the directory under which kubelet can
@@ -847,11 +866,17 @@ func makeMounts(container *api.Container, podVolumes kubecontainer.VolumeMap) (m
 			glog.Warningf("Mount cannot be satisified for container %q, because the volume is missing: %q", container.Name, mount)
 			continue
 		}
+		// A volume that containers cannot write to is mounted read-only,
+		// whatever the container asks for.
+		readOnly := mount.ReadOnly
+		if builder, ok := vol.(volume.Builder); ok && builder.GetAttributes().ReadOnly {
+			readOnly = true
+		}
 		mounts = append(mounts, kubecontainer.Mount{
 			Name:          mount.Name,
 			ContainerPath: mount.MountPath,
 			HostPath:      vol.GetPath(),
-			ReadOnly:      mount.ReadOnly,
+			ReadOnly:      readOnly,
 		})
 	}
 	return
@@ -1446,8 +1471,10 @@ func (kl *Kubelet) SyncPods(allPods []*api.Pod, podSyncTypes map[types.UID]SyncP
 			metrics.ContainersPerPodCount.Observe(float64(len(pod.Spec.Containers)))
 		}
 	}
-	// Stop the workers for no-longer existing pods.
+	// Stop the workers for no-longer existing pods, and cancel any setup of
+	// their volumes that is still under way.
 	kl.podWorkers.ForgetNonExistingPodWorkers(desiredPods)
+	kl.volumeManager.CancelSetUps(desiredPods)
 
 	if !kl.sourcesReady() {
 		// If the sources aren't ready, skip deletion, as we may accidentally delete pods
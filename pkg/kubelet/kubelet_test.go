@@ -36,6 +36,7 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/resource"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/testapi"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/capabilities"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/testclient"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet/cadvisor"
@@ -422,6 +423,32 @@ func TestMountExternalVolumes(t *testing.T) {
 	}
 }
 
+func TestCancelVolumeSetUps(t *testing.T) {
+	vm := newVolumeManager()
+	wanted := vm.GetSetUpStopChannel("wanted")
+	unwanted := vm.GetSetUpStopChannel("unwanted")
+	if vm.GetSetUpStopChannel("wanted") != wanted {
+		t.Errorf("Expected the same channel for the same pod")
+	}
+
+	vm.CancelSetUps(map[types.UID]empty{"wanted": {}})
+	select {
+	case <-unwanted:
+	default:
+		t.Errorf("Expected the setup of the volumes of an unwanted pod to be canceled")
+	}
+	select {
+	case <-wanted:
+		t.Errorf("Expected the setup of the volumes of a wanted pod not to be canceled")
+	default:
+	}
+	select {
+	case <-vm.GetSetUpStopChannel("unwanted"):
+		t.Errorf("Expected a pod that is wanted again to get a new channel")
+	default:
+	}
+}
+
 func TestGetPodVolumesFromDisk(t *testing.T) {
 	testKubelet := newTestKubelet(t)
 	kubelet := testKubelet.kubelet
@@ -470,6 +497,28 @@ func (f *stubVolume) GetPath() string {
 	return f.path
 }
 
+// stubBuilder is a volume builder with the given ReadOnly attribute.
+type stubBuilder struct {
+	stubVolume
+	readOnly bool
+}
+
+func (f *stubBuilder) SetUp() error {
+	return nil
+}
+
+func (f *stubBuilder) SetUpAt(dir string) error {
+	return nil
+}
+
+func (f *stubBuilder) IsReadOnly() bool {
+	return f.readOnly
+}
+
+func (f *stubBuilder) GetAttributes() volume.Attributes {
+	return volume.Attributes{ReadOnly: f.readOnly}
+}
+
 func TestMakeVolumeMounts(t *testing.T) {
 	container := api.Container{
 		VolumeMounts: []api.VolumeMount{
@@ -493,6 +542,11 @@ func TestMakeVolumeMounts(t *testing.T) {
 				Name:      "disk5",
 				ReadOnly:  false,
 			},
+			{
+				MountPath: "/mnt/path6",
+				Name:      "disk6",
+				ReadOnly:  false,
+			},
 		},
 	}
 
@@ -500,6 +554,7 @@ func TestMakeVolumeMounts(t *testing.T) {
 		"disk":  &stubVolume{"/mnt/disk"},
 		"disk4": &stubVolume{"/mnt/host"},
 		"disk5": &stubVolume{"/var/lib/kubelet/podID/volumes/empty/disk5"},
+		"disk6": &stubBuilder{stubVolume{"/var/lib/kubelet/podID/volumes/secret/disk6"}, true},
 	}
 
 	mounts := makeMounts(&container, podVolumes)
@@ -529,6 +584,12 @@ func TestMakeVolumeMounts(t *testing.T) {
 			"/var/lib/kubelet/podID/volumes/empty/disk5",
 			false,
 		},
+		{
+			"disk6",
+			"/mnt/path6",
+			"/var/lib/kubelet/podID/volumes/secret/disk6",
+			true,
+		},
 	}
 	if !reflect.DeepEqual(mounts, expectedMounts) {
 		t.Errorf("Unexpected mounts: Expected %#v got %#v.  Container was: %#v", expectedMounts, mounts, container)
@@ -3293,3 +3354,55 @@ func TestDoesNotDeletePodDirsForTerminatedPods(t *testing.T) {
 		}
 	}
 }
+
+func TestKubeClientForPod(t *testing.T) {
+	testKubelet := newTestKubelet(t)
+	kubelet := testKubelet.kubelet
+	serviceAccount := api.ServiceAccount{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "app", UID: "app-uid"},
+		Secrets:    []api.ObjectReference{{Name: "app-token"}},
+	}
+	token := api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "app-token",
+			Annotations: map[string]string{api.ServiceAccountNameKey: "app", api.ServiceAccountUIDKey: "app-uid"},
+		},
+		Type: api.SecretTypeServiceAccountToken,
+		Data: map[string][]byte{api.ServiceAccountTokenKey: []byte("token-1")},
+	}
+	kubelet.kubeClient = testclient.NewSimpleFake(&serviceAccount, &token)
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "foo"},
+		Spec:       api.PodSpec{ServiceAccountName: "app"},
+	}
+
+	// Without a config, secrets are read with the kubelet's client.
+	if podKubeClient, err := kubelet.kubeClientForPod(pod); podKubeClient != nil || err != nil {
+		t.Errorf("Expected no client without a config, got %v, %v", podKubeClient, err)
+	}
+
+	kubelet.secretVolumeClientConfig = &client.Config{Host: "http://127.0.0.1:8080", Version: testapi.Version(), Username: "kubelet", Password: "secret"}
+	podKubeClient, err := kubelet.kubeClientForPod(pod)
+	if err != nil || podKubeClient == nil {
+		t.Fatalf("Expected a client for the service account, got %v, %v", podKubeClient, err)
+	}
+	if again, err := kubelet.kubeClientForPod(pod); err != nil || again != podKubeClient {
+		t.Errorf("Expected the client for the service account to be reused, got %v, %v", again, err)
+	}
+	if kubelet.podClients["ns/app"].token != "token-1" {
+		t.Errorf("Expected the client to use the token of the service account, got %q", kubelet.podClients["ns/app"].token)
+	}
+
+	noAccount := *pod
+	noAccount.Spec.ServiceAccountName = ""
+	if podKubeClient, err := kubelet.kubeClientForPod(&noAccount); podKubeClient != nil || err != nil {
+		t.Errorf("Expected no client for a pod without a service account, got %v, %v", podKubeClient, err)
+	}
+
+	token.Annotations[api.ServiceAccountUIDKey] = "other-uid"
+	kubelet.kubeClient = testclient.NewSimpleFake(&serviceAccount, &token)
+	if _, err := kubelet.kubeClientForPod(pod); err == nil {
+		t.Errorf("Expected an error for a service account with no token of its own")
+	}
+}
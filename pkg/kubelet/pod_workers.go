@@ -25,6 +25,7 @@ import (
 	kubecontainer "github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet/container"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	"github.com/golang/glog"
 )
 
@@ -50,6 +51,10 @@ type podWorkers struct {
 	// Tracks the last undelivered work item for this pod - a work item is
 	// undelivered if it comes in while the worker is working.
 	lastUndeliveredWorkUpdate map[types.UID]workUpdate
+	// Tracks, for each pod whose last sync failed with an error suggesting
+	// when to retry it, such as a volume.RetryAfterError, the time until
+	// which updates of the pod are dropped rather than synced.
+	notBefore map[types.UID]time.Time
 	// runtimeCache is used for listing running containers.
 	runtimeCache kubecontainer.RuntimeCache
 
@@ -82,6 +87,7 @@ func newPodWorkers(runtimeCache kubecontainer.RuntimeCache, syncPodFn syncPodFnT
 		podUpdates:                map[types.UID]chan workUpdate{},
 		isWorking:                 map[types.UID]bool{},
 		lastUndeliveredWorkUpdate: map[types.UID]workUpdate{},
+		notBefore:                 map[types.UID]time.Time{},
 		runtimeCache:              runtimeCache,
 		syncPodFn:                 syncPodFn,
 		recorder:                  recorder,
@@ -110,6 +116,9 @@ func (p *podWorkers) managePodLoop(podUpdates <-chan workUpdate) {
 			if err != nil {
 				glog.Errorf("Error syncing pod %s, skipping: %v", newWork.pod.UID, err)
 				p.recorder.Eventf(newWork.pod, "failedSync", "Error syncing pod, skipping: %v", err)
+				if retryErr, ok := err.(volume.RetryAfterError); ok {
+					p.holdOff(newWork.pod.UID, retryErr.RetryAfter())
+				}
 				return
 			}
 			minRuntimeCacheTime = time.Now()
@@ -153,6 +162,10 @@ func (p *podWorkers) UpdatePod(pod *api.Pod, mirrorPod *api.Pod, updateComplete
 			p.managePodLoop(podUpdates)
 		}()
 	}
+	if p.holdingOff(uid) {
+		glog.V(4).Infof("Not syncing pod %s before the suggested retry time %v", uid, p.notBefore[uid])
+		return
+	}
 	if !p.isWorking[pod.UID] {
 		p.isWorking[pod.UID] = true
 		podUpdates <- workUpdate{
@@ -184,13 +197,40 @@ func (p *podWorkers) ForgetNonExistingPodWorkers(desiredPods map[types.UID]empty
 			if _, cached := p.lastUndeliveredWorkUpdate[key]; cached {
 				delete(p.lastUndeliveredWorkUpdate, key)
 			}
+			delete(p.notBefore, key)
 		}
 	}
 }
 
+// holdOff drops the updates of the pod with the given uid until wait has
+// passed.
+func (p *podWorkers) holdOff(uid types.UID, wait time.Duration) {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	p.notBefore[uid] = time.Now().Add(wait)
+}
+
+// holdingOff returns true if the updates of the pod with the given uid are
+// still dropped.  The caller must hold podLock.
+func (p *podWorkers) holdingOff(uid types.UID) bool {
+	notBefore, found := p.notBefore[uid]
+	if !found {
+		return false
+	}
+	if time.Now().Before(notBefore) {
+		return true
+	}
+	delete(p.notBefore, uid)
+	return false
+}
+
 func (p *podWorkers) checkForUpdates(uid types.UID, updateComplete func()) {
 	p.podLock.Lock()
 	defer p.podLock.Unlock()
+	if p.holdingOff(uid) {
+		// The update is synced by a later resync instead.
+		delete(p.lastUndeliveredWorkUpdate, uid)
+	}
 	if workUpdate, exists := p.lastUndeliveredWorkUpdate[uid]; exists {
 		p.podUpdates[uid] <- workUpdate
 		delete(p.lastUndeliveredWorkUpdate, uid)
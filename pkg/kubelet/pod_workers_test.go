@@ -120,6 +120,54 @@ func TestUpdatePod(t *testing.T) {
 	}
 }
 
+// retryAfterError is an error that suggests when to retry.
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string {
+	return "try again later"
+}
+
+func (e retryAfterError) RetryAfter() time.Duration {
+	return time.Duration(e)
+}
+
+func TestUpdatePodRetryAfter(t *testing.T) {
+	lock := sync.Mutex{}
+	syncs := 0
+	fakeRecorder := &record.FakeRecorder{}
+	podWorkers := newPodWorkers(
+		createFakeRuntimeCache(fakeRecorder),
+		func(pod *api.Pod, mirrorPod *api.Pod, runningPod kubecontainer.Pod, updateType SyncPodType) error {
+			lock.Lock()
+			defer lock.Unlock()
+			syncs++
+			return retryAfterError(200 * time.Millisecond)
+		},
+		fakeRecorder,
+	)
+	getSyncs := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return syncs
+	}
+
+	podWorkers.UpdatePod(newPod(string(0), "foo"), nil, func() {})
+	drainWorkers(podWorkers, 1)
+	// An update of a pod whose sync suggested waiting is dropped until the
+	// wait has passed.
+	podWorkers.UpdatePod(newPod(string(0), "foo"), nil, func() {})
+	drainWorkers(podWorkers, 1)
+	if syncs := getSyncs(); syncs != 1 {
+		t.Errorf("Expected the pod to be synced once before the wait passed, got %v", syncs)
+	}
+	time.Sleep(300 * time.Millisecond)
+	podWorkers.UpdatePod(newPod(string(0), "foo"), nil, func() {})
+	drainWorkers(podWorkers, 1)
+	if syncs := getSyncs(); syncs != 2 {
+		t.Errorf("Expected the pod to be synced again after the wait passed, got %v", syncs)
+	}
+}
+
 func TestUpdateType(t *testing.T) {
 	syncType := make(chan SyncPodType)
 	fakeRecorder := &record.FakeRecorder{}
@@ -41,6 +41,7 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/flushwriter"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/httpstream"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/httpstream/spdy"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	"github.com/golang/glog"
 	cadvisorApi "github.com/google/cadvisor/info/v1"
 	"github.com/prometheus/client_golang/prometheus"
@@ -99,6 +100,7 @@ type HostInterface interface {
 	GetPods() []*api.Pod
 	GetRunningPods() ([]*api.Pod, error)
 	GetPodByName(namespace, name string) (*api.Pod, bool)
+	GetPodVolumeMetrics(podUID types.UID) map[string]*volume.Metrics
 	RunInContainer(name string, uid types.UID, container string, cmd []string) ([]byte, error)
 	ExecInContainer(name string, uid types.UID, container string, cmd []string, in io.Reader, out, err io.WriteCloser, tty bool) error
 	AttachContainer(name string, uid types.UID, container string, in io.Reader, out, err io.WriteCloser, tty bool) error
@@ -705,6 +707,7 @@ func (s *Server) serveStats(w http.ResponseWriter, req *http.Request) {
 	// /stats/                                              : Root container stats
 	// /stats/container/                                    : Non-Kubernetes container stats (returns a map)
 	// /stats/<pod name>/<container name>                   : Stats for Kubernetes pod/container
+	// /stats/<namespace>/<pod name>/<uid>                  : Volume stats for Kubernetes namespace/pod/uid
 	// /stats/<namespace>/<pod name>/<uid>/<container name> : Stats for Kubernetes namespace/pod/uid/container
 	components := strings.Split(strings.TrimPrefix(path.Clean(req.URL.Path), "/"), "/")
 	var stats interface{}
@@ -745,6 +748,13 @@ func (s *Server) serveStats(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 		stats, err = s.host.GetContainerInfo(kubecontainer.GetPodFullName(pod), "", components[2], &cadvisorRequest)
+	case 4:
+		pod, ok := s.host.GetPodByName(components[1], components[2])
+		if !ok || pod.UID != types.UID(components[3]) {
+			http.Error(w, "Pod does not exist", http.StatusNotFound)
+			return
+		}
+		stats = s.host.GetPodVolumeMetrics(pod.UID)
 	case 5:
 		pod, ok := s.host.GetPodByName(components[1], components[2])
 		if !ok {
@@ -38,11 +38,13 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/httpstream"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/httpstream/spdy"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	cadvisorApi "github.com/google/cadvisor/info/v1"
 )
 
 type fakeKubelet struct {
 	podByNameFunc                      func(namespace, name string) (*api.Pod, bool)
+	podVolumeMetricsFunc               func(podUID types.UID) map[string]*volume.Metrics
 	containerInfoFunc                  func(podFullName string, uid types.UID, containerName string, req *cadvisorApi.ContainerInfoRequest) (*cadvisorApi.ContainerInfo, error)
 	rawInfoFunc                        func(query *cadvisorApi.ContainerInfoRequest) (map[string]*cadvisorApi.ContainerInfo, error)
 	machineInfoFunc                    func() (*cadvisorApi.MachineInfo, error)
@@ -73,6 +75,10 @@ func (fk *fakeKubelet) GetPodByName(namespace, name string) (*api.Pod, bool) {
 	return fk.podByNameFunc(namespace, name)
 }
 
+func (fk *fakeKubelet) GetPodVolumeMetrics(podUID types.UID) map[string]*volume.Metrics {
+	return fk.podVolumeMetricsFunc(podUID)
+}
+
 func (fk *fakeKubelet) GetContainerInfo(podFullName string, uid types.UID, containerName string, req *cadvisorApi.ContainerInfoRequest) (*cadvisorApi.ContainerInfo, error) {
 	return fk.containerInfoFunc(podFullName, uid, containerName, req)
 }
@@ -234,6 +240,54 @@ func TestContainerInfoWithUidNamespace(t *testing.T) {
 	}
 }
 
+func TestPodVolumeStats(t *testing.T) {
+	fw := newServerTest()
+	expectedNamespace := "custom"
+	podID := "somepod"
+	expectedUid := "9b01b80f-8fb4-11e4-95ab-4200af06647"
+	expectedMetrics := map[string]*volume.Metrics{
+		"secret": {Bytes: 10, Files: 2},
+	}
+	fw.fakeKubelet.podByNameFunc = func(namespace, name string) (*api.Pod, bool) {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				UID:       types.UID(expectedUid),
+			},
+		}, true
+	}
+	fw.fakeKubelet.podVolumeMetricsFunc = func(podUID types.UID) map[string]*volume.Metrics {
+		if string(podUID) != expectedUid {
+			t.Errorf("unexpected pod uid %v", podUID)
+		}
+		return expectedMetrics
+	}
+
+	resp, err := http.Get(fw.testHTTPServer.URL + fmt.Sprintf("/stats/%v/%v/%v", expectedNamespace, podID, expectedUid))
+	if err != nil {
+		t.Fatalf("Got error GETing: %v", err)
+	}
+	defer resp.Body.Close()
+	var receivedMetrics map[string]*volume.Metrics
+	err = json.NewDecoder(resp.Body).Decode(&receivedMetrics)
+	if err != nil {
+		t.Fatalf("received invalid json data: %v", err)
+	}
+	if !reflect.DeepEqual(receivedMetrics, expectedMetrics) {
+		t.Errorf("received wrong data: %#v", receivedMetrics)
+	}
+
+	resp, err = http.Get(fw.testHTTPServer.URL + fmt.Sprintf("/stats/%v/%v/%v", expectedNamespace, podID, "other"))
+	if err != nil {
+		t.Fatalf("Got error GETing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a pod with another uid not to be found, got status %v", resp.StatusCode)
+	}
+}
+
 func TestContainerNotFound(t *testing.T) {
 	fw := newServerTest()
 	podID := "somepod"
@@ -29,11 +29,15 @@ import (
 type volumeManager struct {
 	lock       sync.RWMutex
 	volumeMaps map[types.UID]kubecontainer.VolumeMap
+	// setUpStops holds, for each pod whose volumes have been set up, the
+	// channel that is closed to cancel their setup.
+	setUpStops map[types.UID]chan struct{}
 }
 
 func newVolumeManager() *volumeManager {
 	vm := &volumeManager{}
 	vm.volumeMaps = make(map[types.UID]kubecontainer.VolumeMap)
+	vm.setUpStops = make(map[types.UID]chan struct{})
 	return vm
 }
 
@@ -60,3 +64,29 @@ func (vm *volumeManager) DeleteVolumes(podUID types.UID) {
 	defer vm.lock.Unlock()
 	delete(vm.volumeMaps, podUID)
 }
+
+// GetSetUpStopChannel returns the channel that is closed to cancel the setup
+// of the volumes of a pod, once the pod is no longer wanted.
+func (vm *volumeManager) GetSetUpStopChannel(podUID types.UID) <-chan struct{} {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	stopCh, ok := vm.setUpStops[podUID]
+	if !ok {
+		stopCh = make(chan struct{})
+		vm.setUpStops[podUID] = stopCh
+	}
+	return stopCh
+}
+
+// CancelSetUps cancels the setup of the volumes of every pod that is not in
+// desiredPods.  A pod that is wanted again later gets a new channel.
+func (vm *volumeManager) CancelSetUps(desiredPods map[types.UID]empty) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	for podUID, stopCh := range vm.setUpStops {
+		if _, found := desiredPods[podUID]; !found {
+			close(stopCh)
+			delete(vm.setUpStops, podUID)
+		}
+	}
+}
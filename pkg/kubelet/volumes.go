@@ -17,12 +17,15 @@ limitations under the License.
 package kubelet
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"io/ioutil"
 	"path"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	apierrors "github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	kubecontainer "github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet/container"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
@@ -30,6 +33,7 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var errUnsupportedVolumeType = fmt.Errorf("unsupported volume type")
@@ -56,6 +60,31 @@ func (vh *volumeHost) GetKubeClient() client.Interface {
 	return vh.kubelet.kubeClient
 }
 
+func (vh *volumeHost) GetRecorder() record.EventRecorder {
+	return vh.kubelet.recorder
+}
+
+// GetSecretVolumeVerifyingKey returns the key that the secret volume plugin
+// verifies the signatures of secrets with.
+func (vh *volumeHost) GetSecretVolumeVerifyingKey() *rsa.PublicKey {
+	return vh.kubelet.secretVolumeVerifyingKey
+}
+
+// GetKubeClientForPod returns a client that authenticates with the token of
+// the service account of pod, for the secret volume plugin to read the
+// pod's secrets with.  It returns nil if the kubelet reads secrets with its
+// own credentials.
+func (vh *volumeHost) GetKubeClientForPod(pod *api.Pod) (client.Interface, error) {
+	return vh.kubelet.kubeClientForPod(pod)
+}
+
+// RegisterMetrics registers a volume plugin's metrics alongside the
+// kubelet's own.
+func (vh *volumeHost) RegisterMetrics(collector prometheus.Collector) error {
+	_, err := prometheus.RegisterOrGet(collector)
+	return err
+}
+
 func (vh *volumeHost) NewWrapperBuilder(spec *volume.Spec, pod *api.Pod, opts volume.VolumeOptions, mounter mount.Interface) (volume.Builder, error) {
 	b, err := vh.kubelet.newVolumeBuilderFromPlugins(spec, pod, opts, mounter)
 	if err == nil && b == nil {
@@ -99,6 +128,7 @@ func (kl *Kubelet) newVolumeBuilderFromPlugins(spec *volume.Spec, pod *api.Pod,
 
 func (kl *Kubelet) mountExternalVolumes(pod *api.Pod) (kubecontainer.VolumeMap, error) {
 	podVolumes := make(kubecontainer.VolumeMap)
+	currentVolumes, _ := kl.volumeManager.GetVolumes(pod.UID)
 	for i := range pod.Spec.Volumes {
 		volSpec := &pod.Spec.Volumes[i]
 
@@ -109,7 +139,7 @@ func (kl *Kubelet) mountExternalVolumes(pod *api.Pod) (kubecontainer.VolumeMap,
 
 		// Try to use a plugin for this volume.
 		internal := volume.NewSpecFromVolume(volSpec)
-		builder, err := kl.newVolumeBuilderFromPlugins(internal, pod, volume.VolumeOptions{rootContext}, kl.mounter)
+		builder, err := kl.newVolumeBuilderFromPlugins(internal, pod, volume.VolumeOptions{RootContext: rootContext}, kl.mounter)
 		if err != nil {
 			glog.Errorf("Could not create volume builder for pod %s: %v", pod.UID, err)
 			return nil, err
@@ -117,7 +147,21 @@ func (kl *Kubelet) mountExternalVolumes(pod *api.Pod) (kubecontainer.VolumeMap,
 		if builder == nil {
 			return nil, errUnsupportedVolumeType
 		}
-		err = builder.SetUp()
+		// A volume that is already set up is only brought up to date by a
+		// resync, if its builder can do that more cheaply than a setup.  A
+		// setup that can be canceled is given up once the pod is no longer
+		// wanted, rather than hold up its worker.
+		_, isSetUp := currentVolumes[volSpec.Name]
+		if reconciling, ok := builder.(volume.ReconcilingBuilder); ok && isSetUp {
+			err = reconciling.Reconcile()
+		} else if cancelable, ok := builder.(volume.CancelableBuilder); ok {
+			err = cancelable.SetUpAtWithCancel(builder.GetPath(), kl.volumeManager.GetSetUpStopChannel(pod.UID))
+		} else {
+			err = builder.SetUp()
+		}
+		if err == volume.ErrSetUpCanceled {
+			glog.V(3).Infof("Setup of volume %q for pod %s was canceled", volSpec.Name, pod.UID)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -126,6 +170,29 @@ func (kl *Kubelet) mountExternalVolumes(pod *api.Pod) (kubecontainer.VolumeMap,
 	return podVolumes, nil
 }
 
+// GetPodVolumeMetrics returns the usage of each volume of the given pod
+// that can report it, keyed by volume name.
+func (kl *Kubelet) GetPodVolumeMetrics(podUID types.UID) map[string]*volume.Metrics {
+	metrics := map[string]*volume.Metrics{}
+	podVolumes, ok := kl.volumeManager.GetVolumes(podUID)
+	if !ok {
+		return metrics
+	}
+	for name, vol := range podVolumes {
+		provider, ok := vol.(volume.MetricsProvider)
+		if !ok {
+			continue
+		}
+		m, err := provider.GetMetrics()
+		if err != nil {
+			glog.Errorf("Could not get metrics for volume %q of pod %s: %v", name, podUID, err)
+			continue
+		}
+		metrics[name] = m
+	}
+	return metrics
+}
+
 // getPodVolumesFromDisk examines directory structure to determine volumes that
 // are presently active and mounted. Returns a map of volume.Cleaner types.
 func (kl *Kubelet) getPodVolumesFromDisk() map[string]volume.Cleaner {
@@ -194,3 +261,77 @@ func (kl *Kubelet) newVolumeCleanerFromPlugins(kind string, name string, podUID
 	glog.V(3).Infof("Used volume plugin %q for %s/%s", plugin.Name(), podUID, kind)
 	return cleaner, nil
 }
+
+// podClient is a client made with the token of a service account.
+type podClient struct {
+	token  string
+	client client.Interface
+}
+
+// kubeClientForPod returns a client that authenticates with the token of the
+// service account of pod, or nil if the kubelet has no config to make one
+// from or the pod has no service account.  The token is read with the
+// kubelet's own client from the first secret the service account references
+// that is a token for it.  Clients are kept for reuse until the token of
+// their service account changes.
+func (kl *Kubelet) kubeClientForPod(pod *api.Pod) (client.Interface, error) {
+	if kl.secretVolumeClientConfig == nil || kl.kubeClient == nil || pod.Spec.ServiceAccountName == "" {
+		return nil, nil
+	}
+	token, err := kl.getServiceAccountToken(pod.Namespace, pod.Spec.ServiceAccountName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := pod.Namespace + "/" + pod.Spec.ServiceAccountName
+	kl.podClientsLock.Lock()
+	defer kl.podClientsLock.Unlock()
+	if c, found := kl.podClients[key]; found && c.token == token {
+		return c.client, nil
+	}
+	config := *kl.secretVolumeClientConfig
+	config.Username = ""
+	config.Password = ""
+	config.CertFile = ""
+	config.CertData = nil
+	config.KeyFile = ""
+	config.KeyData = nil
+	config.BearerToken = token
+	podKubeClient, err := client.New(&config)
+	if err != nil {
+		return nil, err
+	}
+	if kl.podClients == nil {
+		kl.podClients = make(map[string]podClient)
+	}
+	kl.podClients[key] = podClient{token: token, client: podKubeClient}
+	return podKubeClient, nil
+}
+
+// getServiceAccountToken returns the token of the service account with the
+// given namespace and name.
+func (kl *Kubelet) getServiceAccountToken(namespace, name string) (string, error) {
+	serviceAccount, err := kl.kubeClient.ServiceAccounts(namespace).Get(name)
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range serviceAccount.Secrets {
+		secret, err := kl.kubeClient.Secrets(namespace).Get(ref.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if secret.Type != api.SecretTypeServiceAccountToken || secret.Annotations[api.ServiceAccountNameKey] != serviceAccount.Name {
+			continue
+		}
+		if uid := secret.Annotations[api.ServiceAccountUIDKey]; len(uid) > 0 && uid != string(serviceAccount.UID) {
+			continue
+		}
+		if token := secret.Data[api.ServiceAccountTokenKey]; len(token) > 0 {
+			return string(token), nil
+		}
+	}
+	return "", fmt.Errorf("service account %s/%s has no token", namespace, name)
+}
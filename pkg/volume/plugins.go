@@ -23,6 +23,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/errors"
@@ -39,6 +40,11 @@ import (
 type VolumeOptions struct {
 	// The rootcontext to use when performing mounts for a volume.
 	RootContext string
+	// FSGroup, if set, is a supplemental group that should be given
+	// ownership of the volume's contents so that containers running as
+	// that group can access them.  Plugins which do not support it ignore
+	// it.
+	FSGroup *int64
 }
 
 // VolumePlugin is an interface to volume plugins that can be used on a
@@ -111,6 +117,11 @@ type VolumeHost interface {
 	// GetKubeClient returns a client interface
 	GetKubeClient() client.Interface
 
+	// GetRecorder returns an event recorder that plugins can use to record
+	// events about the pods whose volumes they manage.  The result may be
+	// nil if the host does not record events.
+	GetRecorder() record.EventRecorder
+
 	// NewWrapperBuilder finds an appropriate plugin with which to handle
 	// the provided spec.  This is used to implement volume plugins which
 	// "wrap" other plugins.  For example, the "secret" volume is
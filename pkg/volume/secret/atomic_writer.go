@@ -0,0 +1,800 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+	"github.com/golang/glog"
+)
+
+const (
+	// dataDirName is the name of the symlink in the target directory that
+	// points at the timestamped directory holding the current payload.
+	dataDirName = "..data"
+	// newDataDirName is the name of the temporary symlink that is renamed
+	// over dataDirName to publish a new payload.
+	newDataDirName = "..data_tmp"
+	// newFileName is the name of the temporary file that a copied file is
+	// written to before it is renamed over the file it replaces.
+	newFileName = "..file_tmp"
+	// dataDirMode is the mode of the directories created to hold payload
+	// files; they must be traversable by the consuming containers.
+	dataDirMode os.FileMode = 0755
+)
+
+// atomicWriter publishes a payload of files into a target directory so that
+// readers never observe a mix of old and new files.  The layout is:
+//
+//   <target>/..2015_01_01_12_00_00.123456789/  - the actual files
+//   <target>/..data -> ..2015_01_01_12_00_00.123456789
+//   <target>/<name> -> ..data/<name>            - one per top-level path
+//
+// A new payload is written into a fresh timestamped directory and published
+// by atomically renaming a new ..data symlink over the old one.  Because the
+// user-visible files are links through ..data, they all switch to the new
+// content at once.  Paths beginning with ".." are reserved for this
+// bookkeeping.
+//
+// If copyFiles is set, the files are instead written straight into the
+// target directory, with no data directory and no links.  Each file is
+// still replaced atomically, but readers may see a mix of old and new files
+// while a payload is written.
+type atomicWriter struct {
+	targetDir  string
+	logContext string
+	// fsGroup, if set, is the group given ownership of the files and
+	// directories the writer creates.
+	fsGroup *int64
+	// owned, if set, holds the paths of the payload last written to the
+	// target directory.  Only their top-level paths are pruned.
+	owned []string
+	// stopCh, if closed, aborts a write before it is published.
+	stopCh <-chan struct{}
+	// force, if set, makes a write publish the payload even if it matches
+	// the current contents, recreating every user-visible link.
+	force bool
+	// verify, if set, makes the writer read back every file it writes and
+	// fail the write if the file does not hold the data written.
+	verify bool
+	// exclusive, if set, makes a write fail rather than overwrite or keep a
+	// file it did not create: the target directory may only hold the
+	// writer's own entries, and files are created with O_EXCL.
+	exclusive bool
+	// copyFiles, if set, makes the writer write the files of the payload
+	// straight into the target directory rather than publish them through
+	// ..data.
+	copyFiles bool
+	// progress, if not nil, counts the files of the payload as they are
+	// written.
+	progress *progressCounter
+	// xattrStrict, if set, makes a write fail rather than leave the
+	// extended attributes of its files unset where the filesystem does not
+	// support them.
+	xattrStrict bool
+	// xattrsUnsupported is set once a write has found that the filesystem
+	// does not support extended attributes.
+	xattrsUnsupported bool
+	// changes holds the files changed by the last Write.
+	changes PayloadChanges
+	// changedPaths holds the paths of the files whose content the last
+	// Write added, updated or removed, sorted.
+	changedPaths []string
+}
+
+// PayloadChanges counts the files that publishing a payload changed,
+// compared to the files published before it.
+type PayloadChanges struct {
+	// Added is the number of files that were not published before.
+	Added int
+	// Updated is the number of files whose content changed.
+	Updated int
+	// ModeUpdated is the number of files whose content is unchanged but
+	// whose mode or ownership changed.
+	ModeUpdated int
+	// Removed is the number of files that are no longer published.
+	Removed int
+}
+
+// Changed returns true if any file was added, updated or removed.
+func (c PayloadChanges) Changed() bool {
+	return c != PayloadChanges{}
+}
+
+// readBack reads a file that was just written, for verification.  Tests
+// replace it to simulate a bad disk.
+var readBack = ioutil.ReadFile
+
+// openFile opens a file of the payload for writing.  Tests replace it to
+// simulate a write that fails partway through a payload.
+var openFile = os.OpenFile
+
+// newAtomicWriter returns an atomicWriter that writes into targetDir; the
+// logContext is prefixed to log messages.
+func newAtomicWriter(targetDir, logContext string) *atomicWriter {
+	return &atomicWriter{targetDir: targetDir, logContext: logContext}
+}
+
+// Write publishes payload into the target directory and removes any
+// previously published timestamped directories.
+func (w *atomicWriter) Write(payload map[string]fileProjection) error {
+	if err := validatePayload(payload); err != nil {
+		return err
+	}
+	if w.exclusive {
+		if err := w.checkNoForeignFiles(); err != nil {
+			glog.Errorf("%s: %v", w.logContext, err)
+			return err
+		}
+	}
+
+	w.changes = PayloadChanges{}
+	w.changedPaths = nil
+	w.xattrsUnsupported = false
+	changes, changedPaths, err := w.publishedChanges(payload, true)
+	if err != nil {
+		glog.Errorf("%s: error comparing payload to current contents of %v: %v", w.logContext, w.targetDir, err)
+		return err
+	}
+	if !w.force && !changes.Changed() {
+		glog.V(4).Infof("%s: no update required for %v", w.logContext, w.targetDir)
+		return nil
+	}
+
+	if w.copyFiles {
+		if err := w.writeCopies(payload); err != nil {
+			glog.Errorf("%s: error writing copies of payload to %v: %v", w.logContext, w.targetDir, err)
+			return err
+		}
+		w.changes, w.changedPaths = changes, changedPaths
+		return nil
+	}
+
+	// Files that are unchanged are carried over from the current data
+	// directory, if there is one, so they keep their modification times.
+	oldTsDir := ""
+	if oldTsDirName, err := os.Readlink(path.Join(w.targetDir, dataDirName)); err == nil {
+		oldTsDir = path.Join(w.targetDir, oldTsDirName)
+	} else if !os.IsNotExist(err) {
+		glog.Errorf("%s: error reading %v link in %v: %v", w.logContext, dataDirName, w.targetDir, err)
+		return err
+	}
+
+	tsDir, err := w.newTimestampDir()
+	if err != nil {
+		glog.Errorf("%s: error creating new data directory in %v: %v", w.logContext, w.targetDir, err)
+		return err
+	}
+	tsDirName := path.Base(tsDir)
+
+	if err := w.writePayloadToDir(payload, tsDir, oldTsDir); err != nil {
+		glog.Errorf("%s: error writing payload to %v: %v", w.logContext, tsDir, err)
+		os.RemoveAll(tsDir)
+		return err
+	}
+
+	if err := w.swapDataDir(tsDirName); err != nil {
+		glog.Errorf("%s: error publishing %v: %v", w.logContext, tsDir, err)
+		os.RemoveAll(tsDir)
+		return err
+	}
+
+	if err := w.createUserVisibleFiles(payload); err != nil {
+		glog.Errorf("%s: error creating visible links in %v: %v", w.logContext, w.targetDir, err)
+		return err
+	}
+
+	if err := w.removeUserVisibleFiles(payload); err != nil {
+		glog.Errorf("%s: error removing old visible links in %v: %v", w.logContext, w.targetDir, err)
+		return err
+	}
+
+	if err := w.removeStaleTimestampDirs(tsDirName); err != nil {
+		glog.Errorf("%s: error removing stale data directories in %v: %v", w.logContext, w.targetDir, err)
+		return err
+	}
+
+	w.changes, w.changedPaths = changes, changedPaths
+	return nil
+}
+
+// validatePayload checks that every path in payload can be written beneath
+// the target directory.
+func validatePayload(payload map[string]fileProjection) error {
+	for _, p := range payloadPaths(payload) {
+		if err := validatePayloadPath(p); err != nil {
+			return err
+		}
+		// A path cannot be both a file and the parent directory of
+		// another file.
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, found := payload[dir]; found {
+				return fmt.Errorf("invalid path %q: conflicts with file %q", p, dir)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePayloadPath rejects paths that are absolute, contain a '..'
+// element, collide with the writer's reserved '..' entries, or are not in
+// canonical form.  Together these ensure every path resolves to a location
+// beneath the target directory.
+func validatePayloadPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("invalid path: must not be empty")
+	}
+	if path.IsAbs(p) {
+		return fmt.Errorf("invalid path %q: must be relative", p)
+	}
+	if path.Clean(p) != p {
+		return fmt.Errorf("invalid path %q: must be in canonical form", p)
+	}
+	if strings.HasPrefix(p, "..") {
+		return fmt.Errorf("invalid path %q: must not start with '..'", p)
+	}
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return fmt.Errorf("invalid path %q: must not contain '..'", p)
+		}
+	}
+	return nil
+}
+
+// payloadChanged reports whether payload differs from the contents that
+// are currently published in the target directory.  A file whose mode or
+// ownership differs counts as changed, even if its content matches.
+func (w *atomicWriter) payloadChanged(payload map[string]fileProjection) (bool, error) {
+	return w.publishedDiffers(payload, true)
+}
+
+// metadataChanged is like payloadChanged, but only compares the modes and
+// ownership of the published files, not their content.
+func (w *atomicWriter) metadataChanged(payload map[string]fileProjection) (bool, error) {
+	return w.publishedDiffers(payload, false)
+}
+
+// publishedDiffers reports whether the files published in the target
+// directory differ from payload, comparing their content only if
+// compareData is set.
+func (w *atomicWriter) publishedDiffers(payload map[string]fileProjection, compareData bool) (bool, error) {
+	changes, _, err := w.publishedChanges(payload, compareData)
+	return changes.Changed(), err
+}
+
+// publishedChanges counts the files that publishing payload would change in
+// the target directory, and returns the sorted paths of those whose content
+// would be added, updated or removed.  Unless compareData is set, the
+// content of files is not compared, and a file whose mode or ownership is
+// unchanged is not counted as updated.
+func (w *atomicWriter) publishedChanges(payload map[string]fileProjection, compareData bool) (PayloadChanges, []string, error) {
+	var changes PayloadChanges
+	var changedPaths []string
+	dir, current, err := w.publishedFiles(payload)
+	if err != nil {
+		return changes, nil, err
+	}
+	for _, name := range payloadPaths(payload) {
+		file := payload[name]
+		if !current.Has(name) {
+			changes.Added++
+			changedPaths = append(changedPaths, name)
+			continue
+		}
+		p := path.Join(dir, name)
+		if compareData && !file.pipe {
+			unchanged, err := fileDataUnchanged(p, file.data)
+			if err != nil {
+				return changes, nil, err
+			}
+			if !unchanged {
+				changes.Updated++
+				changedPaths = append(changedPaths, name)
+				continue
+			}
+		}
+		unchanged, err := w.fileMetadataUnchanged(p, file)
+		if err != nil {
+			return changes, nil, err
+		}
+		if !unchanged {
+			changes.ModeUpdated++
+		}
+	}
+	for name := range current {
+		if _, found := payload[name]; !found {
+			changes.Removed++
+			changedPaths = append(changedPaths, name)
+		}
+	}
+	sort.Strings(changedPaths)
+	return changes, changedPaths, nil
+}
+
+// publishedFiles returns the directory holding the files published in the
+// target directory, and their paths relative to it; there are none if
+// nothing is published.  When files are copied, only those in payload or
+// among the owned paths count as published, since the target directory may
+// hold other files too.
+func (w *atomicWriter) publishedFiles(payload map[string]fileProjection) (string, util.StringSet, error) {
+	if w.copyFiles {
+		files, err := listFiles(w.targetDir)
+		if os.IsNotExist(err) {
+			return w.targetDir, nil, nil
+		} else if err != nil {
+			return "", nil, err
+		}
+		owned := util.NewStringSet(w.owned...)
+		for p := range files {
+			if _, found := payload[p]; !found && !owned.Has(p) {
+				files.Delete(p)
+			}
+		}
+		return w.targetDir, files, nil
+	}
+	tsDirName, err := os.Readlink(path.Join(w.targetDir, dataDirName))
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+	tsDir := path.Join(w.targetDir, tsDirName)
+	files, err := listFiles(tsDir)
+	return tsDir, files, err
+}
+
+// listFiles returns the paths, relative to dir, of the regular files and
+// named pipes beneath dir.
+func listFiles(dir string) (util.StringSet, error) {
+	files := util.NewStringSet()
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() || info.Mode()&os.ModeNamedPipe != 0 {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			files.Insert(rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// newTimestampDir creates a new, uniquely named directory in the target
+// directory to hold a payload.
+func (w *atomicWriter) newTimestampDir() (string, error) {
+	tsDir, err := ioutil.TempDir(w.targetDir, time.Now().Format("..2006_01_02_15_04_05."))
+	if err != nil {
+		return "", err
+	}
+	// TempDir creates the directory 0700, which containers running as
+	// other users could not traverse.
+	if err := w.setDirOwnership(tsDir); err != nil {
+		return "", err
+	}
+	return tsDir, nil
+}
+
+// setDirOwnership gives a directory created by the writer its mode and, if
+// an fsGroup is set, group ownership.  The setgid bit is set so that
+// anything created beneath it inherits the group.
+func (w *atomicWriter) setDirOwnership(dir string) error {
+	mode := dataDirMode
+	if w.fsGroup != nil {
+		if err := setFileOwner(dir, -1, int(*w.fsGroup)); err != nil {
+			return err
+		}
+		mode |= os.ModeSetgid
+	}
+	return setFileMode(dir, mode)
+}
+
+// mkdirAll creates dir and any missing parents beneath the target
+// directory, giving each the writer's directory ownership.
+func (w *atomicWriter) mkdirAll(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, dataDirMode); err != nil {
+		return err
+	}
+	return w.setDirOwnership(dir)
+}
+
+// writePayloadToDir writes every file in payload beneath dir, in the order
+// of their paths.  Files that are identical in oldDir, if it is set, are
+// hard linked rather than written, which preserves their modification
+// times.
+func (w *atomicWriter) writePayloadToDir(payload map[string]fileProjection, dir, oldDir string) error {
+	for _, name := range payloadPaths(payload) {
+		file := payload[name]
+		select {
+		case <-w.stopCh:
+			return volume.ErrSetUpCanceled
+		default:
+		}
+		hostFilePath := path.Join(dir, name)
+		if err := w.mkdirAll(path.Dir(hostFilePath)); err != nil {
+			return err
+		}
+		if oldDir != "" {
+			oldFilePath := path.Join(oldDir, name)
+			unchanged, err := w.fileUnchanged(oldFilePath, file)
+			if err != nil {
+				return err
+			}
+			if unchanged && os.Link(oldFilePath, hostFilePath) == nil {
+				glog.V(4).Infof("%s: %v is unchanged", w.logContext, hostFilePath)
+				w.progress.add(projectionSize(file))
+				continue
+			}
+		}
+		if err := w.writeProjection(hostFilePath, file); err != nil {
+			return err
+		}
+		w.progress.add(projectionSize(file))
+	}
+	return nil
+}
+
+// writeCopies writes the files of payload straight into the target
+// directory.  The owned files that are no longer in payload are removed
+// first, along with any directories they leave empty, since a new path may
+// need one of their names for a directory.  Each file that differs from
+// payload, or every file if force is set, is then written beside the
+// others and renamed over the file it replaces, in the order of their
+// paths.
+func (w *atomicWriter) writeCopies(payload map[string]fileProjection) error {
+	for _, name := range w.owned {
+		if _, found := payload[name]; found {
+			continue
+		}
+		p := path.Join(w.targetDir, name)
+		glog.V(3).Infof("%s: removing %v, which is no longer in the payload", w.logContext, p)
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if os.Remove(path.Join(w.targetDir, dir)) != nil {
+				break
+			}
+		}
+	}
+
+	newFilePath := path.Join(w.targetDir, newFileName)
+	for _, name := range payloadPaths(payload) {
+		file := payload[name]
+		select {
+		case <-w.stopCh:
+			return volume.ErrSetUpCanceled
+		default:
+		}
+		hostFilePath := path.Join(w.targetDir, name)
+		if !w.force {
+			unchanged, err := w.fileUnchanged(hostFilePath, file)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if unchanged {
+				glog.V(4).Infof("%s: %v is unchanged", w.logContext, hostFilePath)
+				w.progress.add(projectionSize(file))
+				continue
+			}
+		}
+		if err := w.mkdirAll(path.Dir(hostFilePath)); err != nil {
+			return err
+		}
+		// Clear out a temporary file left over from an interrupted write.
+		if err := os.Remove(newFilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := w.writeProjection(newFilePath, file); err != nil {
+			os.Remove(newFilePath)
+			return err
+		}
+		if err := os.Rename(newFilePath, hostFilePath); err != nil {
+			os.Remove(newFilePath)
+			return err
+		}
+		w.progress.add(projectionSize(file))
+	}
+	return nil
+}
+
+// writeProjection creates the file at p for file, with the content, mode
+// and ownership the writer gives it.
+func (w *atomicWriter) writeProjection(p string, file fileProjection) error {
+	if file.pipe {
+		// The data of a pipe is fed to its readers, not written.
+		glog.V(3).Infof("%s: creating named pipe %v", w.logContext, p)
+		if err := makePipe(p, file.mode); err != nil {
+			return err
+		}
+	} else {
+		glog.V(3).Infof("%s: writing %v bytes to %v", w.logContext, len(file.data), p)
+		if err := writeFile(p, file.data, file.mode, w.exclusive); err != nil {
+			return err
+		}
+	}
+	if uid, gid := w.fileOwner(file); uid != -1 || gid != -1 {
+		if err := setFileOwner(p, uid, gid); err != nil {
+			return err
+		}
+	}
+	// Creating the file honors the process umask, and an existing file
+	// keeps its old mode; set the mode explicitly so the file ends up with
+	// exactly the requested permissions whatever the umask of the kubelet.
+	// This comes after the chown, which may clear bits.
+	if err := setFileMode(p, file.mode); err != nil {
+		return err
+	}
+	if err := w.setExtendedAttributes(p, file); err != nil {
+		return err
+	}
+	if w.verify && !file.pipe {
+		if err := verifyFile(p, file.data); err != nil {
+			glog.Errorf("%s: %v", w.logContext, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes data to the file at p, creating it with mode if it does
+// not exist.  An existing file is truncated, unless exclusive is set, in
+// which case it is an error.
+func writeFile(p string, data []byte, mode os.FileMode, exclusive bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if exclusive {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	f, err := openFile(p, flags, mode)
+	if err != nil {
+		if exclusive && os.IsExist(err) {
+			return fmt.Errorf("unexpected file %v: it already exists", p)
+		}
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// checkNoForeignFiles checks that the target directory holds nothing but
+// entries the writer creates: names beginning with "..", and links through
+// ..data named after their target or, if files are copied, the top-level
+// names of the owned paths.  Anything else was put there by someone else,
+// for example between the teardown and the setup of a volume.
+func (w *atomicWriter) checkNoForeignFiles() error {
+	entries, err := ioutil.ReadDir(w.targetDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	owned := util.NewStringSet()
+	for _, p := range w.owned {
+		owned.Insert(strings.SplitN(p, "/", 2)[0])
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "..") || (w.copyFiles && owned.Has(name)) {
+			continue
+		}
+		p := path.Join(w.targetDir, name)
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Readlink(p); err == nil && target == path.Join(dataDirName, name) {
+				continue
+			}
+		}
+		return fmt.Errorf("unexpected file %v: it was not written by the secret volume plugin", p)
+	}
+	return nil
+}
+
+// verifyFile checks that the file at p holds data, comparing checksums of
+// the contents if their lengths match.
+func verifyFile(p string, data []byte) error {
+	actual, err := readBack(p)
+	if err != nil {
+		return fmt.Errorf("couldn't read back %v to verify it: %v", p, err)
+	}
+	if len(actual) != len(data) {
+		return fmt.Errorf("verification of %v failed: length mismatch: wrote %v bytes, read back %v", p, len(data), len(actual))
+	}
+	if sha256.Sum256(actual) != sha256.Sum256(data) {
+		return fmt.Errorf("verification of %v failed: content mismatch: checksum of the %v bytes read back differs from what was written", p, len(data))
+	}
+	return nil
+}
+
+// fileOwner returns the user and group the writer gives ownership of file,
+// or -1 for each that is left as the writer's own.  An owner set on the file
+// takes precedence over the fsGroup.
+func (w *atomicWriter) fileOwner(file fileProjection) (uid, gid int) {
+	uid, gid = -1, -1
+	if file.uid != nil {
+		uid = int(*file.uid)
+	}
+	if file.gid != nil {
+		gid = int(*file.gid)
+	} else if w.fsGroup != nil {
+		gid = int(*w.fsGroup)
+	}
+	return uid, gid
+}
+
+// fileUnchanged reports whether the file at p already has the content, mode
+// and ownership that the writer would give file.  A named pipe has no
+// content to compare.
+func (w *atomicWriter) fileUnchanged(p string, file fileProjection) (bool, error) {
+	unchanged, err := w.fileMetadataUnchanged(p, file)
+	if err != nil || !unchanged || file.pipe {
+		return unchanged, err
+	}
+	return fileDataUnchanged(p, file.data)
+}
+
+// fileDataUnchanged reports whether the file at p already holds data.
+func fileDataUnchanged(p string, data []byte) (bool, error) {
+	current, err := ioutil.ReadFile(p)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, data), nil
+}
+
+// fileMetadataUnchanged reports whether the file at p is a regular file, or
+// a named pipe if file is one, with the mode and ownership that the writer
+// would give file.
+func (w *atomicWriter) fileMetadataUnchanged(p string, file fileProjection) (bool, error) {
+	info, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	isPipe := info.Mode()&os.ModeType == os.ModeNamedPipe
+	if (file.pipe && !isPipe) || (!file.pipe && !info.Mode().IsRegular()) {
+		return false, nil
+	}
+	uid, gid := w.fileOwner(file)
+	return fileModeMatches(info, file.mode, uid, gid), nil
+}
+
+// swapDataDir atomically points the ..data symlink at tsDirName.
+func (w *atomicWriter) swapDataDir(tsDirName string) error {
+	newDataDirPath := path.Join(w.targetDir, newDataDirName)
+	// Clear out a temporary link left over from an interrupted write.
+	if err := os.Remove(newDataDirPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(tsDirName, newDataDirPath); err != nil {
+		return err
+	}
+	return os.Rename(newDataDirPath, path.Join(w.targetDir, dataDirName))
+}
+
+// createUserVisibleFiles creates a link through ..data for each top-level
+// path element in the payload that does not already have one.
+func (w *atomicWriter) createUserVisibleFiles(payload map[string]fileProjection) error {
+	for _, name := range topLevelNames(payload) {
+		visiblePath := path.Join(w.targetDir, name)
+		if _, err := os.Lstat(visiblePath); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Symlink(path.Join(dataDirName, name), visiblePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUserVisibleFiles removes the links for top-level paths that are no
+// longer part of the payload.  Only links which point through ..data, and
+// which belong to the owned paths if they are known, are removed, so
+// entries this writer did not create are left alone.
+func (w *atomicWriter) removeUserVisibleFiles(payload map[string]fileProjection) error {
+	keep := util.NewStringSet(topLevelNames(payload)...)
+	var owned util.StringSet
+	if w.owned != nil {
+		owned = util.NewStringSet()
+		for _, p := range w.owned {
+			owned.Insert(strings.SplitN(p, "/", 2)[0])
+		}
+	}
+	entries, err := ioutil.ReadDir(w.targetDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "..") || keep.Has(name) || entry.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if owned != nil && !owned.Has(name) {
+			continue
+		}
+		visiblePath := path.Join(w.targetDir, name)
+		target, err := os.Readlink(visiblePath)
+		if err != nil {
+			return err
+		}
+		if target != path.Join(dataDirName, name) {
+			continue
+		}
+		glog.V(3).Infof("%s: removing %v, which is no longer in the payload", w.logContext, visiblePath)
+		if err := os.Remove(visiblePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleTimestampDirs removes every timestamped data directory except
+// the one named current.
+func (w *atomicWriter) removeStaleTimestampDirs(current string) error {
+	entries, err := ioutil.ReadDir(w.targetDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, "..") || name == current {
+			continue
+		}
+		glog.V(4).Infof("%s: removing stale data directory %v", w.logContext, name)
+		if err := os.RemoveAll(path.Join(w.targetDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topLevelNames returns the distinct first path elements of the paths in
+// payload.
+func topLevelNames(payload map[string]fileProjection) []string {
+	names := util.NewStringSet()
+	for p := range payload {
+		names.Insert(strings.SplitN(p, "/", 2)[0])
+	}
+	return names.List()
+}
@@ -0,0 +1,73 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestAtomicWriterIgnoresUmask(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+	// The umask is process-wide; restore it before other tests run.
+	defer syscall.Umask(syscall.Umask(0277))
+
+	payload := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar"), mode: 0640},
+		"nested/baz": {data: []byte("baz"), mode: 0755},
+	}
+	checkModes := func(when string) {
+		for name, file := range payload {
+			info, err := os.Stat(path.Join(dir, name))
+			if err != nil {
+				t.Errorf("%s: couldn't stat %v: %v", when, name, err)
+				continue
+			}
+			if info.Mode() != file.mode {
+				t.Errorf("%s: expected %v to have mode %v, got %v", when, name, file.mode, info.Mode())
+			}
+		}
+		info, err := os.Stat(path.Join(dir, "nested"))
+		if err != nil {
+			t.Fatalf("%s: couldn't stat nested: %v", when, err)
+		}
+		if info.Mode() != os.ModeDir|dataDirMode {
+			t.Errorf("%s: expected nested to have mode %v, got %v", when, os.ModeDir|dataDirMode, info.Mode())
+		}
+	}
+
+	w := newAtomicWriter(dir, "test")
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkModes("write")
+
+	// Unchanged files are carried over to the new data directory with
+	// their modes; changed ones are written afresh.
+	payload["foo"] = fileProjection{data: []byte("updated"), mode: 0444}
+	payload["bar"] = fileProjection{data: []byte("bar"), mode: 0604}
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("Unexpected error updating payload: %v", err)
+	}
+	checkModes("update")
+}
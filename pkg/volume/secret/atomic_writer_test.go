@@ -0,0 +1,501 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWriterDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("/tmp", "atomic_writer_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp dir: %v", err)
+	}
+	return dir
+}
+
+func checkPayload(t *testing.T, dir string, payload map[string]fileProjection) {
+	for name, file := range payload {
+		actual, err := ioutil.ReadFile(path.Join(dir, name))
+		if err != nil {
+			t.Errorf("Couldn't read %v: %v", name, err)
+			continue
+		}
+		if string(actual) != string(file.data) {
+			t.Errorf("Unexpected content for %v; expected %q, got %q", name, file.data, actual)
+		}
+	}
+}
+
+func timestampDirs(t *testing.T, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Couldn't read %v: %v", dir, err)
+	}
+	dirs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "..") {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs
+}
+
+func TestAtomicWriterWrite(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	first := map[string]fileProjection{
+		"foo":         {data: []byte("foo-1"), mode: 0444},
+		"nested/bar":  {data: []byte("bar-1"), mode: 0400},
+		"nested/baz":  {data: []byte("baz-1"), mode: 0444},
+		".dotted-key": {data: []byte("dot-1"), mode: 0444},
+	}
+	if err := writer.Write(first); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, first)
+
+	for _, name := range []string{"foo", "nested", ".dotted-key"} {
+		info, err := os.Lstat(path.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", name, err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Expected %v to be a symlink, got mode %v", name, info.Mode())
+		}
+	}
+	info, err := os.Stat(path.Join(dir, "nested/bar"))
+	if err != nil {
+		t.Fatalf("Couldn't stat nested/bar: %v", err)
+	}
+	if info.Mode().Perm() != 0400 {
+		t.Errorf("Unexpected mode for nested/bar; expected 0400, got %v", info.Mode().Perm())
+	}
+
+	firstTarget, err := os.Readlink(path.Join(dir, dataDirName))
+	if err != nil {
+		t.Fatalf("Couldn't read %v link: %v", dataDirName, err)
+	}
+
+	second := map[string]fileProjection{
+		"foo":         {data: []byte("foo-2"), mode: 0444},
+		"nested/bar":  {data: []byte("bar-2"), mode: 0400},
+		"nested/baz":  {data: []byte("baz-2"), mode: 0444},
+		".dotted-key": {data: []byte("dot-2"), mode: 0444},
+	}
+	if err := writer.Write(second); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, second)
+
+	secondTarget, err := os.Readlink(path.Join(dir, dataDirName))
+	if err != nil {
+		t.Fatalf("Couldn't read %v link: %v", dataDirName, err)
+	}
+	if firstTarget == secondTarget {
+		t.Errorf("Expected %v to point at a new directory, still points at %v", dataDirName, firstTarget)
+	}
+	if dirs := timestampDirs(t, dir); len(dirs) != 1 || dirs[0] != secondTarget {
+		t.Errorf("Expected only %v to remain, got %v", secondTarget, dirs)
+	}
+	if _, err := os.Lstat(path.Join(dir, newDataDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected %v to be gone, got: %v", newDataDirName, err)
+	}
+}
+
+func TestAtomicWriterRemovesDeletedPaths(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	first := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar"), mode: 0444},
+		"nested/baz": {data: []byte("baz"), mode: 0444},
+	}
+	if err := writer.Write(first); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+
+	// Entries the writer did not create must survive a prune.
+	if err := ioutil.WriteFile(path.Join(dir, "foreign"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Couldn't write foreign file: %v", err)
+	}
+	if err := os.Symlink("/tmp", path.Join(dir, "foreign-link")); err != nil {
+		t.Fatalf("Couldn't write foreign link: %v", err)
+	}
+
+	second := map[string]fileProjection{
+		"foo": {data: []byte("foo"), mode: 0444},
+		"qux": {data: []byte("qux"), mode: 0444},
+	}
+	if err := writer.Write(second); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, second)
+
+	for _, name := range []string{"bar", "nested"} {
+		if _, err := os.Lstat(path.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %v to be removed, got: %v", name, err)
+		}
+	}
+	for _, name := range []string{"foreign", "foreign-link", dataDirName} {
+		if _, err := os.Lstat(path.Join(dir, name)); err != nil {
+			t.Errorf("Expected %v to be left alone, got: %v", name, err)
+		}
+	}
+}
+
+func TestAtomicWriterInvalidPaths(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	for _, p := range []string{"", "/etc/passwd", "..data", "..foo", "a/../../b", "../b", "./a", "a//b", "a/", "a/./b"} {
+		payload := map[string]fileProjection{p: {data: []byte("x"), mode: 0444}}
+		if err := writer.Write(payload); err == nil {
+			t.Errorf("Expected an error for path %q", p)
+		}
+	}
+	if dirs := timestampDirs(t, dir); len(dirs) != 0 {
+		t.Errorf("Expected nothing to be written, got %v", dirs)
+	}
+}
+
+func TestAtomicWriterConflictingPaths(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	payload := map[string]fileProjection{
+		"a":         {data: []byte("a"), mode: 0444},
+		"a/b/c.pem": {data: []byte("c"), mode: 0444},
+	}
+	if err := writer.Write(payload); err == nil {
+		t.Errorf("Expected an error for a file that is also a directory")
+	}
+	if dirs := timestampDirs(t, dir); len(dirs) != 0 {
+		t.Errorf("Expected nothing to be written, got %v", dirs)
+	}
+}
+
+func TestAtomicWriterPreservesUnchangedFiles(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	first := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar-1"), mode: 0444},
+		"nested/baz": {data: []byte("baz"), mode: 0444},
+		"qux":        {data: []byte("qux"), mode: 0444},
+	}
+	if err := writer.Write(first); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for name := range first {
+		if err := os.Chtimes(path.Join(dir, name), past, past); err != nil {
+			t.Fatalf("Couldn't set times of %v: %v", name, err)
+		}
+	}
+
+	second := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar-2"), mode: 0444},
+		"nested/baz": {data: []byte("baz"), mode: 0444},
+		"qux":        {data: []byte("qux"), mode: 0400},
+	}
+	if err := writer.Write(second); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, second)
+
+	for name, preserved := range map[string]bool{"foo": true, "nested/baz": true, "bar": false, "qux": false} {
+		info, err := os.Stat(path.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", name, err)
+		}
+		if preserved && !info.ModTime().Equal(past) {
+			t.Errorf("Expected %v to keep its modification time %v, got %v", name, past, info.ModTime())
+		}
+		if !preserved && info.ModTime().Equal(past) {
+			t.Errorf("Expected %v to be rewritten", name)
+		}
+		if info.Mode().Perm() != second[name].mode {
+			t.Errorf("Unexpected mode for %v; expected %v, got %v", name, second[name].mode, info.Mode().Perm())
+		}
+	}
+}
+
+func TestAtomicWriterVerify(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+	defer func(orig func(string) ([]byte, error)) { readBack = orig }(readBack)
+
+	w := newAtomicWriter(dir, "test")
+	w.verify = true
+	payload := map[string]fileProjection{"foo": {data: []byte("foo"), mode: 0644}}
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing a verified payload: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		readBack func(data []byte) []byte
+		expected string
+	}{
+		{"truncated", func(data []byte) []byte { return data[:len(data)-1] }, "length mismatch"},
+		{"corrupted", func(data []byte) []byte { return append([]byte{data[0] ^ 0xff}, data[1:]...) }, "content mismatch"},
+	}
+	for _, tc := range testCases {
+		corrupt := tc.readBack
+		readBack = func(p string) ([]byte, error) {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			return corrupt(data), nil
+		}
+		bad := map[string]fileProjection{"foo": {data: []byte(tc.name), mode: 0644}}
+		err := w.Write(bad)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) || !strings.Contains(err.Error(), "foo") {
+			t.Errorf("%s: expected a %s error naming the file, got: %v", tc.name, tc.expected, err)
+		}
+		// The failed write is cleaned up and the previous payload is kept.
+		if dirs := timestampDirs(t, dir); len(dirs) != 1 {
+			t.Errorf("%s: expected only the published data directory, got %v", tc.name, dirs)
+		}
+		checkPayload(t, dir, payload)
+	}
+}
+
+func TestAtomicWriterExclusive(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	w := newAtomicWriter(dir, "test")
+	w.exclusive = true
+	payload := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0644},
+		"nested/bar": {data: []byte("bar"), mode: 0644},
+	}
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing to an empty directory: %v", err)
+	}
+	// The writer's own entries are not foreign.
+	payload["foo"] = fileProjection{data: []byte("updated"), mode: 0644}
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("Unexpected error updating the payload: %v", err)
+	}
+	checkPayload(t, dir, payload)
+
+	testCases := []struct {
+		name    string
+		tamper  func() error
+		foreign string
+	}{
+		{"stray file", func() error { return ioutil.WriteFile(path.Join(dir, "stray"), []byte("x"), 0644) }, "stray"},
+		{"replaced link", func() error {
+			if err := os.Remove(path.Join(dir, "foo")); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path.Join(dir, "foo"), []byte("evil"), 0644)
+		}, "foo"},
+		{"redirected link", func() error {
+			if err := os.Remove(path.Join(dir, "nested")); err != nil {
+				return err
+			}
+			return os.Symlink("/etc", path.Join(dir, "nested"))
+		}, "nested"},
+	}
+	for _, tc := range testCases {
+		if err := tc.tamper(); err != nil {
+			t.Fatalf("%s: couldn't tamper with the directory: %v", tc.name, err)
+		}
+		err := w.Write(map[string]fileProjection{"foo": {data: []byte(tc.name), mode: 0644}})
+		if err == nil || !strings.Contains(err.Error(), path.Join(dir, tc.foreign)) {
+			t.Errorf("%s: expected an error naming %v, got: %v", tc.name, tc.foreign, err)
+		}
+		os.Remove(path.Join(dir, tc.foreign))
+	}
+
+	// A file that exists is never overwritten.
+	p := path.Join(dir, "..existing")
+	if err := ioutil.WriteFile(p, []byte("old"), 0644); err != nil {
+		t.Fatalf("Couldn't write %v: %v", p, err)
+	}
+	if err := writeFile(p, []byte("new"), 0644, true); err == nil || !strings.Contains(err.Error(), p) {
+		t.Errorf("Expected an error naming the existing file, got: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(p); string(data) != "old" {
+		t.Errorf("Expected the existing file to be kept, got %q", data)
+	}
+}
+
+func TestAtomicWriterChanges(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	payload := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"nested/bar": {data: []byte("bar"), mode: 0444},
+		"baz":        {data: []byte("baz"), mode: 0444},
+	}
+	if err := writer.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	if e, a := (PayloadChanges{Added: 3}), writer.changes; e != a {
+		t.Errorf("Expected changes %+v for the first write, got %+v", e, a)
+	}
+
+	if err := writer.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	if writer.changes.Changed() {
+		t.Errorf("Expected no changes for an unchanged payload, got %+v", writer.changes)
+	}
+
+	payload = map[string]fileProjection{
+		"foo":        {data: []byte("foo-2"), mode: 0444},
+		"nested/bar": {data: []byte("bar"), mode: 0400},
+		"qux":        {data: []byte("qux"), mode: 0444},
+	}
+	if err := writer.Write(payload); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	if e, a := (PayloadChanges{Added: 1, Updated: 1, ModeUpdated: 1, Removed: 1}), writer.changes; e != a {
+		t.Errorf("Expected changes %+v, got %+v", e, a)
+	}
+}
+
+func TestAtomicWriterCopyFiles(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	writer := newAtomicWriter(dir, "test")
+	writer.copyFiles = true
+	first := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar"), mode: 0444},
+		"nested/baz": {data: []byte("baz"), mode: 0400},
+	}
+	if err := writer.Write(first); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, first)
+	if e, a := (PayloadChanges{Added: 3}), writer.changes; e != a {
+		t.Errorf("Expected changes %+v for the first write, got %+v", e, a)
+	}
+	for name, file := range first {
+		info, err := os.Lstat(path.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", name, err)
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm() != file.mode {
+			t.Errorf("Expected %v to be a plain file with mode %v, got %v", name, file.mode, info.Mode())
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Couldn't read %v: %v", dir, err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			t.Errorf("Expected no entries of the writer's own, got %v", entry.Name())
+		}
+	}
+
+	writer.owned = payloadPaths(first)
+	if err := writer.Write(first); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	if writer.changes.Changed() {
+		t.Errorf("Expected no changes for an unchanged payload, got %+v", writer.changes)
+	}
+
+	// Entries the writer did not create must survive a prune.
+	if err := ioutil.WriteFile(path.Join(dir, "foreign"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Couldn't write foreign file: %v", err)
+	}
+	fooInfo, err := os.Stat(path.Join(dir, "foo"))
+	if err != nil {
+		t.Fatalf("Couldn't stat foo: %v", err)
+	}
+	second := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0444},
+		"bar":        {data: []byte("bar-2"), mode: 0444},
+		"nested":     {data: []byte("nested"), mode: 0444},
+		"qux/nested": {data: []byte("qux"), mode: 0444},
+	}
+	if err := writer.Write(second); err != nil {
+		t.Fatalf("Unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, second)
+	if e, a := (PayloadChanges{Added: 2, Updated: 1, Removed: 1}), writer.changes; e != a {
+		t.Errorf("Expected changes %+v, got %+v", e, a)
+	}
+	if info, err := os.Stat(path.Join(dir, "foo")); err != nil || !os.SameFile(fooInfo, info) {
+		t.Errorf("Expected an unchanged file to be left in place, got %v", err)
+	}
+	if _, err := os.Lstat(path.Join(dir, "foreign")); err != nil {
+		t.Errorf("Expected foreign to be left alone, got: %v", err)
+	}
+	if _, err := os.Lstat(path.Join(dir, newFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file to be left behind, got %v", err)
+	}
+}
+
+func TestAtomicWriterWriteOrder(t *testing.T) {
+	dir := newTestWriterDir(t)
+	defer os.RemoveAll(dir)
+
+	payload := map[string]fileProjection{
+		"foo":        {data: []byte("foo"), mode: 0644},
+		"bar":        {data: []byte("bar"), mode: 0644},
+		"nested/baz": {data: []byte("baz"), mode: 0644},
+		"nested/abc": {data: []byte("abc"), mode: 0644},
+		"zed":        {data: []byte("zed"), mode: 0644},
+	}
+	var written []string
+	defer func(orig func(string, int, os.FileMode) (*os.File, error)) { openFile = orig }(openFile)
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		// Drop the timestamped directory the file is written in.
+		rel := strings.TrimPrefix(name, dir+"/")
+		written = append(written, strings.SplitN(rel, "/", 2)[1])
+		return os.OpenFile(name, flag, perm)
+	}
+
+	w := newAtomicWriter(dir, "test")
+	if err := w.Write(payload); err != nil {
+		t.Fatalf("unexpected error writing payload: %v", err)
+	}
+	checkPayload(t, dir, payload)
+	if expected := payloadPaths(payload); !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected files to be written in the order %v, got %v", expected, written)
+	}
+}
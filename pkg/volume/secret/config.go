@@ -0,0 +1,212 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	utilerrors "github.com/GoogleCloudPlatform/kubernetes/pkg/util/errors"
+)
+
+// Config is the node-wide policy for secret volumes, set when the plugin is
+// registered.  Volume hosts that implement the corresponding optional
+// interfaces override it.
+type Config struct {
+	// DefaultFileMode is the mode secret files are written with when the
+	// volume source does not specify one.  Only permission bits may be set.
+	DefaultFileMode os.FileMode
+	// MaxSize is the largest total size, in bytes, of a secret that will be
+	// written to a volume.  Must be positive.
+	MaxSize int
+	// MaxKeys is the largest number of keys a secret may hold to be
+	// written to a volume, to catch a secret that has grown far beyond
+	// what its pods expect before it fills the node.  Zero is no limit.
+	// Must not be negative.
+	MaxKeys int
+	// GetRetries is the number of times a retryable error from the API
+	// server is retried when fetching a secret.  Must not be negative.
+	GetRetries int
+	// GetBackoff is the wait before the first retry of a fetch; it doubles
+	// for each retry after that.  Must be positive if GetRetries is.
+	GetBackoff time.Duration
+	// UseCache serves secrets from a cache kept up to date by watching the
+	// API server, rather than getting them for every setup.
+	UseCache bool
+	// VerifyWrites reads back every file written to a volume and fails the
+	// setup if it does not hold what was written, at the cost of reading
+	// the payload again.
+	VerifyWrites bool
+	// ExclusiveWrites fails a setup that finds a file in the volume that
+	// the plugin did not write, rather than overwriting or keeping it, to
+	// catch tampering and leftovers.  The error names the file.
+	ExclusiveWrites bool
+	// NotFoundGracePeriod is how long a setup keeps polling for a secret
+	// that does not exist yet before failing, for secrets created just
+	// after the pods that use them.  Zero fails at once.  Must not be
+	// negative.
+	NotFoundGracePeriod time.Duration
+	// OmitPodNameLabel leaves the pod name out of the labels of volume
+	// metrics, so that usage can only be aggregated by namespace and
+	// secret but the number of label values stays bounded.
+	OmitPodNameLabel bool
+	// LimitTmpfsSize limits the tmpfs of each memory-backed volume to the
+	// space its files need, plus TmpfsSizeMargin, rather than leaving it
+	// to grow as large as the node's memory allows.  The limit is raised
+	// or lowered whenever the files are updated.
+	LimitTmpfsSize bool
+	// TmpfsSizeMargin is the number of bytes a limited tmpfs holds beyond
+	// what the files of its volume need.  Must not be negative.
+	TmpfsSizeMargin int64
+	// WarnOnExecBit logs a warning and records an event on the pod when a
+	// volume is set up with a file whose mode has an execute bit set.
+	// Secrets are rarely executables, but some hold scripts, so this is
+	// off by default.
+	WarnOnExecBit bool
+	// RejectExecBit fails such a setup instead.
+	RejectExecBit bool
+	// AllowHostDirectories lets volumes that name a host directory write
+	// their files beneath it, rather than fail to set up.  Experimental.
+	AllowHostDirectories bool
+	// HostDirectories are the host directories that volumes may name, by
+	// name.  Each name must be a DNS label, each path must be an absolute
+	// path in canonical form, and there must be at least one if
+	// AllowHostDirectories is set.
+	HostDirectories map[string]string
+	// AllowFileOverlays lets volumes whose pods set a file overlay bind
+	// the file read-only over the existing file it targets, rather than
+	// fail to set up.  The node must support bind mounts of single files.
+	// Experimental.
+	AllowFileOverlays bool
+	// AllowSocketDelivery lets volumes whose pods set socket delivery serve
+	// their secret once over a unix socket in the volume, rather than fail
+	// to set up.  Experimental.
+	AllowSocketDelivery bool
+	// SocketDeliveryTimeout is how long the socket of such a volume waits
+	// for its reader, and then for the reader to take the secret, before
+	// it is removed.  Zero waits until the volume is torn down.  Must not
+	// be negative.
+	SocketDeliveryTimeout time.Duration
+	// HostDirectoryPrefixes are the directories that the targets of file
+	// overlays must be beneath.  Each must be an absolute path in
+	// canonical form, and there must be at least one if AllowFileOverlays
+	// is set.
+	HostDirectoryPrefixes []string
+	// AllowedNamespaces, if not empty, are the only namespaces whose
+	// secrets may be read, so that a node dedicated to some tenants cannot
+	// be made to read the secrets of others by a misconfigured pod.  Empty
+	// allows every namespace.  Each must be a valid namespace name.
+	AllowedNamespaces []string
+	// ResyncPeriod, if positive, has each volume refresh its files from
+	// its secrets about that often on its own, so that rotated secrets
+	// reach pods within a bounded time however slowly the kubelet syncs.
+	// Must not be negative.
+	ResyncPeriod time.Duration
+	// ResyncJitter lengthens each wait between resyncs by a random amount
+	// of up to ResyncJitter times ResyncPeriod, so that the volumes of
+	// many pods do not all get their secrets at once.  Must not be
+	// negative.
+	ResyncJitter float64
+}
+
+// defaultResyncJitter is the default ResyncJitter.
+const defaultResyncJitter = 0.5
+
+// defaultSocketDeliveryTimeout is the default SocketDeliveryTimeout.
+const defaultSocketDeliveryTimeout = 5 * time.Minute
+
+// defaultTmpfsSizeMargin is the default TmpfsSizeMargin.
+const defaultTmpfsSizeMargin = 64 * 1024
+
+// DefaultConfig returns the configuration used by ProbeVolumePlugins.
+func DefaultConfig() Config {
+	return Config{
+		DefaultFileMode: defaultFileMode,
+		// Volumes are memory-backed, so by default limit them to the
+		// largest secret the API server accepts.
+		MaxSize:               api.MaxSecretSize,
+		GetRetries:            defaultGetSecretRetries,
+		GetBackoff:            defaultGetSecretBackoff,
+		TmpfsSizeMargin:       defaultTmpfsSizeMargin,
+		SocketDeliveryTimeout: defaultSocketDeliveryTimeout,
+		ResyncJitter:          defaultResyncJitter,
+	}
+}
+
+// Validate returns an error describing every invalid field of c.
+func (c Config) Validate() error {
+	errs := []error{}
+	if c.DefaultFileMode&^os.ModePerm != 0 {
+		errs = append(errs, fmt.Errorf("invalid default file mode %v: only permission bits may be set", c.DefaultFileMode))
+	}
+	if c.MaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("invalid max size %v: must be positive", c.MaxSize))
+	}
+	if c.MaxKeys < 0 {
+		errs = append(errs, fmt.Errorf("invalid max keys %v: must not be negative", c.MaxKeys))
+	}
+	if c.GetRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid get retries %v: must not be negative", c.GetRetries))
+	}
+	if c.GetRetries > 0 && c.GetBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("invalid get backoff %v: must be positive when retrying", c.GetBackoff))
+	}
+	if c.NotFoundGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("invalid not found grace period %v: must not be negative", c.NotFoundGracePeriod))
+	}
+	if c.TmpfsSizeMargin < 0 {
+		errs = append(errs, fmt.Errorf("invalid tmpfs size margin %v: must not be negative", c.TmpfsSizeMargin))
+	}
+	if c.AllowHostDirectories && len(c.HostDirectories) == 0 {
+		errs = append(errs, fmt.Errorf("invalid host directories: at least one is required when host directories are allowed"))
+	}
+	for name, p := range c.HostDirectories {
+		if !util.IsDNS1123Label(name) {
+			errs = append(errs, fmt.Errorf("invalid host directory name %q: must be a DNS label", name))
+		}
+		if !path.IsAbs(p) || path.Clean(p) != p {
+			errs = append(errs, fmt.Errorf("invalid host directory %q: must be an absolute path in canonical form", p))
+		}
+	}
+	if c.AllowFileOverlays && len(c.HostDirectoryPrefixes) == 0 {
+		errs = append(errs, fmt.Errorf("invalid host directory prefixes: at least one is required when file overlays are allowed"))
+	}
+	for _, prefix := range c.HostDirectoryPrefixes {
+		if !path.IsAbs(prefix) || path.Clean(prefix) != prefix {
+			errs = append(errs, fmt.Errorf("invalid host directory prefix %q: must be an absolute path in canonical form", prefix))
+		}
+	}
+	for _, namespace := range c.AllowedNamespaces {
+		if !util.IsDNS1123Label(namespace) {
+			errs = append(errs, fmt.Errorf("invalid allowed namespace %q: must be a valid namespace name", namespace))
+		}
+	}
+	if c.SocketDeliveryTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid socket delivery timeout %v: must not be negative", c.SocketDeliveryTimeout))
+	}
+	if c.ResyncPeriod < 0 {
+		errs = append(errs, fmt.Errorf("invalid resync period %v: must not be negative", c.ResyncPeriod))
+	}
+	if c.ResyncJitter < 0 {
+		errs = append(errs, fmt.Errorf("invalid resync jitter %v: must not be negative", c.ResyncJitter))
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,63 @@
+// +build !windows
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// defaultMedium is the storage medium secret volumes are backed with unless
+// the host asks otherwise.  Memory keeps secrets off of disk.
+const defaultMedium = api.StorageMediumMemory
+
+// makePipe creates a named pipe at p with mode.
+func makePipe(p string, mode os.FileMode) error {
+	if err := syscall.Mkfifo(p, uint32(mode)); err != nil {
+		return &os.PathError{Op: "mkfifo", Path: p, Err: err}
+	}
+	return nil
+}
+
+// setFileOwner gives ownership of the file at p to uid and gid, leaving
+// either unchanged if it is -1.
+func setFileOwner(p string, uid, gid int) error {
+	return os.Chown(p, uid, gid)
+}
+
+// setFileMode gives the file or directory at p exactly mode.
+func setFileMode(p string, mode os.FileMode) error {
+	return os.Chmod(p, mode)
+}
+
+// fileModeMatches reports whether info describes a file with the
+// permissions of mode and, for each of uid and gid that is not -1, that
+// owner.
+func fileModeMatches(info os.FileInfo, mode os.FileMode, uid, gid int) bool {
+	if info.Mode().Perm() != mode {
+		return false
+	}
+	if uid == -1 && gid == -1 {
+		return true
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && (uid == -1 || int(stat.Uid) == uid) && (gid == -1 || int(stat.Gid) == gid)
+}
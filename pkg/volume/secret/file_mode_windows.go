@@ -0,0 +1,85 @@
+// +build windows
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/exec"
+)
+
+// defaultMedium is the storage medium secret volumes are backed with unless
+// the host asks otherwise.  Windows has no tmpfs, so they are kept on disk.
+const defaultMedium = api.StorageMediumDefault
+
+// The well-known SIDs that access to the files of a volume is granted to.
+const (
+	sidLocalSystem    = "*S-1-5-18"
+	sidAdministrators = "*S-1-5-32-544"
+	sidUsers          = "*S-1-5-32-545"
+)
+
+// makePipe fails: Windows has no named pipes on the filesystem.
+func makePipe(p string, mode os.FileMode) error {
+	return &os.PathError{Op: "mkfifo", Path: p, Err: errors.New("named pipes are not supported on Windows")}
+}
+
+// setFileOwner does nothing: files have no POSIX owner on Windows, and
+// access is granted through the ACL set by setFileMode instead.
+func setFileOwner(p string, uid, gid int) error {
+	return nil
+}
+
+// setFileMode replaces the ACL of the file or directory at p with the
+// grants that stand for mode.  It does not chmod p: that would only set the
+// read-only attribute, which stops the kubelet from replacing or removing
+// the file.
+func setFileMode(p string, mode os.FileMode) error {
+	args := append([]string{p, "/inheritance:r", "/grant:r"}, aclGrants(mode)...)
+	if out, err := exec.New().Command("icacls", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("icacls %v: %v: %s", p, err, out)
+	}
+	return nil
+}
+
+// aclGrants returns the icacls grants that stand for mode.  The local system
+// and administrators, as whom the kubelet runs, keep full control so that
+// the volume can be updated and torn down.  If the group or others may read
+// mode, every user is granted read-only access, which includes traversal if
+// they may also execute it.  Write permissions are never granted.
+func aclGrants(mode os.FileMode) []string {
+	grants := []string{sidLocalSystem + ":(F)", sidAdministrators + ":(F)"}
+	switch {
+	case mode&0044 == 0:
+	case mode&0011 != 0:
+		grants = append(grants, sidUsers+":(RX)")
+	default:
+		grants = append(grants, sidUsers+":(R)")
+	}
+	return grants
+}
+
+// fileModeMatches always reports a match: the ACL of a file is not read
+// back, and its mode bits only reflect the read-only attribute.
+func fileModeMatches(info os.FileInfo, mode os.FileMode, uid, gid int) bool {
+	return true
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+// freeSpaceDetector knows how much space is free for writing files.
+type freeSpaceDetector interface {
+	// AvailableBytes returns the number of bytes that may still be written
+	// to the filesystem holding path.  known is false if the platform
+	// cannot tell.
+	AvailableBytes(path string) (bytes int64, known bool, err error)
+}
+
+// newFreeSpaceDetector returns a new freeSpaceDetector.
+func newFreeSpaceDetector() freeSpaceDetector {
+	return &realFreeSpaceDetector{}
+}
@@ -0,0 +1,34 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"syscall"
+)
+
+type realFreeSpaceDetector struct{}
+
+func (_ *realFreeSpaceDetector) AvailableBytes(path string) (int64, bool, error) {
+	buf := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &buf); err != nil {
+		return 0, false, fmt.Errorf("statfs(%q): %v", path, err)
+	}
+	return int64(buf.Bavail) * int64(buf.Bsize), true, nil
+}
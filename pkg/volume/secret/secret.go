@@ -17,58 +17,519 @@ limitations under the License.
 package secret
 
 import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	volumeutil "github.com/GoogleCloudPlatform/kubernetes/pkg/volume/util"
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ProbeVolumePlugin is the entry point for plugin detection in a package.
 func ProbeVolumePlugins() []volume.VolumePlugin {
-	return []volume.VolumePlugin{&secretPlugin{}}
+	plugins, _ := ProbeVolumePluginsWithConfig(DefaultConfig())
+	return plugins
 }
 
+// ProbeVolumePluginsWithConfig is like ProbeVolumePlugins, but the plugin
+// applies cfg.  It returns an error if cfg is invalid.
+func ProbeVolumePluginsWithConfig(cfg Config) ([]volume.VolumePlugin, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid secret volume configuration: %v", err)
+	}
+	return []volume.VolumePlugin{&secretPlugin{config: cfg}}, nil
+}
+
+// escapedPluginName is the name of the plugin as escaped for the directories
+// of its volumes.  Every path of a volume is built from it, so that they
+// cannot drift apart and leave directories that the plugin no longer finds.
+var escapedPluginName = util.EscapeQualifiedNameForDisk(secretPluginName)
+
 const (
 	secretPluginName = "kubernetes.io/secret"
+
+	// defaultFileMode is the mode secret files are written with when
+	// neither the volume source nor the configuration specifies one.
+	defaultFileMode os.FileMode = 0444
+
+	// defaultDirMode is the mode of the volume root when the host does
+	// not specify one.
+	defaultDirMode os.FileMode = 0755
+
+	// secretEventInterval is the minimum time between events recorded for
+	// a volume whose secret cannot be retrieved.
+	secretEventInterval = 5 * time.Minute
+
+	// isMountPointRetries is the number of times a transient error from
+	// IsMountPoint is retried, waiting isMountPointRetryInterval between
+	// attempts.
+	isMountPointRetries       = 3
+	isMountPointRetryInterval = 50 * time.Millisecond
+
+	// defaultGetSecretRetries is the number of times a retryable error
+	// from the API server is retried by default.
+	// The wait before the first retry is defaultGetSecretBackoff, and it
+	// doubles for each retry after that.
+	defaultGetSecretRetries = 3
+	defaultGetSecretBackoff = 100 * time.Millisecond
+
+	// permanentErrorRetryAfter and transientErrorRetryAfter are the waits
+	// suggested before retrying a setup that failed to get a secret because
+	// of a permanent error, such as a missing secret, or a transient one.
+	permanentErrorRetryAfter = time.Minute
+	transientErrorRetryAfter = 5 * time.Second
+
+	// notFoundPollInterval is how often a missing secret is polled for
+	// during the not found grace period.
+	notFoundPollInterval = time.Second
 )
 
+// ErrNoKubeClient is returned when a secret volume cannot be set up because
+// the volume host has no client for the API server, and no other getter of
+// secrets.  Retrying will not help.
+var ErrNoKubeClient = fmt.Errorf("kube client is not configured")
+
+// maxSizeHost is implemented by volume hosts that override the maximum
+// total size of the data in a secret volume.
+type maxSizeHost interface {
+	// GetSecretVolumeMaxSize returns the maximum size, in bytes.
+	GetSecretVolumeMaxSize() int
+}
+
+// mediumHost is implemented by volume hosts that back secret volumes with a
+// storage medium other than memory.
+type mediumHost interface {
+	// GetSecretVolumeMedium returns the medium of the EmptyDir that secret
+	// volumes are written to.
+	GetSecretVolumeMedium() api.StorageMedium
+}
+
+// dirModeHost is implemented by volume hosts that override the mode of the
+// root directory of secret volumes.
+type dirModeHost interface {
+	// GetSecretVolumeDirMode returns the mode of the volume root.
+	GetSecretVolumeDirMode() os.FileMode
+}
+
+// retryHost is implemented by volume hosts that override how often secret
+// volumes retry fetching a secret after a retryable API server error.
+type retryHost interface {
+	// GetSecretVolumeRetryPolicy returns the number of retries and the
+	// wait before the first retry, which doubles for each retry after it.
+	GetSecretVolumeRetryPolicy() (retries int, backoff time.Duration)
+}
+
 // secretPlugin implements the VolumePlugin interface.
 type secretPlugin struct {
-	host volume.VolumeHost
+	host   volume.VolumeHost
+	clock  util.Clock
+	config Config
+	// fileMode is the mode of secret files when the volume source does not
+	// specify one.
+	fileMode os.FileMode
+	// maxSize is the largest total size, in bytes, of a secret that will
+	// be written to a volume.
+	maxSize int
+	// medium is the storage medium backing secret volumes.
+	medium api.StorageMedium
+	// dirMode is the mode of the volume root.
+	dirMode os.FileMode
+	// getRetries and getBackoff control the retries of retryable errors
+	// when fetching a secret.
+	getRetries int
+	getBackoff time.Duration
+	// notFoundPoll is how often a missing secret is polled for during the
+	// not found grace period.
+	notFoundPoll time.Duration
+	// allowedNamespaces, if not nil, are the only namespaces whose secrets
+	// may be read.
+	allowedNamespaces util.StringSet
+	// getter, if not nil, gets secrets in place of the API server.
+	getter SecretGetter
+	// cache, if not nil, serves secrets before falling back to the API
+	// server.
+	cache *secretCache
+	// fallback, if not nil, holds node-local copies of secrets for use when
+	// the API server cannot be reached.
+	fallback *secretFallback
+	// setupLatency, if not nil, records the latency of setups.
+	setupLatency *prometheus.SummaryVec
+	// auditEvents, if not nil, queues audit events for the host's audit
+	// sink.  auditNode is the node named in them.
+	auditEvents chan AuditEvent
+	auditNode   string
+	// changeQueue, if not nil, queues changes for the host's change hook.
+	changeQueue chan VolumeChange
+	// progressHook, if not nil, is called with the progress of writes.
+	progressHook ProgressHook
+	// verifyingKey, if not nil, is the key that volumes verifying
+	// signatures check secrets against.
+	verifyingKey *rsa.PublicKey
+
+	// lastEvent holds the time an event was last recorded for each volume
+	// whose secret could not be retrieved, keyed by volumeKey.
+	eventLock sync.Mutex
+	lastEvent map[string]time.Time
+
+	// locks holds a lock for each volume that is being set up, keyed by
+	// volumeKey.
+	locksLock sync.Mutex
+	locks     map[string]*volumeLock
+
+	// pipes holds the feeders of the named pipes of each volume, keyed by
+	// volumeKey and then by path.
+	pipesLock sync.Mutex
+	pipes     map[string]map[string]*pipeFeeder
+
+	// sockets holds the socket server of each volume delivered over a
+	// socket, keyed by volumeKey.
+	socketsLock sync.Mutex
+	sockets     map[string]*socketServer
+
+	// resyncs holds the periodic resync of each volume that has one, keyed
+	// by volumeKey.
+	resyncsLock sync.Mutex
+	resyncs     map[string]*volumeResync
+}
+
+// volumeLock serializes setup of a single volume.  refs counts the callers
+// holding or waiting for the lock, so it can be dropped when unused.
+// cancel, if not nil, is closed to cancel the setup holding the lock.  refs
+// and cancel are guarded by the plugin's locksLock.
+type volumeLock struct {
+	sync.Mutex
+	refs   int
+	cancel chan struct{}
 }
 
 var _ volume.VolumePlugin = &secretPlugin{}
 
 func (plugin *secretPlugin) Init(host volume.VolumeHost) {
 	plugin.host = host
+	plugin.clock = util.RealClock{}
+	plugin.lastEvent = map[string]time.Time{}
+	plugin.locks = map[string]*volumeLock{}
+	plugin.pipes = map[string]map[string]*pipeFeeder{}
+	plugin.sockets = map[string]*socketServer{}
+	plugin.resyncs = map[string]*volumeResync{}
+	plugin.fileMode = plugin.config.DefaultFileMode
+	plugin.maxSize = plugin.config.MaxSize
+	if h, ok := host.(maxSizeHost); ok {
+		plugin.maxSize = h.GetSecretVolumeMaxSize()
+	}
+	// Keep secrets off of disk unless the host asks otherwise, or the
+	// platform has no memory-backed medium.
+	plugin.medium = defaultMedium
+	if h, ok := host.(mediumHost); ok {
+		plugin.medium = h.GetSecretVolumeMedium()
+	}
+	plugin.dirMode = defaultDirMode
+	if h, ok := host.(dirModeHost); ok {
+		plugin.dirMode = h.GetSecretVolumeDirMode()
+	}
+	plugin.getRetries, plugin.getBackoff = plugin.config.GetRetries, plugin.config.GetBackoff
+	plugin.notFoundPoll = notFoundPollInterval
+	if h, ok := host.(retryHost); ok {
+		plugin.getRetries, plugin.getBackoff = h.GetSecretVolumeRetryPolicy()
+	}
+	plugin.initAllowedNamespaces()
+	plugin.initSecretGetter()
+	plugin.initSecretCache()
+	plugin.initSecretFallback()
+	plugin.initMetrics()
+	plugin.initAudit()
+	plugin.initChangeHook()
+	plugin.initProgressHook()
+	plugin.initVerifyingKey()
 }
 
 func (plugin *secretPlugin) Name() string {
 	return secretPluginName
 }
 
+// CanSupport is true for secret volumes and for projected volumes that
+// project a secret.
 func (plugin *secretPlugin) CanSupport(spec *volume.Spec) bool {
-	return spec.VolumeSource.Secret != nil
+	return secretSource(spec) != nil
 }
 
 func (plugin *secretPlugin) NewBuilder(spec *volume.Spec, pod *api.Pod, opts volume.VolumeOptions, mounter mount.Interface) (volume.Builder, error) {
-	return &secretVolumeBuilder{
-		secretVolume: &secretVolume{spec.Name, pod.UID, plugin, mounter},
-		secretName:   spec.VolumeSource.Secret.SecretName,
+	if err := validateProjection(spec); err != nil {
+		return nil, fmt.Errorf("invalid secret volume %v: %v", spec.Name, err)
+	}
+	if err := validateSources(secretSource(spec)); err != nil {
+		return nil, fmt.Errorf("invalid secret volume %v: %v", spec.Name, err)
+	}
+	return plugin.newBuilder(spec, pod, opts, mounter), nil
+}
+
+func (plugin *secretPlugin) newBuilder(spec *volume.Spec, pod *api.Pod, opts volume.VolumeOptions, mounter mount.Interface) *secretVolumeBuilder {
+	source := secretSource(spec)
+	b := &secretVolumeBuilder{
+		secretVolume: &secretVolume{volName: spec.Name, podUID: pod.UID, plugin: plugin, mounter: mounter},
+		secretName:   source.SecretName,
+		selector:     source.SecretSelector,
+		items:        source.Items,
+		defaultMode:  source.DefaultMode,
+		optional:     source.Optional != nil && *source.Optional,
+		sources:      source.Sources,
+		wipe:         source.WipeOnTeardown,
+		sanitize:     source.SanitizeKeyNames,
+		onlyKeyPath:  source.SingleKeyTargetPath,
+		bundlePath:   source.BundlePath,
+		bundleSep:    bundleSeparator(source),
+		archivePath:  source.ArchivePath,
+		envFile:      source.EnvFile,
+		contentHash:  source.WriteContentHash,
+		manifest:     source.WriteManifest,
+		plainDir:     source.PlainDirectory,
+		hostDirName:  source.HostDirectory,
+		nonEmpty:     source.RequireNonEmpty,
+		strictKeys:   source.StrictKeys,
+		bestEffort:   source.BestEffort,
+		typeCheck:    source.TypeCheck,
+		copyFiles:    source.ProjectionStrategy == api.SecretProjectionCopy,
+		verifySig:    source.VerifySignature,
+		xattrStrict:  source.StrictExtendedAttributes,
 		pod:          *pod,
-		opts:         &opts}, nil
+		opts:         &opts,
+		chconRunner:  newChconRunner(),
+		freeSpace:    newFreeSpaceDetector()}
+	b.metricLabels = plugin.metricLabels(pod, b.metricSecretName())
+	b.kubeClient = plugin.podKubeClient(pod)
+	if b.hostDirName != "" {
+		// Resolve the host directory once and write only beneath the
+		// result, which setups check it still resolves to.
+		if b.hostDir = b.loadHostDir(); b.hostDir == "" {
+			b.hostDir, _ = plugin.checkHostDir(b.hostDirName)
+		}
+	}
+	b.applyOptionAnnotations(source)
+	return b
+}
+
+// ValidateSpec checks that a secret volume with the given spec would set up
+// cleanly for pod, without writing anything to disk.  The secret must exist
+// unless the volume is optional, every key referenced by the items must be
+// present, no two items may share a path, items may only set an owner if
+// the kubelet can chown, the modes must be legal, and the secret must be
+// within the size limit.
+func (plugin *secretPlugin) ValidateSpec(spec *volume.Spec, pod *api.Pod) error {
+	if err := plugin.checkSpec(spec); err != nil {
+		return err
+	}
+	if err := plugin.checkNamespace(pod.Namespace); err != nil {
+		return err
+	}
+	b := plugin.newBuilder(spec, pod, volume.VolumeOptions{}, nil)
+	// The file overlay and delivery mode are set by annotations of the pod.
+	if _, err := plugin.checkFileOverlay(b.overlay); err != nil {
+		return err
+	}
+	if err := b.checkSocketDelivery(); err != nil {
+		return err
+	}
+
+	getter := b.getSecretGetter()
+	if getter == nil {
+		return ErrNoKubeClient
+	}
+	_, _, _, _, err := b.getPayload(getter, nil, nil)
+	return err
+}
+
+// EstimateSize returns an estimate of the bytes that a secret volume with
+// the given spec would take up for pod, without setting it up: the size of
+// the data of its secrets, plus a page for each of their keys, which covers
+// rounding every file up to whole pages on a tmpfs.  Every key is counted,
+// even if the items of the volume project only some of them, and a missing
+// optional secret takes up nothing.
+func (plugin *secretPlugin) EstimateSize(spec *volume.Spec, pod *api.Pod) (int64, error) {
+	if err := plugin.checkSpec(spec); err != nil {
+		return 0, err
+	}
+	if err := plugin.checkNamespace(pod.Namespace); err != nil {
+		return 0, err
+	}
+	b := plugin.newBuilder(spec, pod, volume.VolumeOptions{}, nil)
+
+	getter := b.getSecretGetter()
+	if getter == nil {
+		return 0, ErrNoKubeClient
+	}
+	pageSize := int64(os.Getpagesize())
+	size := int64(0)
+	for _, sb := range b.sourceBuilders() {
+		secret, err := sb.getSecret(getter, nil)
+		if err != nil {
+			return 0, err
+		}
+		if secret == nil {
+			continue
+		}
+		size += int64(totalSecretBytes(secret)) + int64(len(secret.Data))*pageSize
+	}
+	return size, nil
+}
+
+// checkSpec returns an error if spec is not that of a secret volume the
+// plugin can set up, regardless of the contents of its secrets.
+func (plugin *secretPlugin) checkSpec(spec *volume.Spec) error {
+	if !plugin.CanSupport(spec) {
+		return fmt.Errorf("volume %v is not a secret volume", spec.Name)
+	}
+	if err := validateProjection(spec); err != nil {
+		return err
+	}
+	if _, err := plugin.checkHostDir(secretSource(spec).HostDirectory); err != nil {
+		return err
+	}
+	return validateSources(secretSource(spec))
+}
+
+// volumeKey identifies a volume of a pod in the plugin's bookkeeping.
+func volumeKey(podUID types.UID, volName string) string {
+	return fmt.Sprintf("%v/%v", podUID, volName)
+}
+
+// lockVolume blocks until the caller holds the setup lock for the volume
+// identified by key, and returns a function that releases it.
+func (plugin *secretPlugin) lockVolume(key string) func() {
+	plugin.locksLock.Lock()
+	lock, found := plugin.locks[key]
+	if !found {
+		lock = &volumeLock{}
+		plugin.locks[key] = lock
+	}
+	lock.refs++
+	plugin.locksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		plugin.locksLock.Lock()
+		defer plugin.locksLock.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(plugin.locks, key)
+		}
+	}
+}
+
+// lockForSetUp is like lockVolume, for a setup that a teardown of the volume
+// may cancel: the returned channel is closed by cancelSetUp while the caller
+// holds the lock.
+func (plugin *secretPlugin) lockForSetUp(key string) (func(), <-chan struct{}) {
+	unlock := plugin.lockVolume(key)
+	canceled := make(chan struct{})
+	plugin.locksLock.Lock()
+	plugin.locks[key].cancel = canceled
+	plugin.locksLock.Unlock()
+	return func() {
+		plugin.locksLock.Lock()
+		plugin.locks[key].cancel = nil
+		plugin.locksLock.Unlock()
+		unlock()
+	}, canceled
+}
+
+// cancelSetUp cancels the setup holding the lock of the volume identified by
+// key, if there is one.
+func (plugin *secretPlugin) cancelSetUp(key string) {
+	plugin.locksLock.Lock()
+	defer plugin.locksLock.Unlock()
+	if lock, found := plugin.locks[key]; found && lock.cancel != nil {
+		close(lock.cancel)
+		lock.cancel = nil
+	}
+}
+
+// mergeStop returns a channel that is closed once stopCh or canceled is,
+// and a function that releases the channel once it is no longer needed.
+func mergeStop(stopCh, canceled <-chan struct{}) (<-chan struct{}, func()) {
+	if stopCh == nil {
+		return canceled, func() {}
+	}
+	// A channel that is already closed is seen as closed straight away.
+	select {
+	case <-stopCh:
+		return stopCh, func() {}
+	default:
+	}
+	merged := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stopCh:
+		case <-canceled:
+		case <-done:
+			return
+		}
+		close(merged)
+	}()
+	return merged, func() { close(done) }
 }
 
 func (plugin *secretPlugin) NewCleaner(volName string, podUID types.UID, mounter mount.Interface) (volume.Cleaner, error) {
-	return &secretVolumeCleaner{&secretVolume{volName, podUID, plugin, mounter}}, nil
+	sv := &secretVolume{volName: volName, podUID: podUID, plugin: plugin, mounter: mounter}
+	sv.hostDir = sv.loadHostDir()
+	return &secretVolumeCleaner{sv}, nil
+}
+
+// GetVolumesForPod returns the names of the secret volumes on disk for the
+// pod with the given UID, sorted.  Only directories that the plugin keeps
+// bookkeeping for are returned; anything else in the pod's secret volume
+// dir is skipped.  A pod without secret volumes has none.
+func (plugin *secretPlugin) GetVolumesForPod(podUID types.UID) ([]string, error) {
+	dir := DataDir(plugin.host, podUID, "")
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			glog.V(4).Infof("Skipping non-directory %v in secret volume dir %v", entry.Name(), dir)
+			continue
+		}
+		sv := &secretVolume{volName: entry.Name(), podUID: podUID, plugin: plugin}
+		if _, err := os.Stat(sv.getMetaDir()); err != nil {
+			glog.V(4).Infof("Skipping %v in secret volume dir %v: not a secret volume: %v", entry.Name(), dir, err)
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
 }
 
 type secretVolume struct {
@@ -76,12 +537,104 @@ type secretVolume struct {
 	podUID  types.UID
 	plugin  *secretPlugin
 	mounter mount.Interface
+	// hostDir, if set, is the resolved path of the host directory that the
+	// files of the volume are written beneath in place of its own
+	// directory.
+	hostDir string
+
+	// metrics is the usage of the payload written by the last successful
+	// setup.
+	metrics volume.Metrics
+	// metricLabels are the labels of metrics; they are only known to
+	// builders.
+	metricLabels map[string]string
 }
 
 var _ volume.Volume = &secretVolume{}
+var _ volume.MetricsProvider = &secretVolume{}
+
+// DataDir returns the directory that host sets up the secret volume named
+// volName of the pod with the given UID at, which holds its files.
+func DataDir(host volume.VolumeHost, podUID types.UID, volName string) string {
+	return host.GetPodVolumeDir(podUID, escapedPluginName, volName)
+}
+
+// MetaDir returns the directory holding the plugin's bookkeeping for the
+// secret volume named volName of the pod with the given UID on host, such
+// as its readiness file.
+func MetaDir(host volume.VolumeHost, podUID types.UID, volName string) string {
+	return path.Join(host.GetPodPluginDir(podUID, escapedPluginName), volName)
+}
 
+// GetPath returns the directory holding the files of the volume: its
+// directory beneath its host directory if it has one, or else its own
+// directory.  This is where the volume is mounted into containers; see
+// GetDataPath for the directory the files currently live in.
 func (sv *secretVolume) GetPath() string {
-	return sv.plugin.host.GetPodVolumeDir(sv.podUID, util.EscapeQualifiedNameForDisk(secretPluginName), sv.volName)
+	if sv.hostDir != "" {
+		return path.Join(sv.hostDir, sv.hostDirVolumeName())
+	}
+	return DataDir(sv.plugin.host, sv.podUID, sv.volName)
+}
+
+// GetDataPath returns the directory that currently holds the files of the
+// volume.  Where the files in GetPath are links through the ..data symlink,
+// this is the timestamped directory it points at.  Its files are never
+// changed: an update publishes a new directory and then removes this one,
+// so tools that read it, such as backups, see a consistent snapshot, and
+// should resolve the path again if it disappears partway.  Where the files
+// are written in place, such as with the Copy projection strategy, it is
+// the same as GetPath.
+func (sv *secretVolume) GetDataPath() (string, error) {
+	dir := sv.GetPath()
+	target, err := os.Readlink(path.Join(dir, dataDirName))
+	if os.IsNotExist(err) {
+		return dir, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, target), nil
+}
+
+// getMetaDir returns the directory holding the plugin's bookkeeping for the
+// volume, such as its readiness file.
+func (sv *secretVolume) getMetaDir() string {
+	return MetaDir(sv.plugin.host, sv.podUID, sv.volName)
+}
+
+// GetMetrics returns the total size and number of the files projected into
+// the volume by the last successful setup.  It does not touch the disk.
+func (sv *secretVolume) GetMetrics() (*volume.Metrics, error) {
+	metrics := sv.metrics
+	if sv.metricLabels != nil {
+		metrics.Labels = make(map[string]string, len(sv.metricLabels))
+		for k, v := range sv.metricLabels {
+			metrics.Labels[k] = v
+		}
+	}
+	return &metrics, nil
+}
+
+// Labels of the metrics of secret volumes.
+const (
+	metricLabelNamespace = "namespace"
+	metricLabelPod       = "pod"
+	metricLabelSecret    = "secret"
+)
+
+// metricLabels returns the labels attributing the usage of the volume of
+// the secret named secretName to pod.  The pod name is left out if the
+// plugin is configured to omit it.
+func (plugin *secretPlugin) metricLabels(pod *api.Pod, secretName string) map[string]string {
+	labels := map[string]string{
+		metricLabelNamespace: pod.Namespace,
+		metricLabelSecret:    secretName,
+	}
+	if !plugin.config.OmitPodNameLabel {
+		labels[metricLabelPod] = pod.Name
+	}
+	return labels
 }
 
 // secretVolumeBuilder handles retrieving secrets from the API server
@@ -90,115 +643,1860 @@ type secretVolumeBuilder struct {
 	*secretVolume
 
 	secretName string
-	pod        api.Pod
-	opts       *volume.VolumeOptions
+	// selector, if not empty, selects the secret of the volume in place of
+	// a name; secretName then holds the name it last resolved to.
+	selector    map[string]string
+	items       []api.KeyToPath
+	defaultMode *int
+	optional    bool
+	// sources are the secrets projected into the volume besides its own.
+	sources     []api.SecretProjection
+	wipe        bool
+	sanitize    bool
+	onlyKeyPath string
+	bundlePath  string
+	bundleSep   string
+	archivePath string
+	envFile     *api.EnvFileProjection
+	contentHash bool
+	manifest    bool
+	plainDir    bool
+	// hostDirName is the name of the host directory configured on the
+	// node that the volume is written beneath, if it has one.
+	hostDirName string
+	nonEmpty    bool
+	strictKeys  bool
+	bestEffort  bool
+	typeCheck   api.SecretTypeCheck
+	copyFiles   bool
+	overlay     *fileOverlay
+	// pipes are the paths of the files of the volume that are served
+	// through named pipes instead.
+	pipes util.StringSet
+	// socket, if set, serves the secret over a unix socket in the volume
+	// instead of writing its files.
+	socket      bool
+	verifySig   bool
+	xattrStrict bool
+	pod         api.Pod
+	opts        *volume.VolumeOptions
+	chconRunner chconRunner
+	freeSpace   freeSpaceDetector
+	// kubeClient reads the secrets of the volume with the credentials of
+	// its pod; if nil, the node's client is used.
+	kubeClient client.Interface
+	// writtenPaths holds the paths written by the last successful setup
+	// through this builder, sorted.
+	writtenPaths []string
+	// changes holds the files changed by the last successful setup or
+	// refresh through this builder.
+	changes PayloadChanges
+	// skippedKeys holds the keys of the items that a best-effort volume
+	// skipped when it last got its secrets, sorted.
+	skippedKeys []string
+	// noRemountRefresh, if set, leaves a volume that is already set up as
+	// it is when it is set up again, rather than refreshing its files.
+	noRemountRefresh bool
 }
 
-var _ volume.Builder = &secretVolumeBuilder{}
+var _ volume.CancelableBuilder = &secretVolumeBuilder{}
+var _ volume.ReconcilingBuilder = &secretVolumeBuilder{}
 
 func (b *secretVolumeBuilder) SetUp() error {
 	return b.SetUpAt(b.GetPath())
 }
 
-// This is the spec for the volume that this plugin wraps.
-var wrappedVolumeSpec = &volume.Spec{
-	Name:         "not-used",
-	VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{Medium: api.StorageMediumMemory}},
+// wrappedVolumeSpec returns the spec for the EmptyDir wrapped by the secret
+// volume volName.  The EmptyDir keeps its bookkeeping under its own name, so
+// it is named after the secret volume, which is unique within the pod, to
+// keep that of the secret volumes of a pod apart.
+func wrappedVolumeSpec(volName string, medium api.StorageMedium) *volume.Spec {
+	return &volume.Spec{
+		Name:         volName,
+		VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{Medium: medium}},
+	}
+}
+
+// isMemoryBacked returns true if the volume is a tmpfs mount of its own,
+// rather than a directory on the node's disk.  A plain directory volume, or
+// one with a host directory, is never memory-backed.
+func (b *secretVolumeBuilder) isMemoryBacked() bool {
+	return !b.plainDir && b.hostDir == "" && b.plugin.medium == api.StorageMediumMemory
 }
 
-func (b *secretVolumeBuilder) getMetaDir() string {
-	return path.Join(b.plugin.host.GetPodPluginDir(b.podUID, util.EscapeQualifiedNameForDisk(secretPluginName)), b.volName)
+// fileMode returns the mode that secret files should be written with when
+// no per-item mode is given.
+func (b *secretVolumeBuilder) fileMode() (os.FileMode, error) {
+	if b.defaultMode == nil {
+		return b.plugin.fileMode, nil
+	}
+	return toFileMode(*b.defaultMode)
 }
 
-func (b *secretVolumeBuilder) SetUpAt(dir string) error {
-	isMnt, err := b.mounter.IsMountPoint(dir)
-	// Getting an os.IsNotExist err from is a contingency; the directory
-	// may not exist yet, in which case, setup should run.
-	if err != nil && !os.IsNotExist(err) {
+// canChown returns true if the kubelet may give files to any owner.
+var canChown = func() bool {
+	return os.Geteuid() == 0
+}
+
+// validateSources checks a secret volume source and each of its additional
+// sources for problems that do not depend on the contents of the secrets,
+// such as a missing secret name or bad items.
+func validateSources(source *api.SecretVolumeSource) error {
+	if len(source.SecretSelector) > 0 {
+		if source.SecretName != "" {
+			return fmt.Errorf("secretSelector may not be set together with secretName")
+		}
+		for key, value := range source.SecretSelector {
+			if !util.IsQualifiedName(key) || !util.IsValidLabelValue(value) {
+				return fmt.Errorf("secretSelector: invalid label %q=%q", key, value)
+			}
+		}
+	} else if source.SecretName == "" {
+		return fmt.Errorf("secretName is required")
+	}
+	if err := validateItems(source.Items); err != nil {
+		return err
+	}
+	for i, projection := range source.Sources {
+		if projection.SecretName == "" {
+			return fmt.Errorf("sources[%d]: secretName is required", i)
+		}
+		if err := validateItems(projection.Items); err != nil {
+			return fmt.Errorf("secret %q: %v", projection.SecretName, err)
+		}
+	}
+	return nil
+}
+
+// validateItems checks the items of a secret volume source for problems
+// that do not depend on the contents of the secret.
+func validateItems(items []api.KeyToPath) error {
+	if err := validateItemPaths(items); err != nil {
 		return err
 	}
+	return validateItemOwners(items)
+}
 
-	// If the plugin readiness file is present for this volume and
-	// the setup dir is a mountpoint, this volume is already ready.
-	if volumeutil.IsReady(b.getMetaDir()) && isMnt {
-		return nil
+// validateItemOwners returns an error naming the keys of any items that set
+// an owner if the kubelet cannot chown files.
+func validateItemOwners(items []api.KeyToPath) error {
+	owned := []string{}
+	for _, item := range items {
+		if item.UID != nil || item.GID != nil {
+			owned = append(owned, itemName(item))
+		}
 	}
+	if len(owned) > 0 && !canChown() {
+		return fmt.Errorf("items %q set an owner, but the kubelet lacks the privileges to chown files", owned)
+	}
+	return nil
+}
 
-	glog.V(3).Infof("Setting up volume %v for pod %v at %v", b.volName, b.pod.UID, dir)
+// itemName returns the key of item, or its key pattern if it selects keys
+// by pattern, for use in messages.
+func itemName(item api.KeyToPath) string {
+	if item.KeyPattern != "" {
+		return item.KeyPattern
+	}
+	return item.Key
+}
 
-	// Wrap EmptyDir, let it do the setup.
-	wrapped, err := b.plugin.host.NewWrapperBuilder(wrappedVolumeSpec, &b.pod, *b.opts, b.mounter)
-	if err != nil {
-		return err
+// isItemOptional returns true if item may match no key of the secret.
+func isItemOptional(item api.KeyToPath) bool {
+	return item.Optional != nil && *item.Optional
+}
+
+// skipMissingItems returns items with those that select no key of secret
+// made optional, and the keys, or key patterns, of the items it made
+// optional.  Items with an invalid key pattern are returned unchanged.
+func skipMissingItems(items []api.KeyToPath, secret *api.Secret, fileName func(key string) string) ([]api.KeyToPath, []string) {
+	optional := true
+	result := make([]api.KeyToPath, 0, len(items))
+	var skipped []string
+	for _, item := range items {
+		if !isItemOptional(item) {
+			probe := item
+			probe.Optional = &optional
+			if keys, err := matchItemKeys(probe, secret, fileName); err == nil && len(keys) == 0 {
+				item = probe
+				skipped = append(skipped, itemName(item))
+			}
+		}
+		result = append(result, item)
 	}
-	if err := wrapped.SetUpAt(dir); err != nil {
-		return err
+	return result, skipped
+}
+
+// validateItemPaths returns an error naming the keys of any items that
+// would be projected to the same path.  Paths that differ only by case are
+// also rejected, since they collide when the volume is on a case-insensitive
+// filesystem.  Items that select keys by pattern name a directory rather
+// than a file; collisions among the keys they match can only be found once
+// the secret is known.
+func validateItemPaths(items []api.KeyToPath) error {
+	// Items are grouped by their lowercased path; paths holds the path of
+	// the first item in each group, in the order the items are listed.
+	keys := map[string][]string{}
+	paths := []string{}
+	for _, item := range items {
+		if item.KeyPattern != "" {
+			continue
+		}
+		folded := strings.ToLower(item.Path)
+		if _, found := keys[folded]; !found {
+			paths = append(paths, item.Path)
+		}
+		keys[folded] = append(keys[folded], item.Key)
 	}
+	conflicts := []string{}
+	for _, p := range paths {
+		if k := keys[strings.ToLower(p)]; len(k) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("keys %q all project to path %q", k, p))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicting item paths: %v", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
 
-	kubeClient := b.plugin.host.GetKubeClient()
-	if kubeClient == nil {
-		return fmt.Errorf("Cannot setup secret volume %v because kube client is not configured", b.volName)
+// toFileMode converts mode bits from the API into an os.FileMode, rejecting
+// anything outside of the permission bits.
+func toFileMode(mode int) (os.FileMode, error) {
+	if mode < 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %#o: must be between 0 and 0777", mode)
 	}
+	return os.FileMode(mode), nil
+}
 
-	secret, err := kubeClient.Secrets(b.pod.Namespace).Get(b.secretName)
-	if err != nil {
-		glog.Errorf("Couldn't get secret %v/%v", b.pod.Namespace, b.secretName)
-		return err
-	} else {
-		totalBytes := totalSecretBytes(secret)
-		glog.V(3).Infof("Received secret %v/%v containing (%v) pieces of data, %v total bytes",
-			b.pod.Namespace,
-			b.secretName,
-			len(secret.Data),
-			totalBytes)
-	}
-
-	for name, data := range secret.Data {
-		hostFilePath := path.Join(dir, name)
-		glog.V(3).Infof("Writing secret data %v/%v/%v (%v bytes) to host file %v", b.pod.Namespace, b.secretName, name, len(data), hostFilePath)
-		err := ioutil.WriteFile(hostFilePath, data, 0444)
-		if err != nil {
-			glog.Errorf("Error writing secret data to host path: %v, %v", hostFilePath, err)
-			return err
+// fileProjection is the content, mode and ownership of a single file
+// projected into the volume.  The data is written exactly as it appears in
+// the secret; it is arbitrary binary and must never be treated as text.
+// uid and gid are nil unless the item sets them.  secret and key name the
+// source of the file, for the manifest; key is empty for a file that holds
+// more than one key, and both are empty for a file the plugin generates.
+type fileProjection struct {
+	data   []byte
+	mode   os.FileMode
+	uid    *int64
+	gid    *int64
+	secret string
+	key    string
+	// pipe, if set, makes the file a named pipe that data is fed through.
+	pipe bool
+	// xattrs are the extended attributes set on the file, by name.
+	xattrs map[string]string
+}
+
+// makePayload returns the files that should be present in the volume for
+// the given secret, keyed by their path relative to the volume root.  If no
+// items are given, every key in the secret is projected to a file named by
+// fileName.  An item with a key pattern projects each matching key to a
+// file named by fileName in the directory of the item's path.  The value of
+// each item is decoded according to its encoding.
+func makePayload(items []api.KeyToPath, secret *api.Secret, defaultMode os.FileMode, fileName func(key string) string) (map[string]fileProjection, error) {
+	payload := make(map[string]fileProjection, len(secret.Data))
+	// projectedKeys records the key projected to each lowercased path, to
+	// catch different keys landing on the same file.
+	projectedKeys := map[string]string{}
+	project := func(key, p string, file fileProjection) error {
+		folded := strings.ToLower(p)
+		if other, found := projectedKeys[folded]; found {
+			return fmt.Errorf("projects keys %q and %q to path %q", other, key, p)
 		}
+		projectedKeys[folded] = key
+		file.key = key
+		payload[p] = file
+		return nil
 	}
 
-	volumeutil.SetReady(b.getMetaDir())
+	if len(items) == 0 {
+		for _, key := range secretKeys(secret) {
+			if err := project(key, fileName(key), fileProjection{data: secret.Data[key], mode: defaultMode}); err != nil {
+				return nil, err
+			}
+		}
+		return payload, nil
+	}
 
-	return nil
+	for _, item := range items {
+		mode := defaultMode
+		if item.Mode != nil {
+			var err error
+			if mode, err = toFileMode(*item.Mode); err != nil {
+				return nil, fmt.Errorf("item %q: %v", itemName(item), err)
+			}
+		}
+		keys, err := matchItemKeys(item, secret, fileName)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range util.KeySet(reflect.ValueOf(keys)).List() {
+			p := keys[key]
+			decoded, err := decodeValue(secret.Data[key], item.Encoding)
+			if err != nil {
+				return nil, fmt.Errorf("item %q: %v", key, err)
+			}
+			if item.NormalizeLineEndings {
+				decoded = bytes.Replace(decoded, []byte("\r\n"), []byte("\n"), -1)
+			}
+			if err := project(key, p, fileProjection{data: decoded, mode: mode, uid: item.UID, gid: item.GID, xattrs: item.ExtendedAttributes}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return payload, nil
 }
 
-func (sv *secretVolume) IsReadOnly() bool {
-	return false
+// matchItemKeys returns the keys of secret selected by item, mapped to the
+// paths they are projected to.  Keys matched by a pattern are named by
+// fileName.  It is an error for an item that is not optional to select no
+// key.
+func matchItemKeys(item api.KeyToPath, secret *api.Secret, fileName func(key string) string) (map[string]string, error) {
+	keys := map[string]string{}
+	if item.KeyPattern == "" {
+		if _, ok := secret.Data[item.Key]; ok {
+			keys[item.Key] = item.Path
+		}
+	} else {
+		for key := range secret.Data {
+			matched, err := path.Match(item.KeyPattern, key)
+			if err != nil {
+				return nil, fmt.Errorf("item %q: invalid key pattern: %v", item.KeyPattern, err)
+			}
+			if matched {
+				keys[key] = path.Join(item.Path, fileName(key))
+			}
+		}
+	}
+	if len(keys) == 0 && !isItemOptional(item) {
+		if item.KeyPattern != "" {
+			return nil, fmt.Errorf("has no keys matching pattern %q", item.KeyPattern)
+		}
+		return nil, fmt.Errorf("references non-existent secret key %q", item.Key)
+	}
+	return keys, nil
 }
 
-func totalSecretBytes(secret *api.Secret) int {
-	totalSize := 0
-	for _, bytes := range secret.Data {
-		totalSize += len(bytes)
+// unlistedKeys returns the keys of secret that are not selected by any of
+// items, sorted.  An invalid key pattern selects nothing; makePayload
+// reports it.
+func unlistedKeys(items []api.KeyToPath, secret *api.Secret) []string {
+	extra := []string{}
+	for _, key := range secretKeys(secret) {
+		listed := false
+		for _, item := range items {
+			if item.KeyPattern == "" {
+				listed = item.Key == key
+			} else {
+				listed, _ = path.Match(item.KeyPattern, key)
+			}
+			if listed {
+				break
+			}
+		}
+		if !listed {
+			extra = append(extra, key)
+		}
 	}
+	return extra
+}
 
-	return totalSize
+// maxSanitizedNameLength is the longest file name that sanitizeKeyName
+// returns.  It leaves room for filesystems, such as encrypted ones, with
+// limits well below the usual 255 bytes.
+const maxSanitizedNameLength = 128
+
+// sanitizeKeyName returns a file name for key that is safe on any
+// filesystem: every character other than an ASCII letter, digit, '-', '_'
+// or '.' is replaced with '_', as is a leading '.' that would hide the
+// file, and the name is cut to maxSanitizedNameLength.  A name that had to
+// change gets a suffix hashed from the whole key, so that different keys
+// keep different names.  The result depends only on key, so it is the same
+// on every setup of the volume.
+func sanitizeKeyName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		case c == '.' && i > 0:
+		default:
+			name[i] = '_'
+		}
+	}
+	if string(name) == key && len(name) <= maxSanitizedNameLength {
+		return key
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	if len(name) > maxSanitizedNameLength-len(suffix) {
+		name = name[:maxSanitizedNameLength-len(suffix)]
+	}
+	return string(name) + suffix
 }
 
-// secretVolumeCleaner handles cleaning up secret volumes.
-type secretVolumeCleaner struct {
-	*secretVolume
+// keyFileName returns the name of the file that key is projected to when
+// the name is derived from the key.  If the volume sanitizes key names, the
+// name is sanitized and any change is logged.
+func (b *secretVolumeBuilder) keyFileName(key string) string {
+	if !b.sanitize {
+		return key
+	}
+	name := sanitizeKeyName(key)
+	if name != key {
+		glog.V(3).Infof("Sanitized file name of secret key: %v", b.logFields("key", key, "file", name))
+	}
+	return name
 }
 
-var _ volume.Cleaner = &secretVolumeCleaner{}
+// decodeValue returns the value of a key decoded according to encoding.
+// Decoding happens entirely in memory.
+func decodeValue(data []byte, encoding api.KeyEncoding) ([]byte, error) {
+	switch encoding {
+	case "", api.KeyEncodingRaw:
+		return data, nil
+	case api.KeyEncodingBase64:
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+		n, err := base64.StdEncoding.Decode(decoded, data)
+		if err != nil {
+			return nil, fmt.Errorf("value is not valid base64: %v", err)
+		}
+		return decoded[:n], nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
 
-func (c *secretVolumeCleaner) TearDown() error {
-	return c.TearDownAt(c.GetPath())
+// SetUpAt sets up the volume at dir, or refreshes its contents if it is
+// already set up; once the wrapped volume is set up, the contents are
+// written as by Reconcile.  It returns ErrNoKubeClient if the host cannot
+// reach the API server, and volume.ErrSetUpCanceled if the setup was
+// canceled; every other error is a *SetUpError naming the stage that failed.
+// An error from the API server when getting a secret, such as a missing
+// secret of a volume that is not optional, is in turn wrapped in a
+// volume.RetryAfterError suggesting when to retry.  Cause returns the error
+// that was wrapped.
+func (b *secretVolumeBuilder) SetUpAt(dir string) error {
+	return b.SetUpAtWithCancel(dir, nil)
 }
 
-func (c *secretVolumeCleaner) TearDownAt(dir string) error {
-	glog.V(3).Infof("Tearing down volume %v for pod %v at %v", c.volName, c.podUID, dir)
+// SetUpAtWithCancel is like SetUpAt, but gives up once stopCh is closed or
+// the volume is torn down.  A Get in flight cannot be interrupted, but waits
+// between retries and the writing of the files are; files written for the
+// aborted setup are removed.
+func (b *secretVolumeBuilder) SetUpAtWithCancel(dir string, stopCh <-chan struct{}) (err error) {
+	done := b.plugin.timeOperation(operationSetUp)
+	defer func() { done(err) }()
 
-	// Wrap EmptyDir, let it do the teardown.
-	wrapped, err := c.plugin.host.NewWrapperCleaner(wrappedVolumeSpec, c.podUID, c.mounter)
+	// Catch a bad mode before mounting anything.
+	if _, err := b.fileMode(); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	if err := b.checkHostDir(); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	if _, err := b.plugin.checkFileOverlay(b.overlay); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	if err := b.checkSocketDelivery(); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	if err := b.plugin.checkNamespace(b.pod.Namespace); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+
+	// Overlapping syncs may set up the same volume concurrently.  Only one
+	// may touch the volume at a time; later callers then see it ready and
+	// only refresh its contents.
+	unlock, canceled := b.plugin.lockForSetUp(volumeKey(b.podUID, b.volName))
+	defer unlock()
+	stopCh, release := mergeStop(stopCh, canceled)
+	defer release()
+
+	isMnt, ready, err := b.setUpState(dir)
 	if err != nil {
+		return setUpFailed(SetUpStageMount, err)
+	}
+	if ready && b.noRemountRefresh {
+		glog.V(3).Infof("Secret volume is already set up; not refreshing it: %v", b.logFields("dir", dir))
+		return nil
+	}
+	if ready {
+		glog.V(3).Infof("Refreshing secret volume: %v", b.logFields("dir", dir))
+	} else {
+		glog.V(3).Infof("Setting up secret volume: %v", b.logFields("dir", dir))
+		// Refreshes are not audited, only the first setup.
+		defer func() { b.auditMount(err) }()
+
+		if err := b.setUpDir(dir); err != nil {
+			return setUpFailed(SetUpStageMount, err)
+		}
+	}
+	if err := b.reconcileAt(dir, stopCh, isMnt, ready); err != nil {
 		return err
 	}
-	return wrapped.TearDownAt(dir)
+	// Resyncs refresh the volume at its own path only.
+	if dir == b.GetPath() {
+		b.startResync()
+	}
+	return nil
+}
+
+// Reconcile brings the files of a volume that is already set up at its path
+// up to date with its secrets, without setting up the wrapped volume again.
+// It is the cheaper half of SetUp, for periodic resyncs.  A volume that is
+// not set up yet is set up in full, as by SetUp.
+func (b *secretVolumeBuilder) Reconcile() (err error) {
+	dir := b.GetPath()
+	unlock, canceled := b.plugin.lockForSetUp(volumeKey(b.podUID, b.volName))
+	isMnt, ready, err := b.setUpState(dir)
+	if err == nil && !ready {
+		unlock()
+		glog.V(3).Infof("Secret volume to reconcile is not set up; setting it up: %v", b.logFields("dir", dir))
+		return b.SetUpAt(dir)
+	}
+	defer unlock()
+	if err != nil {
+		return setUpFailed(SetUpStageMount, err)
+	}
+
+	done := b.plugin.timeOperation(operationSetUp)
+	defer func() { done(err) }()
+	glog.V(3).Infof("Reconciling secret volume: %v", b.logFields("dir", dir))
+	return b.reconcileAt(dir, canceled, isMnt, true)
+}
+
+// setUpState reports whether dir is a mountpoint and whether the volume at
+// dir is set up.  If the plugin readiness file is present for the volume,
+// its files are intact and the setup dir is a mountpoint, the volume has
+// already been set up and only its contents need to be refreshed.  A
+// disk-backed volume is never a mountpoint of its own.
+func (b *secretVolumeBuilder) setUpState(dir string) (isMnt, ready bool, err error) {
+	isMnt, err = b.isMountPoint(dir)
+	if err != nil {
+		return false, false, err
+	}
+	return isMnt, (isMnt || !b.isMemoryBacked()) && b.isReady(dir), nil
+}
+
+// reconcileAt writes the current contents of the secrets of the volume to
+// dir, whose wrapped volume is set up, and marks the volume ready.  isMnt
+// and ready are as reported by setUpState before the wrapped volume was set
+// up.  The caller holds the lock of the volume.
+func (b *secretVolumeBuilder) reconcileAt(dir string, stopCh <-chan struct{}, isMnt, ready bool) (err error) {
+	// A memory-backed volume is writable while the plugin sets up its
+	// contents and ownership, and is made read-only once they are final.
+	// The wrapped volume is mounted writable, and a volume that is already
+	// set up is made writable below only if it needs to be written.  If
+	// setup fails partway, the volume is still left read-only.
+	makeReadOnly := !ready && b.isMemoryBacked()
+	defer func() {
+		if !makeReadOnly {
+			return
+		}
+		if err := b.remount(dir, true); err != nil {
+			glog.Errorf("Error remounting secret volume read-only: %v", b.logFields("dir", dir, "err", err))
+		}
+	}()
+
+	// The cleaner has no spec, so leave it a marker to find.  This is done
+	// before anything is written, so a failed setup is still wiped.
+	if b.wipe {
+		if err := b.markForWipe(); err != nil {
+			glog.Errorf("Error marking secret volume to be wiped: %v", b.logFields("dir", dir, "err", err))
+			return setUpFailed(SetUpStageWrite, err)
+		}
+	}
+
+	// Immutable secrets cannot have changed since they were written.
+	if ready && b.skipRefresh() {
+		glog.V(4).Infof("Secrets of volume are immutable; not getting them again: %v", b.logFields("dir", dir))
+		b.metrics = b.writtenMetrics()
+		b.changes = PayloadChanges{}
+		return nil
+	}
+
+	// Checked again here for Reconcile and resyncs, which skip SetUp.
+	if err := b.plugin.checkNamespace(b.pod.Namespace); err != nil {
+		return setUpFailed(SetUpStageValidate, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	getter := b.getSecretGetter()
+	if getter == nil {
+		glog.Errorf("Cannot setup secret volume because kube client is not configured: %v", b.logFields("dir", dir))
+		return ErrNoKubeClient
+	}
+
+	payload, version, uid, immutable, err := b.getPayload(getter, stopCh, func(secretName string, err error) {
+		b.plugin.recordSecretEvent(&b.pod, b.volName, "Unable to get secret %v/%v for volume %v: %v", b.pod.Namespace, secretName, b.volName, err)
+	})
+	if err != nil {
+		return withRetryAfter(setUpFailed(SetUpStageFetch, err))
+	}
+	// The modes cannot change once the volume is set up, so they are only
+	// checked the first time.
+	if !ready {
+		if err := b.checkExecBits(payload); err != nil {
+			return setUpFailed(SetUpStageValidate, err)
+		}
+	}
+	if b.socket {
+		return b.reconcileSocket(dir, payload, ready)
+	}
+
+	previousUID := b.SecretUID()
+	replaced := ready && b.secretReplaced(uid, previousUID)
+
+	writer := newAtomicWriter(dir, b.logFields())
+	writer.fsGroup = b.opts.FSGroup
+	writer.stopCh = stopCh
+	// A volume that is not ready may hold the remains of an interrupted
+	// setup, and one whose secret was replaced holds another secret's
+	// data, so they are written out in full.
+	writer.force = !ready || replaced
+	writer.verify = b.plugin.config.VerifyWrites
+	writer.exclusive = b.plugin.config.ExclusiveWrites
+	writer.copyFiles = b.copyFiles
+	writer.progress = b.newProgressCounter(payload)
+	writer.xattrStrict = b.xattrStrict
+	previous := b.WrittenPaths()
+	previousVersion := b.ResourceVersion()
+	writer.owned = previous
+	if ready && !replaced {
+		// If the volume already holds this version of the secret, trust its
+		// content rather than read every file.  The paths and modes must
+		// match too, in case the items changed.
+		if version != "" && version == previousVersion && reflect.DeepEqual(payloadPaths(payload), previous) {
+			changed, err := writer.metadataChanged(payload)
+			if err != nil {
+				return setUpFailed(SetUpStageWrite, err)
+			}
+			if !changed {
+				glog.V(4).Infof("Secret volume is at the latest version: %v", b.logFields("resourceVersion", previousVersion))
+				b.metrics = payloadMetrics(payload)
+				b.writtenPaths = previous
+				b.recordSecretUID(uid, previousUID)
+				b.changes = PayloadChanges{}
+				b.feedPipes(dir, payload)
+				return nil
+			}
+		}
+		changed, err := writer.payloadChanged(payload)
+		if err != nil {
+			return setUpFailed(SetUpStageWrite, err)
+		}
+		if !changed {
+			b.metrics = payloadMetrics(payload)
+			b.recordWrittenPaths(payload, previous)
+			b.recordResourceVersion(version, previousVersion)
+			b.recordSecretUID(uid, previousUID)
+			b.recordImmutable(immutable)
+			b.changes = PayloadChanges{}
+			b.feedPipes(dir, payload)
+			return nil
+		}
+	}
+
+	// A memory-backed volume is kept mounted read-only between setups;
+	// make it writable while the payload is written.  If its size is
+	// limited, the tmpfs is resized at the same time to hold the files
+	// published now as well as the payload, since the writer keeps both
+	// until the payload is published; it is shrunk to fit the payload alone
+	// once it is made read-only again.
+	limitSize := b.isMemoryBacked() && b.plugin.config.LimitTmpfsSize
+	var readOnlyOptions []string
+	if (isMnt && b.isMemoryBacked()) || limitSize {
+		makeReadOnly = true
+		var options []string
+		if limitSize {
+			published, err := publishedPages(writer)
+			if err != nil {
+				glog.Errorf("Error measuring files of secret volume: %v", b.logFields("dir", dir, "err", err))
+				return setUpFailed(SetUpStageMount, err)
+			}
+			size := tmpfsSize(published+payloadPages(payload), b.plugin.config.TmpfsSizeMargin)
+			glog.V(4).Infof("Limiting size of secret volume: %v", b.logFields("dir", dir, "bytes", size))
+			options = append(options, fmt.Sprintf("size=%d", size))
+			readOnlyOptions = append(readOnlyOptions, fmt.Sprintf("size=%d", tmpfsSize(payloadPages(payload), b.plugin.config.TmpfsSizeMargin)))
+		}
+		if err := b.remount(dir, false, options...); err != nil {
+			glog.Errorf("Error remounting secret volume read-write: %v", b.logFields("dir", dir, "err", err))
+			return setUpFailed(SetUpStageMount, err)
+		}
+	}
+
+	// A first setup that fails once writing has begun removes what it
+	// wrote, so that the next attempt starts from an empty volume rather
+	// than from the remains of this one.  This runs before the volume is
+	// made read-only again, and never touches a volume that is already set
+	// up, whose files stay as they were.
+	if !ready {
+		defer func() {
+			if err != nil {
+				b.removeContents(dir, &makeReadOnly)
+			}
+		}()
+	}
+
+	// Fail cleanly up front rather than leave the writer to run out of
+	// space partway through.
+	if err := b.checkFreeSpace(dir, payload); err != nil {
+		return setUpFailed(SetUpStageWrite, err)
+	}
+	written := b.plugin.timeOperation(operationWrite)
+	err = writer.Write(payload)
+	written(err)
+	if err != nil {
+		glog.Errorf("Error writing secret to volume: %v", b.logFields("dir", dir, "err", err))
+		// The writer has removed whatever it wrote of the payload.
+		if isNoSpaceError(err) {
+			return setUpFailed(SetUpStageWrite, b.insufficientSpaceError(dir, payload))
+		}
+		return setUpFailed(SetUpStageWrite, err)
+	}
+	b.changes = writer.changes
+	if b.changes.Changed() {
+		glog.V(2).Infof("Updated files of secret volume: %v", b.logFields("added", b.changes.Added, "updated", b.changes.Updated,
+			"modeUpdated", b.changes.ModeUpdated, "removed", b.changes.Removed))
+	}
+	b.metrics = payloadMetrics(payload)
+	b.recordWrittenPaths(payload, previous)
+	b.recordResourceVersion(version, previousVersion)
+	b.recordSecretUID(uid, previousUID)
+	b.recordImmutable(immutable)
+	b.feedPipes(dir, payload)
+	if err := setRootMode(dir, b.plugin.dirMode, b.opts.FSGroup); err != nil {
+		glog.Errorf("Error setting mode of secret volume: %v", b.logFields("dir", dir, "err", err))
+		return setUpFailed(SetUpStagePermissions, err)
+	}
+	if b.opts.RootContext != "" {
+		if err := b.setContext(dir, b.opts.RootContext); err != nil {
+			glog.Errorf("Error setting SELinux context of secret volume: %v", b.logFields("dir", dir, "err", err))
+			return setUpFailed(SetUpStagePermissions, err)
+		}
+	}
+	if makeReadOnly {
+		makeReadOnly = false
+		if err := b.remount(dir, true, readOnlyOptions...); err != nil {
+			glog.Errorf("Error remounting secret volume read-only: %v", b.logFields("dir", dir, "err", err))
+			return setUpFailed(SetUpStageMount, err)
+		}
+	}
+	if err := b.mountFileOverlay(dir); err != nil {
+		return setUpFailed(SetUpStageMount, err)
+	}
+
+	// The volume is only trusted once its integrity marker and readiness
+	// file are written, strictly after everything else.
+	if err := b.storeIntegrity(payload); err != nil {
+		glog.Errorf("Error recording integrity of secret volume: %v", b.logFields("dir", dir, "err", err))
+		return setUpFailed(SetUpStageWrite, err)
+	}
+	if !ready {
+		volumeutil.SetReady(b.getMetaDir())
+	} else {
+		b.notifyChange(dir, payload, writer.changedPaths)
+	}
+
+	return nil
+}
+
+// LastChanges returns the files that the last successful setup or refresh
+// of the volume through this builder added, updated or removed.  Nothing is
+// changed by a refresh that finds the volume up to date.
+func (b *secretVolumeBuilder) LastChanges() PayloadChanges {
+	return b.changes
+}
+
+// SkippedKeys returns the keys, or key patterns, of the items that the last
+// setup or refresh through this builder skipped because its secrets lacked
+// them, sorted.  Only a best-effort volume skips items.
+func (b *secretVolumeBuilder) SkippedKeys() []string {
+	return b.skippedKeys
+}
+
+// writtenPathsFileName is the name of the file in the meta dir of a volume
+// that lists the paths written by its last successful setup.
+const writtenPathsFileName = "paths"
+
+// WrittenPaths returns the paths, relative to the volume root, of the files
+// written by the last successful setup of the volume, sorted.  The paths are
+// kept in the volume's meta dir, so they are known even if the volume was set
+// up before the kubelet restarted.  It returns nil if the volume has never
+// been set up or the paths cannot be read.
+func (b *secretVolumeBuilder) WrittenPaths() []string {
+	if b.writtenPaths != nil {
+		return b.writtenPaths
+	}
+	paths, err := b.loadWrittenPaths()
+	if err != nil {
+		glog.Warningf("Couldn't read the paths last written to secret volume: %v", b.logFields("err", err))
+		return nil
+	}
+	return paths
+}
+
+func (sv *secretVolume) loadWrittenPaths() ([]string, error) {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), writtenPathsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// recordWrittenPaths records the paths of payload as written to the volume,
+// persisting them unless they match previous.  A failure to persist them is
+// only logged; the next setup persists them again.
+func (b *secretVolumeBuilder) recordWrittenPaths(payload map[string]fileProjection, previous []string) {
+	paths := payloadPaths(payload)
+	b.writtenPaths = paths
+	if previous != nil && reflect.DeepEqual(paths, previous) {
+		return
+	}
+	if err := b.storeWrittenPaths(paths); err != nil {
+		glog.Errorf("Couldn't persist the paths written to secret volume: %v", b.logFields("err", err))
+	}
+}
+
+// secretKeys returns the keys of secret, sorted, so that its files are
+// projected and written in the same order every time.
+func secretKeys(secret *api.Secret) []string {
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// payloadPaths returns the paths of payload, sorted.
+func payloadPaths(payload map[string]fileProjection) []string {
+	paths := make([]string, 0, len(payload))
+	for p := range payload {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (b *secretVolumeBuilder) storeWrittenPaths(paths []string) error {
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+	return b.writeMetaFile(writtenPathsFileName, data)
+}
+
+// writeMetaFile replaces the file with the given name in the meta dir of
+// the volume with data.  The file is renamed into place so that a crash
+// never leaves it partially written.
+func (sv *secretVolume) writeMetaFile(name string, data []byte) error {
+	if err := os.MkdirAll(sv.getMetaDir(), 0750); err != nil {
+		return err
+	}
+	file := path.Join(sv.getMetaDir(), name)
+	if err := ioutil.WriteFile(file+".tmp", data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// resourceVersionFileName is the name of the file in the meta dir of a
+// volume that holds the resource version of the secret written by its last
+// successful setup.
+const resourceVersionFileName = "resourceVersion"
+
+// ResourceVersion returns the resource version of the secret whose contents
+// the volume currently holds, as of its last successful setup.  Comparing it
+// with the live secret shows whether the volume lags behind it.  For a
+// volume with additional sources, it is the versions of all of the secrets,
+// in order, separated by commas.  It returns "" if the volume has never been
+// set up, lacks a secret, or the version cannot be read.
+func (sv *secretVolume) ResourceVersion() string {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), resourceVersionFileName))
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		glog.Warningf("Couldn't read the resource version of secret volume %v: %v", sv.volName, err)
+		return ""
+	}
+	return string(data)
+}
+
+// recordResourceVersion persists version, the resource version of the
+// secrets just written to the volume, unless it matches previous.  An empty
+// version is not kept.  Failures are only logged; without a version the next
+// refresh compares the files instead.
+func (b *secretVolumeBuilder) recordResourceVersion(version, previous string) {
+	if version == previous {
+		return
+	}
+	var err error
+	if version == "" {
+		err = os.Remove(path.Join(b.getMetaDir(), resourceVersionFileName))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = b.writeMetaFile(resourceVersionFileName, []byte(version))
+	}
+	if err != nil {
+		glog.Errorf("Couldn't persist the resource version of secret volume: %v", b.logFields("resourceVersion", version, "err", err))
+	}
+}
+
+// isMountPoint reports whether dir is a mountpoint.  A dir that does not
+// exist yet is not a mountpoint; setup will create it.  Errors that may
+// clear up on their own are retried a few times, so that a flaky stat does
+// not fail the setup.
+func (b *secretVolumeBuilder) isMountPoint(dir string) (bool, error) {
+	for i := 0; ; i++ {
+		isMnt, err := b.mounter.IsMountPoint(dir)
+		if err == nil {
+			return isMnt, nil
+		}
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if !isTransientError(err) || i == isMountPointRetries {
+			glog.Errorf("Cannot determine whether secret volume is a mountpoint: %v", b.logFields("dir", dir, "err", err))
+			return false, err
+		}
+		glog.V(3).Infof("Retrying check of whether secret volume is a mountpoint: %v", b.logFields("dir", dir, "err", err))
+		time.Sleep(isMountPointRetryInterval)
+	}
+}
+
+// isTransientError returns true if err is from a file system operation that
+// was interrupted or raced with a change on the server, and may succeed if
+// tried again.
+func isTransientError(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	switch err {
+	case syscall.EINTR, syscall.EAGAIN, syscall.ESTALE:
+		return true
+	}
+	return false
+}
+
+// recordSecretEvent records an event against pod about a problem with the
+// secret for volume volName.  At most one event is recorded per volume every
+// secretEventInterval, so that a secret which never appears does not flood
+// the event stream while the kubelet retries setup.
+func (plugin *secretPlugin) recordSecretEvent(pod *api.Pod, volName string, messageFmt string, args ...interface{}) {
+	recorder := plugin.host.GetRecorder()
+	if recorder == nil {
+		return
+	}
+
+	plugin.eventLock.Lock()
+	defer plugin.eventLock.Unlock()
+
+	key := volumeKey(pod.UID, volName)
+	now := plugin.clock.Now()
+	if last, found := plugin.lastEvent[key]; found && now.Sub(last) < secretEventInterval {
+		return
+	}
+	// Forget volumes that have not needed an event recently, so pods that
+	// have gone away do not accumulate here.
+	for k, last := range plugin.lastEvent {
+		if now.Sub(last) >= secretEventInterval {
+			delete(plugin.lastEvent, k)
+		}
+	}
+	plugin.lastEvent[key] = now
+	recorder.Eventf(pod, "failedSecret", messageFmt, args...)
+}
+
+// getSecret retrieves the secret for the volume with getter.  It returns nil
+// without an error if the volume is optional and the secret does not exist.
+// Errors from getter are returned unchanged, so callers can tell a missing
+// secret from other failures with errors.IsNotFound.  If the plugin caches
+// secrets, a cached secret is returned without contacting the API server,
+// unless the volume reads its secrets with the credentials of its pod,
+// which the node-wide cache would bypass.
+// If the host keeps node-local copies of secrets, the copy is used when the
+// API server cannot be reached.  If the plugin has a not found grace period,
+// a missing secret of a volume that is not optional is polled for until it
+// is created or the period has passed.  Closing stopCh abandons any
+// remaining retries or polls with volume.ErrSetUpCanceled.
+func (b *secretVolumeBuilder) getSecret(getter SecretGetter, stopCh <-chan struct{}) (*api.Secret, error) {
+	if len(b.selector) > 0 {
+		if err := b.resolveSelector(getter); err != nil {
+			if !errors.IsNotFound(err) || !b.optional {
+				glog.Errorf("Couldn't resolve secret selector: %v", b.logFields("selector", b.selectorString(), "err", err))
+				return nil, err
+			}
+			glog.V(3).Infof("No secret matches the selector of optional volume; setting up an empty volume: %v", b.logFields("selector", b.selectorString()))
+			return nil, nil
+		}
+	}
+	if b.plugin.cache != nil && b.kubeClient == nil {
+		if secret := b.plugin.cache.get(b.pod.Namespace, b.secretName); secret != nil {
+			return secret, nil
+		}
+		glog.V(4).Infof("Secret is not cached; getting it from the API server: %v", b.logFields())
+	}
+	secret, err := getter.GetSecret(b.pod.Namespace, b.secretName)
+	backoff := b.plugin.getBackoff
+	for i := 0; i < b.plugin.getRetries && err != nil && isRetryableAPIError(err); i++ {
+		glog.V(3).Infof("Retrying get of secret: %v", b.logFields("backoff", backoff, "err", err))
+		select {
+		case <-stopCh:
+			return nil, volume.ErrSetUpCanceled
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		secret, err = getter.GetSecret(b.pod.Namespace, b.secretName)
+	}
+	// An optional volume is set up empty instead of waiting.
+	if errors.IsNotFound(err) && !b.optional && b.plugin.config.NotFoundGracePeriod > 0 {
+		secret, err = b.waitForSecret(getter, stopCh, err)
+	}
+	if b.plugin.fallback != nil {
+		b.updateFallback(secret, err)
+		if err != nil && isUnreachableError(err) {
+			if copy := b.loadFallback(); copy != nil {
+				glog.Warningf("Couldn't get secret; using a node-local copy that may be out of date: %v", b.logFields("err", err))
+				return copy, nil
+			}
+		}
+	}
+	if err != nil {
+		if !errors.IsNotFound(err) || !b.optional {
+			glog.Errorf("Couldn't get secret: %v", b.logFields("err", err))
+			return nil, err
+		}
+		glog.V(3).Infof("Secret for optional volume does not exist; setting up an empty volume: %v", b.logFields())
+		return nil, nil
+	}
+	return secret, nil
+}
+
+// waitForSecret polls for the secret of b, which did not exist, until it
+// is created or the not found grace period has passed, in which case the
+// last not found error is returned.  Closing stopCh stops the wait with
+// volume.ErrSetUpCanceled; this is also how a timeout of the whole setup
+// cuts the wait short.
+func (b *secretVolumeBuilder) waitForSecret(getter SecretGetter, stopCh <-chan struct{}, notFound error) (*api.Secret, error) {
+	grace := b.plugin.config.NotFoundGracePeriod
+	glog.V(3).Infof("Secret does not exist; waiting for it to be created: %v", b.logFields("gracePeriod", grace))
+	deadline := time.After(grace)
+	ticker := time.NewTicker(b.plugin.notFoundPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return nil, volume.ErrSetUpCanceled
+		case <-deadline:
+			glog.V(3).Infof("Secret was not created within the grace period: %v", b.logFields("gracePeriod", grace))
+			return nil, notFound
+		case <-ticker.C:
+		}
+		secret, err := getter.GetSecret(b.pod.Namespace, b.secretName)
+		if !errors.IsNotFound(err) {
+			return secret, err
+		}
+		notFound = err
+	}
+}
+
+// logFields formats the fields identifying b, followed by keysAndValues,
+// for a log line.
+func (b *secretVolumeBuilder) logFields(keysAndValues ...interface{}) string {
+	fields := []interface{}{"pod", b.pod.UID, "namespace", b.pod.Namespace, "secret", b.secretName, "volume", b.volName}
+	return formatLogFields(append(fields, keysAndValues...)...)
+}
+
+// formatLogFields formats alternating keys and values as space-separated
+// key=value fields, so that log scrapers can index them.  Values that are
+// empty or contain spaces, quotes or '=' are quoted.
+func formatLogFields(keysAndValues ...interface{}) string {
+	var buf bytes.Buffer
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		value := fmt.Sprint(keysAndValues[i+1])
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&buf, "%v=%v", keysAndValues[i], value)
+	}
+	return buf.String()
+}
+
+// isRetryableAPIError returns true if err is an error from the API server
+// that may not recur, such as a timeout or an internal error, rather than a
+// permanent error such as a missing secret or a denied request.
+func isRetryableAPIError(err error) bool {
+	if statusErr, ok := err.(*errors.StatusError); ok {
+		code := statusErr.Status().Code
+		return code >= http.StatusInternalServerError || code == errors.StatusTooManyRequests
+	}
+	if netErr, ok := err.(net.Error); ok {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// retryAfterError is an error from getting a secret, along with the wait
+// suggested before the setup is retried.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+var _ volume.RetryAfterError = &retryAfterError{}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) RetryAfter() time.Duration {
+	return e.after
+}
+
+// Unwrap returns the error that failed the setup.
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// withRetryAfter returns err from getting a secret with a suggested wait
+// before retrying, if its class suggests one.  Errors that may not recur,
+// such as a timeout or an internal error of the API server, are retried
+// soon; other errors from the API server, such as a missing secret or a
+// denied request, are retried later.  Any other error is returned as is.
+func withRetryAfter(err error) error {
+	cause := Cause(err)
+	if isRetryableAPIError(cause) {
+		return &retryAfterError{err: err, after: transientErrorRetryAfter}
+	}
+	if _, ok := cause.(*errors.StatusError); ok {
+		return &retryAfterError{err: err, after: permanentErrorRetryAfter}
+	}
+	return err
+}
+
+// secretNames returns the names of the secret of the volume and of each
+// additional source.
+func (b *secretVolumeBuilder) secretNames() []string {
+	names := []string{b.secretName}
+	for _, source := range b.sources {
+		names = append(names, source.SecretName)
+	}
+	return names
+}
+
+// sourceBuilders returns b followed by a builder for each additional source.
+// The additional sources are handled by copies of b that differ only in
+// their secret.
+func (b *secretVolumeBuilder) sourceBuilders() []*secretVolumeBuilder {
+	builders := []*secretVolumeBuilder{b}
+	for _, projection := range b.sources {
+		sb := *b
+		sb.secretName = projection.SecretName
+		sb.selector = nil
+		sb.items = projection.Items
+		sb.optional = projection.Optional != nil && *projection.Optional
+		sb.onlyKeyPath = ""
+		sb.bundlePath = ""
+		sb.archivePath = ""
+		sb.envFile = nil
+		sb.sources = nil
+		sb.contentHash = false
+		sb.manifest = false
+		builders = append(builders, &sb)
+	}
+	return builders
+}
+
+// getPayload gets the secret of the volume and of each additional source,
+// and returns the files that should be written to the volume for them,
+// along with the resource version of the secrets as kept by
+// recordResourceVersion, their UIDs as kept by recordSecretUID and whether
+// they are all immutable.  The version and the UIDs are "" if any secret is
+// missing or has none.  If getting a secret fails, onGetError is called, if
+// it is set, with the name of the secret and the error, which is returned
+// unchanged.  It is an error for two secrets to be projected to the same
+// path.
+func (b *secretVolumeBuilder) getPayload(getter SecretGetter, stopCh <-chan struct{}, onGetError func(secretName string, err error)) (map[string]fileProjection, string, string, bool, error) {
+	builders := b.sourceBuilders()
+	payload := map[string]fileProjection{}
+	// sourceOf records the secret projected to each lowercased path.
+	sourceOf := map[string]string{}
+	versions := make([]string, 0, len(builders))
+	uids := make([]string, 0, len(builders))
+	immutable := true
+	hash := sha256.New()
+	var skipped []string
+	for _, sb := range builders {
+		fetched := b.plugin.timeOperation(operationFetch)
+		secret, err := sb.getSecret(getter, stopCh)
+		fetched(err)
+		if err == volume.ErrSetUpCanceled {
+			return nil, "", "", false, err
+		}
+		if err != nil {
+			if onGetError != nil {
+				onGetError(sb.secretName, err)
+			}
+			return nil, "", "", false, err
+		}
+		sourcePayload, err := sb.buildPayload(secret)
+		if err != nil {
+			return nil, "", "", false, err
+		}
+		hashSecret(hash, sb.secretName, secret)
+		immutable = immutable && isImmutable(secret)
+		skipped = append(skipped, sb.skippedKeys...)
+		for _, p := range payloadPaths(sourcePayload) {
+			file := sourcePayload[p]
+			folded := strings.ToLower(p)
+			if other, found := sourceOf[folded]; found {
+				glog.Errorf("Secrets of volume conflict: %v", b.logFields("path", p, "otherSecret", other))
+				return nil, "", "", false, fmt.Errorf("Cannot setup secret volume %v: secrets %v/%v and %v/%v are both projected to path %q",
+					b.volName, b.pod.Namespace, other, b.pod.Namespace, sb.secretName, p)
+			}
+			sourceOf[folded] = sb.secretName
+			file.secret = sb.secretName
+			payload[p] = file
+		}
+		if secret == nil {
+			versions = append(versions, "")
+			uids = append(uids, "")
+		} else {
+			versions = append(versions, secret.ResourceVersion)
+			uids = append(uids, string(secret.UID))
+		}
+	}
+	if len(builders) > 1 {
+		if err := validatePayload(payload); err != nil {
+			return nil, "", "", false, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err)
+		}
+	}
+	if err := b.markPipes(payload); err != nil {
+		return nil, "", "", false, err
+	}
+	if b.contentHash {
+		if err := b.addGeneratedFile(payload, contentHashFileName, []byte(hex.EncodeToString(hash.Sum(nil))+"\n")); err != nil {
+			return nil, "", "", false, err
+		}
+	}
+	// The manifest comes last, so that it lists every other file.
+	if b.manifest {
+		if err := b.addGeneratedFile(payload, manifestFileName, makeManifest(payload)); err != nil {
+			return nil, "", "", false, err
+		}
+	}
+	sort.Strings(skipped)
+	b.skippedKeys = skipped
+
+	return payload, joinComplete(versions), joinComplete(uids), immutable, nil
+}
+
+// joinComplete joins ids with commas, or returns "" if any of them is "".
+func joinComplete(ids []string) string {
+	for _, id := range ids {
+		if id == "" {
+			return ""
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// contentHashFileName is the name of the file holding the hash of the
+// contents of the secrets of a volume that writes one.
+const contentHashFileName = ".secret-hash"
+
+// hashSecret writes the name and the contents of secret, which may be nil
+// for a missing optional secret, to hash.  Keys are taken in sorted order
+// and every field is prefixed with its length, so the hash only depends on
+// the contents and different contents never write the same bytes.
+func hashSecret(hash io.Writer, name string, secret *api.Secret) {
+	fmt.Fprintf(hash, "%d:%s", len(name), name)
+	if secret == nil {
+		fmt.Fprintf(hash, "-")
+		return
+	}
+	keys := secretKeys(secret)
+	fmt.Fprintf(hash, "%d:", len(keys))
+	for _, key := range keys {
+		value := secret.Data[key]
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(value))
+		hash.Write(value)
+	}
+}
+
+// manifestFileName is the name of the file listing the other files of a
+// volume that writes one.
+const manifestFileName = ".manifest.json"
+
+// manifestEntry describes a file of the volume in the manifest.
+type manifestEntry struct {
+	Path   string `json:"path"`
+	Secret string `json:"secret,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Bytes  int    `json:"bytes"`
+	Mode   string `json:"mode"`
+}
+
+// makeManifest returns the manifest of payload, listing its files in the
+// order of their paths.
+func makeManifest(payload map[string]fileProjection) []byte {
+	manifest := struct {
+		Files []manifestEntry `json:"files"`
+	}{Files: []manifestEntry{}}
+	for _, p := range payloadPaths(payload) {
+		file := payload[p]
+		manifest.Files = append(manifest.Files, manifestEntry{
+			Path:   p,
+			Secret: file.secret,
+			Key:    file.key,
+			Bytes:  len(file.data),
+			Mode:   fmt.Sprintf("%#o", file.mode),
+		})
+	}
+	// The manifest only holds strings and numbers, so it always encodes.
+	data, _ := json.Marshal(manifest)
+	return append(data, '\n')
+}
+
+// addGeneratedFile adds the file of the given name, which the plugin
+// generates rather than projects from a key, to payload.  Because it is part
+// of the payload, it is published together with the files it describes and
+// never pruned as a deleted key.
+func (b *secretVolumeBuilder) addGeneratedFile(payload map[string]fileProjection, name string, data []byte) error {
+	if _, found := payload[name]; found {
+		return fmt.Errorf("Cannot setup secret volume %v: a key is projected to %q, which the plugin writes itself", b.volName, name)
+	}
+	mode, err := b.fileMode()
+	if err != nil {
+		return fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err)
+	}
+	if b.opts.FSGroup != nil {
+		mode |= 0040
+	}
+	payload[name] = fileProjection{data: data, mode: mode}
+	return nil
+}
+
+// buildPayload returns the files that should be written to the volume for
+// secret, which may be nil for a missing optional secret.  It applies every
+// check on the contents of the volume, so that a volume which passes
+// ValidateSpec will also set up cleanly.
+func (b *secretVolumeBuilder) buildPayload(secret *api.Secret) (map[string]fileProjection, error) {
+	b.skippedKeys = nil
+	if secret == nil {
+		return map[string]fileProjection{}, nil
+	}
+	secret, err := b.checkSignature(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := b.fileMode()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err)
+	}
+
+	totalBytes := totalSecretBytes(secret)
+	glog.V(3).Infof("Received secret: %v", b.logFields("keys", len(secret.Data), "bytes", totalBytes))
+
+	if totalBytes > b.plugin.maxSize {
+		glog.Errorf("Secret is too large for volume: %v", b.logFields("bytes", totalBytes, "maxBytes", b.plugin.maxSize))
+		return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v is %v bytes, which exceeds the maximum of %v bytes",
+			b.volName, b.pod.Namespace, b.secretName, totalBytes, b.plugin.maxSize)
+	}
+	if maxKeys := b.plugin.config.MaxKeys; maxKeys > 0 && len(secret.Data) > maxKeys {
+		glog.Errorf("Secret has too many keys for volume: %v", b.logFields("keys", len(secret.Data), "maxKeys", maxKeys))
+		return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v has %v keys, which exceeds the maximum of %v keys",
+			b.volName, b.pod.Namespace, b.secretName, len(secret.Data), maxKeys)
+	}
+
+	// A secret without data is usually a mistake, but by default it still
+	// yields an empty volume.
+	if len(secret.Data) == 0 {
+		if b.nonEmpty && !b.optional {
+			glog.Errorf("Secret has no data: %v", b.logFields("nilData", secret.Data == nil))
+			return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v has no data", b.volName, b.pod.Namespace, b.secretName)
+		}
+		glog.V(3).Infof("Secret has no data; setting up an empty volume: %v", b.logFields("nilData", secret.Data == nil))
+	}
+
+	// A single key volume projects the only key of the secret, if it has
+	// one, to the configured path.
+	items := b.items
+	if b.onlyKeyPath != "" {
+		if len(secret.Data) > 1 {
+			glog.Errorf("Secret has more than one key for a single key volume: %v", b.logFields("keys", len(secret.Data)))
+			return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v has %v keys, but only one can be projected to %q",
+				b.volName, b.pod.Namespace, b.secretName, len(secret.Data), b.onlyKeyPath)
+		}
+		for key := range secret.Data {
+			items = []api.KeyToPath{{Key: key, Path: b.onlyKeyPath}}
+		}
+	}
+	// A strict volume projects every key of a secret that lists items.
+	if b.strictKeys && len(b.items) > 0 {
+		if extra := unlistedKeys(b.items, secret); len(extra) > 0 {
+			glog.Errorf("Secret has keys not listed in items: %v", b.logFields("keys", strings.Join(extra, ",")))
+			return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v has keys not listed in items: %v",
+				b.volName, b.pod.Namespace, b.secretName, strings.Join(extra, ", "))
+		}
+	}
+	// A best-effort volume projects the items it can, and skips those whose
+	// keys are missing rather than fail.
+	if b.bestEffort {
+		var skipped []string
+		if items, skipped = skipMissingItems(items, secret, b.keyFileName); len(skipped) > 0 {
+			glog.Warningf("Secret is missing keys of items; skipping them: %v", b.logFields("keys", strings.Join(skipped, ",")))
+			b.skippedKeys = skipped
+		}
+	}
+	var payload map[string]fileProjection
+	if b.bundlePath != "" {
+		payload = map[string]fileProjection{b.bundlePath: {data: bundleData(secret, b.bundleSep), mode: mode}}
+	} else if b.archivePath != "" {
+		var data []byte
+		if data, err = archiveData(secret, mode); err == nil {
+			payload = map[string]fileProjection{b.archivePath: {data: data, mode: mode}}
+		}
+	} else {
+		payload, err = makePayload(items, secret, mode, b.keyFileName)
+	}
+	if err == nil {
+		err = b.addEnvFile(payload, secret, mode)
+	}
+	if err == nil {
+		err = validatePayload(payload)
+	}
+	if err != nil {
+		glog.Errorf("Couldn't project secret into volume: %v", b.logFields("err", err))
+		return nil, fmt.Errorf("Cannot setup secret volume %v: secret %v/%v %v", b.volName, b.pod.Namespace, b.secretName, err)
+	}
+	if err := b.checkSecretType(secret, payload); err != nil {
+		return nil, err
+	}
+
+	if b.opts.FSGroup != nil {
+		// Members of the fsGroup need to be able to read every file.
+		for name, file := range payload {
+			file.mode |= 0040
+			payload[name] = file
+		}
+	}
+	return payload, nil
+}
+
+// bundleSeparator returns the separator of the values in the bundle file of
+// a volume with the given source.
+func bundleSeparator(source *api.SecretVolumeSource) string {
+	if source.BundleSeparator == nil {
+		return "\n"
+	}
+	return *source.BundleSeparator
+}
+
+// bundleData returns the values of secret concatenated in the order of their
+// keys, with separator between them.  The order does not depend on the map
+// iteration order, so an unchanged secret always yields the same bundle.
+func bundleData(secret *api.Secret, separator string) []byte {
+	var buf bytes.Buffer
+	for i, key := range secretKeys(secret) {
+		if i > 0 {
+			buf.WriteString(separator)
+		}
+		buf.Write(secret.Data[key])
+	}
+	return buf.Bytes()
+}
+
+// setRootMode sets the permissions of the volume root to mode, replacing
+// whatever the wrapped volume created it with.  Anyone who may read the
+// directory may also traverse it.  If fsGroup is set, the group is given
+// ownership of the root, permission to read it, and the setgid bit.
+func setRootMode(dir string, mode os.FileMode, fsGroup *int64) error {
+	mode = mode.Perm()
+	if fsGroup != nil {
+		if err := setFileOwner(dir, -1, int(*fsGroup)); err != nil {
+			return err
+		}
+		mode |= 0040 | os.ModeSetgid
+	}
+	mode |= (mode & 0444) >> 2
+	return setFileMode(dir, mode)
+}
+
+// setContext applies the SELinux context to dir and everything beneath it,
+// so that containers can read the files the writer just created.
+func (b *secretVolumeBuilder) setContext(dir, context string) error {
+	glog.V(3).Infof("Setting SELinux context of secret volume: %v", b.logFields("dir", dir, "context", context))
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return b.chconRunner.SetContext(p, context)
+	})
+}
+
+// removeContents removes everything in dir after a failed first setup,
+// along with the feeders of any named pipes and any socket server it
+// started.
+// A memory-backed volume that was already made read-only is made writable
+// again, and makeReadOnly is set so that it is made read-only once more
+// afterwards.
+func (b *secretVolumeBuilder) removeContents(dir string, makeReadOnly *bool) {
+	b.plugin.stopPipes(b.podUID, b.volName)
+	b.plugin.stopSocket(b.podUID, b.volName)
+	if err := b.unmountFileOverlay(); err != nil {
+		glog.Errorf("Error cleaning up secret volume: %v", b.logFields("dir", dir, "err", err))
+		return
+	}
+	if b.isMemoryBacked() && !*makeReadOnly {
+		if err := b.remount(dir, false); err != nil {
+			glog.Errorf("Error remounting secret volume read-write to clean it up: %v", b.logFields("dir", dir, "err", err))
+			return
+		}
+		*makeReadOnly = true
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		glog.Errorf("Error listing secret volume to clean it up: %v", b.logFields("dir", dir, "err", err))
+		return
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(path.Join(dir, entry.Name())); err != nil {
+			glog.Errorf("Error cleaning up secret volume: %v", b.logFields("dir", dir, "file", entry.Name(), "err", err))
+			return
+		}
+	}
+	glog.V(2).Infof("Cleaned up secret volume after failed setup: %v", b.logFields("dir", dir, "files", len(entries)))
+}
+
+// remount changes whether the tmpfs backing dir is mounted read-only, and
+// applies any other mount options given.
+func (sv *secretVolume) remount(dir string, readOnly bool, options ...string) error {
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	return sv.mounter.Mount("", dir, "", append([]string{"remount", mode}, options...))
+}
+
+// tmpfsSize returns the size in bytes of a tmpfs that can hold the given
+// number of pages, plus margin.  The size is at least a page, since a size
+// of zero would not limit the tmpfs at all.
+func tmpfsSize(pages, margin int64) int64 {
+	pageSize := int64(os.Getpagesize())
+	size := pages*pageSize + margin
+	if size < pageSize {
+		size = pageSize
+	}
+	return size
+}
+
+// filePages returns the number of pages that a file of size bytes takes on
+// a tmpfs, which gives every file whole pages.
+func filePages(size int64) int64 {
+	pageSize := int64(os.Getpagesize())
+	return (size + pageSize - 1) / pageSize
+}
+
+// payloadPages returns the number of pages that the files of payload take
+// on a tmpfs.
+func payloadPages(payload map[string]fileProjection) int64 {
+	pages := int64(0)
+	for _, file := range payload {
+		if !file.pipe {
+			pages += filePages(int64(len(file.data)))
+		}
+	}
+	return pages
+}
+
+// publishedPages returns the number of pages that the files currently
+// published by writer take on a tmpfs.
+func publishedPages(writer *atomicWriter) (int64, error) {
+	dir, files, err := writer.publishedFiles(nil)
+	if err != nil {
+		return 0, err
+	}
+	pages := int64(0)
+	for p := range files {
+		info, err := os.Lstat(path.Join(dir, p))
+		if err != nil {
+			return 0, err
+		}
+		if info.Mode().IsRegular() {
+			pages += filePages(info.Size())
+		}
+	}
+	return pages, nil
+}
+
+// GetAttributes reports that secret volumes are read-only to containers and
+// that the plugin manages and labels their contents.
+func (b *secretVolumeBuilder) GetAttributes() volume.Attributes {
+	return volume.Attributes{
+		ReadOnly:        true,
+		Managed:         true,
+		SupportsSELinux: true,
+	}
+}
+
+// IsReadOnly returns true; containers can never write to secret volumes.
+func (b *secretVolumeBuilder) IsReadOnly() bool {
+	return b.GetAttributes().ReadOnly
+}
+
+func totalSecretBytes(secret *api.Secret) int {
+	totalSize := 0
+	for _, bytes := range secret.Data {
+		totalSize += len(bytes)
+	}
+
+	return totalSize
+}
+
+// checkFreeSpace returns an error if the filesystem holding dir is known to
+// lack the space to write payload.  A failure to find the free space is
+// only logged, since the write itself may still succeed.
+func (b *secretVolumeBuilder) checkFreeSpace(dir string, payload map[string]fileProjection) error {
+	available, known, err := b.freeSpace.AvailableBytes(dir)
+	if err != nil {
+		glog.Warningf("Couldn't determine free space of secret volume: %v", b.logFields("dir", dir, "err", err))
+		return nil
+	}
+	if known && payloadMetrics(payload).Bytes > available {
+		return b.insufficientSpaceError(dir, payload)
+	}
+	return nil
+}
+
+// insufficientSpaceError returns an error describing the shortfall of space
+// for writing payload to dir.
+func (b *secretVolumeBuilder) insufficientSpaceError(dir string, payload map[string]fileProjection) error {
+	needed := payloadMetrics(payload).Bytes
+	available, known, err := b.freeSpace.AvailableBytes(dir)
+	if err != nil || !known {
+		return fmt.Errorf("Cannot setup secret volume %v: insufficient space: %v bytes needed", b.volName, needed)
+	}
+	return fmt.Errorf("Cannot setup secret volume %v: insufficient space: %v bytes needed, %v bytes available", b.volName, needed, available)
+}
+
+// isNoSpaceError returns true if err is from a filesystem that ran out of
+// space.
+func isNoSpaceError(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+	return err == syscall.ENOSPC
+}
+
+// payloadMetrics returns the usage of a volume holding payload.  Named
+// pipes take up no bytes.
+func payloadMetrics(payload map[string]fileProjection) volume.Metrics {
+	metrics := volume.Metrics{Files: int64(len(payload))}
+	for _, file := range payload {
+		if !file.pipe {
+			metrics.Bytes += int64(len(file.data))
+		}
+	}
+	return metrics
+}
+
+// secretVolumeCleaner handles cleaning up secret volumes.
+type secretVolumeCleaner struct {
+	*secretVolume
+}
+
+var _ volume.Cleaner = &secretVolumeCleaner{}
+
+func (c *secretVolumeCleaner) TearDown() error {
+	return c.TearDownAt(c.GetPath())
+}
+
+func (c *secretVolumeCleaner) TearDownAt(dir string) (err error) {
+	glog.V(3).Infof("Tearing down secret volume: %v", formatLogFields("pod", c.podUID, "volume", c.volName, "dir", dir))
+	// Every teardown is audited, whichever way it returns.
+	defer func() { c.auditUnmount(err) }()
+
+	// A setup in progress would write to the volume while it is removed, or
+	// set it up again behind the teardown; cancel it and wait for it to
+	// give up.  It cleans up after itself first.  The periodic resync, if
+	// any, is stopped the same way.
+	key := volumeKey(c.podUID, c.volName)
+	c.plugin.stopResync(key)
+	c.plugin.cancelSetUp(key)
+	unlock := c.plugin.lockVolume(key)
+	defer unlock()
+
+	c.plugin.stopPipes(c.podUID, c.volName)
+	c.plugin.stopSocket(c.podUID, c.volName)
+	if err := c.unmountFileOverlay(); err != nil {
+		return err
+	}
+
+	// A teardown may be retried after the volume is gone; there is nothing
+	// left to do but drop any leftover bookkeeping.
+	if _, err := os.Lstat(dir); os.IsNotExist(err) {
+		glog.V(3).Infof("Secret volume is already torn down: %v", formatLogFields("pod", c.podUID, "volume", c.volName, "dir", dir))
+		return os.RemoveAll(c.getMetaDir())
+	}
+
+	if c.markedForWipe() {
+		if err := c.wipe(dir); err != nil {
+			glog.Errorf("Error wiping secret volume: %v", formatLogFields("pod", c.podUID, "volume", c.volName, "dir", dir, "err", err))
+			return err
+		}
+	}
+
+	if err := c.tearDownDir(dir); err != nil {
+		return err
+	}
+
+	// Remove the readiness file along with the rest of the volume's
+	// bookkeeping; it may already be gone if an earlier teardown was
+	// interrupted.
+	return os.RemoveAll(c.getMetaDir())
+}
+
+// setUpDir creates the directory at dir that the volume is written to.
+func (b *secretVolumeBuilder) setUpDir(dir string) error {
+	if b.hostDir != "" {
+		return b.setUpHostDir(dir)
+	}
+	if b.plainDir {
+		// The cleaner has no spec, so leave it a marker to find.
+		if err := b.writeMetaFile(plainDirMarkerName, nil); err != nil {
+			return err
+		}
+		return os.MkdirAll(dir, 0750)
+	}
+
+	// Wrap EmptyDir, let it do the setup.
+	wrapped, err := b.plugin.host.NewWrapperBuilder(wrappedVolumeSpec(b.volName, b.plugin.medium), &b.pod, *b.opts, b.mounter)
+	if err != nil {
+		return err
+	}
+	return wrapped.SetUpAt(dir)
+}
+
+// plainDirMarkerName is the name of the file in the meta dir of a volume
+// that is a plain directory rather than a wrapped EmptyDir.
+const plainDirMarkerName = "plainDirectory"
+
+// isPlainDir returns true if the volume is a plain directory.
+func (sv *secretVolume) isPlainDir() bool {
+	_, err := os.Stat(path.Join(sv.getMetaDir(), plainDirMarkerName))
+	return err == nil
+}
+
+// tearDownDir removes the directory at dir that the volume was written to.
+func (c *secretVolumeCleaner) tearDownDir(dir string) error {
+	if c.hostDir != "" {
+		return c.tearDownHostDir(dir)
+	}
+	if c.isPlainDir() {
+		return os.RemoveAll(dir)
+	}
+
+	// Wrap EmptyDir, let it do the teardown.
+	wrapped, err := c.plugin.host.NewWrapperCleaner(wrappedVolumeSpec(c.volName, c.plugin.medium), c.podUID, c.mounter)
+	if err != nil {
+		return err
+	}
+	return wrapped.TearDownAt(dir)
+}
+
+// wipeMarkerName is the name of the file in the meta dir of a volume that
+// must be wiped on teardown.
+const wipeMarkerName = "wipe"
+
+// markForWipe records that the volume must be wiped on teardown.
+func (sv *secretVolume) markForWipe() error {
+	if err := os.MkdirAll(sv.getMetaDir(), 0750); err != nil {
+		return err
+	}
+	file, err := os.Create(path.Join(sv.getMetaDir(), wipeMarkerName))
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// markedForWipe returns true if the volume must be wiped on teardown.
+func (sv *secretVolume) markedForWipe() bool {
+	_, err := os.Stat(path.Join(sv.getMetaDir(), wipeMarkerName))
+	return err == nil
+}
+
+// wipe overwrites every file in the volume at dir with zeros, so that the
+// secret cannot be recovered from pages the files occupied.  A mounted
+// memory-backed volume is made writable first.
+func (sv *secretVolume) wipe(dir string) error {
+	if sv.hostDir == "" && sv.plugin.medium == api.StorageMediumMemory {
+		isMnt, err := sv.mounter.IsMountPoint(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if isMnt {
+			if err := sv.remount(dir, false); err != nil {
+				return err
+			}
+		}
+	}
+	// Walk does not follow symlinks, so each file is visited once, in the
+	// data directory that holds it.
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		// The files are read-only; they are about to be removed anyway.
+		if err := os.Chmod(p, info.Mode().Perm()|0200); err != nil {
+			return err
+		}
+		return zeroFile(p, info.Size())
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// zeroFile overwrites the first size bytes of the file at p with zeros and
+// flushes them to the file's storage.
+func zeroFile(p string, size int64) error {
+	file, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zeros := make([]byte, 4096)
+	for written := int64(0); written < size; {
+		n := int64(len(zeros))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := file.Write(zeros[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return file.Sync()
 }
@@ -0,0 +1,147 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/golang/glog"
+)
+
+// annotationOption is an option of a secret volume that can be set through
+// an annotation of its pod, so it can be tried out before it becomes a field
+// of the volume source.
+type annotationOption struct {
+	// set sets the option of b from the value of its annotation, or returns
+	// an error if the value is malformed.
+	set func(b *secretVolumeBuilder, value string) error
+	// inSource returns true if source sets the option, in which case the
+	// annotation is ignored.
+	inSource func(source *api.SecretVolumeSource) bool
+}
+
+// inNoSource is the inSource of options that are not fields of the volume
+// source.
+func inNoSource(source *api.SecretVolumeSource) bool {
+	return false
+}
+
+// boolOption returns the set of an option whose annotation holds "true" or
+// "false".
+func boolOption(set func(b *secretVolumeBuilder, enabled bool)) func(*secretVolumeBuilder, string) error {
+	return func(b *secretVolumeBuilder, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		set(b, enabled)
+		return nil
+	}
+}
+
+// annotationOptions maps the names of the options that can be set through
+// annotations to the options.
+var annotationOptions = map[string]annotationOption{
+	"wipe-on-teardown": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.wipe = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.WipeOnTeardown },
+	},
+	"sanitize-key-names": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.sanitize = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.SanitizeKeyNames },
+	},
+	"write-content-hash": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.contentHash = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.WriteContentHash },
+	},
+	"plain-directory": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.plainDir = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.PlainDirectory },
+	},
+	"require-non-empty": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.nonEmpty = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.RequireNonEmpty },
+	},
+	"copy-files": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.copyFiles = enabled }),
+		func(source *api.SecretVolumeSource) bool { return source.ProjectionStrategy != "" },
+	},
+	// refresh-on-remount is on by default: a setup of a volume that is
+	// already set up refreshes its files.
+	"refresh-on-remount": {
+		boolOption(func(b *secretVolumeBuilder, enabled bool) { b.noRemountRefresh = !enabled }),
+		inNoSource,
+	},
+	// file-overlay holds a JSON object; see parseFileOverlay.
+	"file-overlay": {
+		func(b *secretVolumeBuilder, value string) error {
+			overlay, err := parseFileOverlay(value)
+			if err != nil {
+				return err
+			}
+			b.overlay = overlay
+			return nil
+		},
+		inNoSource,
+	},
+	// delivery-mode holds Files or Socket; see setDeliveryMode.
+	"delivery-mode": {setDeliveryMode, inNoSource},
+	// named-pipes holds a JSON array; see parseNamedPipes.
+	"named-pipes": {
+		func(b *secretVolumeBuilder, value string) error {
+			pipes, err := parseNamedPipes(value)
+			if err != nil {
+				return err
+			}
+			b.pipes = pipes
+			return nil
+		},
+		inNoSource,
+	},
+}
+
+// applyOptionAnnotations sets the options of b that source leaves unset from
+// the annotations of its pod.  An option the source sets takes precedence
+// over its annotation; since the options of the source are booleans, a
+// source sets one by enabling it.  An unknown option or a malformed value
+// is logged as a warning and otherwise ignored, leaving the option at its
+// default.
+func (b *secretVolumeBuilder) applyOptionAnnotations(source *api.SecretVolumeSource) {
+	prefix := api.SecretVolumeOptionAnnotationPrefix + b.volName + "."
+	for key, value := range b.pod.Annotations {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		option := strings.TrimPrefix(key, prefix)
+		opt, found := annotationOptions[option]
+		if !found {
+			glog.Warningf("Ignoring unknown secret volume option annotation: %v", b.logFields("annotation", key))
+			continue
+		}
+		if opt.inSource(source) {
+			glog.V(3).Infof("Ignoring secret volume option annotation for an option set by the volume source: %v", b.logFields("option", option))
+			continue
+		}
+		if err := opt.set(b, value); err != nil {
+			glog.Warningf("Ignoring malformed secret volume option annotation: %v", b.logFields("annotation", key, "err", err))
+			continue
+		}
+		glog.V(3).Infof("Set secret volume option from annotation: %v", b.logFields("option", option, "value", value))
+	}
+}
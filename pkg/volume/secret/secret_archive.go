@@ -0,0 +1,77 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// archiveModTime is the modification time of every entry of an archive, so
+// that the archive of the same secret is always the same bytes and a
+// refresh does not rewrite it.
+var archiveModTime = time.Unix(0, 0)
+
+// archiveData returns a gzip-compressed tar archive holding a regular file
+// for each key of secret, named after the key, with the given mode and the
+// value of the key, in the order of their keys.
+func archiveData(secret *api.Secret, mode os.FileMode) ([]byte, error) {
+	files := make(map[string]fileProjection, len(secret.Data))
+	for key, value := range secret.Data {
+		files[key] = fileProjection{key: key, data: value, mode: mode}
+	}
+	return archivePayload(files)
+}
+
+// archivePayload returns a gzip-compressed tar archive holding a regular
+// file for each file of payload, at its path and with its mode and data, in
+// the order of their paths.
+func archivePayload(payload map[string]fileProjection) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.ModTime = archiveModTime
+	tw := tar.NewWriter(zw)
+	for _, p := range payloadPaths(payload) {
+		file := payload[p]
+		header := &tar.Header{
+			Name:     p,
+			Mode:     int64(file.mode.Perm()),
+			Size:     int64(len(file.data)),
+			ModTime:  archiveModTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("has key %q, which cannot be written to the archive: %v", file.key, err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return nil, fmt.Errorf("has key %q, which cannot be written to the archive: %v", file.key, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
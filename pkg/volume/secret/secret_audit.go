@@ -0,0 +1,135 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/golang/glog"
+)
+
+// auditQueueSize is the number of audit events that may wait to be
+// recorded before further events are dropped.
+const auditQueueSize = 100
+
+// AuditEventType is the kind of an AuditEvent.
+type AuditEventType string
+
+const (
+	// AuditEventMount is recorded when a secret volume is first set up.
+	AuditEventMount AuditEventType = "mount"
+	// AuditEventUnmount is recorded when a secret volume is torn down.
+	AuditEventUnmount AuditEventType = "unmount"
+)
+
+// AuditEvent records that secrets were mounted into, or unmounted from, a
+// pod.
+type AuditEvent struct {
+	Type AuditEventType
+	Time time.Time
+	// Node is the hostname of the node.
+	Node   string
+	PodUID types.UID
+	// Namespace, PodName and Secrets are only known for mount events.
+	Namespace string
+	PodName   string
+	Volume    string
+	// Secrets are the names of the secrets projected into the volume.
+	Secrets []string
+	// Error is the error the operation failed with, or "" if it succeeded.
+	Error string
+}
+
+// AuditSink records audit events durably, for example to a file or an
+// external system.
+type AuditSink interface {
+	// Record records event.  Events are recorded one at a time, in order,
+	// apart from the setups and teardowns they describe.
+	Record(event AuditEvent) error
+}
+
+// auditHost is implemented by volume hosts that keep an audit trail of the
+// secret volumes they mount.
+type auditHost interface {
+	// GetSecretVolumeAuditSink returns the sink for audit events, or nil to
+	// keep no audit trail.
+	GetSecretVolumeAuditSink() AuditSink
+}
+
+// initAudit starts recording audit events for plugin if its host asks for
+// them.
+func (plugin *secretPlugin) initAudit() {
+	h, ok := plugin.host.(auditHost)
+	if !ok {
+		return
+	}
+	sink := h.GetSecretVolumeAuditSink()
+	if sink == nil {
+		return
+	}
+	node, err := os.Hostname()
+	if err != nil {
+		glog.Warningf("Couldn't get the hostname for secret volume audit events: %v", err)
+	}
+	plugin.auditNode = node
+	plugin.auditEvents = make(chan AuditEvent, auditQueueSize)
+	go func() {
+		for event := range plugin.auditEvents {
+			if err := sink.Record(event); err != nil {
+				glog.Errorf("Couldn't record secret volume audit event: %v", formatLogFields("type", event.Type, "pod", event.PodUID, "volume", event.Volume, "err", err))
+			}
+		}
+	}()
+}
+
+// audit queues event to be recorded, filling in its time and node.  It
+// never blocks: if the sink has fallen too far behind, the event is dropped
+// and logged instead.
+func (plugin *secretPlugin) audit(event AuditEvent, err error) {
+	if plugin.auditEvents == nil {
+		return
+	}
+	event.Time = plugin.clock.Now()
+	event.Node = plugin.auditNode
+	if err != nil {
+		event.Error = err.Error()
+	}
+	select {
+	case plugin.auditEvents <- event:
+	default:
+		glog.Errorf("Dropped secret volume audit event: %v", formatLogFields("type", event.Type, "pod", event.PodUID, "volume", event.Volume))
+	}
+}
+
+// auditMount records the first setup of the volume of b.
+func (b *secretVolumeBuilder) auditMount(err error) {
+	b.plugin.audit(AuditEvent{
+		Type:      AuditEventMount,
+		PodUID:    b.podUID,
+		Namespace: b.pod.Namespace,
+		PodName:   b.pod.Name,
+		Volume:    b.volName,
+		Secrets:   b.secretNames(),
+	}, err)
+}
+
+// auditUnmount records a teardown of the volume of c.
+func (c *secretVolumeCleaner) auditUnmount(err error) {
+	c.plugin.audit(AuditEvent{Type: AuditEventUnmount, PodUID: c.podUID, Volume: c.volName}, err)
+}
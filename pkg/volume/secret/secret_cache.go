@@ -0,0 +1,113 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/controller/framework"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/fields"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/runtime"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/golang/glog"
+)
+
+// secretCacheResyncPeriod is how often the secret cache is resynced with
+// the API server.
+const secretCacheResyncPeriod = 10 * time.Minute
+
+// cacheHost is implemented by volume hosts that serve secrets to secret
+// volumes from a cache kept up to date by watching the API server, rather
+// than getting the secret from the API server for every setup.
+type cacheHost interface {
+	// UseSecretVolumeCache returns true if secrets should be cached.
+	UseSecretVolumeCache() bool
+}
+
+// secretCache holds the secrets of every namespace, as seen by a watch of
+// the API server.
+type secretCache struct {
+	store      cache.Store
+	controller *framework.Controller
+}
+
+// newSecretCache starts a cache of the secrets visible to kubeClient.  It
+// runs until stopCh is closed.
+func newSecretCache(kubeClient client.Interface, stopCh <-chan struct{}) *secretCache {
+	store, controller := framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func() (runtime.Object, error) {
+				return kubeClient.Secrets(api.NamespaceAll).List(labels.Everything(), fields.Everything())
+			},
+			WatchFunc: func(resourceVersion string) (watch.Interface, error) {
+				return kubeClient.Secrets(api.NamespaceAll).Watch(labels.Everything(), fields.Everything(), resourceVersion)
+			},
+		},
+		&api.Secret{},
+		secretCacheResyncPeriod,
+		framework.ResourceEventHandlerFuncs{},
+	)
+	go controller.Run(stopCh)
+	return &secretCache{store: store, controller: controller}
+}
+
+// get returns the cached secret with the given name in namespace, or nil
+// if the cache has not synced yet or does not hold the secret.  The
+// returned secret is shared with the cache and must not be modified.
+func (c *secretCache) get(namespace, name string) *api.Secret {
+	if !c.controller.HasSynced() {
+		return nil
+	}
+	// The store is keyed by namespace/name, so only secrets in namespace
+	// can be returned.
+	obj, exists, err := c.store.GetByKey(namespace + "/" + name)
+	if err != nil {
+		glog.Errorf("Couldn't look up secret %v/%v in the cache: %v", namespace, name, err)
+		return nil
+	}
+	if !exists {
+		return nil
+	}
+	return obj.(*api.Secret)
+}
+
+// initSecretCache starts the secret cache of plugin if its configuration or
+// host asks for one and the host has a client for the API server.
+func (plugin *secretPlugin) initSecretCache() {
+	useCache := plugin.config.UseCache
+	if h, ok := plugin.host.(cacheHost); ok {
+		useCache = h.UseSecretVolumeCache()
+	}
+	if !useCache {
+		return
+	}
+	if plugin.getter != nil {
+		glog.Warningf("Not caching secrets for secret volumes: the host gets secrets from elsewhere than the API server")
+		return
+	}
+	kubeClient := plugin.host.GetKubeClient()
+	if kubeClient == nil {
+		glog.Warningf("Not caching secrets for secret volumes: kube client is not configured")
+		return
+	}
+	plugin.cache = newSecretCache(kubeClient, util.NeverStop)
+}
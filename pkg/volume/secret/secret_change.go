@@ -0,0 +1,104 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"sort"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/golang/glog"
+)
+
+// changeQueueSize is the number of changes that may wait to be passed to
+// the change hook before further changes are dropped.
+const changeQueueSize = 100
+
+// VolumeChange describes a refresh of a secret volume that changed the
+// content of its files.
+type VolumeChange struct {
+	PodUID types.UID
+	Volume string
+	// Path is the path of the volume on the node.
+	Path string
+	// Keys are the keys of the secrets whose files were added or updated,
+	// sorted.  A key projected by more than one secret is listed once.
+	Keys []string
+	// Paths are the paths, relative to the volume, of the files that were
+	// added, updated or removed, sorted.
+	Paths []string
+}
+
+// ChangeHook is called after a refresh of a secret volume changed its files,
+// for example to have the application reading them reload.  It is called
+// apart from the refresh, one change at a time, in order; an error is only
+// logged.
+type ChangeHook func(change VolumeChange) error
+
+// changeHookHost is implemented by volume hosts that want to know when a
+// refresh changes the files of a secret volume.
+type changeHookHost interface {
+	// GetSecretVolumeOnChange returns the hook to call on a change, or nil
+	// to call none.
+	GetSecretVolumeOnChange() ChangeHook
+}
+
+// initChangeHook starts passing changes to the change hook of the host, if
+// it has one.
+func (plugin *secretPlugin) initChangeHook() {
+	h, ok := plugin.host.(changeHookHost)
+	if !ok {
+		return
+	}
+	hook := h.GetSecretVolumeOnChange()
+	if hook == nil {
+		return
+	}
+	plugin.changeQueue = make(chan VolumeChange, changeQueueSize)
+	go func() {
+		for change := range plugin.changeQueue {
+			if err := hook(change); err != nil {
+				glog.Errorf("Change hook of secret volume failed: %v", formatLogFields("pod", change.PodUID, "volume", change.Volume, "err", err))
+			}
+		}
+	}()
+}
+
+// notifyChange queues a change to the files at changedPaths of the volume at
+// dir, which now holds payload, for the change hook.  It never blocks: if
+// the hook has fallen too far behind, the change is dropped and logged
+// instead.
+func (b *secretVolumeBuilder) notifyChange(dir string, payload map[string]fileProjection, changedPaths []string) {
+	if b.plugin.changeQueue == nil || len(changedPaths) == 0 {
+		return
+	}
+	keys := map[string]bool{}
+	for _, p := range changedPaths {
+		if file, found := payload[p]; found && file.key != "" {
+			keys[file.key] = true
+		}
+	}
+	change := VolumeChange{PodUID: b.podUID, Volume: b.volName, Path: dir, Paths: changedPaths}
+	for key := range keys {
+		change.Keys = append(change.Keys, key)
+	}
+	sort.Strings(change.Keys)
+	select {
+	case b.plugin.changeQueue <- change:
+	default:
+		glog.Errorf("Dropped change of secret volume for its change hook: %v", b.logFields("dir", dir, "paths", len(changedPaths)))
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/golang/glog"
+)
+
+// podClientHost is implemented by volume hosts that can talk to the API
+// server with the credentials of a pod's service account, so that the
+// secrets of a pod are read with no more access than the pod itself has
+// rather than with the node's credentials.
+type podClientHost interface {
+	// GetKubeClientForPod returns a client authenticated with the token of
+	// the service account of pod, or nil if there is none.
+	GetKubeClientForPod(pod *api.Pod) (client.Interface, error)
+}
+
+// podKubeClient returns the client the secrets of pod should be read with,
+// or nil to read them with the node's client.
+func (plugin *secretPlugin) podKubeClient(pod *api.Pod) client.Interface {
+	h, ok := plugin.host.(podClientHost)
+	if !ok {
+		return nil
+	}
+	kubeClient, err := h.GetKubeClientForPod(pod)
+	if err != nil {
+		glog.Warningf("Couldn't get a client for the service account of pod; using the node's client: %v",
+			formatLogFields("pod", pod.UID, "serviceAccount", pod.Spec.ServiceAccountName, "err", err))
+		return nil
+	}
+	return kubeClient
+}
+
+// getKubeClient returns the client the secrets of the volume are read with:
+// that of its pod if there is one, or else that of the node.
+func (b *secretVolumeBuilder) getKubeClient() client.Interface {
+	if b.kubeClient != nil {
+		return b.kubeClient
+	}
+	return b.plugin.host.GetKubeClient()
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// addEnvFile adds the env file of the volume, if it has one, to the payload
+// projected from secret, next to the files of the keys.
+func (b *secretVolumeBuilder) addEnvFile(payload map[string]fileProjection, secret *api.Secret, mode os.FileMode) error {
+	if b.envFile == nil {
+		return nil
+	}
+	for _, p := range payloadPaths(payload) {
+		if strings.EqualFold(p, b.envFile.Path) {
+			return fmt.Errorf("projects key %q to path %q, which is the path of the env file", payload[p].key, p)
+		}
+	}
+	data, err := envFileData(secret, b.envFile.Keys)
+	if err != nil {
+		return err
+	}
+	payload[b.envFile.Path] = fileProjection{data: data, mode: mode}
+	return nil
+}
+
+// envFileData renders the given keys of secret, or all of them if keys is
+// empty, as KEY="value" lines in the order of their keys.  In each value, a
+// backslash is written before every backslash, double quote, dollar sign and
+// backquote, and every newline is written as \n, which is how shells and
+// dotenv readers unquote double-quoted values.  A value that is not valid
+// UTF-8 or holds any other control character than a newline or a tab has no
+// such rendering, and is an error rather than being mangled.
+func envFileData(secret *api.Secret, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		keys = secretKeys(secret)
+	} else {
+		keys = append([]string(nil), keys...)
+		sort.Strings(keys)
+	}
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		value, found := secret.Data[key]
+		if !found {
+			return nil, fmt.Errorf("has no key %q for the env file", key)
+		}
+		if !util.IsCIdentifier(key) {
+			return nil, fmt.Errorf("has key %q, which is not a C identifier and cannot be written to the env file", key)
+		}
+		if !utf8.Valid(value) {
+			return nil, fmt.Errorf("has key %q, whose value is not valid UTF-8 and cannot be written to the env file", key)
+		}
+		buf.WriteString(key)
+		buf.WriteString(`="`)
+		for _, r := range string(value) {
+			switch {
+			case r == '\\' || r == '"' || r == '$' || r == '`':
+				buf.WriteByte('\\')
+				buf.WriteRune(r)
+			case r == '\n':
+				buf.WriteString(`\n`)
+			case r == '\t' || !unicode.IsControl(r):
+				buf.WriteRune(r)
+			default:
+				return nil, fmt.Errorf("has key %q, whose value holds the control character %U and cannot be written to the env file", key, r)
+			}
+		}
+		buf.WriteString("\"\n")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+)
+
+// SetUpStage names the stage at which the setup of a secret volume failed,
+// so that failures can be told apart and counted in logs.
+type SetUpStage string
+
+const (
+	SetUpStageValidate    SetUpStage = "validate"    // the spec or the payload is not acceptable
+	SetUpStageMount       SetUpStage = "mount"       // checking, setting up or remounting the wrapped volume, or binding a file overlay
+	SetUpStageFetch       SetUpStage = "fetch"       // getting the secrets, or projecting them to files
+	SetUpStageWrite       SetUpStage = "write"       // writing the files or the bookkeeping of the volume
+	SetUpStagePermissions SetUpStage = "permissions" // setting the mode, ownership or SELinux context of the volume
+)
+
+// SetUpError is returned by a failed setup or refresh of a secret volume.
+// It names the stage that failed and wraps the error that failed it, which
+// is left unchanged.
+type SetUpError struct {
+	Stage SetUpStage
+	Err   error
+}
+
+func (e *SetUpError) Error() string {
+	return fmt.Sprintf("%v (stage=%v)", e.Err, e.Stage)
+}
+
+// Unwrap returns the error that failed the setup.
+func (e *SetUpError) Unwrap() error {
+	return e.Err
+}
+
+// setUpFailed returns err wrapped in a SetUpError naming stage, or nil if
+// err is nil.  An error that already names its stage is returned as it is,
+// and so are ErrNoKubeClient and volume.ErrSetUpCanceled, which callers
+// compare errors against directly.
+func setUpFailed(stage SetUpStage, err error) error {
+	if err == nil || err == ErrNoKubeClient || err == volume.ErrSetUpCanceled {
+		return err
+	}
+	switch err.(type) {
+	case *SetUpError, *retryAfterError:
+		return err
+	}
+	return &SetUpError{Stage: stage, Err: err}
+}
+
+// Cause returns the error that failed a setup with err, without the stage
+// and retry suggestion it was wrapped in, for callers that check it with
+// errors.IsNotFound and the like.  Go releases before 1.13 do not unwrap
+// errors by themselves.
+func Cause(err error) error {
+	if e, ok := err.(*retryAfterError); ok {
+		err = e.err
+	}
+	if e, ok := err.(*SetUpError); ok {
+		err = e.Err
+	}
+	return err
+}
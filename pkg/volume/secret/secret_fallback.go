@@ -0,0 +1,156 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/latest"
+	"github.com/golang/glog"
+)
+
+// fallbackDirHost is implemented by volume hosts that keep a node-local copy
+// of every secret fetched for a secret volume, for use when the API server
+// cannot be reached.  The copies are written unencrypted to disk, so the
+// directory should only be readable by root.
+type fallbackDirHost interface {
+	// GetSecretVolumeFallbackDir returns the directory holding the copies,
+	// or "" to disable them.
+	GetSecretVolumeFallbackDir() string
+}
+
+// secretFallback is a directory of node-local copies of secrets, laid out
+// as <dir>/<namespace>/<name>.  A copy may be out of date.
+type secretFallback struct {
+	dir string
+}
+
+func (f *secretFallback) path(namespace, name string) string {
+	return path.Join(f.dir, namespace, name)
+}
+
+// store saves a copy of secret as the secret with the given name in
+// namespace, replacing any previous copy.
+func (f *secretFallback) store(namespace, name string, secret *api.Secret) error {
+	data, err := latest.Codec.Encode(secret)
+	if err != nil {
+		return err
+	}
+	nsDir := path.Join(f.dir, namespace)
+	if err := os.MkdirAll(nsDir, 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it into place, so that a reader
+	// never sees a partial copy.
+	tmp, err := ioutil.TempFile(nsDir, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(namespace, name))
+}
+
+// load returns the copy of the secret with the given name in namespace, or
+// nil if there is none.
+func (f *secretFallback) load(namespace, name string) (*api.Secret, error) {
+	data, err := ioutil.ReadFile(f.path(namespace, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	obj, err := latest.Codec.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := obj.(*api.Secret)
+	if !ok {
+		return nil, fmt.Errorf("expected a secret, got %T", obj)
+	}
+	return secret, nil
+}
+
+// remove deletes the copy of the secret with the given name in namespace.
+func (f *secretFallback) remove(namespace, name string) error {
+	if err := os.Remove(f.path(namespace, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// initSecretFallback enables the node-local copies of secrets for plugin if
+// its host asks for them.
+func (plugin *secretPlugin) initSecretFallback() {
+	h, ok := plugin.host.(fallbackDirHost)
+	if !ok {
+		return
+	}
+	if dir := h.GetSecretVolumeFallbackDir(); dir != "" {
+		plugin.fallback = &secretFallback{dir: dir}
+	}
+}
+
+// isUnreachableError returns true if err from a Get of a secret could mean
+// the API server was not reached, rather than an answer from it such as a
+// missing secret or a denied request.
+func isUnreachableError(err error) bool {
+	if _, ok := err.(*errors.StatusError); ok {
+		return isRetryableAPIError(err)
+	}
+	return true
+}
+
+// updateFallback brings the node-local copy of the secret for b in line with
+// the result of a live Get.  Failures are only logged; the copy is a best
+// effort.
+func (b *secretVolumeBuilder) updateFallback(secret *api.Secret, err error) {
+	f := b.plugin.fallback
+	switch {
+	case err == nil:
+		if err := f.store(b.pod.Namespace, b.secretName, secret); err != nil {
+			glog.Errorf("Couldn't save a fallback copy of secret: %v", b.logFields("err", err))
+		}
+	case errors.IsNotFound(err):
+		if err := f.remove(b.pod.Namespace, b.secretName); err != nil {
+			glog.Errorf("Couldn't remove the fallback copy of secret: %v", b.logFields("err", err))
+		}
+	}
+}
+
+// loadFallback returns the node-local copy of the secret for b, or nil if
+// there is none or it cannot be read.
+func (b *secretVolumeBuilder) loadFallback() *api.Secret {
+	secret, err := b.plugin.fallback.load(b.pod.Namespace, b.secretName)
+	if err != nil {
+		glog.Errorf("Couldn't read the fallback copy of secret: %v", b.logFields("err", err))
+		return nil
+	}
+	return secret
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/fields"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// SecretGetter gets the secrets that secret volumes project.
+type SecretGetter interface {
+	// GetSecret returns the secret with the given name in namespace.  A
+	// secret that does not exist must be reported with an error for which
+	// errors.IsNotFound is true, so that optional volumes and the not found
+	// grace period work as they do with the API server.
+	GetSecret(namespace, name string) (*api.Secret, error)
+}
+
+// SecretLister is implemented by getters that can also list secrets, as the
+// volumes that select their secret by labels need.
+type SecretLister interface {
+	// ListSecrets returns the secrets in namespace that selector matches.
+	ListSecrets(namespace string, selector labels.Selector) ([]api.Secret, error)
+}
+
+// secretGetterHost is implemented by volume hosts that get secrets from
+// somewhere other than the API server, such as an external secret store.
+type secretGetterHost interface {
+	// GetSecretGetter returns the getter of secrets for secret volumes, or
+	// nil to get them from the API server.
+	GetSecretGetter() SecretGetter
+}
+
+// clientSecretGetter gets secrets from the API server.
+type clientSecretGetter struct {
+	kubeClient client.Interface
+}
+
+func (g clientSecretGetter) GetSecret(namespace, name string) (*api.Secret, error) {
+	return g.kubeClient.Secrets(namespace).Get(name)
+}
+
+func (g clientSecretGetter) ListSecrets(namespace string, selector labels.Selector) ([]api.Secret, error) {
+	list, err := g.kubeClient.Secrets(namespace).List(selector, fields.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// initSecretGetter sets the getter of secrets of plugin, if its host has
+// one.
+func (plugin *secretPlugin) initSecretGetter() {
+	if h, ok := plugin.host.(secretGetterHost); ok {
+		plugin.getter = h.GetSecretGetter()
+	}
+}
+
+// getSecretGetter returns the getter the secrets of the volume are read
+// with: that of the host if it has one, or else the client of getKubeClient.
+// Selectors are resolved with it too.  It returns nil if there is neither.
+func (b *secretVolumeBuilder) getSecretGetter() SecretGetter {
+	if b.plugin.getter != nil {
+		return b.plugin.getter
+	}
+	kubeClient := b.getKubeClient()
+	if kubeClient == nil {
+		return nil
+	}
+	return clientSecretGetter{kubeClient}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"os"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	volumeutil "github.com/GoogleCloudPlatform/kubernetes/pkg/volume/util"
+	"github.com/golang/glog"
+)
+
+// IsHealthy reports whether the volume is still as its last setup left it:
+// it is marked ready, it is still mounted if it is memory-backed, and the
+// files it wrote are all there, with the number and total size recorded by
+// its integrity marker.  Only the files' metadata is checked; CheckHealth
+// can read their contents too.  An error is returned only if the health of
+// the volume cannot be told.  An unhealthy volume is set up again from
+// scratch by its next setup.
+func (sv *secretVolume) IsHealthy() (bool, error) {
+	return sv.CheckHealth(false)
+}
+
+// CheckHealth is like IsHealthy, but if deep is set it also reads every file
+// of the volume and checks it against the checksum recorded by its last
+// setup.
+func (sv *secretVolume) CheckHealth(deep bool) (bool, error) {
+	dir := sv.GetPath()
+	unhealthy := func(reason string, keysAndValues ...interface{}) (bool, error) {
+		fields := append([]interface{}{"pod", sv.podUID, "volume", sv.volName, "dir", dir}, keysAndValues...)
+		glog.Warningf("Secret volume is unhealthy: %v: %v", reason, formatLogFields(fields...))
+		return false, nil
+	}
+
+	if !volumeutil.IsReady(sv.getMetaDir()) {
+		return unhealthy("not marked ready")
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return unhealthy("its directory is gone")
+	} else if err != nil {
+		return false, err
+	}
+	if !sv.isPlainDir() && sv.hostDir == "" && sv.plugin.medium == api.StorageMediumMemory {
+		isMnt, err := sv.mounter.IsMountPoint(dir)
+		if err != nil {
+			return false, err
+		}
+		if !isMnt {
+			return unhealthy("no longer mounted")
+		}
+	}
+	if err := sv.checkIntegrity(dir, deep); err != nil {
+		return unhealthy("files do not match its integrity marker", "deep", deep, "err", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hostDirMarkerName is the name of the file in the meta dir of a volume
+// that holds the resolved path of its host directory, if it has one.
+const hostDirMarkerName = "hostDirectory"
+
+// checkHostDir returns the path of the host directory that the node
+// operator configured under name, with the symlinks in it resolved, or an
+// error unless host directories are allowed and it names an existing
+// directory.  name is "" for a volume without a host directory, which is
+// always fine.
+func (plugin *secretPlugin) checkHostDir(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if !plugin.config.AllowHostDirectories {
+		return "", fmt.Errorf("host directory %v: host directories are not allowed on this node", name)
+	}
+	p, ok := plugin.config.HostDirectories[name]
+	if !ok {
+		return "", fmt.Errorf("host directory %v: is not configured on this node", name)
+	}
+	resolved, info, err := plugin.resolveHostPath(p)
+	if err != nil {
+		return "", fmt.Errorf("host directory %v: %v", name, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("host directory %v: %v is not a directory", name, p)
+	}
+	return resolved, nil
+}
+
+// checkHostDir returns an error unless the host directory of the volume
+// still resolves to the path its files are written beneath, so that they
+// cannot be redirected elsewhere after the path was checked.
+func (b *secretVolumeBuilder) checkHostDir() error {
+	resolved, err := b.plugin.checkHostDir(b.hostDirName)
+	if err != nil {
+		return err
+	}
+	if resolved != b.hostDir {
+		return fmt.Errorf("host directory %v: resolves to %v rather than %v", b.hostDirName, resolved, b.hostDir)
+	}
+	return nil
+}
+
+// resolveHostPath resolves the symlinks in the existing path p on the node,
+// and returns the result along with what it names.
+func (plugin *secretPlugin) resolveHostPath(p string) (string, os.FileInfo, error) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, info, nil
+}
+
+// beneathHostPrefix returns true if the resolved path p is strictly beneath
+// one of the allowed prefixes.
+func (plugin *secretPlugin) beneathHostPrefix(p string) bool {
+	for _, prefix := range plugin.config.HostDirectoryPrefixes {
+		// The prefix may itself be reached through a symlink.
+		if resolvedPrefix, err := filepath.EvalSymlinks(prefix); err == nil {
+			prefix = resolvedPrefix
+		}
+		if rel, err := filepath.Rel(prefix, p); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+// hostDirVolumeName returns the name of the directory of its own that the
+// volume is written to beneath its host directory.
+func (sv *secretVolume) hostDirVolumeName() string {
+	return fmt.Sprintf("%v_%v", sv.podUID, sv.volName)
+}
+
+// loadHostDir returns the resolved path of the host directory recorded for
+// the volume, or "" if it has none.
+func (sv *secretVolume) loadHostDir() string {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), hostDirMarkerName))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// setUpHostDir creates the directory of the volume at dir, beneath its host
+// directory.  A directory that is already there is only reused if the
+// volume recorded it, so that a volume never takes over a directory it did
+// not create.  The cleaner has no spec, so it is left a marker to find; the
+// volume's own directory is created empty, so that the volume is still
+// found on disk with the other volumes of its pod.
+func (b *secretVolumeBuilder) setUpHostDir(dir string) error {
+	recorded := b.loadHostDir() == b.hostDir
+	if err := os.Mkdir(dir, 0750); err != nil {
+		if !os.IsExist(err) {
+			return err
+		}
+		if info, err := os.Lstat(dir); err != nil {
+			return err
+		} else if !recorded || !info.IsDir() {
+			return fmt.Errorf("%v already exists and was not created by the volume", dir)
+		}
+	}
+	if err := b.writeMetaFile(hostDirMarkerName, []byte(b.hostDir)); err != nil {
+		return err
+	}
+	return os.MkdirAll(DataDir(b.plugin.host, b.podUID, b.volName), 0750)
+}
+
+// tearDownHostDir removes the directory of the volume at dir, beneath its
+// host directory, and the volume's own empty directory.  The host directory
+// itself is left in place, along with anything else in it.
+func (c *secretVolumeCleaner) tearDownHostDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Remove(DataDir(c.plugin.host, c.podUID, c.volName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
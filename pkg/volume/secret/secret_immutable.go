@@ -0,0 +1,113 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+	"github.com/golang/glog"
+)
+
+// immutableFileName is the name of the file in the meta dir of a volume
+// whose last successful setup wrote only immutable secrets.
+const immutableFileName = "immutable"
+
+// isImmutable returns true if secret is marked immutable.  A missing
+// optional secret is not; it may still be created.
+func isImmutable(secret *api.Secret) bool {
+	return secret != nil && secret.Immutable != nil && *secret.Immutable
+}
+
+// recordImmutable persists whether every secret just written to the volume
+// is immutable.  Failures are only logged; without the marker the next
+// refresh gets the secrets again.
+func (b *secretVolumeBuilder) recordImmutable(immutable bool) {
+	if immutable == b.wasImmutable() {
+		return
+	}
+	var err error
+	if immutable {
+		err = b.writeMetaFile(immutableFileName, nil)
+	} else {
+		err = os.Remove(path.Join(b.getMetaDir(), immutableFileName))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		glog.Errorf("Couldn't persist the immutability of the secrets of secret volume: %v", b.logFields("immutable", immutable, "err", err))
+	}
+}
+
+// wasImmutable returns true if every secret written by the last successful
+// setup of the volume was immutable.
+func (b *secretVolumeBuilder) wasImmutable() bool {
+	_, err := os.Stat(path.Join(b.getMetaDir(), immutableFileName))
+	return err == nil
+}
+
+// skipRefresh returns true if a volume that is set up cannot lag behind its
+// secrets, because they were all immutable when it was written, so that it
+// can be refreshed without getting them.  Immutable secrets cannot be made
+// mutable again, but if the plugin caches secrets and the cache holds one
+// that is no longer immutable, or that was deleted and created again with
+// another UID, the volume is refreshed as usual.  So is a volume with named pipes that are
+// not fed, such as after a restart of the kubelet, and a volume whose
+// selector may select another secret since.
+func (b *secretVolumeBuilder) skipRefresh() bool {
+	if !b.wasImmutable() || len(b.selector) > 0 {
+		return false
+	}
+	if b.hasPipes() && !b.feedingPipes() {
+		return false
+	}
+	if b.plugin.cache != nil {
+		for i, name := range b.secretNames() {
+			secret := b.plugin.cache.get(b.pod.Namespace, name)
+			if secret == nil {
+				continue
+			}
+			if !isImmutable(secret) {
+				glog.Warningf("Secret of volume is no longer immutable; refreshing the volume: %v", b.logFields("secret", name))
+				return false
+			}
+			if b.cachedSecretReplaced(i, string(secret.UID)) {
+				glog.Warningf("Secret of volume was replaced by another of the same name; refreshing the volume: %v", b.logFields("secret", name))
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writtenMetrics returns the usage of the volume as recorded by its last
+// successful setup, for a refresh that does not build the payload.
+func (b *secretVolumeBuilder) writtenMetrics() volume.Metrics {
+	data, err := ioutil.ReadFile(path.Join(b.getMetaDir(), integrityFileName))
+	if err != nil {
+		glog.Warningf("Couldn't read the usage of secret volume: %v", b.logFields("err", err))
+		return volume.Metrics{}
+	}
+	integrity := volumeIntegrity{}
+	if err := json.Unmarshal(data, &integrity); err != nil {
+		glog.Warningf("Couldn't read the usage of secret volume: %v", b.logFields("err", err))
+		return volume.Metrics{}
+	}
+	return volume.Metrics{Files: integrity.Files, Bytes: integrity.Bytes}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	volumeutil "github.com/GoogleCloudPlatform/kubernetes/pkg/volume/util"
+	"github.com/golang/glog"
+)
+
+// integrityFileName is the name of the file in the meta dir of a volume
+// that describes the files written by its last successful setup.
+const integrityFileName = "integrity"
+
+// volumeIntegrity is the number and total size of the files in a volume,
+// and a checksum of their paths and contents.  It is written as the last
+// step of every setup, so a volume whose files do not match it was
+// interrupted partway.  Markers written by older kubelets have no checksum.
+type volumeIntegrity struct {
+	Files    int64  `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+func payloadIntegrity(payload map[string]fileProjection) volumeIntegrity {
+	metrics := payloadMetrics(payload)
+	h := sha256.New()
+	for _, p := range payloadPaths(payload) {
+		addChecksumFile(h, p, payload[p].data, payload[p].pipe)
+	}
+	return volumeIntegrity{Files: metrics.Files, Bytes: metrics.Bytes, Checksum: hex.EncodeToString(h.Sum(nil))}
+}
+
+// addChecksumFile adds the file at path p holding data to the checksum h.
+// The data of a named pipe is fed to its readers rather than held by it,
+// so only its path counts.
+func addChecksumFile(h hash.Hash, p string, data []byte, pipe bool) {
+	h.Write([]byte(p))
+	h.Write([]byte{0})
+	if !pipe {
+		h.Write(data)
+	}
+	h.Write([]byte{0})
+}
+
+// isReady returns true if the volume at dir has been set up and still holds
+// the files that its last setup wrote.  A volume marked ready whose files
+// cannot all be found, or differ in number or total size from its integrity
+// marker, is not ready, so that it is set up again from scratch.
+func (b *secretVolumeBuilder) isReady(dir string) bool {
+	if !volumeutil.IsReady(b.getMetaDir()) {
+		return false
+	}
+	if err := b.checkIntegrity(dir, false); err != nil {
+		glog.Warningf("Secret volume is marked ready but is incomplete; setting it up again: %v", b.logFields("dir", dir, "err", err))
+		return false
+	}
+	return true
+}
+
+// checkIntegrity returns an error if the files in the volume at dir do not
+// match its integrity marker.  The marker is written before the readiness
+// file, so a ready volume without one was set up by a kubelet that predates
+// markers; it is trusted as before.  Only the number and sizes of the files
+// are checked, unless deep is set, in which case every file is read and its
+// content checked against the checksum of the marker, if it has one.
+func (sv *secretVolume) checkIntegrity(dir string, deep bool) error {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), integrityFileName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	expected := volumeIntegrity{}
+	if err := json.Unmarshal(data, &expected); err != nil {
+		return err
+	}
+	paths, err := sv.loadWrittenPaths()
+	if err != nil {
+		return err
+	}
+	if paths == nil {
+		return fmt.Errorf("the paths written to the volume are not known")
+	}
+	actual := volumeIntegrity{}
+	for _, p := range paths {
+		info, err := os.Stat(path.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && info.Mode()&os.ModeNamedPipe == 0 {
+			return fmt.Errorf("%v is not a regular file or named pipe", p)
+		}
+		actual.Files++
+		actual.Bytes += info.Size()
+	}
+	if actual.Files != expected.Files || actual.Bytes != expected.Bytes {
+		return fmt.Errorf("found %v files of %v bytes, expected %v files of %v bytes", actual.Files, actual.Bytes, expected.Files, expected.Bytes)
+	}
+	if !deep || expected.Checksum == "" {
+		return nil
+	}
+	checksum, err := filesChecksum(dir, paths)
+	if err != nil {
+		return err
+	}
+	if checksum != expected.Checksum {
+		return fmt.Errorf("the contents of the files do not match their checksum")
+	}
+	return nil
+}
+
+// filesChecksum reads the files at paths beneath dir and returns the
+// checksum that payloadIntegrity gives them.  Named pipes are not read.
+func filesChecksum(dir string, paths []string) (string, error) {
+	paths = append([]string(nil), paths...)
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		info, err := os.Stat(path.Join(dir, p))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeNamedPipe != 0 {
+			addChecksumFile(h, p, nil, true)
+			continue
+		}
+		data, err := ioutil.ReadFile(path.Join(dir, p))
+		if err != nil {
+			return "", err
+		}
+		addChecksumFile(h, p, data, false)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storeIntegrity writes the integrity marker for payload, which has just
+// been written to the volume.
+func (b *secretVolumeBuilder) storeIntegrity(payload map[string]fileProjection) error {
+	data, err := json.Marshal(payloadIntegrity(payload))
+	if err != nil {
+		return err
+	}
+	return b.writeMetaFile(integrityFileName, data)
+}
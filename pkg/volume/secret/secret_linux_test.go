@@ -0,0 +1,114 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/testclient"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+)
+
+func TestPluginExtendedAttributes(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid92")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		client = testclient.NewSimpleFake(&secret)
+		xattrs = map[string]string{"user.credential": "true", "user.owner": "payments"}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	setUp := func(volumeName string, strict bool) (string, error) {
+		spec := volumeSpec(volumeName, testName)
+		spec.Secret.Items = []api.KeyToPath{{Key: "data-1", Path: "one", ExtendedAttributes: xattrs}, {Key: "data-2", Path: "two"}}
+		spec.Secret.StrictExtendedAttributes = strict
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder.GetPath(), builder.SetUp()
+	}
+
+	// The attributes are set on the files of the new data directory, before
+	// it is published.
+	defer func(set func(p, name, value string) error) { setXattr = set }(setXattr)
+	realSetXattr := setXattr
+	var xattrPaths []string
+	setXattr = func(p, name, value string) error {
+		xattrPaths = append(xattrPaths, p)
+		return realSetXattr(p, name, value)
+	}
+	volumePath, err := setUp("test_volume_name", false)
+	if err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if len(xattrPaths) == 0 {
+		t.Errorf("Expected extended attributes to be set")
+	}
+	for _, p := range xattrPaths {
+		dir := strings.TrimPrefix(path.Dir(p), volumePath+"/")
+		if !strings.HasPrefix(dir, "..") || dir == dataDirName || path.Base(p) != "one" {
+			t.Errorf("Expected extended attributes to be set in an unpublished data directory, got %v", p)
+		}
+	}
+	buf := make([]byte, 64)
+	for name, value := range xattrs {
+		n, err := syscall.Getxattr(path.Join(volumePath, "one"), name, buf)
+		if err == syscall.EOPNOTSUPP {
+			t.Logf("The filesystem of %v does not support extended attributes", volumePath)
+			break
+		}
+		if err != nil || string(buf[:n]) != value {
+			t.Errorf("Expected extended attribute %v to be %q, got %q, %v", name, value, buf[:n], err)
+		}
+	}
+	if _, err := syscall.Getxattr(path.Join(volumePath, "two"), "user.credential", buf); err == nil {
+		t.Errorf("Expected no extended attributes on a file whose item has none")
+	}
+
+	// Simulate a filesystem without extended attributes.
+	setXattr = func(p, name, value string) error {
+		return &os.PathError{Op: "setxattr", Path: p, Err: syscall.EOPNOTSUPP}
+	}
+	volumePath, err = setUp("test_volume_lenient", false)
+	if err != nil {
+		t.Errorf("Expected unsupported extended attributes to be left unset, got: %v", err)
+	}
+	if data, err := ioutil.ReadFile(path.Join(volumePath, "one")); err != nil || string(data) != string(secret.Data["data-1"]) {
+		t.Errorf("Expected the file to be written anyway, got %q, %v", data, err)
+	}
+	if _, err := setUp("test_volume_strict", true); err == nil {
+		t.Errorf("Expected a strict volume to fail where extended attributes are unsupported")
+	} else if setUpErr, ok := err.(*SetUpError); !ok || setUpErr.Stage != SetUpStageWrite {
+		t.Errorf("Expected a failure writing the files, got: %v", err)
+	}
+}
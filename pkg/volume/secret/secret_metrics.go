@@ -0,0 +1,83 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Operations timed by setupLatency.
+const (
+	operationSetUp = "setup"
+	operationFetch = "fetch"
+	operationWrite = "write"
+)
+
+// setupLatency is the latency of secret volume setups, of getting their
+// secrets from the API server and of writing them to disk, broken down by
+// whether the operation succeeded.
+var setupLatency = prometheus.NewSummaryVec(
+	prometheus.SummaryOpts{
+		Subsystem: "kubelet",
+		Name:      "secret_volume_setup_latency_microseconds",
+		Help:      "Latency in microseconds of secret volume setups. Broken down by operation type: setup, fetch or write, and result.",
+	},
+	[]string{"operation_type", "result"},
+)
+
+// metricsHost is implemented by volume hosts that export metrics.
+type metricsHost interface {
+	// RegisterMetrics registers collector with the host's metrics
+	// registry.  Registering a collector that is already registered is
+	// not an error.
+	RegisterMetrics(collector prometheus.Collector) error
+}
+
+// initMetrics enables the setup latency metrics of plugin if its host
+// exports metrics.
+func (plugin *secretPlugin) initMetrics() {
+	h, ok := plugin.host.(metricsHost)
+	if !ok {
+		return
+	}
+	if err := h.RegisterMetrics(setupLatency); err != nil {
+		glog.Warningf("Couldn't register secret volume metrics: %v", err)
+		return
+	}
+	plugin.setupLatency = setupLatency
+}
+
+// timeOperation starts timing operation and returns a function that
+// records its latency and result.  If the plugin has no metrics, nothing is
+// timed and the returned function does nothing.
+func (plugin *secretPlugin) timeOperation(operation string) func(err error) {
+	if plugin.setupLatency == nil {
+		return func(error) {}
+	}
+	start := time.Now()
+	return func(err error) {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		latency := float64(time.Since(start).Nanoseconds() / time.Microsecond.Nanoseconds())
+		plugin.setupLatency.WithLabelValues(operation, result).Observe(latency)
+	}
+}
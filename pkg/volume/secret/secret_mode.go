@@ -0,0 +1,64 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// execBits are the permission bits that make a file executable.
+const execBits = 0111
+
+// execFiles describes each file of payload whose mode has an execute bit
+// set, in the order of their paths.
+func execFiles(payload map[string]fileProjection) []string {
+	var files []string
+	for p, file := range payload {
+		if file.mode&execBits != 0 {
+			files = append(files, fmt.Sprintf("%q (mode %04o)", p, file.mode))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// checkExecBits checks the modes of the files of payload, as configured for
+// the node.  An execute bit on a credential file is usually a mistake in the
+// mode of the volume or of an item, such as 0755 for 0644; it fails the
+// setup or is only logged and recorded as an event.
+func (b *secretVolumeBuilder) checkExecBits(payload map[string]fileProjection) error {
+	config := b.plugin.config
+	if !config.WarnOnExecBit && !config.RejectExecBit {
+		return nil
+	}
+	files := execFiles(payload)
+	if len(files) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("would write executable files %v", strings.Join(files, ", "))
+	if config.RejectExecBit {
+		glog.Errorf("Secret volume has executable files: %v", b.logFields("files", len(files)))
+		return fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, message)
+	}
+	glog.Warningf("Secret volume has executable files: %v", b.logFields("files", len(files)))
+	b.plugin.recordSecretEvent(&b.pod, b.volName, "Secret volume %v: %v", b.volName, message)
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// namespaceHost is implemented by volume hosts that override the namespaces
+// whose secrets secret volumes may read.
+type namespaceHost interface {
+	// GetSecretVolumeAllowedNamespaces returns the namespaces whose secrets
+	// may be read, or nil if those of any namespace may be.
+	GetSecretVolumeAllowedNamespaces() []string
+}
+
+// initAllowedNamespaces sets the namespaces whose secrets the plugin may
+// read, as configured or as overridden by the host.
+func (plugin *secretPlugin) initAllowedNamespaces() {
+	namespaces := plugin.config.AllowedNamespaces
+	if h, ok := plugin.host.(namespaceHost); ok {
+		namespaces = h.GetSecretVolumeAllowedNamespaces()
+	}
+	plugin.allowedNamespaces = nil
+	if len(namespaces) > 0 {
+		plugin.allowedNamespaces = util.NewStringSet(namespaces...)
+	}
+}
+
+// checkNamespace returns an error unless the plugin may read the secrets of
+// namespace.
+func (plugin *secretPlugin) checkNamespace(namespace string) error {
+	if plugin.allowedNamespaces == nil || plugin.allowedNamespaces.Has(namespace) {
+		return nil
+	}
+	return fmt.Errorf("secrets of namespace %q may not be read on this node", namespace)
+}
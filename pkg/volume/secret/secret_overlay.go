@@ -0,0 +1,209 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/validation"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
+	"github.com/golang/glog"
+)
+
+// fileOverlayMarkerName is the name of the file in the meta dir of a volume
+// that holds the path of the file its file overlay is bound over, once it
+// is.
+const fileOverlayMarkerName = "fileOverlay"
+
+// fileOverlaySourceMarkerName is the name of the file in the meta dir of a
+// volume that holds the path of the file its file overlay was bound from.
+const fileOverlaySourceMarkerName = "fileOverlaySource"
+
+// fileOverlay is a file of a secret volume that is bound read-only over an
+// existing file on the node, as set by the file-overlay option annotation.
+type fileOverlay struct {
+	// Path is the relative path of the file in the volume, as projected
+	// from the secret.  It may not contain the path element '..' or start
+	// with '..'.
+	Path string `json:"path"`
+	// TargetPath is the absolute path, in canonical form, of the existing
+	// regular file on the node to overlay.
+	TargetPath string `json:"targetPath"`
+}
+
+// parseFileOverlay parses the value of a file-overlay option annotation,
+// a JSON object such as {"path":"app.conf","targetPath":"/etc/app.conf"}.
+func parseFileOverlay(value string) (*fileOverlay, error) {
+	overlay := &fileOverlay{}
+	if err := json.Unmarshal([]byte(value), overlay); err != nil {
+		return nil, err
+	}
+	if overlay.Path == "" || !validation.IsValidRelativePath(overlay.Path) || strings.HasPrefix(overlay.Path, "..") {
+		return nil, fmt.Errorf("invalid path %q: must be a relative path without '..' that does not start with '..'", overlay.Path)
+	}
+	if p := overlay.TargetPath; !path.IsAbs(p) || path.Clean(p) != p {
+		return nil, fmt.Errorf("invalid targetPath %q: must be an absolute path in canonical form", p)
+	}
+	return overlay, nil
+}
+
+// checkFileOverlay returns an error unless file overlays are supported and
+// allowed, and the target of overlay, once the symlinks in it are resolved,
+// is an existing regular file strictly beneath one of the allowed prefixes.
+// It returns the resolved target.  overlay is nil for a volume without a
+// file overlay, which is always fine.
+func (plugin *secretPlugin) checkFileOverlay(overlay *fileOverlay) (string, error) {
+	if overlay == nil {
+		return "", nil
+	}
+	if !fileOverlaySupported {
+		return "", fmt.Errorf("file overlay of %v: file overlays are not supported on this platform", overlay.TargetPath)
+	}
+	if !plugin.config.AllowFileOverlays {
+		return "", fmt.Errorf("file overlay of %v: file overlays are not allowed on this node", overlay.TargetPath)
+	}
+	resolved, info, err := plugin.resolveHostPath(overlay.TargetPath)
+	if err != nil {
+		return "", fmt.Errorf("file overlay of %v: %v", overlay.TargetPath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file overlay of %v: is not a regular file", overlay.TargetPath)
+	}
+	if !plugin.beneathHostPrefix(resolved) {
+		return "", fmt.Errorf("file overlay of %v: is not beneath any of the allowed prefixes %v", overlay.TargetPath, plugin.config.HostDirectoryPrefixes)
+	}
+	return resolved, nil
+}
+
+// loadFileOverlay returns the file that the file overlay of the volume is
+// bound over and the file it was bound from, or "" if it is not bound.
+func (sv *secretVolume) loadFileOverlay() (target, source string) {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), fileOverlayMarkerName))
+	if err != nil {
+		return "", ""
+	}
+	target = string(data)
+	if data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), fileOverlaySourceMarkerName)); err == nil {
+		source = string(data)
+	}
+	return target, source
+}
+
+// isFileOverlayMount returns true if mp, mounted over the target of a file
+// overlay, is the bind of source.  Depending on the mounter, a bind is
+// listed with its source or with the device of the filesystem the source is
+// on, which is that of the deepest of mounts holding source.
+func isFileOverlayMount(mp mount.MountPoint, source string, mounts []mount.MountPoint) bool {
+	if source == "" {
+		return false
+	}
+	if mp.Device == source {
+		return true
+	}
+	device, depth := "", -1
+	for _, m := range mounts {
+		if m.Path == mp.Path {
+			continue
+		}
+		if m.Path == "/" || source == m.Path || strings.HasPrefix(source, m.Path+"/") {
+			if len(m.Path) > depth {
+				device, depth = m.Device, len(m.Path)
+			}
+		}
+	}
+	return device != "" && mp.Device == device
+}
+
+// mountFileOverlay binds the file of the file overlay of the volume, as just
+// written to dir, read-only over its target.  The bind holds on to the file
+// it was made with, which a refresh replaces, so the bind of an earlier
+// version is undone first; the original file shows through in between.
+func (b *secretVolumeBuilder) mountFileOverlay(dir string) error {
+	if b.overlay == nil {
+		return nil
+	}
+	target, err := b.plugin.checkFileOverlay(b.overlay)
+	if err != nil {
+		return fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err)
+	}
+	source, err := filepath.EvalSymlinks(path.Join(dir, b.overlay.Path))
+	if err != nil {
+		return fmt.Errorf("Cannot setup secret volume %v: file overlay path %q is not a file of the volume: %v", b.volName, b.overlay.Path, err)
+	}
+	if info, err := os.Stat(source); err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("Cannot setup secret volume %v: file overlay path %q is not a regular file of the volume", b.volName, b.overlay.Path)
+	}
+	if err := b.unmountFileOverlay(); err != nil {
+		return err
+	}
+	glog.V(3).Infof("Binding file overlay of secret volume: %v", b.logFields("source", source, "target", target))
+	if err := b.mounter.Mount(source, target, "", []string{"bind", "ro"}); err != nil {
+		glog.Errorf("Error binding file overlay of secret volume: %v", b.logFields("source", source, "target", target, "err", err))
+		return fmt.Errorf("Cannot setup secret volume %v: couldn't bind %q over %v, which may not be supported by this node: %v", b.volName, b.overlay.Path, target, err)
+	}
+	if err := b.writeMetaFile(fileOverlaySourceMarkerName, []byte(source)); err != nil {
+		return err
+	}
+	return b.writeMetaFile(fileOverlayMarkerName, []byte(target))
+}
+
+// unmountFileOverlay undoes the bind of the file overlay of the volume, if
+// there is one, so that the original file shows through again.  A bind
+// that is gone already, such as after a reboot, is forgotten, and so is one
+// that something else has since been mounted over, which is left alone.
+func (sv *secretVolume) unmountFileOverlay() error {
+	target, source := sv.loadFileOverlay()
+	if target == "" {
+		return nil
+	}
+	mounts, err := sv.mounter.List()
+	if err != nil {
+		return err
+	}
+	// Unmounting the target undoes the last mount over it, which is listed
+	// last.
+	top := -1
+	for i, mp := range mounts {
+		if mp.Path == target {
+			top = i
+		}
+	}
+	if top >= 0 {
+		if !isFileOverlayMount(mounts[top], source, mounts) {
+			glog.Warningf("Not unbinding file overlay of secret volume, the mount over its target is not of the volume: %v",
+				formatLogFields("pod", sv.podUID, "volume", sv.volName, "target", target, "source", source, "device", mounts[top].Device))
+		} else {
+			glog.V(3).Infof("Unbinding file overlay of secret volume: %v", formatLogFields("pod", sv.podUID, "volume", sv.volName, "target", target))
+			if err := sv.mounter.Unmount(target); err != nil {
+				glog.Errorf("Error unbinding file overlay of secret volume: %v", formatLogFields("pod", sv.podUID, "volume", sv.volName, "target", target, "err", err))
+				return err
+			}
+		}
+	}
+	for _, name := range []string{fileOverlayMarkerName, fileOverlaySourceMarkerName} {
+		if err := os.Remove(path.Join(sv.getMetaDir(), name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/validation"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
+)
+
+// pipePollInterval is how often a feeder checks whether its named pipe has
+// a reader.
+var pipePollInterval = 100 * time.Millisecond
+
+// pipeWriteInterval is how often a feeder retries a write to a named pipe
+// that is full.
+var pipeWriteInterval = 10 * time.Millisecond
+
+// pipeFeeder writes data to a named pipe each time a reader opens it, until
+// it is stopped.
+type pipeFeeder struct {
+	path   string
+	stopCh chan struct{}
+	done   chan struct{}
+
+	// lock guards data and file, which is the pipe while it is being
+	// written to.
+	lock sync.Mutex
+	data []byte
+	file *os.File
+}
+
+// newPipeFeeder starts feeding data to the named pipe at p.
+func newPipeFeeder(p string, data []byte) *pipeFeeder {
+	f := &pipeFeeder{path: p, data: data, stopCh: make(chan struct{}), done: make(chan struct{})}
+	go f.run()
+	return f
+}
+
+// setData replaces the data fed to readers that open the pipe from now on.
+func (f *pipeFeeder) setData(data []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.data = data
+}
+
+func (f *pipeFeeder) run() {
+	defer close(f.done)
+	for {
+		f.feed()
+		select {
+		case <-f.stopCh:
+			return
+		case <-time.After(pipePollInterval):
+		}
+	}
+}
+
+// feed writes the data to the pipe and closes it, if the pipe has a reader.
+// Without a reader, opening the pipe fails rather than blocks, so that the
+// feeder can still be stopped.
+func (f *pipeFeeder) feed() {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); !ok || pathErr.Err != syscall.ENXIO {
+			glog.V(4).Infof("Couldn't open named pipe of secret volume: %v", formatLogFields("path", f.path, "err", err))
+		}
+		return
+	}
+	f.lock.Lock()
+	select {
+	case <-f.stopCh:
+		f.lock.Unlock()
+		file.Close()
+		return
+	default:
+	}
+	f.file = file
+	data := f.data
+	f.lock.Unlock()
+
+	if stopped, err := f.write(file, data); err != nil && !stopped {
+		glog.Errorf("Couldn't write to named pipe of secret volume: %v", formatLogFields("path", f.path, "err", err))
+	}
+	f.lock.Lock()
+	f.file = nil
+	f.lock.Unlock()
+	file.Close()
+}
+
+// write writes data to the pipe in file.  The pipe is not blocking, so a
+// write to a full pipe is only partly done; the rest is written as the
+// reader makes room.  A reader that does not read holds up the write until
+// the feeder is stopped, which closes the pipe.  stopped is true if the
+// write was cut short by that.
+func (f *pipeFeeder) write(file *os.File, data []byte) (stopped bool, err error) {
+	for len(data) > 0 {
+		n, err := file.Write(data)
+		data = data[n:]
+		if err == nil {
+			continue
+		}
+		if pathErr, ok := err.(*os.PathError); !ok || pathErr.Err != syscall.EAGAIN {
+			select {
+			case <-f.stopCh:
+				return true, err
+			default:
+				return false, err
+			}
+		}
+		select {
+		case <-f.stopCh:
+			return true, nil
+		case <-time.After(pipeWriteInterval):
+		}
+	}
+	return false, nil
+}
+
+// stop stops the feeder and waits for it to finish.
+func (f *pipeFeeder) stop() {
+	f.lock.Lock()
+	close(f.stopCh)
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.lock.Unlock()
+	<-f.done
+}
+
+// parseNamedPipes parses the value of a named-pipes option annotation, a JSON
+// array of the paths in the volume, such as ["pipes/token"], to serve
+// through named pipes.
+func parseNamedPipes(value string) (util.StringSet, error) {
+	var paths []string
+	if err := json.Unmarshal([]byte(value), &paths); err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if p == "" || !validation.IsValidRelativePath(p) || strings.HasPrefix(p, "..") {
+			return nil, fmt.Errorf("invalid path %q: must be a relative path without '..' that does not start with '..'", p)
+		}
+	}
+	return util.NewStringSet(paths...), nil
+}
+
+// hasPipes returns true if the volume projects a named pipe.
+func (b *secretVolumeBuilder) hasPipes() bool {
+	return len(b.pipes) > 0
+}
+
+// markPipes makes the files of payload at the paths of the named pipes of
+// the volume named pipes.  A path that no file is projected to is ignored.
+func (b *secretVolumeBuilder) markPipes(payload map[string]fileProjection) error {
+	for _, p := range b.pipes.List() {
+		file, found := payload[p]
+		if !found {
+			continue
+		}
+		if len(file.xattrs) > 0 {
+			return fmt.Errorf("Cannot setup secret volume %v: named pipe %q may not set extended attributes", b.volName, p)
+		}
+		file.pipe = true
+		payload[p] = file
+	}
+	return nil
+}
+
+// feedPipes has the named pipes of payload, just written to the volume at
+// dir, serve the current values of their keys.  A feeder is started for
+// each pipe that has none, the others are given the current values, and the
+// feeders of pipes no longer in payload are stopped.
+func (b *secretVolumeBuilder) feedPipes(dir string, payload map[string]fileProjection) {
+	plugin := b.plugin
+	key := volumeKey(b.podUID, b.volName)
+	plugin.pipesLock.Lock()
+	defer plugin.pipesLock.Unlock()
+
+	feeders := plugin.pipes[key]
+	for _, p := range payloadPaths(payload) {
+		file := payload[p]
+		if !file.pipe {
+			continue
+		}
+		if f, found := feeders[p]; found {
+			f.setData(file.data)
+			continue
+		}
+		if feeders == nil {
+			feeders = map[string]*pipeFeeder{}
+			plugin.pipes[key] = feeders
+		}
+		glog.V(4).Infof("Feeding named pipe of secret volume: %v", b.logFields("path", p))
+		feeders[p] = newPipeFeeder(path.Join(dir, p), file.data)
+	}
+	for p, f := range feeders {
+		if file, found := payload[p]; !found || !file.pipe {
+			f.stop()
+			delete(feeders, p)
+		}
+	}
+	if len(feeders) == 0 {
+		delete(plugin.pipes, key)
+	}
+}
+
+// feedingPipes returns true if the named pipes of the volume are fed.
+func (b *secretVolumeBuilder) feedingPipes() bool {
+	b.plugin.pipesLock.Lock()
+	defer b.plugin.pipesLock.Unlock()
+	return len(b.plugin.pipes[volumeKey(b.podUID, b.volName)]) != 0
+}
+
+// stopPipes stops the feeders of the named pipes of the volume named
+// volName of the pod with the given UID, if it has any.
+func (plugin *secretPlugin) stopPipes(podUID types.UID, volName string) {
+	key := volumeKey(podUID, volName)
+	plugin.pipesLock.Lock()
+	defer plugin.pipesLock.Unlock()
+	for _, f := range plugin.pipes[key] {
+		f.stop()
+	}
+	delete(plugin.pipes, key)
+}
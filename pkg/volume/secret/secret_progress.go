@@ -0,0 +1,111 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/golang/glog"
+)
+
+// Progress is reported each time another progressFiles files or
+// progressBytes bytes of a payload have been written, so secrets of a few
+// keys are written without any report.
+const (
+	progressFiles = 100
+	progressBytes = 1 << 20
+)
+
+// SetUpProgress describes how far the files of a secret volume have been
+// written.  Files that are unchanged count as written.
+type SetUpProgress struct {
+	PodUID     types.UID
+	Volume     string
+	Files      int
+	TotalFiles int
+	Bytes      int
+	TotalBytes int
+}
+
+// ProgressHook is called as the files of a secret volume are written.  It is
+// called from the write itself, so it must return quickly.
+type ProgressHook func(progress SetUpProgress)
+
+// progressHost is implemented by volume hosts that want to follow the
+// writing of large secret volumes.
+type progressHost interface {
+	// GetSecretVolumeOnProgress returns the hook to call with progress, or
+	// nil to call none.
+	GetSecretVolumeOnProgress() ProgressHook
+}
+
+// initProgressHook picks up the progress hook of the host, if it has one.
+func (plugin *secretPlugin) initProgressHook() {
+	if h, ok := plugin.host.(progressHost); ok {
+		plugin.progressHook = h.GetSecretVolumeOnProgress()
+	}
+}
+
+// progressCounter counts the files and bytes of a payload as they are
+// written, and reports them at intervals.  A nil counter counts nothing.
+type progressCounter struct {
+	report    func(files, bytes int)
+	files     int
+	bytes     int
+	nextFiles int
+	nextBytes int
+}
+
+// add counts a file of n bytes as written.
+func (c *progressCounter) add(n int) {
+	if c == nil {
+		return
+	}
+	c.files++
+	c.bytes += n
+	if c.files < c.nextFiles && c.bytes < c.nextBytes {
+		return
+	}
+	c.report(c.files, c.bytes)
+	c.nextFiles = c.files + progressFiles
+	c.nextBytes = c.bytes + progressBytes
+}
+
+// projectionSize returns the number of bytes written for file: none for a
+// named pipe, whose data is fed through it instead.
+func projectionSize(file fileProjection) int {
+	if file.pipe {
+		return 0
+	}
+	return len(file.data)
+}
+
+// newProgressCounter returns the counter that reports the progress of
+// writing payload, or nil if there is nobody to report it to.
+func (b *secretVolumeBuilder) newProgressCounter(payload map[string]fileProjection) *progressCounter {
+	hook := b.plugin.progressHook
+	if hook == nil && !glog.V(4) {
+		return nil
+	}
+	total := payloadMetrics(payload)
+	report := func(files, bytes int) {
+		glog.V(4).Infof("Writing secret volume: %v", b.logFields("files", files, "totalFiles", total.Files, "bytes", bytes, "totalBytes", total.Bytes))
+		if hook != nil {
+			hook(SetUpProgress{PodUID: b.podUID, Volume: b.volName, Files: files, TotalFiles: int(total.Files), Bytes: bytes, TotalBytes: int(total.Bytes)})
+		}
+	}
+	return &progressCounter{report: report, nextFiles: progressFiles, nextBytes: progressBytes}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+)
+
+// secretSource returns the source of the secret that a volume with spec is
+// populated from: its secret source, or else the first secret projected by
+// its projected source.  It returns nil if the volume has neither.
+func secretSource(spec *volume.Spec) *api.SecretVolumeSource {
+	if spec.VolumeSource.Secret != nil {
+		return spec.VolumeSource.Secret
+	}
+	if spec.VolumeSource.Projected == nil {
+		return nil
+	}
+	for _, projection := range spec.VolumeSource.Projected.Sources {
+		if projection.Secret != nil {
+			return projection.Secret
+		}
+	}
+	return nil
+}
+
+// validateProjection checks that a projected volume with spec projects
+// nothing but the one secret the plugin sets up.  Projecting several
+// secrets, or a secret next to other sources, is not supported yet.
+func validateProjection(spec *volume.Spec) error {
+	if spec.VolumeSource.Projected == nil {
+		return nil
+	}
+	if n := len(spec.VolumeSource.Projected.Sources); n != 1 {
+		return fmt.Errorf("projects %d sources; only a single secret can be projected", n)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// volumeResync is the periodic resync of a single volume.  stop is closed to
+// stop it, and done once it has stopped.
+type volumeResync struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startResync starts the periodic resync of the volume that b has just set
+// up, unless resyncs are off or it already has one.
+func (b *secretVolumeBuilder) startResync() {
+	period := b.plugin.config.ResyncPeriod
+	if period <= 0 {
+		return
+	}
+	key := volumeKey(b.podUID, b.volName)
+	b.plugin.resyncsLock.Lock()
+	defer b.plugin.resyncsLock.Unlock()
+	if _, found := b.plugin.resyncs[key]; found {
+		return
+	}
+	r := &volumeResync{stop: make(chan struct{}), done: make(chan struct{})}
+	b.plugin.resyncs[key] = r
+	glog.V(4).Infof("Starting periodic resync of secret volume: %v", b.logFields("period", period))
+	go b.resyncLoop(key, period, r)
+}
+
+// stopResync stops the periodic resync of the volume identified by key, if
+// it has one, and waits for a resync in progress to give up.  The caller
+// must not hold the lock of the volume.
+func (plugin *secretPlugin) stopResync(key string) {
+	plugin.resyncsLock.Lock()
+	r, found := plugin.resyncs[key]
+	delete(plugin.resyncs, key)
+	plugin.resyncsLock.Unlock()
+	if !found {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// resyncLoop refreshes the volume after every jittered period until r is
+// stopped or the volume is found not to be set up any more.  The volume may
+// be torn down behind a stopped resync by a setup that was in progress, so
+// the loop does not count on being stopped.
+func (b *secretVolumeBuilder) resyncLoop(key string, period time.Duration, r *volumeResync) {
+	defer close(r.done)
+	for {
+		wait := period + time.Duration(rand.Float64()*b.plugin.config.ResyncJitter*float64(period))
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(wait):
+		}
+		setUp, err := b.resync(r.stop)
+		select {
+		case <-r.stop:
+			// A resync canceled by a teardown is not worth a warning.
+			return
+		default:
+		}
+		if err != nil {
+			glog.Warningf("Couldn't resync secret volume: %v", b.logFields("err", err))
+		}
+		if !setUp {
+			glog.V(3).Infof("Secret volume is no longer set up; stopping its resync: %v", b.logFields())
+			b.plugin.resyncsLock.Lock()
+			if b.plugin.resyncs[key] == r {
+				delete(b.plugin.resyncs, key)
+			}
+			b.plugin.resyncsLock.Unlock()
+			return
+		}
+	}
+}
+
+// resync refreshes the files of the volume if it is still set up, as
+// Reconcile does, and reports whether it is.  Unlike Reconcile, it never
+// sets up a volume that is not.  Closing stopCh cancels it.
+func (b *secretVolumeBuilder) resync(stopCh <-chan struct{}) (setUp bool, err error) {
+	dir := b.GetPath()
+	unlock, canceled := b.plugin.lockForSetUp(volumeKey(b.podUID, b.volName))
+	defer unlock()
+	select {
+	case <-stopCh:
+		return true, nil
+	default:
+	}
+	stopCh, release := mergeStop(stopCh, canceled)
+	defer release()
+
+	isMnt, ready, err := b.setUpState(dir)
+	if err != nil || !ready {
+		return ready, err
+	}
+	done := b.plugin.timeOperation(operationSetUp)
+	defer func() { done(err) }()
+	glog.V(4).Infof("Resyncing secret volume: %v", b.logFields("dir", dir))
+	return true, b.reconcileAt(dir, stopCh, isMnt, true)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/golang/glog"
+)
+
+// resolveSelector sets the name of the secret of the volume to that of the
+// only secret in the namespace of its pod that its selector matches.  It is
+// called for every setup and refresh, and the result is never cached, so
+// that a rotation that moves the selected labels to another secret reaches
+// the volume at its next refresh.  The secrets are listed with getter, which
+// must be a SecretLister, and the secret itself is then got by name with it
+// as usual.  No match is a not found error; more than one, the names of the
+// matches are listed.  validateSources has checked the selector, which
+// would otherwise select every secret if it were invalid.
+func (b *secretVolumeBuilder) resolveSelector(getter SecretGetter) error {
+	lister, ok := getter.(SecretLister)
+	if !ok {
+		return fmt.Errorf("Cannot setup secret volume %v: the secret getter cannot list secrets to resolve secret selector %v with", b.volName, b.selectorString())
+	}
+	secrets, err := lister.ListSecrets(b.pod.Namespace, labels.SelectorFromSet(labels.Set(b.selector)))
+	if err != nil {
+		return err
+	}
+	switch len(secrets) {
+	case 0:
+		return errors.NewNotFound("secrets", b.selectorString())
+	case 1:
+	default:
+		names := make([]string, 0, len(secrets))
+		for _, secret := range secrets {
+			names = append(names, secret.Name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Cannot setup secret volume %v: secret selector %v matches %d secrets in namespace %v, not one: %v",
+			b.volName, b.selectorString(), len(names), b.pod.Namespace, strings.Join(names, ", "))
+	}
+	name := secrets[0].Name
+	if name != b.secretName {
+		glog.V(3).Infof("Secret selector resolved: %v", b.logFields("selector", b.selectorString(), "resolved", name))
+	}
+	b.secretName = name
+	return nil
+}
+
+// selectorString returns the selector of the volume in the usual label
+// selector syntax, sorted by label.
+func (b *secretVolumeBuilder) selectorString() string {
+	return labels.Set(b.selector).String()
+}
+
+// metricSecretName returns the secret named in the labels of the metrics of
+// the volume: the selector of a volume that has one, which does not change
+// as it resolves to one secret after another.
+func (b *secretVolumeBuilder) metricSecretName() string {
+	if len(b.selector) > 0 {
+		return b.selectorString()
+	}
+	return b.secretName
+}
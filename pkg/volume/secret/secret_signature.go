@@ -0,0 +1,117 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/golang/glog"
+)
+
+// signatureKey is the key of a secret that holds the signature of its other
+// keys, for volumes that verify signatures.
+const signatureKey = ".sig"
+
+// signatureHost is implemented by volume hosts that verify the signatures
+// of secrets.  The kubelet reads its key from the file given with
+// --secret-volume-verifying-key-file.
+type signatureHost interface {
+	// GetSecretVolumeVerifyingKey returns the key that secrets must be
+	// signed with, or nil if there is none.
+	GetSecretVolumeVerifyingKey() *rsa.PublicKey
+}
+
+// initVerifyingKey picks up the verifying key of the host, if it has one.
+func (plugin *secretPlugin) initVerifyingKey() {
+	if h, ok := plugin.host.(signatureHost); ok {
+		plugin.verifyingKey = h.GetSecretVolumeVerifyingKey()
+	}
+}
+
+// signedDigest returns the SHA-256 hash that the signature of secret is
+// made over: the namespace and name of the secret, then each key other than
+// signatureKey, in sorted order, followed by its value, each preceded by its
+// length as a big-endian 64-bit integer.  Covering the namespace and name
+// keeps a signed secret from being copied under another name and mounted
+// in its place.
+func signedDigest(secret *api.Secret) []byte {
+	h := sha256.New()
+	writeSigned(h, []byte(secret.Namespace))
+	writeSigned(h, []byte(secret.Name))
+	for _, key := range secretKeys(secret) {
+		if key == signatureKey {
+			continue
+		}
+		writeSigned(h, []byte(key))
+		writeSigned(h, secret.Data[key])
+	}
+	return h.Sum(nil)
+}
+
+func writeSigned(h hash.Hash, data []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+	h.Write(length[:])
+	h.Write(data)
+}
+
+// verifySignature checks the signature of secret against the verifying key
+// of the plugin, and returns a copy of secret without it.
+func verifySignature(secret *api.Secret, key *rsa.PublicKey) (*api.Secret, error) {
+	if key == nil {
+		return nil, errors.New("the kubelet has no verifying key")
+	}
+	signature, found := secret.Data[signatureKey]
+	if !found {
+		return nil, fmt.Errorf("the secret has no %q key", signatureKey)
+	}
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, signedDigest(secret), signature); err != nil {
+		return nil, err
+	}
+	unsigned := *secret
+	unsigned.Data = make(map[string][]byte, len(secret.Data)-1)
+	for k, v := range secret.Data {
+		if k != signatureKey {
+			unsigned.Data[k] = v
+		}
+	}
+	return &unsigned, nil
+}
+
+// checkSignature verifies the signature of secret, if the volume calls for
+// it, and returns the secret to project.  A failure is recorded as an event.
+func (b *secretVolumeBuilder) checkSignature(secret *api.Secret) (*api.Secret, error) {
+	if !b.verifySig {
+		return secret, nil
+	}
+	unsigned, err := verifySignature(secret, b.plugin.verifyingKey)
+	if err != nil {
+		glog.Errorf("Secret signature verification failed: %v", b.logFields("err", err))
+		b.plugin.recordSecretEvent(&b.pod, b.volName, "Secret volume %v: signature verification of secret %v/%v failed: %v",
+			b.volName, b.pod.Namespace, b.secretName, err)
+		return nil, fmt.Errorf("Cannot setup secret volume %v: signature verification of secret %v/%v failed: %v",
+			b.volName, b.pod.Namespace, b.secretName, err)
+	}
+	return unsigned, nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	volumeutil "github.com/GoogleCloudPlatform/kubernetes/pkg/volume/util"
+	"github.com/golang/glog"
+)
+
+// deliverySocketName is the name of the unix socket in a volume delivered
+// over a socket, which serves its secret.
+const deliverySocketName = "secret.sock"
+
+// maxSocketPathLen is the size of the buffer that holds the path of a unix
+// socket on Linux, including its terminating NUL.
+const maxSocketPathLen = 108
+
+// socketServer serves data once, to the first client of a unix socket, and
+// then removes the socket.  The socket is also removed if the server times
+// out or is stopped first.
+type socketServer struct {
+	path     string
+	timeout  time.Duration
+	listener *net.UnixListener
+	// dir, if not nil, is the directory of the socket, held open while the
+	// socket is bound through its entry in /proc/self/fd.
+	dir  *os.File
+	done chan struct{}
+	// onExit, if not nil, is called once the server is done.
+	onExit func()
+
+	// lock guards data, stopped and conn, which is the client while data is
+	// being written to it.
+	lock    sync.Mutex
+	data    []byte
+	stopped bool
+	conn    net.Conn
+}
+
+// newSocketServer starts serving data on a unix socket created at p.  Anyone
+// whom mode lets read may connect.  onExit, if not nil, is called once the
+// server is done, whether it served data, timed out or was stopped.
+func newSocketServer(p string, data []byte, mode os.FileMode, timeout time.Duration, onExit func()) (*socketServer, error) {
+	listener, dir, err := listenUnix(p)
+	if err != nil {
+		return nil, err
+	}
+	s := &socketServer{path: p, timeout: timeout, listener: listener, dir: dir, data: data, done: make(chan struct{}), onExit: onExit}
+	// Connecting to a socket takes permission to write to it.
+	if err := setFileMode(p, mode|(mode&0444)>>1); err != nil {
+		s.close()
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// listenUnix listens on a new unix socket at p.  The path of a volume may be
+// too long for a socket, in which case the socket is bound through the
+// entry of its directory in /proc/self/fd instead, and the directory is
+// returned too.  It must be kept open until the listener is closed, since
+// closing the listener removes the socket by the path it was bound to.
+func listenUnix(p string) (*net.UnixListener, *os.File, error) {
+	addr := p
+	var dir *os.File
+	if len(p) >= maxSocketPathLen {
+		var err error
+		if dir, err = os.Open(path.Dir(p)); err != nil {
+			return nil, nil, err
+		}
+		addr = fmt.Sprintf("/proc/self/fd/%d/%s", dir.Fd(), path.Base(p))
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: addr, Net: "unix"})
+	if err != nil {
+		if dir != nil {
+			dir.Close()
+		}
+		return nil, nil, &os.PathError{Op: "listen", Path: p, Err: err}
+	}
+	return listener, dir, nil
+}
+
+// close closes the listener, which removes the socket, and then the
+// directory it was bound through.  The socket is removed by its path too,
+// in case closing the listener did not.
+func (s *socketServer) close() {
+	s.listener.Close()
+	if s.dir != nil {
+		s.dir.Close()
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Couldn't remove the socket of secret volume: %v", formatLogFields("path", s.path, "err", err))
+	}
+}
+
+// setData replaces the data served to the client, if none has connected yet.
+func (s *socketServer) setData(data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data = data
+}
+
+func (s *socketServer) run() {
+	s.serve()
+	// The server is done before onExit is called, so that onExit may take
+	// locks that are held while stop waits for it.
+	close(s.done)
+	if s.onExit != nil {
+		s.onExit()
+	}
+}
+
+func (s *socketServer) serve() {
+	if s.timeout > 0 {
+		// Closing the listener ends the wait for a client.
+		timer := time.AfterFunc(s.timeout, func() { s.listener.Close() })
+		defer timer.Stop()
+	}
+	conn, err := s.listener.Accept()
+	// The socket is only ever read once.
+	s.close()
+	if err != nil {
+		s.lock.Lock()
+		stopped := s.stopped
+		s.lock.Unlock()
+		if !stopped {
+			glog.Warningf("No reader took the secret from the socket of secret volume; removed it: %v", formatLogFields("path", s.path, "timeout", s.timeout))
+		}
+		return
+	}
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		conn.Close()
+		return
+	}
+	s.conn = conn
+	data := s.data
+	s.lock.Unlock()
+
+	// A client that does not read holds up the write until it times out or
+	// the server is stopped, which closes the connection.
+	if s.timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	}
+	if _, err := conn.Write(data); err != nil {
+		glog.Warningf("Couldn't write secret to the socket of secret volume: %v", formatLogFields("path", s.path, "err", err))
+	} else {
+		glog.V(3).Infof("Delivered secret over the socket of secret volume: %v", formatLogFields("path", s.path, "bytes", len(data)))
+	}
+	s.lock.Lock()
+	s.conn = nil
+	s.lock.Unlock()
+	conn.Close()
+}
+
+// stop stops the server, removing its socket, and waits for it to finish.
+func (s *socketServer) stop() {
+	s.lock.Lock()
+	s.stopped = true
+	s.listener.Close()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.lock.Unlock()
+	<-s.done
+}
+
+// The delivery modes that the delivery-mode option annotation may set.
+const (
+	// deliveryFiles writes the files of the secret to the volume.
+	deliveryFiles = "Files"
+	// deliverySocket writes no files, and instead serves them once, as a
+	// gzip-compressed tar archive, over a unix socket in the volume.
+	deliverySocket = "Socket"
+)
+
+// setDeliveryMode sets the delivery mode of b from the value of its
+// delivery-mode option annotation.
+func setDeliveryMode(b *secretVolumeBuilder, value string) error {
+	switch value {
+	case deliveryFiles:
+		b.socket = false
+	case deliverySocket:
+		b.socket = true
+	default:
+		return fmt.Errorf("unsupported delivery mode %q: must be %q or %q", value, deliveryFiles, deliverySocket)
+	}
+	return nil
+}
+
+// checkSocketDelivery returns an error unless the volume of b may deliver
+// its secret over a socket, if it is set to.
+func (b *secretVolumeBuilder) checkSocketDelivery() error {
+	if !b.socket {
+		return nil
+	}
+	if !b.plugin.config.AllowSocketDelivery {
+		return fmt.Errorf("socket delivery is not allowed on this node")
+	}
+	if b.hostDirName != "" {
+		return fmt.Errorf("socket delivery may not be set together with a host directory")
+	}
+	if b.overlay != nil {
+		return fmt.Errorf("socket delivery may not be set together with a file overlay")
+	}
+	return nil
+}
+
+// reconcileSocket serves payload over the socket of the volume at dir, in
+// place of writing its files.  Only the first setup creates the socket; a
+// refresh hands the current payload to a reader that has not connected
+// yet, and does nothing once the socket is gone.
+func (b *secretVolumeBuilder) reconcileSocket(dir string, payload map[string]fileProjection, ready bool) error {
+	data, err := archivePayload(payload)
+	if err != nil {
+		return setUpFailed(SetUpStageFetch, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	b.changes = PayloadChanges{}
+	key := volumeKey(b.podUID, b.volName)
+	plugin := b.plugin
+	plugin.socketsLock.Lock()
+	defer plugin.socketsLock.Unlock()
+	if s, found := plugin.sockets[key]; found {
+		s.setData(data)
+		return nil
+	}
+	if ready {
+		return nil
+	}
+
+	if err := setRootMode(dir, plugin.dirMode, b.opts.FSGroup); err != nil {
+		glog.Errorf("Error setting mode of secret volume: %v", b.logFields("dir", dir, "err", err))
+		return setUpFailed(SetUpStagePermissions, err)
+	}
+	mode, err := b.fileMode()
+	if err != nil {
+		return setUpFailed(SetUpStageValidate, err)
+	}
+	var s *socketServer
+	// A server that is done is forgotten, unless it was already replaced.
+	onExit := func() {
+		plugin.socketsLock.Lock()
+		defer plugin.socketsLock.Unlock()
+		if plugin.sockets[key] == s {
+			delete(plugin.sockets, key)
+		}
+	}
+	s, err = newSocketServer(path.Join(dir, deliverySocketName), data, mode, plugin.config.SocketDeliveryTimeout, onExit)
+	if err != nil {
+		glog.Errorf("Error creating the socket of secret volume: %v", b.logFields("dir", dir, "err", err))
+		return setUpFailed(SetUpStageWrite, fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, err))
+	}
+	if b.opts.RootContext != "" {
+		if err := b.setContext(dir, b.opts.RootContext); err != nil {
+			glog.Errorf("Error setting SELinux context of secret volume: %v", b.logFields("dir", dir, "err", err))
+			s.stop()
+			return setUpFailed(SetUpStagePermissions, err)
+		}
+	}
+	plugin.sockets[key] = s
+	glog.V(3).Infof("Serving secret over the socket of secret volume: %v", b.logFields("dir", dir, "bytes", len(data)))
+	volumeutil.SetReady(b.getMetaDir())
+	return nil
+}
+
+// stopSocket stops the socket server of the volume named volName of the pod
+// with the given UID, if it has one.
+func (plugin *secretPlugin) stopSocket(podUID types.UID, volName string) {
+	key := volumeKey(podUID, volName)
+	plugin.socketsLock.Lock()
+	defer plugin.socketsLock.Unlock()
+	if s, found := plugin.sockets[key]; found {
+		s.stop()
+		delete(plugin.sockets, key)
+	}
+}
@@ -17,21 +17,44 @@ limitations under the License.
 package secret
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/testclient"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/runtime"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	libutil "github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume/empty_dir"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func newTestHost(t *testing.T, client client.Interface) (string, volume.VolumeHost) {
@@ -43,6 +66,18 @@ func newTestHost(t *testing.T, client client.Interface) (string, volume.VolumeHo
 	return tempDir, volume.NewFakeVolumeHost(tempDir, client, empty_dir.ProbeVolumePlugins())
 }
 
+func newTestPlugin(t *testing.T, client client.Interface) (string, volume.VolumePlugin) {
+	rootDir, host := newTestHost(t, client)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	return rootDir, plugin
+}
+
 func TestCanSupport(t *testing.T) {
 	pluginMgr := volume.VolumePluginMgr{}
 	_, host := newTestHost(t, nil)
@@ -58,6 +93,16 @@ func TestCanSupport(t *testing.T) {
 	if !plugin.CanSupport(&volume.Spec{Name: "foo", VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{SecretName: ""}}}) {
 		t.Errorf("Expected true")
 	}
+	projected := &api.ProjectedVolumeSource{Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{SecretName: ""}}}}
+	if !plugin.CanSupport(&volume.Spec{Name: "foo", VolumeSource: api.VolumeSource{Projected: projected}}) {
+		t.Errorf("Expected true for a projected secret")
+	}
+	if plugin.CanSupport(&volume.Spec{Name: "foo", VolumeSource: api.VolumeSource{Projected: &api.ProjectedVolumeSource{Sources: []api.VolumeProjection{{}}}}}) {
+		t.Errorf("Expected false for a projected volume without a secret")
+	}
+	if plugin.CanSupport(&volume.Spec{Name: "foo", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{}}}) {
+		t.Errorf("Expected false")
+	}
 }
 
 func TestPlugin(t *testing.T) {
@@ -82,7 +127,8 @@ func TestPlugin(t *testing.T) {
 	}
 
 	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
-	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	mounter := &mount.FakeMounter{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
 	if err != nil {
 		t.Errorf("Failed to make a new Builder: %v", err)
 	}
@@ -94,6 +140,12 @@ func TestPlugin(t *testing.T) {
 	if !strings.HasSuffix(volumePath, fmt.Sprintf("pods/test_pod_uid/volumes/kubernetes.io~secret/test_volume_name")) {
 		t.Errorf("Got unexpected path: %s", volumePath)
 	}
+	if !builder.IsReadOnly() {
+		t.Errorf("Expected secret volumes to be read-only")
+	}
+	if attrs := builder.GetAttributes(); attrs != (volume.Attributes{ReadOnly: true, Managed: true, SupportsSELinux: true}) {
+		t.Errorf("Unexpected attributes for a secret volume: %+v", attrs)
+	}
 
 	err = builder.SetUp()
 	if err != nil {
@@ -106,13 +158,17 @@ func TestPlugin(t *testing.T) {
 			t.Errorf("SetUp() failed: %v", err)
 		}
 	}
+	if n := len(mounter.Log); n == 0 || mounter.Log[n-1].FSType != "" || mounter.Log[n-1].Target != volumePath {
+		t.Errorf("Expected the volume to be remounted read-only last, got: %v", mounter.Log)
+	}
 
 	doTestSecretDataInVolume(volumePath, secret, t)
 	doTestCleanAndTeardown(plugin, testPodUID, testVolumeName, volumePath, t)
 }
 
 // Test the case where the 'ready' file has been created and the pod volume dir
-// is a mountpoint.  Mount should not be called.
+// is a mountpoint.  Mount should not be called, but the secret data should be
+// refreshed into the existing volume.
 func TestPluginIdempotent(t *testing.T) {
 	var (
 		testPodUID     = types.UID("test_pod_uid2")
@@ -144,6 +200,9 @@ func TestPluginIdempotent(t *testing.T) {
 		},
 	}
 	util.SetReady(podMetadataDir)
+	if err := os.MkdirAll(podVolumeDir, 0750); err != nil {
+		t.Fatalf("Couldn't create volume dir: %v", err)
+	}
 	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
 	if err != nil {
 		t.Errorf("Failed to make a new Builder: %v", err)
@@ -158,16 +217,57 @@ func TestPluginIdempotent(t *testing.T) {
 		t.Errorf("Failed to setup volume: %v", err)
 	}
 
-	if len(mounter.Log) != 0 {
-		t.Errorf("Unexpected calls made to mounter: %v", mounter.Log)
+	doTestNoNewMounts(mounter, t)
+	doTestSecretDataInVolume(volumePath, secret, t)
+}
+
+// Test that calling SetUp on a volume that is already set up picks up changes
+// made to the secret since the last call.
+func TestPluginRefresh(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid8")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		current    = secret
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return &current, nil
+		}}
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	mounter := &mount.FakeMounter{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
 	}
 
-	if _, err := os.Stat(volumePath); err != nil {
-		if !os.IsNotExist(err) {
-			t.Errorf("SetUp() failed unexpectedly: %v", err)
-		}
-	} else {
-		t.Errorf("volume path should not exist: %v", volumePath)
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	updated := secret
+	updated.Data = map[string][]byte{
+		"data-1": []byte("value-1"),
+		"data-2": []byte("new-value-2"),
+		"data-4": []byte("value-4"),
+	}
+	current = updated
+
+	mounter.ResetLog()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	doTestNoNewMounts(mounter, t)
+	doTestSecretDataInVolume(volumePath, updated, t)
+	if _, err := os.Lstat(path.Join(volumePath, "data-3")); !os.IsNotExist(err) {
+		t.Errorf("Expected removed key data-3 to be gone, got: %v", err)
 	}
 }
 
@@ -227,65 +327,6125 @@ func TestPluginReboot(t *testing.T) {
 	doTestCleanAndTeardown(plugin, testPodUID, testVolumeName, volumePath, t)
 }
 
-func volumeSpec(volumeName, secretName string) *api.Volume {
-	return &api.Volume{
-		Name: volumeName,
-		VolumeSource: api.VolumeSource{
-			Secret: &api.SecretVolumeSource{
-				SecretName: secretName,
-			},
-		},
+func TestPluginDefaultMode(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid4")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testMode       = 0400
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pluginMgr  = volume.VolumePluginMgr{}
+		_, host    = newTestHost(t, client)
+	)
+
+	volumeSpec.Secret.DefaultMode = &testMode
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Errorf("Can't find the plugin by name")
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
 	}
+
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	doTestSecretDataInVolume(volumePath, secret, t)
+	doTestSecretModeInVolume(volumePath, secret, os.FileMode(testMode), t)
 }
 
-func secret(namespace, name string) api.Secret {
-	return api.Secret{
-		ObjectMeta: api.ObjectMeta{
-			Namespace: namespace,
-			Name:      name,
-		},
-		Data: map[string][]byte{
-			"data-1": []byte("value-1"),
-			"data-2": []byte("value-2"),
-			"data-3": []byte("value-3"),
-		},
+func TestPluginInvalidDefaultMode(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid5")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testMode       = 01777
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pluginMgr  = volume.VolumePluginMgr{}
+		_, host    = newTestHost(t, client)
+	)
+
+	volumeSpec.Secret.DefaultMode = &testMode
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Errorf("Can't find the plugin by name")
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error for mode %#o", testMode)
 	}
 }
 
-func doTestSecretDataInVolume(volumePath string, secret api.Secret, t *testing.T) {
-	for key, value := range secret.Data {
-		secretDataHostPath := path.Join(volumePath, key)
-		if _, err := os.Stat(secretDataHostPath); err != nil {
-			t.Fatalf("SetUp() failed, couldn't find secret data on disk: %v", secretDataHostPath)
-		} else {
-			actualSecretBytes, err := ioutil.ReadFile(secretDataHostPath)
-			if err != nil {
-				t.Fatalf("Couldn't read secret data from: %v", secretDataHostPath)
-			}
+func TestPluginOptional(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid9")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		optional       = true
 
-			actualSecretValue := string(actualSecretBytes)
-			if string(value) != actualSecretValue {
-				t.Errorf("Unexpected value; expected %q, got %q", value, actualSecretValue)
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		exists     = false
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			if !exists {
+				return nil, errors.NewNotFound("secrets", testName)
 			}
+			return &secret, nil
+		}}
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error for a missing secret that is not optional")
+	}
+
+	volumeSpec.Secret.Optional = &optional
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume for a missing optional secret: %v", err)
+	}
+	for key := range secret.Data {
+		if _, err := os.Lstat(path.Join(volumePath, key)); !os.IsNotExist(err) {
+			t.Errorf("Expected %v not to exist, got: %v", key, err)
 		}
 	}
+
+	exists = true
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
 }
 
-func doTestCleanAndTeardown(plugin volume.VolumePlugin, podUID types.UID, testVolumeName, volumePath string, t *testing.T) {
-	cleaner, err := plugin.NewCleaner(testVolumeName, podUID, mount.New())
+func TestPluginFSGroup(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid10")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		fsGroup        = int64(os.Getgid())
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{FSGroup: &fsGroup}, &mount.FakeMounter{})
 	if err != nil {
-		t.Errorf("Failed to make a new Cleaner: %v", err)
+		t.Errorf("Failed to make a new Builder: %v", err)
 	}
-	if cleaner == nil {
-		t.Errorf("Got a nil Cleaner")
+
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
 	}
 
-	if err := cleaner.TearDown(); err != nil {
-		t.Errorf("Expected success, got: %v", err)
+	doTestSecretDataInVolume(volumePath, secret, t)
+	doTestSecretModeInVolume(volumePath, secret, defaultFileMode|0040, t)
+	for key := range secret.Data {
+		info, err := os.Stat(path.Join(volumePath, key))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", key, err)
+		}
+		if gid := info.Sys().(*syscall.Stat_t).Gid; int64(gid) != fsGroup {
+			t.Errorf("Unexpected group for %v; expected %v, got %v", key, fsGroup, gid)
+		}
 	}
-	if _, err := os.Stat(volumePath); err == nil {
-		t.Errorf("TearDown() failed, volume path still exists: %s", volumePath)
-	} else if !os.IsNotExist(err) {
-		t.Errorf("SetUp() failed: %v", err)
+	info, err := os.Stat(volumePath)
+	if err != nil {
+		t.Fatalf("Couldn't stat %v: %v", volumePath, err)
+	}
+	if info.Mode()&os.ModeSetgid == 0 {
+		t.Errorf("Expected %v to be setgid, got mode %v", volumePath, info.Mode())
+	}
+}
+
+func TestPluginItems(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid6")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testMode       = 0400
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "certs/server.key", Mode: &testMode},
+		{Key: "data-2", Path: "data-2"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	expected := map[string]struct {
+		value string
+		mode  os.FileMode
+	}{
+		"certs/server.key": {"value-1", 0400},
+		"data-2":           {"value-2", defaultFileMode},
+	}
+	for name, want := range expected {
+		hostPath := path.Join(volumePath, name)
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			t.Fatalf("Expected %v to exist: %v", hostPath, err)
+		}
+		if info.Mode().Perm() != want.mode {
+			t.Errorf("Unexpected mode for %v; expected %v, got %v", hostPath, want.mode, info.Mode().Perm())
+		}
+		actual, err := ioutil.ReadFile(hostPath)
+		if err != nil {
+			t.Fatalf("Couldn't read secret data from: %v", hostPath)
+		}
+		if string(actual) != want.value {
+			t.Errorf("Unexpected value in %v; expected %q, got %q", hostPath, want.value, actual)
+		}
+	}
+	for _, name := range []string{"data-1", "data-3"} {
+		if _, err := os.Stat(path.Join(volumePath, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected unlisted key %v not to be projected, got: %v", name, err)
+		}
+	}
+}
+
+type fakeChconRunner struct {
+	contexts map[string]string
+}
+
+func (f *fakeChconRunner) SetContext(path, context string) error {
+	f.contexts[path] = context
+	return nil
+}
+
+func TestPluginRootContext(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid19")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testContext    = "user:role:type:range"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, context := range []string{"", testContext} {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{RootContext: context}, &mount.FakeMounter{})
+		if err != nil {
+			t.Errorf("Failed to make a new Builder: %v", err)
+		}
+		chcon := &fakeChconRunner{contexts: map[string]string{}}
+		builder.(*secretVolumeBuilder).chconRunner = chcon
+		// Change the secret so that every setup writes the volume.
+		secret.Data["data-1"] = []byte("value-" + context)
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+
+		if context == "" {
+			if len(chcon.contexts) != 0 {
+				t.Errorf("Expected no SELinux contexts to be set, got %v", chcon.contexts)
+			}
+			continue
+		}
+		volumePath := builder.GetPath()
+		dataDir, err := filepath.EvalSymlinks(path.Join(volumePath, dataDirName))
+		if err != nil {
+			t.Fatalf("Couldn't resolve %v: %v", dataDirName, err)
+		}
+		expected := []string{volumePath, path.Join(volumePath, dataDirName), dataDir}
+		for key := range secret.Data {
+			expected = append(expected, path.Join(volumePath, key), path.Join(dataDir, key))
+		}
+		for _, p := range expected {
+			if chcon.contexts[p] != context {
+				t.Errorf("Expected SELinux context %q on %v, got %q", context, p, chcon.contexts[p])
+			}
+		}
+	}
+}
+
+// flakyMounter is a FakeMounter whose IsMountPoint returns errs, one per
+// call, before answering normally.
+type flakyMounter struct {
+	mount.FakeMounter
+	errs  []error
+	calls int
+}
+
+func (m *flakyMounter) IsMountPoint(file string) (bool, error) {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return false, err
+	}
+	return m.FakeMounter.IsMountPoint(file)
+}
+
+func TestPluginIsMountPointErrors(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid20")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	stale := &os.PathError{Op: "stat", Path: "dir", Err: syscall.ESTALE}
+	permanent := make([]error, isMountPointRetries+1)
+	for i := range permanent {
+		permanent[i] = stale
+	}
+	// Failed setups must give up after exactly the given number of calls.
+	testCases := []struct {
+		name    string
+		errs    []error
+		isValid bool
+		calls   int
+	}{
+		{"missing dir", []error{&os.PathError{Op: "stat", Path: "dir", Err: syscall.ENOENT}}, true, 0},
+		{"transient errors", []error{&os.PathError{Op: "stat", Path: "dir", Err: syscall.EINTR}, stale}, true, 0},
+		{"persistent transient error", permanent, false, isMountPointRetries + 1},
+		{"permanent error", []error{&os.PathError{Op: "stat", Path: "dir", Err: syscall.EACCES}}, false, 1},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, tc := range testCases {
+		mounter := &flakyMounter{errs: tc.errs}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Errorf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.isValid && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if !tc.isValid && err == nil {
+			t.Errorf("%v: expected an error", tc.name)
+		}
+		if tc.isValid && len(mounter.errs) != 0 {
+			t.Errorf("%v: expected every error to be retried, %v left", tc.name, mounter.errs)
+		}
+		if !tc.isValid && mounter.calls != tc.calls {
+			t.Errorf("%v: expected %v calls to IsMountPoint, got %v", tc.name, tc.calls, mounter.calls)
+		}
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid18")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+
+	_, plugin := newTestPlugin(t, nil)
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != ErrNoKubeClient {
+		t.Errorf("Expected %v, got: %v", ErrNoKubeClient, err)
+	}
+
+	client := &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+		return nil, errors.NewNotFound("secrets", testName)
+	}}
+	_, plugin = newTestPlugin(t, client)
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); !errors.IsNotFound(Cause(err)) {
+		t.Errorf("Expected a not found error, got: %v", err)
+	}
+}
+
+func TestPluginBinaryData(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid21")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       map[string][]byte{},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	every := make([]byte, 512)
+	for i := range every {
+		every[i] = byte(i)
+	}
+	secret.Data["every-byte"] = every
+	secret.Data["nuls"] = []byte{0, 0, 0}
+	secret.Data["invalid-utf8"] = []byte{0xff, 0xfe, 0xc3, 0x28, '\n', 0x80}
+	secret.Data["gzip"] = []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x03, 0x00}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	for key, value := range secret.Data {
+		actual, err := ioutil.ReadFile(path.Join(volumePath, key))
+		if err != nil {
+			t.Fatalf("Couldn't read secret data for %v: %v", key, err)
+		}
+		if !bytes.Equal(actual, value) {
+			t.Errorf("Unexpected value for %v; expected % x, got % x", key, value, actual)
+		}
+	}
+	expected := volume.Metrics{Bytes: int64(totalSecretBytes(&secret)), Files: int64(len(secret.Data))}
+	if m, _ := builder.(volume.MetricsProvider).GetMetrics(); m.Bytes != expected.Bytes || m.Files != expected.Files {
+		t.Errorf("Expected metrics %+v, got %+v", expected, m)
+	}
+}
+
+func TestPluginNestedPaths(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid15")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       map[string][]byte{"a/b/c.pem": []byte("value-1")},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	// Paths that would escape the volume must be rejected.
+	secret.Data = map[string][]byte{"../../escaped": []byte("value-1")}
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error for a path outside of the volume")
+	}
+	if _, err := os.Lstat(path.Join(volumePath, "../../escaped")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside of the volume, got: %v", err)
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid16")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		validMode      = 0400
+		invalidMode    = 01000
+		optional       = true
+
+		secret = secret(testNamespace, testName)
+		client = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			if name := action.(testclient.GetAction).GetName(); name != testName {
+				return nil, errors.NewNotFound("secrets", name)
+			}
+			return &secret, nil
+		}}
+		rootDir, plugin = newTestPlugin(t, client)
+	)
+
+	testCases := []struct {
+		name    string
+		source  api.SecretVolumeSource
+		isValid bool
+	}{
+		{"all keys", api.SecretVolumeSource{SecretName: testName}, true},
+		{"items", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "a/b", Mode: &validMode}}}, true},
+		{"missing optional secret", api.SecretVolumeSource{SecretName: "missing", Optional: &optional}, true},
+		{"missing secret", api.SecretVolumeSource{SecretName: "missing"}, false},
+		{"missing key", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-4", Path: "data-4"}}}, false},
+		{"invalid default mode", api.SecretVolumeSource{SecretName: testName, DefaultMode: &invalidMode}, false},
+		{"invalid item mode", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "data-1", Mode: &invalidMode}}}, false},
+		{"invalid path", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "../data-1"}}}, false},
+		{"duplicate path", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "a"}, {Key: "data-2", Path: "a"}}}, false},
+		{"duplicate path by case", api.SecretVolumeSource{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "a/B"}, {Key: "data-2", Path: "A/b"}}}, false},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, tc := range testCases {
+		source := tc.source
+		spec := &volume.Spec{Name: testVolumeName, VolumeSource: api.VolumeSource{Secret: &source}}
+		err := plugin.(*secretPlugin).ValidateSpec(spec, pod)
+		if tc.isValid && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if !tc.isValid && err == nil {
+			t.Errorf("%v: expected an error", tc.name)
+		}
+	}
+
+	plugin.(*secretPlugin).maxSize = totalSecretBytes(&secret) - 1
+	spec := &volume.Spec{Name: testVolumeName, VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{SecretName: testName}}}
+	if err := plugin.(*secretPlugin).ValidateSpec(spec, pod); err == nil {
+		t.Errorf("Expected an error for a secret over the size limit")
+	}
+
+	if _, err := os.Stat(path.Join(rootDir, "pods")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written to disk, got: %v", err)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid69")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		optional       = true
+		pageSize       = int64(os.Getpagesize())
+
+		secret = secret(testNamespace, testName)
+		client = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			if name := action.(testclient.GetAction).GetName(); name != testName {
+				return nil, errors.NewNotFound("secrets", name)
+			}
+			return &secret, nil
+		}}
+		rootDir, plugin = newTestPlugin(t, client)
+	)
+	defer os.RemoveAll(rootDir)
+
+	expected := int64(totalSecretBytes(&secret)) + 3*pageSize
+	testCases := []struct {
+		name     string
+		source   api.SecretVolumeSource
+		expected int64
+		isValid  bool
+	}{
+		{"all keys", api.SecretVolumeSource{SecretName: testName}, expected, true},
+		{"missing optional secret", api.SecretVolumeSource{SecretName: "missing", Optional: &optional}, 0, true},
+		{"sources", api.SecretVolumeSource{SecretName: testName, Sources: []api.SecretProjection{
+			{SecretName: testName, Items: []api.KeyToPath{{Key: "data-1", Path: "other/data-1"}}},
+			{SecretName: "missing", Optional: &optional},
+		}}, 2 * expected, true},
+		{"missing secret", api.SecretVolumeSource{SecretName: "missing"}, 0, false},
+		{"no secret name", api.SecretVolumeSource{}, 0, false},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, tc := range testCases {
+		source := tc.source
+		spec := &volume.Spec{Name: testVolumeName, VolumeSource: api.VolumeSource{Secret: &source}}
+		size, err := plugin.(*secretPlugin).EstimateSize(spec, pod)
+		if tc.isValid && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if !tc.isValid && err == nil {
+			t.Errorf("%v: expected an error", tc.name)
+		}
+		if size != tc.expected {
+			t.Errorf("%v: expected an estimate of %v bytes, got %v", tc.name, tc.expected, size)
+		}
+	}
+
+	if _, err := os.Stat(path.Join(rootDir, "pods")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written to disk, got: %v", err)
+	}
+}
+
+func TestPluginItemsMissingKey(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid7")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{{Key: "no-such-key", Path: "foo"}}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+
+	err = builder.SetUp()
+	if err == nil {
+		t.Fatalf("Expected an error for a missing key")
+	}
+	if !strings.Contains(err.Error(), "no-such-key") {
+		t.Errorf("Expected the error to name the missing key, got: %v", err)
+	}
+}
+
+func TestPluginConcurrentSetUp(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid14")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+
+	// Hold the volume's lock as a concurrent setup would.
+	plug := plugin.(*secretPlugin)
+	unlock := plug.lockVolume(volumeKey(testPodUID, testVolumeName))
+	done := make(chan error)
+	go func() {
+		done <- builder.SetUp()
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Expected setup to wait for the volume lock, returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("Expected no API calls while the volume is locked, got %v", client.Actions())
+	}
+
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	if len(plug.locks) != 0 {
+		t.Errorf("Expected unused volume locks to be dropped, got %v", plug.locks)
+	}
+}
+
+// configTestHost is a VolumeHost that overrides the plugin's configuration.
+type configTestHost struct {
+	volume.VolumeHost
+	maxSize int
+	medium  api.StorageMedium
+	dirMode os.FileMode
+	retries int
+	backoff time.Duration
+	cache   bool
+	// fallbackDir is the directory for node-local copies of secrets.
+	fallbackDir string
+}
+
+func (h *configTestHost) GetSecretVolumeMaxSize() int {
+	return h.maxSize
+}
+
+func (h *configTestHost) GetSecretVolumeMedium() api.StorageMedium {
+	return h.medium
+}
+
+func (h *configTestHost) GetSecretVolumeDirMode() os.FileMode {
+	return h.dirMode
+}
+
+func (h *configTestHost) GetSecretVolumeRetryPolicy() (int, time.Duration) {
+	return h.retries, h.backoff
+}
+
+func (h *configTestHost) UseSecretVolumeCache() bool {
+	return h.cache
+}
+
+func (h *configTestHost) GetSecretVolumeFallbackDir() string {
+	return h.fallbackDir
+}
+
+// newConfigTestPlugin returns a secret plugin initialized with a host that
+// overrides its configuration with that of host.  host.VolumeHost is
+// filled in.
+func newConfigTestPlugin(t *testing.T, client client.Interface, host *configTestHost) (string, volume.VolumePlugin) {
+	rootDir, fakeHost := newTestHost(t, client)
+	host.VolumeHost = fakeHost
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	return rootDir, plugin
+}
+
+func TestPluginMaxSize(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid13")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+	)
+
+	host := &configTestHost{maxSize: totalSecretBytes(&secret) - 1, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: defaultGetSecretRetries, backoff: defaultGetSecretBackoff}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	err = builder.SetUp()
+	if err == nil {
+		t.Fatalf("Expected an error for a secret larger than %v bytes", host.maxSize)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%v bytes", totalSecretBytes(&secret))) {
+		t.Errorf("Expected the error to name the size of the secret, got: %v", err)
+	}
+	if entries, err := ioutil.ReadDir(volumePath); err != nil || len(entries) != 0 {
+		t.Errorf("Expected nothing to be written to %v, got %v, %v", volumePath, entries, err)
+	}
+
+	host.maxSize = totalSecretBytes(&secret)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err = pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume for a secret at the limit: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+}
+
+func TestPluginDiskMedium(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid17")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return &secret, nil
+		}}
+	)
+
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumDefault, dirMode: defaultDirMode, retries: defaultGetSecretRetries, backoff: defaultGetSecretBackoff}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &mount.FakeMounter{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	secret.Data["data-1"] = []byte("updated-1")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	if len(mounter.Log) != 0 {
+		t.Errorf("Expected a disk-backed volume not to be mounted, got %v", mounter.Log)
+	}
+}
+
+func TestPluginDirMode(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid22")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testFileMode   = 0400
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+	)
+	volumeSpec.Secret.DefaultMode = &testFileMode
+
+	testCases := []struct {
+		dirMode  os.FileMode
+		expected os.FileMode
+	}{
+		{defaultDirMode, 0755},
+		{0700, 0700},
+		// Readable directories must also be traversable.
+		{0640, 0750},
+	}
+	for _, tc := range testCases {
+		host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: tc.dirMode, retries: defaultGetSecretRetries, backoff: defaultGetSecretBackoff}
+		rootDir, plugin := newConfigTestPlugin(t, client, host)
+		defer os.RemoveAll(rootDir)
+
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Errorf("Failed to make a new Builder: %v", err)
+		}
+		volumePath := builder.GetPath()
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+
+		info, err := os.Stat(volumePath)
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", volumePath, err)
+		}
+		if info.Mode().Perm() != tc.expected {
+			t.Errorf("Unexpected mode for volume root with dir mode %v; expected %v, got %v", tc.dirMode, tc.expected, info.Mode().Perm())
+		}
+		doTestSecretModeInVolume(volumePath, secret, os.FileMode(testFileMode), t)
+	}
+}
+
+func TestPluginGetSecretRetries(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid23")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testRetries    = 2
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+	)
+
+	internal := errors.NewInternalError(fmt.Errorf("etcd is unavailable"))
+	timeout := errors.NewServerTimeout("secrets", "get", 1)
+	testCases := []struct {
+		name    string
+		errs    []error
+		isValid bool
+		calls   int
+	}{
+		{"no errors", nil, true, 1},
+		{"transient errors", []error{internal, timeout}, true, 3},
+		{"too many transient errors", []error{internal, internal, timeout}, false, 3},
+		{"forbidden", []error{errors.NewForbidden("secrets", testName, fmt.Errorf("denied"))}, false, 1},
+		{"not found", []error{errors.NewNotFound("secrets", testName)}, false, 1},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, tc := range testCases {
+		errs := tc.errs
+		client := &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			if len(errs) > 0 {
+				err := errs[0]
+				errs = errs[1:]
+				return nil, err
+			}
+			return &secret, nil
+		}}
+		host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: testRetries, backoff: time.Millisecond}
+		rootDir, plugin := newConfigTestPlugin(t, client, host)
+		defer os.RemoveAll(rootDir)
+
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Errorf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.isValid && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if !tc.isValid {
+			if err == nil {
+				t.Errorf("%v: expected an error", tc.name)
+			} else if last := tc.errs[len(tc.errs)-1]; Cause(err) != last {
+				t.Errorf("%v: expected the last error %v, got %v", tc.name, last, err)
+			}
+		}
+		if calls := len(client.Actions()); calls != tc.calls {
+			t.Errorf("%v: expected %v gets of the secret, got %v", tc.name, tc.calls, calls)
+		}
+	}
+}
+
+func TestPluginMetrics(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid12")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "data-1"},
+		{Key: "data-3", Path: "nested/data-3"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace, Name: "test_pod_name"}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	provider, ok := builder.(volume.MetricsProvider)
+	if !ok {
+		t.Fatalf("Expected the builder to provide metrics")
+	}
+	labels := map[string]string{"namespace": testNamespace, "pod": "test_pod_name", "secret": testName}
+	if m, err := provider.GetMetrics(); err != nil || !reflect.DeepEqual(*m, volume.Metrics{Labels: labels}) {
+		t.Errorf("Expected empty metrics before setup, got %+v, %v", m, err)
+	}
+
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expected := volume.Metrics{
+		Bytes:  int64(len(secret.Data["data-1"]) + len(secret.Data["data-3"])),
+		Files:  2,
+		Labels: labels,
+	}
+	if m, err := provider.GetMetrics(); err != nil || !reflect.DeepEqual(*m, expected) {
+		t.Errorf("Expected metrics %+v, got %+v, %v", expected, m, err)
+	}
+}
+
+func TestPluginMetricsOmitPodName(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid61")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+	)
+
+	cfg := DefaultConfig()
+	cfg.OmitPodNameLabel = true
+	plugins, err := ProbeVolumePluginsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error for a valid config: %v", err)
+	}
+	rootDir, host := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(plugins, host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace, Name: "test_pod_name"}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expected := map[string]string{"namespace": testNamespace, "secret": testName}
+	if m, err := builder.(volume.MetricsProvider).GetMetrics(); err != nil || !reflect.DeepEqual(m.Labels, expected) {
+		t.Errorf("Expected labels %v, got %+v, %v", expected, m, err)
+	}
+}
+
+// eventCounter is an EventRecorder that remembers the messages of the
+// events it is given.
+type eventCounter struct {
+	record.FakeRecorder
+	messages []string
+}
+
+func (e *eventCounter) Eventf(object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	e.messages = append(e.messages, fmt.Sprintf(messageFmt, args...))
+}
+
+func TestPluginMissingSecretEvent(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid11")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return nil, errors.NewNotFound("secrets", testName)
+		}}
+		recorder = &eventCounter{}
+		clock    = &libutil.FakeClock{Time: time.Now()}
+	)
+
+	rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp rootdir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	host := volume.NewFakeVolumeHostWithRecorder(rootDir, client, empty_dir.ProbeVolumePlugins(), recorder)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	plugin.(*secretPlugin).clock = clock
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	setUp := func() {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err == nil {
+			t.Errorf("Expected an error for a missing secret")
+		}
+	}
+
+	setUp()
+	if len(recorder.messages) != 1 {
+		t.Fatalf("Expected 1 event, got %v", recorder.messages)
+	}
+	if secretRef := testNamespace + "/" + testName; !strings.Contains(recorder.messages[0], secretRef) {
+		t.Errorf("Expected event to name %v, got %q", secretRef, recorder.messages[0])
+	}
+
+	clock.Time = clock.Time.Add(time.Minute)
+	setUp()
+	if len(recorder.messages) != 1 {
+		t.Errorf("Expected repeated failures to be rate-limited, got %v", recorder.messages)
+	}
+
+	clock.Time = clock.Time.Add(secretEventInterval)
+	setUp()
+	if len(recorder.messages) != 2 {
+		t.Errorf("Expected a new event after %v, got %v", secretEventInterval, recorder.messages)
+	}
+}
+
+func volumeSpec(volumeName, secretName string) *api.Volume {
+	return &api.Volume{
+		Name: volumeName,
+		VolumeSource: api.VolumeSource{
+			Secret: &api.SecretVolumeSource{
+				SecretName: secretName,
+			},
+		},
+	}
+}
+
+func secret(namespace, name string) api.Secret {
+	return api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: map[string][]byte{
+			"data-1": []byte("value-1"),
+			"data-2": []byte("value-2"),
+			"data-3": []byte("value-3"),
+		},
+	}
+}
+
+func doTestSecretDataInVolume(volumePath string, secret api.Secret, t *testing.T) {
+	for key, value := range secret.Data {
+		secretDataHostPath := path.Join(volumePath, key)
+		if _, err := os.Stat(secretDataHostPath); err != nil {
+			t.Fatalf("SetUp() failed, couldn't find secret data on disk: %v", secretDataHostPath)
+		} else {
+			actualSecretBytes, err := ioutil.ReadFile(secretDataHostPath)
+			if err != nil {
+				t.Fatalf("Couldn't read secret data from: %v", secretDataHostPath)
+			}
+
+			actualSecretValue := string(actualSecretBytes)
+			if string(value) != actualSecretValue {
+				t.Errorf("Unexpected value; expected %q, got %q", value, actualSecretValue)
+			}
+		}
+	}
+}
+
+// doTestNoNewMounts verifies that the only calls made to the mounter were
+// remounts of existing mounts.
+func doTestNoNewMounts(mounter *mount.FakeMounter, t *testing.T) {
+	for _, action := range mounter.Log {
+		if action.Action != mount.FakeActionMount || action.FSType != "" {
+			t.Errorf("Unexpected call made to mounter: %v", action)
+		}
+	}
+}
+
+func doTestSecretModeInVolume(volumePath string, secret api.Secret, mode os.FileMode, t *testing.T) {
+	for key := range secret.Data {
+		secretDataHostPath := path.Join(volumePath, key)
+		info, err := os.Stat(secretDataHostPath)
+		if err != nil {
+			t.Fatalf("SetUp() failed, couldn't find secret data on disk: %v", secretDataHostPath)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("Unexpected mode for %v; expected %v, got %v", secretDataHostPath, mode, info.Mode().Perm())
+		}
+	}
+}
+
+func doTestCleanAndTeardown(plugin volume.VolumePlugin, podUID types.UID, testVolumeName, volumePath string, t *testing.T) {
+	cleaner, err := plugin.NewCleaner(testVolumeName, podUID, mount.New())
+	if err != nil {
+		t.Errorf("Failed to make a new Cleaner: %v", err)
+	}
+	if cleaner == nil {
+		t.Errorf("Got a nil Cleaner")
+	}
+
+	if err := cleaner.TearDown(); err != nil {
+		t.Errorf("Expected success, got: %v", err)
+	}
+	if _, err := os.Stat(volumePath); err == nil {
+		t.Errorf("TearDown() failed, volume path still exists: %s", volumePath)
+	} else if !os.IsNotExist(err) {
+		t.Errorf("SetUp() failed: %v", err)
+	}
+	metaDir := cleaner.(*secretVolumeCleaner).getMetaDir()
+	if _, err := os.Stat(metaDir); err == nil {
+		t.Errorf("TearDown() failed, metadata dir still exists: %s", metaDir)
+	} else if !os.IsNotExist(err) {
+		t.Errorf("TearDown() failed: %v", err)
+	}
+}
+
+func TestPluginSecretCache(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid24")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec   = volumeSpec(testVolumeName, testName)
+		cached       = secret(testNamespace, testName)
+		otherNsCache = secret("other_namespace", "other_secret_name")
+		uncached     = secret(testNamespace, "uncached_secret_name")
+		gets         = []string{}
+		client       = &testclient.Fake{
+			Watch: watch.NewFake(),
+			ReactFn: func(action testclient.Action) (runtime.Object, error) {
+				switch action := action.(type) {
+				case testclient.ListAction:
+					return &api.SecretList{ListMeta: api.ListMeta{ResourceVersion: "1"}, Items: []api.Secret{cached, otherNsCache}}, nil
+				case testclient.GetAction:
+					gets = append(gets, action.GetNamespace()+"/"+action.GetName())
+					if action.GetNamespace() == testNamespace && action.GetName() == uncached.Name {
+						return &uncached, nil
+					}
+					return nil, errors.NewNotFound("secret", action.GetName())
+				}
+				return nil, nil
+			},
+		}
+	)
+
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: defaultGetSecretRetries, backoff: defaultGetSecretBackoff, cache: true}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return plugin.(*secretPlugin).cache.controller.HasSynced(), nil
+	}); err != nil {
+		t.Fatalf("Secret cache did not sync: %v", err)
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(builder.GetPath(), cached, t)
+	if len(gets) != 0 {
+		t.Errorf("Expected a cached secret not to be fetched, got %v", gets)
+	}
+
+	// A secret missing from the cache is fetched from the API server.
+	uncachedSpec := volume.NewSpecFromVolume(volumeSpec)
+	uncachedSpec.VolumeSource.Secret = &api.SecretVolumeSource{SecretName: uncached.Name}
+	if err := plugin.(*secretPlugin).ValidateSpec(uncachedSpec, pod); err != nil {
+		t.Errorf("Unexpected error validating an uncached secret: %v", err)
+	}
+
+	// Secrets cached for other namespaces are not visible to the pod.
+	otherSpec := volume.NewSpecFromVolume(volumeSpec)
+	otherSpec.VolumeSource.Secret = &api.SecretVolumeSource{SecretName: otherNsCache.Name}
+	if err := plugin.(*secretPlugin).ValidateSpec(otherSpec, pod); !errors.IsNotFound(err) {
+		t.Errorf("Expected a not found error for a secret in another namespace, got %v", err)
+	}
+
+	expected := []string{testNamespace + "/" + uncached.Name, testNamespace + "/" + otherNsCache.Name}
+	if len(gets) != len(expected) || gets[0] != expected[0] || gets[1] != expected[1] {
+		t.Errorf("Expected gets %v, got %v", expected, gets)
+	}
+}
+
+func TestPluginDuplicateItemPaths(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid25")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "foo"},
+		{Key: "data-2", Path: "bar"},
+		{Key: "data-3", Path: "Foo"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	_, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err == nil {
+		t.Fatalf("Expected an error for items sharing a path")
+	}
+	for _, key := range []string{"data-1", "data-3"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected the error to name key %v, got: %v", key, err)
+		}
+	}
+	if strings.Contains(err.Error(), "data-2") {
+		t.Errorf("Expected the error not to name key data-2, got: %v", err)
+	}
+}
+
+func TestPluginFallbackCopy(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid26")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		getErr     error
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			if getErr != nil {
+				return nil, getErr
+			}
+			return &secret, nil
+		}}
+	)
+
+	fallbackDir, err := ioutil.TempDir("/tmp", "secret_fallback_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp dir: %v", err)
+	}
+	defer os.RemoveAll(fallbackDir)
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: 0, fallbackDir: fallbackDir}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	copyPath := path.Join(fallbackDir, testNamespace, testName)
+	if _, err := os.Stat(copyPath); err != nil {
+		t.Fatalf("Expected a fallback copy of the secret: %v", err)
+	}
+
+	// The copy is used while the API server cannot be reached.
+	getErr = fmt.Errorf("dial tcp: connection refused")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Expected setup to use the fallback copy, got: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	// Answers from the API server are not overridden by the copy.
+	getErr = errors.NewForbidden("secrets", testName, fmt.Errorf("denied"))
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error when the API server denies the request")
+	}
+
+	// A deleted secret removes its copy.
+	getErr = errors.NewNotFound("secrets", testName)
+	if err := builder.SetUp(); !errors.IsNotFound(Cause(err)) {
+		t.Errorf("Expected a not found error, got: %v", err)
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the fallback copy to be removed, got: %v", err)
+	}
+}
+
+func TestFormatLogFields(t *testing.T) {
+	testCases := []struct {
+		keysAndValues []interface{}
+		expected      string
+	}{
+		{[]interface{}{}, ""},
+		{[]interface{}{"pod", types.UID("uid"), "bytes", 42}, "pod=uid bytes=42"},
+		{[]interface{}{"secret", ""}, `secret=""`},
+		{[]interface{}{"err", fmt.Errorf("no such file")}, `err="no such file"`},
+		{[]interface{}{"dir", `a"b=c`}, `dir="a\"b=c"`},
+	}
+	for _, tc := range testCases {
+		if actual := formatLogFields(tc.keysAndValues...); actual != tc.expected {
+			t.Errorf("Expected %q for %v, got %q", tc.expected, tc.keysAndValues, actual)
+		}
+	}
+}
+
+func TestPluginItemOwners(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown requires root")
+	}
+	var (
+		testPodUID     = types.UID("test_pod_uid27")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		keyOwner       = int64(1000)
+		configOwner    = int64(2000)
+		fsGroup        = int64(3000)
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "key", UID: &keyOwner, GID: &keyOwner},
+		{Key: "data-2", Path: "config", UID: &configOwner},
+		{Key: "data-3", Path: "other"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{FSGroup: &fsGroup}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	expected := map[string][2]int64{
+		"key":    {keyOwner, keyOwner},
+		"config": {configOwner, fsGroup},
+		"other":  {int64(os.Geteuid()), fsGroup},
+	}
+	for name, owner := range expected {
+		info, err := os.Stat(path.Join(volumePath, name))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", name, err)
+		}
+		stat := info.Sys().(*syscall.Stat_t)
+		if int64(stat.Uid) != owner[0] || int64(stat.Gid) != owner[1] {
+			t.Errorf("Unexpected owner of %v; expected %v:%v, got %v:%v", name, owner[0], owner[1], stat.Uid, stat.Gid)
+		}
+	}
+}
+
+func TestPluginItemOwnersUnprivileged(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid28")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		owner          = int64(1000)
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	defer func(f func() bool) { canChown = f }(canChown)
+	canChown = func() bool { return false }
+
+	volumeSpec.Secret.Items = []api.KeyToPath{{Key: "data-1", Path: "key", GID: &owner}}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	spec := volume.NewSpecFromVolume(volumeSpec)
+	if _, err := plugin.NewBuilder(spec, pod, volume.VolumeOptions{}, &mount.FakeMounter{}); err == nil || !strings.Contains(err.Error(), "data-1") {
+		t.Errorf("Expected an error naming key data-1, got: %v", err)
+	}
+	if err := plugin.(*secretPlugin).ValidateSpec(spec, pod); err == nil {
+		t.Errorf("Expected ValidateSpec to reject an owner")
+	}
+}
+
+func TestPluginItemEncoding(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid29")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	// Wrapped lines are accepted, as in PEM bodies.
+	secret.Data["encoded"] = []byte("AAEC\n/w==")
+	secret.Data["malformed"] = []byte("not base64!")
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "encoded", Path: "decoded", Encoding: api.KeyEncodingBase64},
+		{Key: "encoded", Path: "raw", Encoding: api.KeyEncodingRaw},
+		{Key: "data-1", Path: "default"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	for name, expected := range map[string][]byte{
+		"decoded": {0, 1, 2, 0xff},
+		"raw":     secret.Data["encoded"],
+		"default": secret.Data["data-1"],
+	} {
+		actual, err := ioutil.ReadFile(path.Join(volumePath, name))
+		if err != nil {
+			t.Fatalf("Couldn't read %v: %v", name, err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("Unexpected content of %v; expected %v, got %v", name, expected, actual)
+		}
+	}
+
+	volumeSpec.Secret.Items = []api.KeyToPath{{Key: "malformed", Path: "decoded", Encoding: api.KeyEncodingBase64}}
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+		t.Errorf("Expected an error for a malformed base64 value")
+	}
+}
+
+// fakeFreeSpaceDetector reports a fixed number of available bytes.
+type fakeFreeSpaceDetector struct {
+	available int64
+}
+
+func (f *fakeFreeSpaceDetector) AvailableBytes(path string) (int64, bool, error) {
+	return f.available, true, nil
+}
+
+func TestPluginInsufficientSpace(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid30")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+		needed     = int64(totalSecretBytes(&secret))
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	freeSpace := &fakeFreeSpaceDetector{available: needed - 1}
+	builder.(*secretVolumeBuilder).freeSpace = freeSpace
+	volumePath := builder.GetPath()
+
+	err = builder.SetUp()
+	if err == nil {
+		t.Fatalf("Expected an error when the volume lacks space")
+	}
+	for _, s := range []string{"insufficient space", fmt.Sprintf("%v bytes needed", needed), fmt.Sprintf("%v bytes available", needed-1)} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected the error to contain %q, got: %v", s, err)
+		}
+	}
+	if _, err := os.Lstat(path.Join(volumePath, dataDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written, got: %v", err)
+	}
+
+	freeSpace.available = needed
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+}
+
+func TestIsNoSpaceError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{syscall.ENOSPC, true},
+		{&os.PathError{Op: "write", Path: "/foo", Err: syscall.ENOSPC}, true},
+		{&os.LinkError{Op: "link", Old: "/foo", New: "/bar", Err: syscall.ENOSPC}, true},
+		{&os.PathError{Op: "write", Path: "/foo", Err: syscall.EIO}, false},
+		{fmt.Errorf("no space"), false},
+	}
+	for _, tc := range testCases {
+		if actual := isNoSpaceError(tc.err); actual != tc.expected {
+			t.Errorf("Expected %v for %v, got %v", tc.expected, tc.err, actual)
+		}
+	}
+}
+
+func TestProbeVolumePluginsWithConfig(t *testing.T) {
+	invalid := map[string]func(*Config){
+		"mode":    func(c *Config) { c.DefaultFileMode = os.ModeSetuid | 0444 },
+		"size":    func(c *Config) { c.MaxSize = 0 },
+		"retries": func(c *Config) { c.GetRetries = -1 },
+		"backoff": func(c *Config) { c.GetBackoff = 0 },
+		"grace":   func(c *Config) { c.NotFoundGracePeriod = -time.Second },
+		"margin":  func(c *Config) { c.TmpfsSizeMargin = -1 },
+		"allow":   func(c *Config) { c.AllowHostDirectories = true },
+		"hostdir": func(c *Config) { c.HostDirectories = map[string]string{"secrets": "srv/secrets"} },
+		"name":    func(c *Config) { c.HostDirectories = map[string]string{"Secrets": "/srv/secrets"} },
+		"overlay": func(c *Config) { c.AllowFileOverlays = true },
+		"prefix":  func(c *Config) { c.HostDirectoryPrefixes = []string{"srv/secrets"} },
+		"resync":  func(c *Config) { c.ResyncPeriod = -time.Second },
+		"jitter":  func(c *Config) { c.ResyncJitter = -1 },
+		"socket":  func(c *Config) { c.SocketDeliveryTimeout = -time.Second },
+		"tenant":  func(c *Config) { c.AllowedNamespaces = []string{"Tenant_A"} },
+		"keys":    func(c *Config) { c.MaxKeys = -1 },
+	}
+	for name, mutate := range invalid {
+		cfg := DefaultConfig()
+		mutate(&cfg)
+		if _, err := ProbeVolumePluginsWithConfig(cfg); err == nil {
+			t.Errorf("%v: expected an error for %+v", name, cfg)
+		}
+	}
+
+	var (
+		testPodUID     = types.UID("test_pod_uid31")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+	)
+
+	cfg := DefaultConfig()
+	cfg.DefaultFileMode = 0400
+	cfg.MaxSize = totalSecretBytes(&secret)
+	plugins, err := ProbeVolumePluginsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error for a valid config: %v", err)
+	}
+	rootDir, host := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(plugins, host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretModeInVolume(builder.GetPath(), secret, 0400, t)
+
+	// The size limit is also applied.
+	secret.Data["data-4"] = []byte("one too many")
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error for a secret over the configured size limit")
+	}
+}
+
+func TestPluginWipeOnTeardown(t *testing.T) {
+	var (
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret    = secret(testNamespace, testName)
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	for i, wipe := range []bool{false, true} {
+		testPodUID := types.UID(fmt.Sprintf("test_pod_uid32-%v", i))
+		volumeSpec := volumeSpec(testVolumeName, testName)
+		volumeSpec.Secret.WipeOnTeardown = wipe
+
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter := &mount.FakeMounter{}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Errorf("Failed to make a new Builder: %v", err)
+		}
+		volumePath := builder.GetPath()
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+
+		// Keep a link to the file's storage so it can be inspected after
+		// teardown.
+		dataFile, err := filepath.EvalSymlinks(path.Join(volumePath, "data-1"))
+		if err != nil {
+			t.Fatalf("Couldn't resolve data-1: %v", err)
+		}
+		keep := path.Join(path.Dir(path.Dir(volumePath)), fmt.Sprintf("kept-%v", i))
+		if err := os.Link(dataFile, keep); err != nil {
+			t.Fatalf("Couldn't link %v: %v", dataFile, err)
+		}
+		defer os.Remove(keep)
+
+		cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+		if err != nil {
+			t.Errorf("Failed to make a new Cleaner: %v", err)
+		}
+		if err := cleaner.TearDown(); err != nil {
+			t.Errorf("Failed to tear down volume: %v", err)
+		}
+
+		expected := secret.Data["data-1"]
+		if wipe {
+			expected = make([]byte, len(expected))
+		}
+		actual, err := ioutil.ReadFile(keep)
+		if err != nil {
+			t.Fatalf("Couldn't read %v: %v", keep, err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("wipe %v: expected %q after teardown, got %q", wipe, expected, actual)
+		}
+	}
+}
+
+func TestPluginWrittenPaths(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid33")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "one"},
+		{Key: "data-2", Path: "nested/two"},
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &mount.FakeMounter{}
+	newBuilder := func() *secretVolumeBuilder {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder.(*secretVolumeBuilder)
+	}
+
+	builder := newBuilder()
+	if paths := builder.WrittenPaths(); paths != nil {
+		t.Errorf("Expected no paths before setup, got %v", paths)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expected := []string{"nested/two", "one"}
+	if paths := builder.WrittenPaths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+
+	// A builder made after a restart reads the paths from the meta dir.
+	if paths := newBuilder().WrittenPaths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected persisted paths %v, got %v", expected, paths)
+	}
+
+	// Only owned links are pruned: a link through ..data that the volume
+	// did not write survives a refresh.
+	volumePath := builder.GetPath()
+	if err := os.Symlink(path.Join(dataDirName, "stray"), path.Join(volumePath, "stray")); err != nil {
+		t.Fatalf("Couldn't create stray link: %v", err)
+	}
+	volumeSpec.Secret.Items = []api.KeyToPath{{Key: "data-1", Path: "one"}}
+	builder = newBuilder()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if paths := newBuilder().WrittenPaths(); !reflect.DeepEqual(paths, []string{"one"}) {
+		t.Errorf("Expected persisted paths [one], got %v", paths)
+	}
+	if _, err := os.Lstat(path.Join(volumePath, "nested")); !os.IsNotExist(err) {
+		t.Errorf("Expected nested to be pruned, got: %v", err)
+	}
+	if _, err := os.Lstat(path.Join(volumePath, "stray")); err != nil {
+		t.Errorf("Expected stray link to be left alone, got: %v", err)
+	}
+}
+
+// remountRecorder is a FakeMounter that records the mode of each remount.
+type remountRecorder struct {
+	mount.FakeMounter
+	remounts []string
+}
+
+func (m *remountRecorder) Mount(source string, target string, fstype string, options []string) error {
+	if len(options) == 2 && options[0] == "remount" {
+		m.remounts = append(m.remounts, options[1])
+	}
+	return m.FakeMounter.Mount(source, target, fstype, options)
+}
+
+func TestPluginReadOnlyWithFSGroup(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid34")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		fsGroup        = int64(os.Getgid())
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &remountRecorder{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{FSGroup: &fsGroup}, mounter)
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+
+	// A setup that fails partway still leaves the volume read-only.
+	builder.(*secretVolumeBuilder).freeSpace = &fakeFreeSpaceDetector{available: 0}
+	if err := builder.SetUp(); err == nil {
+		t.Fatalf("Expected an error when the volume lacks space")
+	}
+	if n := len(mounter.remounts); n == 0 || mounter.remounts[n-1] != "ro" {
+		t.Errorf("Expected a failed setup to leave the volume read-only, got remounts %v", mounter.remounts)
+	}
+
+	builder.(*secretVolumeBuilder).freeSpace = &fakeFreeSpaceDetector{available: 1 << 20}
+	secret.Data["data-1"] = []byte("updated-1")
+	mounter.remounts = nil
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if !reflect.DeepEqual(mounter.remounts, []string{"rw", "ro"}) {
+		t.Errorf("Expected the volume to be made writable, then read-only, got remounts %v", mounter.remounts)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	for key := range secret.Data {
+		info, err := os.Stat(path.Join(volumePath, key))
+		if err != nil {
+			t.Fatalf("Couldn't stat %v: %v", key, err)
+		}
+		if info.Mode().Perm()&0040 == 0 {
+			t.Errorf("Expected %v to be group-readable, got mode %v", key, info.Mode().Perm())
+		}
+		if gid := info.Sys().(*syscall.Stat_t).Gid; int64(gid) != fsGroup {
+			t.Errorf("Unexpected group for %v; expected %v, got %v", key, fsGroup, gid)
+		}
+	}
+	if !builder.GetAttributes().ReadOnly {
+		t.Errorf("Expected the volume to be read-only")
+	}
+}
+
+func TestPluginSetUpCanceled(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid35")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec  = volumeSpec(testVolumeName, testName)
+		secret      = secret(testNamespace, testName)
+		unavailable = errors.NewInternalError(fmt.Errorf("unavailable"))
+		getErr      error
+		client      = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			if getErr != nil {
+				return nil, getErr
+			}
+			return &secret, nil
+		}}
+	)
+
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: 10, backoff: time.Hour}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	cancelable := builder.(volume.CancelableBuilder)
+	volumePath := builder.GetPath()
+
+	// Waits between retries are abandoned.
+	getErr = unavailable
+	stopCh := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cancelable.SetUpAtWithCancel(volumePath, stopCh)
+	}()
+	close(stopCh)
+	select {
+	case err := <-done:
+		if err != volume.ErrSetUpCanceled {
+			t.Errorf("Expected %v, got: %v", volume.ErrSetUpCanceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Setup was not canceled")
+	}
+
+	// Files written for a canceled setup are removed.
+	getErr = nil
+	if err := cancelable.SetUpAtWithCancel(volumePath, stopCh); err != volume.ErrSetUpCanceled {
+		t.Errorf("Expected %v, got: %v", volume.ErrSetUpCanceled, err)
+	}
+	if dirs := timestampDirs(t, volumePath); len(dirs) != 0 {
+		t.Errorf("Expected nothing to be written, got %v", dirs)
+	}
+	if _, err := os.Lstat(path.Join(volumePath, dataDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected %v not to exist, got: %v", dataDirName, err)
+	}
+
+	if err := cancelable.SetUpAtWithCancel(volumePath, make(chan struct{})); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+}
+
+func TestPluginItemKeyPattern(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid36")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+		optional   = true
+	)
+
+	secret.Data["cert-a.pem"] = []byte("a")
+	secret.Data["cert-b.pem"] = []byte("b")
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{KeyPattern: "cert-*.pem", Path: "certs"},
+		{KeyPattern: "key-*.pem", Path: "keys", Optional: &optional},
+		{Key: "data-1", Path: "data"},
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	for name, expected := range map[string]string{
+		"certs/cert-a.pem": "a",
+		"certs/cert-b.pem": "b",
+		"data":             "value-1",
+	} {
+		actual, err := ioutil.ReadFile(path.Join(volumePath, name))
+		if err != nil {
+			t.Fatalf("Couldn't read %v: %v", name, err)
+		}
+		if string(actual) != expected {
+			t.Errorf("Unexpected content of %v; expected %q, got %q", name, expected, actual)
+		}
+	}
+	if _, err := os.Stat(path.Join(volumePath, "keys")); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory for an optional pattern matching nothing, got: %v", err)
+	}
+
+	errorCases := map[string][]api.KeyToPath{
+		"no matches":  {{KeyPattern: "key-*.pem", Path: "keys"}},
+		"overlapping": {{KeyPattern: "cert-*", Path: "certs"}, {KeyPattern: "*.pem", Path: "certs"}},
+	}
+	for k, items := range errorCases {
+		volumeSpec.Secret.Items = items
+		if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+			t.Errorf("%s: expected an error", k)
+		}
+	}
+}
+
+func TestPluginResourceVersion(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid37")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			current := secret
+			return &current, nil
+		}}
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	secretBuilder := builder.(*secretVolumeBuilder)
+	if version := secretBuilder.ResourceVersion(); version != "" {
+		t.Errorf("Expected no resource version before setup, got %q", version)
+	}
+
+	secret.ResourceVersion = "1"
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	if version := secretBuilder.ResourceVersion(); version != "1" {
+		t.Errorf("Expected resource version %q, got %q", "1", version)
+	}
+
+	// The same version is not written again, even if the file on disk no
+	// longer matches it.
+	original := secret.Data["data-1"]
+	secret.Data = map[string][]byte{"data-1": []byte("unseen-1"), "data-2": secret.Data["data-2"], "data-3": secret.Data["data-3"]}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if actual, err := ioutil.ReadFile(path.Join(volumePath, "data-1")); err != nil || !bytes.Equal(actual, original) {
+		t.Errorf("Expected data-1 to keep %q for an unchanged version, got %q: %v", original, actual, err)
+	}
+
+	secret.ResourceVersion = "2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	if version := secretBuilder.ResourceVersion(); version != "2" {
+		t.Errorf("Expected resource version %q, got %q", "2", version)
+	}
+}
+
+func TestSanitizeKeyName(t *testing.T) {
+	long := strings.Repeat("a", maxSanitizedNameLength+1)
+	for key, expected := range map[string]string{
+		"tls.crt":          "tls.crt",
+		"cert-a.pem":       "cert-a.pem",
+		".dockercfg":       "_dockercfg-",
+		"a b:c":            "a_b_c-",
+		long:               strings.Repeat("a", maxSanitizedNameLength-9) + "-",
+		long[:len(long)-1]: long[:len(long)-1],
+	} {
+		name := sanitizeKeyName(key)
+		if !strings.HasPrefix(name, expected) || (name != expected && len(name) != len(expected)+8) {
+			t.Errorf("Unexpected name for key %q: expected %q, got %q", key, expected, name)
+		}
+		if len(name) > maxSanitizedNameLength {
+			t.Errorf("Name for key %q is longer than %v: %q", key, maxSanitizedNameLength, name)
+		}
+		if again := sanitizeKeyName(key); again != name {
+			t.Errorf("Unstable name for key %q: %q, then %q", key, name, again)
+		}
+	}
+	if sanitizeKeyName("a:b") == sanitizeKeyName("a;b") {
+		t.Errorf("Expected different keys to get different names")
+	}
+}
+
+func TestPluginSanitizeKeyNames(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid38")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	secret.Data[".hidden"] = []byte("hidden")
+	volumeSpec.Secret.SanitizeKeyNames = true
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Errorf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	for key, value := range secret.Data {
+		actual, err := ioutil.ReadFile(path.Join(volumePath, sanitizeKeyName(key)))
+		if err != nil {
+			t.Fatalf("Couldn't read file for key %v: %v", key, err)
+		}
+		if !bytes.Equal(actual, value) {
+			t.Errorf("Unexpected content for key %v; expected %q, got %q", key, value, actual)
+		}
+	}
+	if _, err := os.Lstat(path.Join(volumePath, ".hidden")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file named after the raw key, got: %v", err)
+	}
+}
+
+func TestPluginGetVolumesForPod(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid39")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+
+		secret    = secret(testNamespace, testName)
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	secretPlugin := plugin.(*secretPlugin)
+	if names, err := secretPlugin.GetVolumesForPod(testPodUID); err != nil || len(names) != 0 {
+		t.Errorf("Expected no volumes before setup, got %v: %v", names, err)
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	var volumePath string
+	for _, name := range []string{"vol-b", "vol-a"} {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec(name, testName)), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume %v: %v", name, err)
+		}
+		volumePath = builder.GetPath()
+	}
+
+	// Entries the plugin did not set up are skipped.
+	volumesDir := path.Dir(volumePath)
+	if err := ioutil.WriteFile(path.Join(volumesDir, "stray-file"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Couldn't write stray file: %v", err)
+	}
+	if err := os.Mkdir(path.Join(volumesDir, "stray-dir"), 0755); err != nil {
+		t.Fatalf("Couldn't make stray dir: %v", err)
+	}
+
+	names, err := secretPlugin.GetVolumesForPod(testPodUID)
+	if err != nil {
+		t.Fatalf("Failed to list volumes: %v", err)
+	}
+	if expected := []string{"vol-a", "vol-b"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected volumes %v, got %v", expected, names)
+	}
+}
+
+func TestPluginSources(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid40")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		tlsName        = "test_tls_name"
+		optional       = true
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secrets    = map[string]api.Secret{
+			testName: secret(testNamespace, testName),
+			tlsName: {
+				ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: tlsName, ResourceVersion: "7"},
+				Data:       map[string][]byte{"tls.crt": []byte("cert"), "data-1": []byte("other-1")},
+			},
+		}
+		client = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			name := action.(testclient.GetAction).GetName()
+			secret, ok := secrets[name]
+			if !ok {
+				return nil, errors.NewNotFound("secrets", name)
+			}
+			return &secret, nil
+		}}
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	primary := secrets[testName]
+	primary.ResourceVersion = "3"
+	secrets[testName] = primary
+	volumeSpec.Secret.Sources = []api.SecretProjection{
+		{SecretName: tlsName, Items: []api.KeyToPath{{Key: "tls.crt", Path: "tls/server.crt"}}},
+		{SecretName: "missing", Optional: &optional},
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, primary, t)
+	if actual, err := ioutil.ReadFile(path.Join(volumePath, "tls/server.crt")); err != nil || string(actual) != "cert" {
+		t.Errorf("Expected tls/server.crt to hold %q, got %q: %v", "cert", actual, err)
+	}
+	if version := builder.(*secretVolumeBuilder).ResourceVersion(); version != "" {
+		t.Errorf("Expected no resource version with a missing source, got %q", version)
+	}
+
+	// Keys of different secrets may not land on the same path.
+	errorCases := map[string][]api.SecretProjection{
+		"same path":      {{SecretName: tlsName}},
+		"missing secret": {{SecretName: "missing"}},
+	}
+	for k, sources := range errorCases {
+		volumeSpec.Secret.Sources = sources
+		if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+			t.Errorf("%s: expected an error", k)
+		}
+	}
+
+	volumeSpec.Secret.Sources = []api.SecretProjection{{SecretName: tlsName, Items: []api.KeyToPath{{Key: "tls.crt", Path: "server.crt"}}}}
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if version := builder.(*secretVolumeBuilder).ResourceVersion(); version != "3,7" {
+		t.Errorf("Expected resource version %q, got %q", "3,7", version)
+	}
+}
+
+func TestPluginReSetUpIncompleteVolume(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid41")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	secretBuilder := builder.(*secretVolumeBuilder)
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if !secretBuilder.isReady(volumePath) {
+		t.Errorf("Expected a complete volume to be ready")
+	}
+
+	// Simulate a setup interrupted after the volume was marked ready.
+	if err := os.Remove(path.Join(volumePath, "data-2")); err != nil {
+		t.Fatalf("Couldn't remove data-2: %v", err)
+	}
+	if !util.IsReady(secretBuilder.getMetaDir()) {
+		t.Fatalf("Expected the readiness file to remain")
+	}
+	if secretBuilder.isReady(volumePath) {
+		t.Errorf("Expected a volume missing a file not to be ready")
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to set up volume again: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	if !secretBuilder.isReady(volumePath) {
+		t.Errorf("Expected the volume to be ready after setting it up again")
+	}
+}
+
+func TestPluginSingleKeyTargetPath(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid42")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       map[string][]byte{"token-20150901": []byte("token")},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.SingleKeyTargetPath = "auth/token"
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if actual, err := ioutil.ReadFile(path.Join(volumePath, "auth/token")); err != nil || string(actual) != "token" {
+		t.Errorf("Expected auth/token to hold %q, got %q: %v", "token", actual, err)
+	}
+	if _, err := os.Lstat(path.Join(volumePath, "token-20150901")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file named after the key, got: %v", err)
+	}
+
+	secret.Data["token-20151001"] = []byte("next")
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+		t.Errorf("Expected an error for a secret with more than one key")
+	}
+}
+
+func TestPluginEmptySecretName(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid43")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+
+		client = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			return nil, fmt.Errorf("unexpected call to the API server: %v", action)
+		}}
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for name, source := range map[string]*api.SecretVolumeSource{
+		"volume": {},
+		"source": {SecretName: "test_secret_name", Sources: []api.SecretProjection{{}}},
+	} {
+		spec := &api.Volume{Name: testVolumeName, VolumeSource: api.VolumeSource{Secret: source}}
+		_, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err == nil || !strings.Contains(err.Error(), "secretName is required") {
+			t.Errorf("%s: expected an error for an empty secret name, got: %v", name, err)
+		}
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("Expected no calls to the API server, got %v", actions)
+	}
+}
+
+// metricsTestHost is a VolumeHost that records the metrics registered with
+// it.
+type metricsTestHost struct {
+	volume.VolumeHost
+	registered []prometheus.Collector
+}
+
+func (h *metricsTestHost) RegisterMetrics(collector prometheus.Collector) error {
+	h.registered = append(h.registered, collector)
+	return nil
+}
+
+// setupLatencyCount returns the number of observations of operation with
+// result.
+func setupLatencyCount(t *testing.T, operation, result string) uint64 {
+	metric := &dto.Metric{}
+	if err := setupLatency.WithLabelValues(operation, result).Write(metric); err != nil {
+		t.Fatalf("Couldn't read the setup latency of %v: %v", operation, err)
+	}
+	return metric.GetSummary().GetSampleCount()
+}
+
+func TestPluginSetupLatencyMetrics(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid44")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			if name := action.(testclient.GetAction).GetName(); name != testName {
+				return nil, errors.NewNotFound("secrets", name)
+			}
+			return &secret, nil
+		}}
+	)
+
+	// Without a registry, nothing is recorded.
+	_, plugin := newTestPlugin(t, client)
+	if plugin.(*secretPlugin).setupLatency != nil {
+		t.Errorf("Expected no metrics without a registry")
+	}
+
+	rootDir, fakeHost := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	host := &metricsTestHost{VolumeHost: fakeHost}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	if len(host.registered) != 1 || host.registered[0] != setupLatency {
+		t.Errorf("Expected the setup latency to be registered, got %v", host.registered)
+	}
+
+	type count struct{ operation, result string }
+	counts := func() map[count]uint64 {
+		m := map[count]uint64{}
+		for _, op := range []string{operationSetUp, operationFetch, operationWrite} {
+			for _, result := range []string{"success", "failure"} {
+				m[count{op, result}] = setupLatencyCount(t, op, result)
+			}
+		}
+		return m
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	before := counts()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	after := counts()
+	for _, c := range []count{{operationSetUp, "success"}, {operationFetch, "success"}, {operationWrite, "success"}} {
+		if after[c] != before[c]+1 {
+			t.Errorf("Expected one more observation of %v, got %v", c, after[c]-before[c])
+		}
+	}
+
+	volumeSpec.Secret.SecretName = "missing"
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	before = counts()
+	if err := builder.SetUp(); err == nil {
+		t.Fatalf("Expected an error for a missing secret")
+	}
+	after = counts()
+	for _, c := range []count{{operationSetUp, "failure"}, {operationFetch, "failure"}} {
+		if after[c] != before[c]+1 {
+			t.Errorf("Expected one more observation of %v, got %v", c, after[c]-before[c])
+		}
+	}
+	if c := (count{operationWrite, "failure"}); after[c] != before[c] {
+		t.Errorf("Expected no write to be timed for a missing secret")
+	}
+}
+
+func TestPluginContentHash(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid45")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       map[string][]byte{"data-1": []byte("value-1")},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.WriteContentHash = true
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	setUp := func() string {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+		hash, err := ioutil.ReadFile(path.Join(builder.GetPath(), ".secret-hash"))
+		if err != nil {
+			t.Fatalf("Couldn't read the content hash: %v", err)
+		}
+		return string(hash)
+	}
+
+	first := setUp()
+	if again := setUp(); again != first {
+		t.Errorf("Expected the hash of unchanged contents to stay %q, got %q", first, again)
+	}
+	secret.Data["data-1"] = []byte("value-2")
+	if changed := setUp(); changed == first {
+		t.Errorf("Expected the hash to change with the contents of the secret")
+	}
+
+	secret.Data[".secret-hash"] = []byte("value")
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+		t.Errorf("Expected an error for a key projected to the content hash file")
+	}
+}
+
+func TestPluginPlainDirectory(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid46")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.PlainDirectory = true
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &mount.FakeMounter{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	secret.Data["data-1"] = []byte("updated-1")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	if _, err := os.Stat(volumePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume to be removed, got: %v", err)
+	}
+	if len(mounter.Log) != 0 {
+		t.Errorf("Expected a plain directory volume not to be mounted, got %v", mounter.Log)
+	}
+}
+
+func TestFakePlugin(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid47")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+	)
+
+	fake, err := NewFakePlugin(&secret)
+	if err != nil {
+		t.Fatalf("Failed to make a fake plugin: %v", err)
+	}
+	defer fake.Cleanup()
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := fake.NewBuilder(volumeSpec, pod)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if !strings.HasPrefix(volumePath, fake.RootDir) {
+		t.Errorf("Expected the volume to be under %v, got %v", fake.RootDir, volumePath)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	if len(fake.Mounter.(*mount.FakeMounter).Log) == 0 {
+		t.Errorf("Expected the volume to be mounted through the fake mounter")
+	}
+
+	fake.Client.ReactFn = func(testclient.Action) (runtime.Object, error) {
+		return nil, fmt.Errorf("stubbed error")
+	}
+	if err := builder.SetUp(); err == nil || !strings.Contains(err.Error(), "stubbed error") {
+		t.Errorf("Expected the stubbed error, got: %v", err)
+	}
+
+	cleaner, err := fake.NewCleaner(testVolumeName, testPodUID)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	if _, err := os.Stat(volumePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume to be removed, got: %v", err)
+	}
+}
+
+func TestPluginRequireNonEmpty(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid48")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	optional := true
+	testCases := []struct {
+		name            string
+		data            map[string][]byte
+		requireNonEmpty bool
+		optional        *bool
+		expectErr       bool
+	}{
+		{name: "nil data", data: nil},
+		{name: "empty data", data: map[string][]byte{}},
+		{name: "nil data required", data: nil, requireNonEmpty: true, expectErr: true},
+		{name: "empty data required", data: map[string][]byte{}, requireNonEmpty: true, expectErr: true},
+		{name: "empty data required optional", data: map[string][]byte{}, requireNonEmpty: true, optional: &optional},
+		{name: "data required", data: map[string][]byte{"data-1": []byte("value-1")}, requireNonEmpty: true},
+	}
+	for _, tc := range testCases {
+		secret := api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       tc.data,
+		}
+		_, plugin := newTestPlugin(t, testclient.NewSimpleFake(&secret))
+		spec := volumeSpec(testVolumeName, testName)
+		spec.Secret.RequireNonEmpty = tc.requireNonEmpty
+		spec.Secret.Optional = tc.optional
+
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%s: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.expectErr {
+			if err == nil || !strings.Contains(err.Error(), "has no data") {
+				t.Errorf("%s: expected an error for a secret without data, got: %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: failed to setup volume: %v", tc.name, err)
+			continue
+		}
+		doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	}
+}
+
+func TestPluginRefreshItemMode(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid49")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	// The resource version does not change along with the mode, so the
+	// refresh must not trust it alone.
+	secret.ResourceVersion = "1"
+	mode := 0644
+	volumeSpec.Secret.Items = []api.KeyToPath{{Key: "data-1", Path: "data-1", Mode: &mode}}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	for _, expected := range []int{0644, 0400} {
+		mode = expected
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+		info, err := os.Stat(path.Join(builder.GetPath(), "data-1"))
+		if err != nil {
+			t.Fatalf("Couldn't stat data-1: %v", err)
+		}
+		if actual := info.Mode().Perm(); actual != os.FileMode(expected) {
+			t.Errorf("Expected data-1 to have mode %v, got %v", os.FileMode(expected), actual)
+		}
+	}
+}
+
+func TestPluginSetUpRetryAfter(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid50")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	testCases := []struct {
+		name     string
+		err      error
+		expected time.Duration
+	}{
+		{"not found", errors.NewNotFound("secrets", testName), permanentErrorRetryAfter},
+		{"forbidden", errors.NewForbidden("secrets", testName, fmt.Errorf("denied")), permanentErrorRetryAfter},
+		{"unavailable", errors.NewInternalError(fmt.Errorf("unavailable")), transientErrorRetryAfter},
+		{"unknown", fmt.Errorf("connection refused"), 0},
+	}
+	for _, tc := range testCases {
+		getErr := tc.err
+		client := &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return nil, getErr
+		}}
+		host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode}
+		rootDir, plugin := newConfigTestPlugin(t, client, host)
+		defer os.RemoveAll(rootDir)
+
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if err == nil {
+			t.Errorf("%v: expected an error", tc.name)
+			continue
+		}
+		retryErr, ok := err.(volume.RetryAfterError)
+		if tc.expected == 0 {
+			if ok {
+				t.Errorf("%v: expected no suggested retry, got %v", tc.name, retryErr.RetryAfter())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%v: expected a suggested retry, got: %v", tc.name, err)
+		} else if actual := retryErr.RetryAfter(); actual != tc.expected {
+			t.Errorf("%v: expected a retry after %v, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestPluginBundlePath(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid51")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data: map[string][]byte{
+				"intermediate.pem": []byte("intermediate"),
+				"root.pem":         []byte("root"),
+				"extra.pem":        []byte("extra"),
+			},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.BundlePath = "certs/bundle.pem"
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	separator := "\n--\n"
+	testCases := []struct {
+		separator *string
+		expected  string
+	}{
+		{nil, "extra\nintermediate\nroot"},
+		{&separator, "extra\n--\nintermediate\n--\nroot"},
+	}
+	for _, tc := range testCases {
+		volumeSpec.Secret.BundleSeparator = tc.separator
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		volumePath := builder.GetPath()
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+		bundlePath := path.Join(volumePath, "certs/bundle.pem")
+		if actual, err := ioutil.ReadFile(bundlePath); err != nil || string(actual) != tc.expected {
+			t.Errorf("Expected the bundle to hold %q, got %q: %v", tc.expected, actual, err)
+		}
+		if _, err := os.Lstat(path.Join(volumePath, "root.pem")); !os.IsNotExist(err) {
+			t.Errorf("Expected no file named after a key, got: %v", err)
+		}
+
+		// A refresh of an unchanged secret leaves the bundle alone.
+		before, err := os.Stat(bundlePath)
+		if err != nil {
+			t.Fatalf("Couldn't stat the bundle: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to refresh volume: %v", err)
+		}
+		after, err := os.Stat(bundlePath)
+		if err != nil {
+			t.Fatalf("Couldn't stat the bundle: %v", err)
+		}
+		if !os.SameFile(before, after) {
+			t.Errorf("Expected the bundle of an unchanged secret not to be rewritten")
+		}
+	}
+}
+
+func TestPluginPathHelpers(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid52")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	host := plugin.(*secretPlugin).host
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	if expected, actual := builder.GetPath(), DataDir(host, testPodUID, testVolumeName); actual != expected {
+		t.Errorf("Expected the data dir %v, got %v", expected, actual)
+	}
+	if !util.IsReady(MetaDir(host, testPodUID, testVolumeName)) {
+		t.Errorf("Expected the meta dir to hold the readiness file")
+	}
+}
+
+func TestPluginOptionAnnotations(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid53")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.SanitizeKeyNames = true
+	volumeSpec.Secret.ProjectionStrategy = api.SecretProjectionAtomicSymlink
+	prefix := api.SecretVolumeOptionAnnotationPrefix + testVolumeName + "."
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{
+		UID:       testPodUID,
+		Namespace: testNamespace,
+		Annotations: map[string]string{
+			prefix + "wipe-on-teardown":   "true",
+			prefix + "sanitize-key-names": "false",
+			prefix + "copy-files":         "true",
+			prefix + "write-content-hash": "maybe",
+			prefix + "no-such-option":     "true",
+			prefix + "refresh-on-remount": "false",
+			prefix + "file-overlay":       `{"path": "../data-1", "targetPath": "/etc/app.conf"}`,
+			prefix + "delivery-mode":      "Pipe",
+			prefix + "named-pipes":        `["/data-1"]`,
+			api.SecretVolumeOptionAnnotationPrefix + "other_volume.plain-directory": "true",
+		},
+	}}
+	b := plugin.(*secretPlugin).newBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if !b.wipe {
+		t.Errorf("Expected the annotation to enable wipe-on-teardown")
+	}
+	if !b.sanitize {
+		t.Errorf("Expected sanitizeKeyNames of the volume source to take precedence over the annotation")
+	}
+	if b.copyFiles {
+		t.Errorf("Expected projectionStrategy of the volume source to take precedence over the annotation")
+	}
+	if b.contentHash {
+		t.Errorf("Expected a malformed annotation to leave write-content-hash off")
+	}
+	if b.plainDir {
+		t.Errorf("Expected an annotation for another volume to be ignored")
+	}
+	if !b.noRemountRefresh {
+		t.Errorf("Expected the annotation to turn refresh-on-remount off")
+	}
+	if b.overlay != nil {
+		t.Errorf("Expected a file overlay with an invalid path to be ignored, got %+v", b.overlay)
+	}
+	if b.socket {
+		t.Errorf("Expected an unsupported delivery mode to be ignored")
+	}
+	if b.hasPipes() {
+		t.Errorf("Expected named pipes with an invalid path to be ignored, got %v", b.pipes)
+	}
+
+	if err := b.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(b.GetPath(), secret, t)
+
+	// Setting the volume up again leaves it as it is.
+	calls := len(client.Actions())
+	if err := b.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume again: %v", err)
+	}
+	if len(client.Actions()) != calls {
+		t.Errorf("Expected no API calls when setting up the volume again, got %v", client.Actions()[calls:])
+	}
+	doTestSecretDataInVolume(b.GetPath(), secret, t)
+}
+
+func TestPluginNotFoundGracePeriod(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid54")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	testCases := []struct {
+		name      string
+		grace     time.Duration
+		missing   int
+		cancel    bool
+		expectErr error
+		calls     int
+	}{
+		{name: "created in time", grace: time.Minute, missing: 3, calls: 4},
+		{name: "no grace", missing: 1, expectErr: errors.NewNotFound("secrets", testName), calls: 1},
+		{name: "never created", grace: 50 * time.Millisecond, missing: 1000, expectErr: errors.NewNotFound("secrets", testName)},
+		{name: "canceled", grace: time.Minute, missing: 1000, cancel: true, expectErr: volume.ErrSetUpCanceled},
+	}
+	for _, tc := range testCases {
+		cfg := DefaultConfig()
+		cfg.NotFoundGracePeriod = tc.grace
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("%v: unexpected error for a valid config: %v", tc.name, err)
+		}
+		calls := 0
+		stopCh := make(chan struct{})
+		client := &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			calls++
+			if calls == 2 && tc.cancel {
+				close(stopCh)
+			}
+			if calls <= tc.missing {
+				return nil, errors.NewNotFound("secrets", testName)
+			}
+			return &secret, nil
+		}}
+		rootDir, host := newTestHost(t, client)
+		defer os.RemoveAll(rootDir)
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		plugin.(*secretPlugin).notFoundPoll = time.Millisecond
+
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.(volume.CancelableBuilder).SetUpAtWithCancel(builder.GetPath(), stopCh)
+		if tc.expectErr == nil {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tc.name, err)
+			} else {
+				doTestSecretDataInVolume(builder.GetPath(), secret, t)
+			}
+		} else if cause := Cause(err); cause == nil || cause.Error() != tc.expectErr.Error() {
+			t.Errorf("%v: expected error %v, got %v", tc.name, tc.expectErr, err)
+		}
+		if tc.calls != 0 && calls != tc.calls {
+			t.Errorf("%v: expected %v gets of the secret, got %v", tc.name, tc.calls, calls)
+		}
+	}
+}
+
+func TestPluginNormalizeLineEndings(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid55")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		mixed      = "line-1\r\nline-2\nline-3\r\nbare\rcr\r\n"
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data:       map[string][]byte{"config": []byte(mixed)},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "config", Path: "normalized", NormalizeLineEndings: true},
+		{Key: "config", Path: "verbatim"},
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+
+	expected := map[string]string{
+		"normalized": "line-1\nline-2\nline-3\nbare\rcr\n",
+		"verbatim":   mixed,
+	}
+	for name, value := range expected {
+		if actual, err := ioutil.ReadFile(path.Join(volumePath, name)); err != nil || string(actual) != value {
+			t.Errorf("Expected %v to hold %q, got %q: %v", name, value, actual, err)
+		}
+	}
+	if string(secret.Data["config"]) != mixed {
+		t.Errorf("Expected the secret to be left unchanged, got %q", secret.Data["config"])
+	}
+}
+
+// auditTestHost is a VolumeHost with an audit sink.
+type auditTestHost struct {
+	volume.VolumeHost
+	sink AuditSink
+}
+
+func (h *auditTestHost) GetSecretVolumeAuditSink() AuditSink {
+	return h.sink
+}
+
+// chanAuditSink sends the events it records to a channel.
+type chanAuditSink chan AuditEvent
+
+func (s chanAuditSink) Record(event AuditEvent) error {
+	s <- event
+	return nil
+}
+
+func TestPluginAudit(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid56")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+		testPodName    = "test_pod_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+	)
+
+	sink := make(chanAuditSink, 10)
+	rootDir, fakeHost := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	host := &auditTestHost{VolumeHost: fakeHost, sink: sink}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	nextEvent := func() AuditEvent {
+		select {
+		case event := <-sink:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for an audit event")
+		}
+		return AuditEvent{}
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace, Name: testPodName}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	event := nextEvent()
+	if event.Type != AuditEventMount || event.PodUID != testPodUID || event.Namespace != testNamespace || event.PodName != testPodName ||
+		event.Volume != testVolumeName || !reflect.DeepEqual(event.Secrets, []string{testName}) || event.Error != "" || event.Time.IsZero() {
+		t.Errorf("Unexpected mount event: %+v", event)
+	}
+
+	// A refresh is not audited.
+	mounter := &mount.FakeMounter{MountPoints: []mount.MountPoint{{Path: builder.GetPath()}}}
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	event = nextEvent()
+	if event.Type != AuditEventUnmount || event.PodUID != testPodUID || event.Volume != testVolumeName || event.Error != "" {
+		t.Errorf("Unexpected unmount event: %+v", event)
+	}
+
+	// A teardown retried after the volume is gone is audited too.
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume again: %v", err)
+	}
+	event = nextEvent()
+	if event.Type != AuditEventUnmount || event.PodUID != testPodUID || event.Volume != testVolumeName || event.Error != "" {
+		t.Errorf("Unexpected unmount event for a retried teardown: %+v", event)
+	}
+}
+
+func TestPluginStrictKeys(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid57")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data: map[string][]byte{
+				"password": []byte("password"),
+				"tls.crt":  []byte("crt"),
+				"tls.key":  []byte("key"),
+			},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.StrictKeys = true
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "password", Path: "password"},
+		{KeyPattern: "tls.*", Path: "tls"},
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup a volume listing every key: %v", err)
+	}
+
+	secret.Data["username"] = []byte("username")
+	secret.Data["api-token"] = []byte("token")
+	err = builder.SetUp()
+	if err == nil || !strings.Contains(err.Error(), "not listed in items: api-token, username") {
+		t.Errorf("Expected an error listing the unexpected keys, got: %v", err)
+	}
+
+	// Without strictKeys, the other keys are ignored.
+	volumeSpec.Secret.StrictKeys = false
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err != nil {
+		t.Errorf("Unexpected error for a volume ignoring other keys: %v", err)
+	}
+}
+
+func TestPluginTearDownMissingDir(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid58")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDownAt(path.Join(cleaner.GetPath(), "no-such-dir")); err != nil {
+		t.Errorf("Expected no error tearing down a volume that does not exist, got: %v", err)
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	// A retried teardown succeeds too.
+	for i := 0; i < 2; i++ {
+		if err := cleaner.TearDown(); err != nil {
+			t.Errorf("Teardown %v: unexpected error: %v", i, err)
+		}
+	}
+	if _, err := os.Stat(builder.GetPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume to be removed, got: %v", err)
+	}
+}
+
+func TestPluginManifest(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid59")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data: map[string][]byte{
+				"password": []byte("password"),
+				"username": []byte("user"),
+			},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	mode := 0400
+	volumeSpec.Secret.WriteManifest = true
+	volumeSpec.Secret.Items = []api.KeyToPath{
+		{Key: "password", Path: "creds/password", Mode: &mode},
+		{Key: "username", Path: "creds/username"},
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	readManifest := func() []manifestEntry {
+		data, err := ioutil.ReadFile(path.Join(builder.GetPath(), ".manifest.json"))
+		if err != nil {
+			t.Fatalf("Couldn't read the manifest: %v", err)
+		}
+		var manifest struct{ Files []manifestEntry }
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Couldn't decode the manifest %q: %v", data, err)
+		}
+		return manifest.Files
+	}
+
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expected := []manifestEntry{
+		{Path: "creds/password", Secret: testName, Key: "password", Bytes: 8, Mode: "0400"},
+		{Path: "creds/username", Secret: testName, Key: "username", Bytes: 4, Mode: "0444"},
+	}
+	if actual := readManifest(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected the manifest %+v, got %+v", expected, actual)
+	}
+
+	// A refresh brings the manifest up to date.
+	secret.Data["username"] = []byte("administrator")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expected[1].Bytes = 13
+	if actual := readManifest(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected the refreshed manifest %+v, got %+v", expected, actual)
+	}
+
+	volumeSpec.Secret.Items = append(volumeSpec.Secret.Items, api.KeyToPath{Key: "username", Path: ".manifest.json"})
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(volumeSpec), pod); err == nil {
+		t.Errorf("Expected an error for a key projected to the manifest")
+	}
+}
+
+func TestPluginEnvFile(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid60")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Data: map[string][]byte{
+				"DB_USER":     []byte("admin"),
+				"DB_PASSWORD": []byte("p\"a$s`s\\w\no\trd"),
+				"tls.crt":     []byte("cert"),
+			},
+		}
+		client    = testclient.NewSimpleFake(&secret)
+		_, plugin = newTestPlugin(t, client)
+	)
+
+	volumeSpec.Secret.EnvFile = &api.EnvFileProjection{Path: "config/.env", Keys: []string{"DB_USER", "DB_PASSWORD"}}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expected := "DB_PASSWORD=\"p\\\"a\\$s\\`s\\\\w\\no\trd\"\nDB_USER=\"admin\"\n"
+	if actual, err := ioutil.ReadFile(path.Join(volumePath, "config/.env")); err != nil || string(actual) != expected {
+		t.Errorf("Expected the env file to hold %q, got %q: %v", expected, actual, err)
+	}
+	// The keys are still projected to files of their own.
+	for key, value := range secret.Data {
+		if actual, err := ioutil.ReadFile(path.Join(volumePath, key)); err != nil || string(actual) != string(value) {
+			t.Errorf("Expected file %v to hold %q, got %q: %v", key, value, actual, err)
+		}
+	}
+
+	errorCases := map[string]struct {
+		data map[string][]byte
+		keys []string
+	}{
+		"missing key":       {map[string][]byte{"A": []byte("a")}, []string{"A", "B"}},
+		"not an identifier": {map[string][]byte{"A": []byte("a"), "tls.crt": []byte("cert")}, nil},
+		"not UTF-8":         {map[string][]byte{"A": {0xff}}, nil},
+		"carriage return":   {map[string][]byte{"A": []byte("a\r\n")}, nil},
+		"NUL":               {map[string][]byte{"A": []byte("a\x00")}, nil},
+	}
+	for k, v := range errorCases {
+		if data, err := envFileData(&api.Secret{Data: v.data}, v.keys); err == nil {
+			t.Errorf("%s: expected an error, got %q", k, data)
+		}
+	}
+	if data, err := envFileData(&api.Secret{Data: map[string][]byte{"B": []byte("b"), "A": []byte("")}}, nil); err != nil || string(data) != "A=\"\"\nB=\"b\"\n" {
+		t.Errorf("Expected every key of the secret in the env file, got %q: %v", data, err)
+	}
+}
+
+// podClientTestHost is a VolumeHost with a client for each service account.
+type podClientTestHost struct {
+	volume.VolumeHost
+	clients map[string]client.Interface
+}
+
+func (h *podClientTestHost) GetKubeClientForPod(pod *api.Pod) (client.Interface, error) {
+	if pod.Spec.ServiceAccountName == "broken" {
+		return nil, fmt.Errorf("no token for service account %q", pod.Spec.ServiceAccountName)
+	}
+	return h.clients[pod.Spec.ServiceAccountName], nil
+}
+
+func TestPluginPodKubeClient(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid62")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		nodeClient = testclient.NewSimpleFake(&secret)
+		podClient  = testclient.NewSimpleFake(&secret)
+	)
+
+	rootDir, fakeHost := newTestHost(t, nodeClient)
+	defer os.RemoveAll(rootDir)
+	host := &podClientTestHost{VolumeHost: fakeHost, clients: map[string]client.Interface{"app": podClient}}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	testCases := []struct {
+		serviceAccount string
+		expected       *testclient.Fake
+	}{
+		{"app", podClient},
+		// Without a client of its own, a pod's secrets are read with the
+		// node's client.
+		{"default", nodeClient},
+		{"broken", nodeClient},
+	}
+	for _, tc := range testCases {
+		nodeClient.ClearActions()
+		podClient.ClearActions()
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace},
+			Spec:       api.PodSpec{ServiceAccountName: tc.serviceAccount},
+		}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.serviceAccount, err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("%v: failed to setup volume: %v", tc.serviceAccount, err)
+		}
+		if len(tc.expected.Actions()) != 1 || len(nodeClient.Actions())+len(podClient.Actions()) != 1 {
+			t.Errorf("%v: expected the secret to be read once with the expected client, got node %v and pod %v",
+				tc.serviceAccount, nodeClient.Actions(), podClient.Actions())
+		}
+	}
+
+	// A selector is resolved with the pod's client too.
+	nodeClient.ClearActions()
+	podClient.ClearActions()
+	selectorSpec := volumeSpec
+	selectorSpec.Secret = &api.SecretVolumeSource{SecretSelector: map[string]string{"app": "db"}}
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace},
+		Spec:       api.PodSpec{ServiceAccountName: "app"},
+	}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(selectorSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	builder.SetUp()
+	if len(nodeClient.Actions()) != 0 || len(podClient.Actions()) == 0 || podClient.Actions()[0].GetVerb() != "list" {
+		t.Errorf("Expected the selector to be resolved with the pod's client, got node %v and pod %v", nodeClient.Actions(), podClient.Actions())
+	}
+}
+
+func TestPluginTypeCheck(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid63")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+	)
+
+	testCases := []struct {
+		name       string
+		secretType api.SecretType
+		data       map[string][]byte
+		items      []api.KeyToPath
+		check      api.SecretTypeCheck
+		expectErr  string
+		expectWarn bool
+	}{
+		{name: "complete tls", secretType: secretTypeTLS, data: map[string][]byte{"tls.crt": []byte("c"), "tls.key": []byte("k")}, check: api.SecretTypeCheckError},
+		{name: "missing key", secretType: secretTypeTLS, data: map[string][]byte{"tls.crt": []byte("c")}, check: api.SecretTypeCheckError, expectErr: `has no key "tls.key"`},
+		{name: "unprojected key", secretType: secretTypeTLS, data: map[string][]byte{"tls.crt": []byte("c"), "tls.key": []byte("k")},
+			items: []api.KeyToPath{{Key: "tls.crt", Path: "server.crt"}}, check: api.SecretTypeCheckError, expectErr: `does not project key "tls.key"`},
+		{name: "moved key", secretType: api.SecretTypeDockercfg, data: map[string][]byte{".dockercfg": []byte("{}")},
+			items: []api.KeyToPath{{Key: ".dockercfg", Path: "config.json"}}, check: api.SecretTypeCheckError},
+		{name: "warn", secretType: api.SecretTypeDockercfg, data: map[string][]byte{"config": []byte("{}")}, check: api.SecretTypeCheckWarn, expectWarn: true},
+		{name: "unchecked", secretType: api.SecretTypeDockercfg, data: map[string][]byte{"config": []byte("{}")}},
+		{name: "unknown type", secretType: "example.com/custom", data: map[string][]byte{"foo": []byte("bar")}, check: api.SecretTypeCheckError},
+	}
+	for _, tc := range testCases {
+		secret := api.Secret{
+			ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName},
+			Type:       tc.secretType,
+			Data:       tc.data,
+		}
+		client := testclient.NewSimpleFake(&secret)
+		recorder := &eventCounter{}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		defer os.RemoveAll(rootDir)
+		host := volume.NewFakeVolumeHostWithRecorder(rootDir, client, empty_dir.ProbeVolumePlugins(), recorder)
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+
+		volumeSpec := volumeSpec(testVolumeName, testName)
+		volumeSpec.Secret.Items = tc.items
+		volumeSpec.Secret.TypeCheck = tc.check
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.expectErr == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if tc.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tc.expectErr)) {
+			t.Errorf("%v: expected an error containing %q, got: %v", tc.name, tc.expectErr, err)
+		}
+		if warned := len(recorder.messages) != 0; warned != tc.expectWarn {
+			t.Errorf("%v: expected warning %v, got events %v", tc.name, tc.expectWarn, recorder.messages)
+		}
+	}
+}
+
+func TestPluginReconcile(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid64")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		_, plugin  = newTestPlugin(t, client)
+	)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &mount.FakeMounter{}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	reconciler, ok := builder.(volume.ReconcilingBuilder)
+	if !ok {
+		t.Fatalf("Expected the builder to reconcile")
+	}
+
+	// A volume that is not set up yet is set up in full.
+	if err := reconciler.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile a new volume: %v", err)
+	}
+	volumePath := builder.GetPath()
+	doTestSecretDataInVolume(volumePath, secret, t)
+	if len(mounter.Log) == 0 || mounter.Log[0].FSType != "tmpfs" {
+		t.Errorf("Expected the wrapped volume to be mounted, got %v", mounter.Log)
+	}
+	if !builder.(*secretVolumeBuilder).isReady(volumePath) {
+		t.Errorf("Expected the volume to be ready")
+	}
+
+	// A volume that is set up only has its files updated.
+	mounter.ResetLog()
+	secret.Data["data-1"] = []byte("updated")
+	secret.ResourceVersion = "2"
+	if err := reconciler.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumePath, secret, t)
+	doTestNoNewMounts(mounter, t)
+}
+
+func TestPluginLimitTmpfsSize(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid65")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pageSize   = int64(os.Getpagesize())
+	)
+
+	cfg := DefaultConfig()
+	cfg.LimitTmpfsSize = true
+	cfg.TmpfsSizeMargin = 100
+	plugins, err := ProbeVolumePluginsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error for a valid config: %v", err)
+	}
+	rootDir, host := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(plugins, host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &optionsMounter{FakeMounter: &mount.FakeMounter{}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	// Each of the three small keys takes a page.
+	expected := fmt.Sprintf("size=%d", 3*pageSize+100)
+	if !mounter.mountedWith(expected) {
+		t.Errorf("Expected the tmpfs to be remounted with %v, got %v", expected, mounter.options)
+	}
+
+	// The limit follows the secret as it grows: the tmpfs holds the old
+	// files along with the new ones while they are written, and then only
+	// the new ones.
+	mounter.options = nil
+	secret.Data["data-4"] = make([]byte, pageSize+1)
+	secret.ResourceVersion = "2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	for _, expected := range []string{fmt.Sprintf("size=%d", 8*pageSize+100), fmt.Sprintf("size=%d", 5*pageSize+100)} {
+		if !mounter.mountedWith(expected) {
+			t.Errorf("Expected the tmpfs to be remounted with %v, got %v", expected, mounter.options)
+		}
+	}
+
+	// And as it shrinks, it is only shrunk once the old files are gone.
+	mounter.options = nil
+	delete(secret.Data, "data-4")
+	secret.ResourceVersion = "3"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expected = fmt.Sprintf("size=%d", 3*pageSize+100)
+	if last := mounter.options[len(mounter.options)-1]; len(mounter.options) != 2 || !reflect.DeepEqual(last, []string{"remount", "ro", expected}) {
+		t.Errorf("Expected the tmpfs to be grown and then remounted read-only with %v, got %v", expected, mounter.options)
+	}
+	if expected := fmt.Sprintf("size=%d", 8*pageSize+100); !mounter.mountedWith(expected) {
+		t.Errorf("Expected the tmpfs to be remounted with %v, got %v", expected, mounter.options)
+	}
+}
+
+// optionsMounter is a FakeMounter that remembers the options of each mount.
+type optionsMounter struct {
+	*mount.FakeMounter
+	options [][]string
+}
+
+func (m *optionsMounter) Mount(source string, target string, fstype string, options []string) error {
+	m.options = append(m.options, options)
+	return m.FakeMounter.Mount(source, target, fstype, options)
+}
+
+// mountedWith returns true if a mount was given option.
+func (m *optionsMounter) mountedWith(option string) bool {
+	for _, options := range m.options {
+		for _, o := range options {
+			if o == option {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestPluginProjected(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid66")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret  = secret(testNamespace, testName)
+		client  = testclient.NewSimpleFake(&secret)
+		pod     = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter = &mount.FakeMounter{}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	projection := api.VolumeProjection{Secret: &api.SecretVolumeSource{SecretName: testName}}
+	spec := &volume.Spec{
+		Name:         testVolumeName,
+		VolumeSource: api.VolumeSource{Projected: &api.ProjectedVolumeSource{Sources: []api.VolumeProjection{projection}}},
+	}
+	if err := plugin.(*secretPlugin).ValidateSpec(spec, pod); err != nil {
+		t.Errorf("Expected a projected secret to validate: %v", err)
+	}
+	builder, err := plugin.NewBuilder(spec, pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(builder.GetPath(), secret, t)
+
+	// Only a single secret can be projected so far.
+	spec.VolumeSource.Projected.Sources = []api.VolumeProjection{projection, projection}
+	if err := plugin.(*secretPlugin).ValidateSpec(spec, pod); err == nil {
+		t.Errorf("Expected an error validating a volume projecting two secrets")
+	}
+	if _, err := plugin.NewBuilder(spec, pod, volume.VolumeOptions{}, mounter); err == nil {
+		t.Errorf("Expected an error making a Builder for a volume projecting two secrets")
+	}
+}
+
+func TestPluginLastChanges(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid67")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	newBuilder := func() *secretVolumeBuilder {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder.(*secretVolumeBuilder)
+	}
+	builder := newBuilder()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if e, a := (PayloadChanges{Added: 3}), builder.LastChanges(); e != a {
+		t.Errorf("Expected changes %+v for the first setup, got %+v", e, a)
+	}
+
+	if err := builder.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile volume: %v", err)
+	}
+	if builder.LastChanges().Changed() {
+		t.Errorf("Expected no changes for an unchanged secret, got %+v", builder.LastChanges())
+	}
+
+	secret.Data["data-1"] = []byte("updated")
+	delete(secret.Data, "data-2")
+	secret.Data["data-4"] = []byte("value-4")
+	secret.ResourceVersion = "2"
+	if err := builder.Reconcile(); err != nil {
+		t.Fatalf("Failed to reconcile volume: %v", err)
+	}
+	if e, a := (PayloadChanges{Added: 1, Updated: 1, Removed: 1}), builder.LastChanges(); e != a {
+		t.Errorf("Expected changes %+v for an updated secret, got %+v", e, a)
+	}
+
+	// Changing the mode of the files does not change their content.
+	mode := 0400
+	volumeSpec.VolumeSource.Secret.DefaultMode = &mode
+	builder = newBuilder()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if e, a := (PayloadChanges{ModeUpdated: 3}), builder.LastChanges(); e != a {
+		t.Errorf("Expected changes %+v for a new mode, got %+v", e, a)
+	}
+}
+
+func TestPluginImmutableSecret(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid68")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		immutable  = true
+		mounter    = &mount.FakeMounter{}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	setUp := func() *secretVolumeBuilder {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+		return builder.(*secretVolumeBuilder)
+	}
+
+	// A mutable secret is got again by every refresh.
+	setUp()
+	client.ClearActions()
+	setUp()
+	if len(client.Actions()) != 1 {
+		t.Errorf("Expected a mutable secret to be got again, got %v", client.Actions())
+	}
+
+	secret.Immutable = &immutable
+	builder := setUp()
+	expected := builder.GetMetrics
+	client.ClearActions()
+	builder = setUp()
+	if len(client.Actions()) != 0 {
+		t.Errorf("Expected an immutable secret not to be got again, got %v", client.Actions())
+	}
+	doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	e, _ := expected()
+	a, _ := builder.GetMetrics()
+	if a.Files != e.Files || a.Bytes != e.Bytes {
+		t.Errorf("Expected metrics %+v for a volume that was not refreshed, got %+v", e, a)
+	}
+	if a.Files != int64(len(secret.Data)) {
+		t.Errorf("Expected %v files, got %+v", len(secret.Data), a)
+	}
+}
+
+func TestPluginNamedPipe(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid70")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{
+			UID:         testPodUID,
+			Namespace:   testNamespace,
+			Annotations: map[string]string{api.SecretVolumeOptionAnnotationPrefix + testVolumeName + ".named-pipes": `["pipes/data-2"]`},
+		}}
+		mounter = &mount.FakeMounter{}
+	)
+
+	oldInterval := pipePollInterval
+	pipePollInterval = 10 * time.Millisecond
+	defer func() { pipePollInterval = oldInterval }()
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	volumeSpec.VolumeSource.Secret.Items = []api.KeyToPath{
+		{Key: "data-1", Path: "data-1"},
+		{Key: "data-2", Path: "pipes/data-2"},
+	}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	// Nothing reads the pipe yet, which must not hold up the setup.
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	pipePath := path.Join(builder.GetPath(), "pipes/data-2")
+	if info, err := os.Stat(pipePath); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("Expected a named pipe at %v, got %v, %v", pipePath, info, err)
+	}
+
+	readPipe := func() string {
+		result := make(chan string, 1)
+		go func() {
+			data, err := ioutil.ReadFile(pipePath)
+			if err != nil {
+				t.Errorf("Couldn't read named pipe: %v", err)
+			}
+			result <- string(data)
+		}()
+		select {
+		case data := <-result:
+			return data
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out reading named pipe")
+			return ""
+		}
+	}
+	if e, a := "value-2", readPipe(); e != a {
+		t.Errorf("Expected %q from the named pipe, got %q", e, a)
+	}
+	if e, a := "value-2", readPipe(); e != a {
+		t.Errorf("Expected %q from the named pipe again, got %q", e, a)
+	}
+
+	secret.Data["data-2"] = []byte("updated")
+	secret.ResourceVersion = "2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if e, a := "updated", readPipe(); e != a {
+		t.Errorf("Expected %q from the named pipe after a refresh, got %q", e, a)
+	}
+
+	// A value larger than the buffer of the pipe is written in full.
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	secret.Data["data-2"] = large
+	secret.ResourceVersion = "3"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if a := readPipe(); a != string(large) {
+		t.Errorf("Expected %v bytes from the named pipe, got %v", len(large), len(a))
+	}
+	secret.Data["data-2"] = []byte("updated")
+	secret.ResourceVersion = "4"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	metrics, _ := builder.(*secretVolumeBuilder).GetMetrics()
+	if metrics.Files != 2 || metrics.Bytes != int64(len(secret.Data["data-1"])) {
+		t.Errorf("Expected the named pipe to take up no bytes, got %+v", metrics)
+	}
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	if _, err := os.Lstat(pipePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the named pipe to be removed, got %v", err)
+	}
+	if feeders := plugin.(*secretPlugin).pipes; len(feeders) != 0 {
+		t.Errorf("Expected no named pipes to be fed after teardown, got %v", feeders)
+	}
+}
+
+// failingChconRunner fails to set any SELinux context.
+type failingChconRunner struct{}
+
+func (failingChconRunner) SetContext(path, context string) error {
+	return fmt.Errorf("injected failure setting context of %v", path)
+}
+
+func TestPluginPartialFailureCleanup(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid71")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+	)
+	secret.Data["data-4"] = []byte("value-4")
+	secret.Data["data-5"] = []byte("value-5")
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	defer func(orig func(string, int, os.FileMode) (*os.File, error)) { openFile = orig }(openFile)
+	failNth := func(n int) {
+		calls := 0
+		openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			calls++
+			if calls == n {
+				return nil, fmt.Errorf("injected failure writing %v", name)
+			}
+			return os.OpenFile(name, flag, perm)
+		}
+	}
+	expectEmpty := func(builder volume.Builder, when string) {
+		entries, err := ioutil.ReadDir(builder.GetPath())
+		if err != nil {
+			t.Fatalf("Couldn't list volume %s: %v", when, err)
+		}
+		if len(entries) != 0 {
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			t.Errorf("Expected an empty volume %s, got %v", when, names)
+		}
+		if util.IsReady(builder.(*secretVolumeBuilder).getMetaDir()) {
+			t.Errorf("Expected the volume not to be ready %s", when)
+		}
+	}
+
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	failNth(3)
+	if err := builder.SetUp(); err == nil {
+		t.Fatalf("Expected setup to fail writing the 3rd file")
+	}
+	expectEmpty(builder, "after failing to write a file")
+
+	// A failure after the files are published removes them as well.
+	openFile = os.OpenFile
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{RootContext: "user:role:type:range"}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	builder.(*secretVolumeBuilder).chconRunner = failingChconRunner{}
+	if err := builder.SetUp(); err == nil {
+		t.Fatalf("Expected setup to fail setting the SELinux context")
+	}
+	expectEmpty(builder, "after failing to set its SELinux context")
+
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(builder.GetPath(), secret, t)
+
+	// A failed refresh leaves the files of a volume that is set up alone.
+	secret.Data["data-1"] = []byte("updated")
+	secret.ResourceVersion = "2"
+	failNth(1)
+	if err := builder.SetUp(); err == nil {
+		t.Fatalf("Expected refresh to fail writing a file")
+	}
+	openFile = os.OpenFile
+	if data, err := ioutil.ReadFile(path.Join(builder.GetPath(), "data-2")); err != nil || string(data) != "value-2" {
+		t.Errorf("Expected the files of the volume to be kept after a failed refresh, got %q, %v", data, err)
+	}
+}
+
+func TestPluginCopyFiles(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid72")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	volumeSpec.VolumeSource.Secret.ProjectionStrategy = api.SecretProjectionCopy
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	expectPlainFiles := func() {
+		entries, err := ioutil.ReadDir(builder.GetPath())
+		if err != nil {
+			t.Fatalf("Couldn't list volume: %v", err)
+		}
+		if len(entries) != len(secret.Data) {
+			t.Errorf("Expected %v files in the volume, got %v", len(secret.Data), len(entries))
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() {
+				t.Errorf("Expected %v to be a plain file, got mode %v", entry.Name(), entry.Mode())
+			}
+		}
+		doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expectPlainFiles()
+
+	secret.Data["data-1"] = []byte("updated")
+	delete(secret.Data, "data-2")
+	secret.ResourceVersion = "2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expectPlainFiles()
+	if e, a := (PayloadChanges{Updated: 1, Removed: 1}), builder.(*secretVolumeBuilder).LastChanges(); e != a {
+		t.Errorf("Expected changes %+v, got %+v", e, a)
+	}
+}
+
+func TestPluginExecBit(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid73")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		pod    = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mode   = 0755
+	)
+
+	cases := []struct {
+		name           string
+		warn, reject   bool
+		expectErr      bool
+		expectedEvents int
+	}{
+		{name: "off"},
+		{name: "warn", warn: true, expectedEvents: 1},
+		{name: "reject", reject: true, expectErr: true},
+	}
+	for _, tc := range cases {
+		cfg := DefaultConfig()
+		cfg.WarnOnExecBit = tc.warn
+		cfg.RejectExecBit = tc.reject
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		defer os.RemoveAll(rootDir)
+		recorder := &eventCounter{}
+		host := volume.NewFakeVolumeHostWithRecorder(rootDir, testclient.NewSimpleFake(&secret), empty_dir.ProbeVolumePlugins(), recorder)
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+
+		volumeSpec := volumeSpec(testVolumeName, testName)
+		volumeSpec.VolumeSource.Secret.Items = []api.KeyToPath{
+			{Key: "data-1", Path: "run.sh", Mode: &mode},
+			{Key: "data-2", Path: "data-2"},
+		}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: Failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.expectErr {
+			if err == nil || !strings.Contains(err.Error(), `"run.sh" (mode 0755)`) {
+				t.Errorf("%v: Expected an error naming the executable file, got %v", tc.name, err)
+			}
+			if _, err := os.Stat(path.Join(builder.GetPath(), "run.sh")); !os.IsNotExist(err) {
+				t.Errorf("%v: Expected no files to be written, got %v", tc.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%v: Failed to setup volume: %v", tc.name, err)
+		}
+		if len(recorder.messages) != tc.expectedEvents {
+			t.Errorf("%v: Expected %v events, got %v", tc.name, tc.expectedEvents, recorder.messages)
+		} else if tc.expectedEvents > 0 && !strings.Contains(recorder.messages[0], "run.sh") {
+			t.Errorf("%v: Expected the event to name the executable file, got %q", tc.name, recorder.messages[0])
+		}
+	}
+}
+
+// mapSecretGetter is a SecretGetter serving the secrets it holds, keyed by
+// namespace/name.
+type mapSecretGetter struct {
+	secrets map[string]*api.Secret
+	gets    int
+}
+
+func (g *mapSecretGetter) GetSecret(namespace, name string) (*api.Secret, error) {
+	g.gets++
+	secret, found := g.secrets[namespace+"/"+name]
+	if !found {
+		return nil, errors.NewNotFound("secrets", name)
+	}
+	return secret, nil
+}
+
+type secretGetterTestHost struct {
+	volume.VolumeHost
+	getter SecretGetter
+}
+
+func (h *secretGetterTestHost) GetSecretGetter() SecretGetter {
+	return h.getter
+}
+
+func TestPluginSecretGetter(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid74")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		getter = &mapSecretGetter{secrets: map[string]*api.Secret{testNamespace + "/" + testName: &secret}}
+		pod    = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+
+	// The host has no client for the API server; the getter is enough.
+	rootDir, fakeHost := newTestHost(t, nil)
+	defer os.RemoveAll(rootDir)
+	host := &secretGetterTestHost{VolumeHost: fakeHost, getter: getter}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec(testVolumeName, testName)), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	if getter.gets != 1 {
+		t.Errorf("Expected the secret to be got once from the getter, got %v", getter.gets)
+	}
+
+	// A missing secret is reported as it is by the API server.
+	optional := true
+	missingSpec := volumeSpec("missing_volume", "missing")
+	missingSpec.VolumeSource.Secret.Optional = &optional
+	builder, err = plugin.NewBuilder(volume.NewSpecFromVolume(missingSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Errorf("Expected an optional volume of a missing secret to be set up, got %v", err)
+	}
+	missingSpec.VolumeSource.Secret.Optional = nil
+	if err := plugin.(*secretPlugin).ValidateSpec(volume.NewSpecFromVolume(missingSpec), pod); !errors.IsNotFound(Cause(err)) {
+		t.Errorf("Expected a not found error for a missing secret, got %v", err)
+	}
+}
+
+// listingSecretGetter is a mapSecretGetter that can also list its secrets.
+type listingSecretGetter struct {
+	*mapSecretGetter
+}
+
+func (g listingSecretGetter) ListSecrets(namespace string, selector labels.Selector) ([]api.Secret, error) {
+	secrets := []api.Secret{}
+	for _, secret := range g.secrets {
+		if secret.Namespace == namespace && selector.Matches(labels.Set(secret.Labels)) {
+			secrets = append(secrets, *secret)
+		}
+	}
+	return secrets, nil
+}
+
+func TestPluginSecretGetterSelector(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid96")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		getter = &mapSecretGetter{secrets: map[string]*api.Secret{testNamespace + "/" + testName: &secret}}
+		pod    = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+	secret.Labels = map[string]string{"app": "db"}
+	spec := volumeSpec(testVolumeName, "")
+	spec.Secret.SecretSelector = map[string]string{"app": "db"}
+
+	testCases := []struct {
+		name      string
+		getter    SecretGetter
+		expectErr string
+	}{
+		{name: "listing getter", getter: listingSecretGetter{getter}},
+		{name: "getter that cannot list", getter: getter, expectErr: "cannot list secrets"},
+	}
+	for _, tc := range testCases {
+		// The host has no client for the API server; the selector is
+		// resolved through the getter alone.
+		rootDir, fakeHost := newTestHost(t, nil)
+		defer os.RemoveAll(rootDir)
+		host := &secretGetterTestHost{VolumeHost: fakeHost, getter: tc.getter}
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if tc.expectErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+				t.Errorf("%v: expected an error containing %q, got %v", tc.name, tc.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: failed to setup volume: %v", tc.name, err)
+		}
+		doTestSecretDataInVolume(builder.GetPath(), secret, t)
+	}
+}
+
+func TestPluginTearDownCancelsSetUp(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid75")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec  = volumeSpec(testVolumeName, testName)
+		unavailable = errors.NewInternalError(fmt.Errorf("unavailable"))
+		client      = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return nil, unavailable
+		}}
+		mounter = &mount.FakeMounter{}
+	)
+
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumMemory, dirMode: defaultDirMode, retries: 10, backoff: time.Hour}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+	plug := plugin.(*secretPlugin)
+	key := volumeKey(testPodUID, testVolumeName)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+
+	// The setup waits to retry getting the secret; the teardown cancels it
+	// rather than waiting an hour.
+	done := make(chan error)
+	go func() {
+		done <- builder.SetUp()
+	}()
+	for cancelable := false; !cancelable; time.Sleep(time.Millisecond) {
+		plug.locksLock.Lock()
+		cancelable = plug.locks[key] != nil && plug.locks[key].cancel != nil
+		plug.locksLock.Unlock()
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != volume.ErrSetUpCanceled {
+			t.Errorf("Expected %v, got: %v", volume.ErrSetUpCanceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Setup was not canceled by the teardown")
+	}
+	if _, err := os.Lstat(builder.GetPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume to be torn down, got %v", err)
+	}
+
+	// A teardown waits for whoever holds the lock of the volume.
+	unlock := plug.lockVolume(key)
+	go func() {
+		done <- cleaner.TearDown()
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected teardown to wait for the volume lock, returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Errorf("Failed to tear down volume: %v", err)
+	}
+	if len(plug.locks) != 0 {
+		t.Errorf("Expected unused volume locks to be dropped, got %v", plug.locks)
+	}
+}
+
+func TestPluginIsHealthy(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid76")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	sv := builder.(*secretVolumeBuilder).secretVolume
+	expectHealth := func(when string, deep, expected bool) {
+		healthy, err := sv.CheckHealth(deep)
+		if err != nil {
+			t.Fatalf("Couldn't check health of volume %s: %v", when, err)
+		}
+		if healthy != expected {
+			t.Errorf("Expected healthy %v %s with deep %v, got %v", expected, when, deep, healthy)
+		}
+	}
+
+	expectHealth("before setup", false, false)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if healthy, err := sv.IsHealthy(); !healthy || err != nil {
+		t.Errorf("Expected the volume to be healthy after setup, got %v, %v", healthy, err)
+	}
+	expectHealth("after setup", true, true)
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if healthy, err := cleaner.(*secretVolumeCleaner).IsHealthy(); !healthy || err != nil {
+		t.Errorf("Expected the volume to be healthy to its cleaner, got %v, %v", healthy, err)
+	}
+
+	// Content of the same size is only caught by a deep check.
+	p := path.Join(builder.GetPath(), "data-1")
+	if err := os.Chmod(p, 0644); err != nil {
+		t.Fatalf("Couldn't make %v writable: %v", p, err)
+	}
+	if err := ioutil.WriteFile(p, []byte("VALUE-1"), 0644); err != nil {
+		t.Fatalf("Couldn't corrupt %v: %v", p, err)
+	}
+	expectHealth("with corrupt content", false, true)
+	expectHealth("with corrupt content", true, false)
+
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expectHealth("after a refresh", true, true)
+
+	if err := os.Remove(path.Join(builder.GetPath(), "data-2")); err != nil {
+		t.Fatalf("Couldn't remove a file: %v", err)
+	}
+	expectHealth("with a missing file", false, false)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume again: %v", err)
+	}
+	expectHealth("after setting it up again", false, true)
+
+	if err := mounter.Unmount(builder.GetPath()); err != nil {
+		t.Fatalf("Couldn't unmount volume: %v", err)
+	}
+	expectHealth("once unmounted", false, false)
+}
+
+func TestPluginHostDirectory(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid77")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret  = secret(testNamespace, testName)
+		pod     = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter = &mount.FakeMounter{}
+	)
+
+	root, err := ioutil.TempDir("/tmp", "secret_volume_test_host.")
+	if err != nil {
+		t.Fatalf("can't make a temp host root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	hostDir := path.Join(root, "secrets")
+	otherDir := path.Join(root, "other")
+	for _, dir := range []string{hostDir, otherDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Couldn't make the host directory: %v", err)
+		}
+	}
+	operatorFile := path.Join(hostDir, "operator")
+	if err := ioutil.WriteFile(operatorFile, []byte("operator"), 0644); err != nil {
+		t.Fatalf("Couldn't write a file of the operator: %v", err)
+	}
+	linked := path.Join(root, "linked")
+	if err := os.Symlink(hostDir, linked); err != nil {
+		t.Fatalf("Couldn't make a symlink to the host directory: %v", err)
+	}
+
+	newPlugin := func(allow bool) (string, volume.VolumePlugin) {
+		cfg := DefaultConfig()
+		cfg.AllowHostDirectories = allow
+		cfg.HostDirectories = map[string]string{
+			"secrets": hostDir,
+			"linked":  linked,
+			"missing": path.Join(root, "missing"),
+			"file":    operatorFile,
+		}
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		host := volume.NewFakeVolumeHost(rootDir, testclient.NewSimpleFake(&secret), empty_dir.ProbeVolumePlugins())
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		return rootDir, plugin
+	}
+	newBuilder := func(plugin volume.VolumePlugin, volName, name string) volume.Builder {
+		volumeSpec := volumeSpec(volName, testName)
+		volumeSpec.VolumeSource.Secret.HostDirectory = name
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder
+	}
+	tearDown := func(plugin volume.VolumePlugin, volName string) {
+		cleaner, err := plugin.NewCleaner(volName, testPodUID, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Cleaner: %v", err)
+		}
+		if err := cleaner.TearDown(); err != nil {
+			t.Fatalf("Failed to teardown volume: %v", err)
+		}
+	}
+
+	rootDir, plugin := newPlugin(false)
+	defer os.RemoveAll(rootDir)
+	if err := newBuilder(plugin, testVolumeName, "secrets").SetUp(); err == nil {
+		t.Errorf("Expected an error for a host directory where none are allowed")
+	}
+
+	rootDir, plugin = newPlugin(true)
+	defer os.RemoveAll(rootDir)
+	for _, name := range []string{"unknown", "missing", "file"} {
+		if err := newBuilder(plugin, testVolumeName, name).SetUp(); err == nil {
+			t.Errorf("Expected an error for host directory %v", name)
+		}
+	}
+
+	volumeDir := path.Join(hostDir, string(testPodUID)+"_"+testVolumeName)
+	builder := newBuilder(plugin, testVolumeName, "secrets")
+	if builder.GetPath() != volumeDir {
+		t.Errorf("Expected path %v, got %v", volumeDir, builder.GetPath())
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	doTestSecretDataInVolume(volumeDir, secret, t)
+	placeholder := DataDir(plugin.(*secretPlugin).host, testPodUID, testVolumeName)
+	if _, err := os.Stat(placeholder); err != nil {
+		t.Errorf("Expected the volume's own directory to exist: %v", err)
+	}
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if cleaner.GetPath() != volumeDir {
+		t.Errorf("Expected the cleaner's path %v, got %v", volumeDir, cleaner.GetPath())
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to teardown volume: %v", err)
+	}
+	entries, err := ioutil.ReadDir(hostDir)
+	if err != nil {
+		t.Fatalf("Expected the host directory to be left in place: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "operator" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only the file of the operator to be left, got %v", names)
+	}
+	if _, err := os.Stat(placeholder); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume's own directory to be removed, got %v", err)
+	}
+
+	// A host directory reached through a symlink is written through the
+	// path it resolved to, and a setup fails once it resolves elsewhere.
+	linkedDir := path.Join(hostDir, string(testPodUID)+"_linked_volume")
+	builder = newBuilder(plugin, "linked_volume", "linked")
+	if builder.GetPath() != linkedDir {
+		t.Errorf("Expected path %v, got %v", linkedDir, builder.GetPath())
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if err := os.Remove(linked); err != nil {
+		t.Fatalf("Couldn't remove the symlink: %v", err)
+	}
+	if err := os.Symlink(otherDir, linked); err != nil {
+		t.Fatalf("Couldn't retarget the symlink: %v", err)
+	}
+	builder = newBuilder(plugin, "linked_volume", "linked")
+	if builder.GetPath() != linkedDir {
+		t.Errorf("Expected the recorded path %v, got %v", linkedDir, builder.GetPath())
+	}
+	if err := builder.SetUp(); err == nil {
+		t.Errorf("Expected an error for a host directory that resolves elsewhere")
+	}
+	doTestSecretDataInVolume(linkedDir, secret, t)
+	tearDown(plugin, "linked_volume")
+	if _, err := os.Stat(linkedDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume's directory to be removed, got %v", err)
+	}
+	if entries, err := ioutil.ReadDir(otherDir); err != nil || len(entries) != 0 {
+		t.Errorf("Expected nothing to be written where the symlink now points, got %v, %v", len(entries), err)
+	}
+}
+
+func TestPluginHostDirectoryShared(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid95")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret  = secret(testNamespace, testName)
+		pod     = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter = &mount.FakeMounter{}
+	)
+
+	root, err := ioutil.TempDir("/tmp", "secret_volume_test_host.")
+	if err != nil {
+		t.Fatalf("can't make a temp host root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	taken := path.Join(root, "taken")
+	shared := path.Join(root, "shared")
+	for _, dir := range []string{taken, shared} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Couldn't make the host directory: %v", err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.AllowHostDirectories = true
+	cfg.HostDirectories = map[string]string{"taken": taken, "shared": shared}
+	plugins, err := ProbeVolumePluginsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error for a valid config: %v", err)
+	}
+	rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp rootdir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	host := volume.NewFakeVolumeHost(rootDir, testclient.NewSimpleFake(&secret), empty_dir.ProbeVolumePlugins())
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(plugins, host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	newBuilder := func(volName, name string) volume.Builder {
+		volumeSpec := volumeSpec(volName, testName)
+		volumeSpec.VolumeSource.Secret.HostDirectory = name
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder
+	}
+	tearDown := func(volName string) {
+		cleaner, err := plugin.NewCleaner(volName, testPodUID, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Cleaner: %v", err)
+		}
+		if err := cleaner.TearDown(); err != nil {
+			t.Fatalf("Failed to teardown volume: %v", err)
+		}
+	}
+	entryNames := func(dir string) []string {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Couldn't list %v: %v", dir, err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	// A directory that the volume did not create fails the setup, and the
+	// files in it survive its teardown.
+	takenDir := path.Join(taken, string(testPodUID)+"_"+testVolumeName)
+	if err := os.Mkdir(takenDir, 0755); err != nil {
+		t.Fatalf("Couldn't make the directory of the operator: %v", err)
+	}
+	if err := ioutil.WriteFile(path.Join(takenDir, "data-1"), []byte("operator"), 0644); err != nil {
+		t.Fatalf("Couldn't write a file of the operator: %v", err)
+	}
+	if err := newBuilder(testVolumeName, "taken").SetUp(); err == nil {
+		t.Errorf("Expected an error for a directory the volume did not create")
+	}
+	tearDown(testVolumeName)
+	if names := entryNames(takenDir); !reflect.DeepEqual(names, []string{"data-1"}) {
+		t.Errorf("Expected only the file of the operator to be left, got %v", names)
+	}
+	if data, err := ioutil.ReadFile(path.Join(takenDir, "data-1")); err != nil || string(data) != "operator" {
+		t.Errorf("Expected the file of the operator to be left alone, got %q, %v", data, err)
+	}
+
+	// Volumes in the same host directory each get a directory of their own,
+	// and the teardown of one leaves the other alone.
+	first := newBuilder("first", "shared")
+	second := newBuilder("second", "shared")
+	if first.GetPath() == second.GetPath() {
+		t.Errorf("Expected volumes in the same host directory to have paths of their own, got %v", first.GetPath())
+	}
+	for _, builder := range []volume.Builder{first, second} {
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume: %v", err)
+		}
+	}
+	tearDown("second")
+	doTestSecretDataInVolume(first.GetPath(), secret, t)
+	if names := entryNames(shared); !reflect.DeepEqual(names, []string{path.Base(first.GetPath())}) {
+		t.Errorf("Expected only the directory of the first volume to be left, got %v", names)
+	}
+	tearDown("first")
+	if names := entryNames(shared); len(names) != 0 {
+		t.Errorf("Expected the host directory to be left empty, got %v", names)
+	}
+}
+
+func TestPluginResync(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid78")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+
+		lock   sync.Mutex
+		value  = "value-1"
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			secret := secret(testNamespace, testName)
+			secret.Data["data-1"] = []byte(value)
+			return &secret, nil
+		}}
+	)
+
+	cfg := DefaultConfig()
+	cfg.ResyncPeriod = 10 * time.Millisecond
+	plugins, err := ProbeVolumePluginsWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error for a valid config: %v", err)
+	}
+	rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp rootdir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(plugins, volume.NewFakeVolumeHost(rootDir, client, empty_dir.ProbeVolumePlugins()))
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	p := path.Join(builder.GetPath(), "data-1")
+
+	lock.Lock()
+	value = "value-2"
+	lock.Unlock()
+	var data []byte
+	for i := 0; i < 200; i++ {
+		if data, err = ioutil.ReadFile(p); err == nil && string(data) == "value-2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if string(data) != "value-2" {
+		t.Errorf("Expected the volume to resync to %q, got %q", "value-2", string(data))
+	}
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to teardown volume: %v", err)
+	}
+	sp := plugin.(*secretPlugin)
+	sp.resyncsLock.Lock()
+	resyncs := len(sp.resyncs)
+	sp.resyncsLock.Unlock()
+	if resyncs != 0 {
+		t.Errorf("Expected the resync to be stopped by the teardown, got %v running", resyncs)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(builder.GetPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected the volume to stay torn down, got %v", err)
+	}
+}
+
+func TestPluginSecretSelector(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid79")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+
+		pod     = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter = &mount.FakeMounter{}
+
+		v1      = secret(testNamespace, "db-v1")
+		v2      = secret(testNamespace, "db-v2")
+		secrets = []*api.Secret{&v1, &v2}
+		client  = &testclient.Fake{ReactFn: func(action testclient.Action) (runtime.Object, error) {
+			switch a := action.(type) {
+			case testclient.ListAction:
+				list := &api.SecretList{}
+				for _, secret := range secrets {
+					if a.GetListRestrictions().Labels.Matches(labels.Set(secret.Labels)) {
+						list.Items = append(list.Items, *secret)
+					}
+				}
+				return list, nil
+			case testclient.GetAction:
+				for _, secret := range secrets {
+					if secret.Name == a.GetName() {
+						return secret, nil
+					}
+				}
+				return nil, errors.NewNotFound("secrets", a.GetName())
+			}
+			return nil, fmt.Errorf("unexpected action %v", action)
+		}}
+	)
+	v1.Data["data-1"] = []byte("v1")
+	v2.Data["data-1"] = []byte("v2")
+	setActive := func(active ...*api.Secret) {
+		for _, secret := range secrets {
+			secret.Labels = map[string]string{"app": "db"}
+		}
+		for _, secret := range active {
+			secret.Labels["active"] = "true"
+		}
+	}
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	volumeSpec := volumeSpec(testVolumeName, "")
+	volumeSpec.Secret.SecretSelector = map[string]string{"app": "db", "active": "true"}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	p := path.Join(builder.GetPath(), "data-1")
+	expectData := func(when, expected string) {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatalf("Couldn't read %v %s: %v", p, when, err)
+		}
+		if string(data) != expected {
+			t.Errorf("Expected %q %s, got %q", expected, when, string(data))
+		}
+	}
+
+	setActive(&v1)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	expectData("after setup", "v1")
+
+	// A rotation moves the label, and a refresh follows it.
+	setActive(&v2)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expectData("after a rotation", "v2")
+
+	setActive(&v1, &v2)
+	if err := builder.SetUp(); err == nil || !strings.Contains(err.Error(), "db-v1, db-v2") {
+		t.Errorf("Expected an error naming both matching secrets, got %v", err)
+	}
+	setActive()
+	if err := builder.SetUp(); err == nil || !strings.Contains(err.Error(), `"active=true,app=db" not found`) {
+		t.Errorf("Expected a not found error when no secret matches, got %v", err)
+	}
+	expectData("after failed refreshes", "v2")
+
+	volumeSpec.Secret.SecretSelector["app"] = "db?"
+	if _, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter); err == nil {
+		t.Errorf("Expected an error for a volume with an invalid selector")
+	}
+	volumeSpec.Secret.SecretSelector["app"] = "db"
+	volumeSpec.Secret.SecretName = "db-v1"
+	if _, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter); err == nil {
+		t.Errorf("Expected an error for a volume with both a secret name and a selector")
+	}
+}
+
+func TestPluginPathHelpersAgree(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid80")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+	)
+
+	if escapedPluginName != "kubernetes.io~secret" {
+		t.Errorf("Unexpected escaped plugin name %q", escapedPluginName)
+	}
+	if name := libutil.UnescapeQualifiedNameForDisk(escapedPluginName); name != secretPluginName {
+		t.Errorf("Expected the escaped plugin name to unescape to %q, got %q", secretPluginName, name)
+	}
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	host := plugin.(*secretPlugin).host
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+
+	dataDir := DataDir(host, testPodUID, testVolumeName)
+	metaDir := MetaDir(host, testPodUID, testVolumeName)
+	paths := map[string]string{
+		"builder GetPath":    builder.GetPath(),
+		"cleaner GetPath":    cleaner.GetPath(),
+		"builder getMetaDir": builder.(*secretVolumeBuilder).getMetaDir(),
+		"cleaner getMetaDir": cleaner.(*secretVolumeCleaner).getMetaDir(),
+	}
+	expected := map[string]string{
+		"builder GetPath":    dataDir,
+		"cleaner GetPath":    dataDir,
+		"builder getMetaDir": metaDir,
+		"cleaner getMetaDir": metaDir,
+	}
+	for name, p := range paths {
+		if p != expected[name] {
+			t.Errorf("Expected %s to be %v, got %v", name, expected[name], p)
+		}
+	}
+	if dir := host.GetPodVolumeDir(testPodUID, escapedPluginName, testVolumeName); dataDir != dir {
+		t.Errorf("Expected the data dir %v to be the host's volume dir %v", dataDir, dir)
+	}
+	if dir := path.Join(host.GetPodPluginDir(testPodUID, escapedPluginName), testVolumeName); metaDir != dir {
+		t.Errorf("Expected the meta dir %v to be in the host's plugin dir %v", metaDir, dir)
+	}
+	if base := path.Base(path.Dir(dataDir)); base != escapedPluginName {
+		t.Errorf("Expected the data dir %v to be in a dir named %q", dataDir, escapedPluginName)
+	}
+	if base := path.Base(path.Dir(metaDir)); base != escapedPluginName {
+		t.Errorf("Expected the meta dir %v to be in a dir named %q", metaDir, escapedPluginName)
+	}
+
+	// A volume set up at one path is found and torn down at the others.
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	names, err := plugin.(*secretPlugin).GetVolumesForPod(testPodUID)
+	if err != nil {
+		t.Fatalf("Couldn't list the volumes of the pod: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{testVolumeName}) {
+		t.Errorf("Expected the volumes of the pod to be %v, got %v", []string{testVolumeName}, names)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to teardown volume: %v", err)
+	}
+	for _, dir := range []string{dataDir, metaDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected %v to be removed by the teardown, got %v", dir, err)
+		}
+	}
+}
+
+func TestPluginFileOverlay(t *testing.T) {
+	if !fileOverlaySupported {
+		t.Skip("file overlays are not supported on this platform")
+	}
+	var (
+		testPodUID     = types.UID("test_pod_uid81")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		pod     = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter = &mount.FakeMounter{}
+
+		value  = "value-1"
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			secret := secret(testNamespace, testName)
+			secret.Data["data-1"] = []byte(value)
+			return &secret, nil
+		}}
+	)
+
+	prefix, err := ioutil.TempDir("/tmp", "secret_volume_test_host.")
+	if err != nil {
+		t.Fatalf("can't make a temp prefix: %v", err)
+	}
+	defer os.RemoveAll(prefix)
+	target := path.Join(prefix, "app.conf")
+	if err := ioutil.WriteFile(target, []byte("original"), 0644); err != nil {
+		t.Fatalf("Couldn't write the file to overlay: %v", err)
+	}
+
+	newPlugin := func(allow bool) (string, volume.VolumePlugin) {
+		cfg := DefaultConfig()
+		cfg.AllowFileOverlays = allow
+		cfg.HostDirectoryPrefixes = []string{prefix}
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, volume.NewFakeVolumeHost(rootDir, client, empty_dir.ProbeVolumePlugins()))
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		return rootDir, plugin
+	}
+	newBuilderNamed := func(plugin volume.VolumePlugin, volName string, overlay fileOverlay) volume.Builder {
+		value, err := json.Marshal(overlay)
+		if err != nil {
+			t.Fatalf("Couldn't encode the file overlay: %v", err)
+		}
+		pod := *pod
+		pod.Annotations = map[string]string{api.SecretVolumeOptionAnnotationPrefix + volName + ".file-overlay": string(value)}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec(volName, testName)), &pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder
+	}
+	newBuilder := func(plugin volume.VolumePlugin, overlay fileOverlay) volume.Builder {
+		return newBuilderNamed(plugin, testVolumeName, overlay)
+	}
+	overlaySources := func() []string {
+		var sources []string
+		for _, mp := range mounter.MountPoints {
+			if mp.Path == target {
+				sources = append(sources, mp.Device)
+			}
+		}
+		return sources
+	}
+
+	rootDir, plugin := newPlugin(false)
+	defer os.RemoveAll(rootDir)
+	if err := newBuilder(plugin, fileOverlay{Path: "data-1", TargetPath: target}).SetUp(); err == nil {
+		t.Errorf("Expected an error for a file overlay where none are allowed")
+	}
+
+	rootDir, plugin = newPlugin(true)
+	defer os.RemoveAll(rootDir)
+	for _, overlay := range []fileOverlay{
+		{Path: "data-1", TargetPath: "/etc/hostname"},
+		{Path: "data-1", TargetPath: prefix},
+		{Path: "data-1", TargetPath: path.Join(prefix, "missing")},
+	} {
+		if err := newBuilder(plugin, overlay).SetUp(); err == nil {
+			t.Errorf("Expected an error for a file overlay of %v", overlay.TargetPath)
+		}
+	}
+	if err := newBuilder(plugin, fileOverlay{Path: "missing", TargetPath: target}).SetUp(); err == nil || !strings.Contains(err.Error(), "not a file of the volume") {
+		t.Errorf("Expected an error for a file overlay of a path the volume lacks, got %v", err)
+	}
+	if sources := overlaySources(); len(sources) != 0 {
+		t.Errorf("Expected nothing bound over %v after failed setups, got %v", target, sources)
+	}
+
+	builder := newBuilder(plugin, fileOverlay{Path: "data-1", TargetPath: target})
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	sources := overlaySources()
+	if len(sources) != 1 {
+		t.Fatalf("Expected one bind over %v, got %v", target, sources)
+	}
+	if data, err := ioutil.ReadFile(sources[0]); err != nil || string(data) != "value-1" {
+		t.Errorf("Expected the bind to be of a file holding %q, got %q, %v", "value-1", string(data), err)
+	}
+
+	// A refresh binds the new version of the file in place of the old.
+	value = "value-2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	sources = overlaySources()
+	if len(sources) != 1 {
+		t.Fatalf("Expected one bind over %v after a refresh, got %v", target, sources)
+	}
+	if data, err := ioutil.ReadFile(sources[0]); err != nil || string(data) != "value-2" {
+		t.Errorf("Expected the bind to be of a file holding %q after a refresh, got %q, %v", "value-2", string(data), err)
+	}
+
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to teardown volume: %v", err)
+	}
+	if sources := overlaySources(); len(sources) != 0 {
+		t.Errorf("Expected the bind over %v to be undone by the teardown, got %v", target, sources)
+	}
+	if data, err := ioutil.ReadFile(target); err != nil || string(data) != "original" {
+		t.Errorf("Expected the overlaid file to be left as it was, got %q, %v", string(data), err)
+	}
+
+	// A mount made over the bind by something else is not undone.
+	builder = newBuilderNamed(plugin, "other_volume_name", fileOverlay{Path: "data-1", TargetPath: target})
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if err := mounter.Mount("/other/app.conf", target, "", []string{"bind"}); err != nil {
+		t.Fatalf("Couldn't mount over %v: %v", target, err)
+	}
+	mounter.ResetLog()
+	cleaner, err = plugin.NewCleaner("other_volume_name", testPodUID, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to teardown volume: %v", err)
+	}
+	for _, action := range mounter.Log {
+		if action.Action == mount.FakeActionUnmount && action.Target == target {
+			t.Errorf("Expected the mount over %v by something else to be left alone", target)
+		}
+	}
+	if sources := overlaySources(); len(sources) != 2 || sources[1] != "/other/app.conf" {
+		t.Errorf("Expected the mount over %v by something else to be left alone, got %v", target, sources)
+	}
+}
+
+func TestPluginSecretUID(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid82")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		mounter    = &mount.FakeMounter{}
+
+		secret = secret(testNamespace, testName)
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return &secret, nil
+		}}
+	)
+	secret.UID = "secret-uid-1"
+	secret.ResourceVersion = "1"
+
+	rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+	if err != nil {
+		t.Fatalf("can't make a temp rootdir: %v", err)
+	}
+	defer os.RemoveAll(rootDir)
+	recorder := &eventCounter{}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), volume.NewFakeVolumeHostWithRecorder(rootDir, client, empty_dir.ProbeVolumePlugins(), recorder))
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, mounter)
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	sv := builder.(*secretVolumeBuilder)
+	if uid := sv.SecretUID(); uid != "" {
+		t.Errorf("Expected no secret UID before setup, got %q", uid)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if uid := sv.SecretUID(); uid != "secret-uid-1" {
+		t.Errorf("Expected secret UID %q after setup, got %q", "secret-uid-1", uid)
+	}
+
+	// A volume at the latest version of its secret trusts its files.
+	p := path.Join(builder.GetPath(), "data-1")
+	if err := os.Chmod(p, 0644); err != nil {
+		t.Fatalf("Couldn't make %v writable: %v", p, err)
+	}
+	if err := ioutil.WriteFile(p, []byte("VALUE-1"), 0644); err != nil {
+		t.Fatalf("Couldn't change %v: %v", p, err)
+	}
+	if err := os.Chmod(p, 0444); err != nil {
+		t.Fatalf("Couldn't restore the mode of %v: %v", p, err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(p); string(data) != "VALUE-1" {
+		t.Errorf("Expected a refresh at the same version to trust the files, got %q", string(data))
+	}
+	if len(recorder.messages) != 0 {
+		t.Errorf("Expected no events, got %v", recorder.messages)
+	}
+
+	// A secret created again under the same name is not trusted, even at
+	// the same version.
+	secret.UID = "secret-uid-2"
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(p); string(data) != "value-1" {
+		t.Errorf("Expected a replaced secret to rewrite the files, got %q", string(data))
+	}
+	if uid := sv.SecretUID(); uid != "secret-uid-2" {
+		t.Errorf("Expected secret UID %q after a replacement, got %q", "secret-uid-2", uid)
+	}
+	if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0], "replaced") {
+		t.Errorf("Expected an event for the replaced secret, got %v", recorder.messages)
+	}
+}
+
+func TestPluginArchivePath(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid83")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	volumeSpec.Secret.ArchivePath = "keys.tar.gz"
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if _, err := os.Lstat(path.Join(volumePath, "data-1")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file named after a key, got: %v", err)
+	}
+
+	archivePath := path.Join(volumePath, "keys.tar.gz")
+	f, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatalf("Couldn't open the archive: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Couldn't read the archive: %v", err)
+	}
+	tr := tar.NewReader(zr)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Couldn't read the archive: %v", err)
+		}
+		names = append(names, header.Name)
+		if header.Mode != 0444 {
+			t.Errorf("Expected %v to have mode 0444 in the archive, got %o", header.Name, header.Mode)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Couldn't read %v from the archive: %v", header.Name, err)
+		}
+		if expected := string(secret.Data[header.Name]); string(data) != expected {
+			t.Errorf("Expected %v to hold %q in the archive, got %q", header.Name, expected, string(data))
+		}
+	}
+	if expected := []string{"data-1", "data-2", "data-3"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the archive to hold %v, got %v", expected, names)
+	}
+
+	// A refresh of an unchanged secret leaves the archive alone.
+	before, err := os.Stat(archivePath)
+	if err != nil {
+		t.Fatalf("Couldn't stat the archive: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	after, err := os.Stat(archivePath)
+	if err != nil {
+		t.Fatalf("Couldn't stat the archive: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Errorf("Expected a refresh of an unchanged secret to leave the archive alone")
+	}
+}
+
+func TestPluginSocketDelivery(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid84")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		client = testclient.NewSimpleFake(&secret)
+		pod    = &api.Pod{ObjectMeta: api.ObjectMeta{
+			UID:         testPodUID,
+			Namespace:   testNamespace,
+			Annotations: map[string]string{api.SecretVolumeOptionAnnotationPrefix + testVolumeName + ".delivery-mode": "Socket"},
+		}}
+	)
+
+	newPlugin := func(allow bool, timeout time.Duration) (string, *secretPlugin) {
+		cfg := DefaultConfig()
+		cfg.AllowSocketDelivery = allow
+		cfg.SocketDeliveryTimeout = timeout
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, volume.NewFakeVolumeHost(rootDir, client, empty_dir.ProbeVolumePlugins()))
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		return rootDir, plugin.(*secretPlugin)
+	}
+	setUp := func(plugin *secretPlugin) (volume.Builder, string) {
+		volumeSpec := volumeSpec(testVolumeName, testName)
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder, path.Join(builder.GetPath(), deliverySocketName)
+	}
+	waitForRemoval := func(socketPath string) {
+		if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			_, err := os.Lstat(socketPath)
+			return os.IsNotExist(err), nil
+		}); err != nil {
+			t.Errorf("Expected the socket to be removed")
+		}
+	}
+
+	// A node that does not allow socket delivery refuses it.
+	rootDir, plugin := newPlugin(false, time.Minute)
+	defer os.RemoveAll(rootDir)
+	builder, _ := setUp(plugin)
+	if err := builder.SetUp(); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Expected socket delivery to be refused, got: %v", err)
+	}
+
+	// The first reader gets the secret, and the socket goes away.
+	rootDir, plugin = newPlugin(true, time.Minute)
+	defer os.RemoveAll(rootDir)
+	builder, socketPath := setUp(plugin)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if _, err := os.Lstat(path.Join(builder.GetPath(), "data-1")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written, got: %v", err)
+	}
+	if info, err := os.Lstat(socketPath); err != nil || info.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("Expected a socket at %v: %v", socketPath, err)
+	}
+	// The path is too long to dial; go through the directory instead.
+	dir, err := os.Open(path.Dir(socketPath))
+	if err != nil {
+		t.Fatalf("Couldn't open the volume: %v", err)
+	}
+	defer dir.Close()
+	conn, err := net.Dial("unix", fmt.Sprintf("/proc/self/fd/%d/%s", dir.Fd(), deliverySocketName))
+	if err != nil {
+		t.Fatalf("Couldn't connect to the socket: %v", err)
+	}
+	zr, err := gzip.NewReader(conn)
+	if err != nil {
+		t.Fatalf("Couldn't read the archive: %v", err)
+	}
+	tr := tar.NewReader(zr)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Couldn't read the archive: %v", err)
+		}
+		if files[header.Name], err = ioutil.ReadAll(tr); err != nil {
+			t.Fatalf("Couldn't read %v from the archive: %v", header.Name, err)
+		}
+	}
+	conn.Close()
+	if !reflect.DeepEqual(files, secret.Data) {
+		t.Errorf("Expected the archive to hold %v, got %v", secret.Data, files)
+	}
+	waitForRemoval(socketPath)
+
+	// A refresh does not serve the secret again.
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if _, err := os.Lstat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no socket after a refresh, got: %v", err)
+	}
+	cleaner, err := plugin.NewCleaner(testVolumeName, testPodUID, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	if len(plugin.sockets) != 0 {
+		t.Errorf("Expected no socket servers after teardown, got %v", plugin.sockets)
+	}
+
+	// A socket that nobody reads is removed once it times out.
+	rootDir, plugin = newPlugin(true, 50*time.Millisecond)
+	defer os.RemoveAll(rootDir)
+	builder, socketPath = setUp(plugin)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	waitForRemoval(socketPath)
+	// The server that timed out is forgotten.
+	if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		plugin.socketsLock.Lock()
+		defer plugin.socketsLock.Unlock()
+		return len(plugin.sockets) == 0, nil
+	}); err != nil {
+		t.Errorf("Expected the socket server to be forgotten once it timed out")
+	}
+
+	// Teardown stops a socket that nobody has read.
+	rootDir, plugin = newPlugin(true, 0)
+	defer os.RemoveAll(rootDir)
+	builder, socketPath = setUp(plugin)
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	s := plugin.sockets[volumeKey(testPodUID, testVolumeName)]
+	cleaner, err = plugin.NewCleaner(testVolumeName, testPodUID, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if err := cleaner.TearDown(); err != nil {
+		t.Fatalf("Failed to tear down volume: %v", err)
+	}
+	select {
+	case <-s.done:
+	default:
+		t.Errorf("Expected the socket server to be stopped by teardown")
+	}
+	if _, err := os.Lstat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed by teardown, got: %v", err)
+	}
+}
+
+func TestPluginSetUpErrorStage(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid85")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		secret      = secret(testNamespace, testName)
+		pod         = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		invalidMode = 01000
+	)
+
+	testCases := []struct {
+		name   string
+		client client.Interface
+		mode   *int
+		stage  SetUpStage
+		retry  bool
+	}{
+		{name: "bad mode", client: testclient.NewSimpleFake(&secret), mode: &invalidMode, stage: SetUpStageValidate},
+		{name: "missing secret", client: testclient.NewSimpleFake(), stage: SetUpStageFetch, retry: true},
+	}
+	for _, tc := range testCases {
+		rootDir, plugin := newTestPlugin(t, tc.client)
+		defer os.RemoveAll(rootDir)
+		volumeSpec := volumeSpec(testVolumeName, testName)
+		volumeSpec.Secret.DefaultMode = tc.mode
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if r, ok := err.(*retryAfterError); ok != tc.retry {
+			t.Errorf("%v: expected a suggested retry %v, got: %v", tc.name, tc.retry, err)
+		} else if ok {
+			err = r.Unwrap()
+		}
+		setUpErr, ok := err.(*SetUpError)
+		if !ok {
+			t.Errorf("%v: expected a SetUpError, got: %v", tc.name, err)
+			continue
+		}
+		if setUpErr.Stage != tc.stage {
+			t.Errorf("%v: expected stage %v, got %v", tc.name, tc.stage, setUpErr.Stage)
+		}
+		if expected := "(stage=" + string(tc.stage) + ")"; !strings.Contains(err.Error(), expected) {
+			t.Errorf("%v: expected the error to contain %q, got: %v", tc.name, expected, err)
+		}
+	}
+}
+
+// namespaceTestHost is a VolumeHost that overrides the namespaces whose
+// secrets may be read.
+type namespaceTestHost struct {
+	volume.VolumeHost
+	namespaces []string
+}
+
+func (h *namespaceTestHost) GetSecretVolumeAllowedNamespaces() []string {
+	return h.namespaces
+}
+
+func TestPluginAllowedNamespaces(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid86")
+		testVolumeName = "test_volume_name"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secretA    = secret("tenant-a", testName)
+		secretB    = secret("tenant-b", testName)
+	)
+
+	newPlugin := func(client client.Interface, allowed []string, hostAllowed []string) (string, *secretPlugin) {
+		cfg := DefaultConfig()
+		cfg.AllowedNamespaces = allowed
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		var host volume.VolumeHost = volume.NewFakeVolumeHost(rootDir, client, empty_dir.ProbeVolumePlugins())
+		if hostAllowed != nil {
+			host = &namespaceTestHost{VolumeHost: host, namespaces: hostAllowed}
+		}
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		return rootDir, plugin.(*secretPlugin)
+	}
+
+	testCases := []struct {
+		name        string
+		allowed     []string
+		hostAllowed []string
+		namespace   string
+		expectErr   bool
+	}{
+		{name: "no allowlist", namespace: "tenant-b"},
+		{name: "allowed", allowed: []string{"tenant-a"}, namespace: "tenant-a"},
+		{name: "not allowed", allowed: []string{"tenant-a"}, namespace: "tenant-b", expectErr: true},
+		{name: "host override", allowed: []string{"tenant-a"}, hostAllowed: []string{"tenant-b"}, namespace: "tenant-a", expectErr: true},
+	}
+	for _, tc := range testCases {
+		client := testclient.NewSimpleFake(&secretA, &secretB)
+		rootDir, plugin := newPlugin(client, tc.allowed, tc.hostAllowed)
+		defer os.RemoveAll(rootDir)
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: tc.namespace}}
+
+		spec := volume.NewSpecFromVolume(volumeSpec)
+		err := plugin.ValidateSpec(spec, pod)
+		if tc.expectErr != (err != nil) {
+			t.Errorf("%v: expected validation error %v, got: %v", tc.name, tc.expectErr, err)
+		}
+		builder, err := plugin.NewBuilder(spec, pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		err = builder.SetUp()
+		if !tc.expectErr {
+			if err != nil {
+				t.Errorf("%v: failed to setup volume: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "may not be read on this node") {
+			t.Errorf("%v: expected the namespace to be refused, got: %v", tc.name, err)
+		}
+		// The refusal comes before anything is fetched or mounted.
+		if actions := client.Actions(); len(actions) != 0 {
+			t.Errorf("%v: expected no calls to the API server, got %v", tc.name, actions)
+		}
+		if _, err := os.Stat(builder.GetPath()); !os.IsNotExist(err) {
+			t.Errorf("%v: expected the volume not to be set up, got: %v", tc.name, err)
+		}
+	}
+}
+
+// changeTestHost is a VolumeHost with a change hook.
+type changeTestHost struct {
+	volume.VolumeHost
+	hook ChangeHook
+}
+
+func (h *changeTestHost) GetSecretVolumeOnChange() ChangeHook {
+	return h.hook
+}
+
+func TestPluginChangeHook(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid87")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+
+		lock   sync.Mutex
+		values = map[string]string{"data-1": "value-1", "data-2": "value-2"}
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			secret := api.Secret{ObjectMeta: api.ObjectMeta{Namespace: testNamespace, Name: testName}, Data: map[string][]byte{}}
+			for key, value := range values {
+				secret.Data[key] = []byte(value)
+			}
+			return &secret, nil
+		}}
+		changes = make(chan VolumeChange, 10)
+	)
+	setValues := func(newValues map[string]string) {
+		lock.Lock()
+		defer lock.Unlock()
+		values = newValues
+	}
+
+	rootDir, host := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), &changeTestHost{VolumeHost: host, hook: func(change VolumeChange) error {
+		changes <- change
+		// A failing hook does not fail the volume.
+		return fmt.Errorf("reload failed")
+	}})
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Builder: %v", err)
+	}
+	volumePath := builder.GetPath()
+	nextChange := func() VolumeChange {
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the change hook to be called")
+		}
+		return VolumeChange{}
+	}
+
+	// Neither the first setup nor a refresh that changes nothing calls the
+	// hook, so the first call is for the first change.
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	setValues(map[string]string{"data-1": "value-1b", "data-3": "value-3"})
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	expected := VolumeChange{
+		PodUID: testPodUID,
+		Volume: testVolumeName,
+		Path:   volumePath,
+		Keys:   []string{"data-1", "data-3"},
+		Paths:  []string{"data-1", "data-2", "data-3"},
+	}
+	if change := nextChange(); !reflect.DeepEqual(change, expected) {
+		t.Errorf("Expected change %+v, got %+v", expected, change)
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("Expected the hook to be called once, got another change %+v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPluginMaxKeys(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid88")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+
+		volumeSpec = volumeSpec(testVolumeName, testName)
+		secret     = secret(testNamespace, testName)
+		client     = testclient.NewSimpleFake(&secret)
+		pod        = &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	)
+
+	testCases := []struct {
+		maxKeys   int
+		expectErr string
+	}{
+		{maxKeys: 2, expectErr: "has 3 keys, which exceeds the maximum of 2 keys"},
+		{maxKeys: 3},
+		{maxKeys: 0},
+	}
+	for _, tc := range testCases {
+		cfg := DefaultConfig()
+		cfg.MaxKeys = tc.maxKeys
+		plugins, err := ProbeVolumePluginsWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error for a valid config: %v", err)
+		}
+		rootDir, host := newTestHost(t, client)
+		defer os.RemoveAll(rootDir)
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(plugins, host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		err = builder.SetUp()
+		if tc.expectErr == "" {
+			if err != nil {
+				t.Errorf("max keys %v: failed to setup volume: %v", tc.maxKeys, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+			t.Errorf("max keys %v: expected an error containing %q, got: %v", tc.maxKeys, tc.expectErr, err)
+		}
+	}
+}
+
+func TestPluginWrappedVolumeName(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid89")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+		volumeNames   = []string{"test_volume_name_1", "test_volume_name_2"}
+
+		secret = secret(testNamespace, testName)
+		client = testclient.NewSimpleFake(&secret)
+	)
+
+	host := &configTestHost{maxSize: api.MaxSecretSize, medium: api.StorageMediumDefault, dirMode: defaultDirMode, retries: defaultGetSecretRetries, backoff: defaultGetSecretBackoff}
+	rootDir, plugin := newConfigTestPlugin(t, client, host)
+	defer os.RemoveAll(rootDir)
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	mounter := &mount.FakeMounter{}
+	emptyDirPluginDir := host.GetPodPluginDir(testPodUID, libutil.EscapeQualifiedNameForDisk("kubernetes.io/empty-dir"))
+	for _, name := range volumeNames {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec(name, testName)), pod, volume.VolumeOptions{}, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		volumePath := builder.GetPath()
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume %v: %v", name, err)
+		}
+		doTestSecretDataInVolume(volumePath, secret, t)
+		// The wrapped EmptyDir must have been set up for this volume, not
+		// found ready from the setup of another.
+		if !util.IsReady(path.Join(emptyDirPluginDir, name)) {
+			t.Errorf("Expected the wrapped EmptyDir of volume %v to be ready under its name", name)
+		}
+	}
+	if _, err := os.Stat(path.Join(emptyDirPluginDir, "not-used")); !os.IsNotExist(err) {
+		t.Errorf("Expected no shared EmptyDir bookkeeping, got: %v", err)
+	}
+
+	for _, name := range volumeNames {
+		cleaner, err := plugin.NewCleaner(name, testPodUID, mounter)
+		if err != nil {
+			t.Fatalf("Failed to make a new Cleaner: %v", err)
+		}
+		volumePath := cleaner.GetPath()
+		if err := cleaner.TearDown(); err != nil {
+			t.Fatalf("Failed to tear down volume %v: %v", name, err)
+		}
+		if _, err := os.Stat(volumePath); !os.IsNotExist(err) {
+			t.Errorf("Expected volume %v to be torn down, got: %v", name, err)
+		}
+	}
+}
+
+// progressTestHost is a VolumeHost that records the progress of writes.
+type progressTestHost struct {
+	volume.VolumeHost
+	progress []SetUpProgress
+}
+
+func (h *progressTestHost) GetSecretVolumeOnProgress() ProgressHook {
+	return func(progress SetUpProgress) {
+		h.progress = append(h.progress, progress)
+	}
+}
+
+func TestPluginSetUpProgress(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid90")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+
+		small   = secret(testNamespace, testName)
+		large   = secret(testNamespace, testName)
+		current = &small
+		client  = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return current, nil
+		}}
+	)
+	large.Data = map[string][]byte{}
+	for i := 0; i < 2*progressFiles+50; i++ {
+		large.Data[fmt.Sprintf("key-%03d", i)] = []byte("value")
+	}
+
+	rootDir, fakeHost := newTestHost(t, client)
+	defer os.RemoveAll(rootDir)
+	host := &progressTestHost{VolumeHost: fakeHost}
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		t.Fatalf("Can't find the plugin by name")
+	}
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+
+	setUp := func(volumeName string) {
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(volumeSpec(volumeName, testName)), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		if err := builder.SetUp(); err != nil {
+			t.Fatalf("Failed to setup volume %v: %v", volumeName, err)
+		}
+	}
+
+	setUp("test_volume_small")
+	if len(host.progress) != 0 {
+		t.Errorf("Expected no progress for a small secret, got %v", host.progress)
+	}
+
+	current = &large
+	setUp("test_volume_large")
+	total := 2*progressFiles + 50
+	expected := []SetUpProgress{
+		{PodUID: testPodUID, Volume: "test_volume_large", Files: progressFiles, TotalFiles: total, Bytes: 5 * progressFiles, TotalBytes: 5 * total},
+		{PodUID: testPodUID, Volume: "test_volume_large", Files: 2 * progressFiles, TotalFiles: total, Bytes: 10 * progressFiles, TotalBytes: 5 * total},
+	}
+	if !reflect.DeepEqual(host.progress, expected) {
+		t.Errorf("Expected progress %v, got %v", expected, host.progress)
+	}
+}
+
+// signatureTestHost is a VolumeHost that provides a key to verify the
+// signatures of secrets with.
+type signatureTestHost struct {
+	volume.VolumeHost
+	key *rsa.PublicKey
+}
+
+func (h *signatureTestHost) GetSecretVolumeVerifyingKey() *rsa.PublicKey {
+	return h.key
+}
+
+func TestPluginVerifySignature(t *testing.T) {
+	var (
+		testPodUID     = types.UID("test_pod_uid91")
+		testVolumeName = "test_volume_name"
+		testNamespace  = "test_secret_namespace"
+		testName       = "test_secret_name"
+	)
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	sign := func(secret api.Secret, key *rsa.PrivateKey) api.Secret {
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, signedDigest(&secret))
+		if err != nil {
+			t.Fatalf("Failed to sign the secret: %v", err)
+		}
+		signed := secret
+		signed.Data = map[string][]byte{signatureKey: signature}
+		for k, v := range secret.Data {
+			signed.Data[k] = v
+		}
+		return signed
+	}
+	unsigned := secret(testNamespace, testName)
+	tampered := sign(unsigned, key)
+	tampered.Data["data-1"] = []byte("tampered")
+	renamed := sign(secret(testNamespace, "other_secret_name"), key)
+	renamed.Name = testName
+
+	testCases := []struct {
+		name      string
+		secret    api.Secret
+		hostKey   *rsa.PublicKey
+		expectErr string
+	}{
+		{name: "signed", secret: sign(unsigned, key), hostKey: &key.PublicKey},
+		{name: "no verifying key", secret: sign(unsigned, key), expectErr: "the kubelet has no verifying key"},
+		{name: "unsigned", secret: unsigned, hostKey: &key.PublicKey, expectErr: `has no ".sig" key`},
+		{name: "other key", secret: sign(unsigned, otherKey), hostKey: &key.PublicKey, expectErr: "verification error"},
+		{name: "tampered", secret: tampered, hostKey: &key.PublicKey, expectErr: "verification error"},
+		{name: "renamed", secret: renamed, hostKey: &key.PublicKey, expectErr: "verification error"},
+	}
+	for _, tc := range testCases {
+		rootDir, err := ioutil.TempDir("/tmp", "secret_volume_test.")
+		if err != nil {
+			t.Fatalf("can't make a temp rootdir: %v", err)
+		}
+		defer os.RemoveAll(rootDir)
+		recorder := &eventCounter{}
+		host := &signatureTestHost{
+			VolumeHost: volume.NewFakeVolumeHostWithRecorder(rootDir, testclient.NewSimpleFake(&tc.secret), empty_dir.ProbeVolumePlugins(), recorder),
+			key:        tc.hostKey,
+		}
+		pluginMgr := volume.VolumePluginMgr{}
+		pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+		plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+		if err != nil {
+			t.Fatalf("Can't find the plugin by name")
+		}
+
+		spec := volumeSpec(testVolumeName, testName)
+		spec.Secret.VerifySignature = true
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("%v: failed to make a new Builder: %v", tc.name, err)
+		}
+		volumePath := builder.GetPath()
+		err = builder.SetUp()
+		if tc.expectErr == "" {
+			if err != nil {
+				t.Errorf("%v: failed to setup volume: %v", tc.name, err)
+				continue
+			}
+			doTestSecretDataInVolume(volumePath, unsigned, t)
+			if _, err := os.Stat(path.Join(volumePath, signatureKey)); !os.IsNotExist(err) {
+				t.Errorf("%v: expected the signature not to be projected, got: %v", tc.name, err)
+			}
+			if len(recorder.messages) != 0 {
+				t.Errorf("%v: expected no events, got %v", tc.name, recorder.messages)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+			t.Errorf("%v: expected an error containing %q, got: %v", tc.name, tc.expectErr, err)
+		}
+		if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0], "signature verification") {
+			t.Errorf("%v: expected an event about the signature, got %v", tc.name, recorder.messages)
+		}
+		if _, err := os.Stat(path.Join(volumePath, "data-1")); !os.IsNotExist(err) {
+			t.Errorf("%v: expected no files to be written, got: %v", tc.name, err)
+		}
+	}
+}
+
+func TestPluginGetDataPath(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid93")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return &secret, nil
+		}}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	newBuilder := func(volumeName string, strategy api.SecretProjectionStrategy) *secretVolumeBuilder {
+		spec := volumeSpec(volumeName, testName)
+		spec.Secret.ProjectionStrategy = strategy
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder.(*secretVolumeBuilder)
+	}
+
+	builder := newBuilder("test_volume_name", "")
+	if dataPath, err := builder.GetDataPath(); err != nil || dataPath != builder.GetPath() {
+		t.Errorf("Expected the data path of a volume not set up to be %v, got %v, %v", builder.GetPath(), dataPath, err)
+	}
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	dataPath, err := builder.GetDataPath()
+	if err != nil {
+		t.Fatalf("Failed to get the data path: %v", err)
+	}
+	if path.Dir(dataPath) != builder.GetPath() || dataPath == builder.GetPath() {
+		t.Errorf("Expected the data path to be a directory in %v, got %v", builder.GetPath(), dataPath)
+	}
+	doTestSecretDataInVolume(dataPath, secret, t)
+
+	secret.Data["data-1"] = []byte("updated-1")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	updatedPath, err := builder.GetDataPath()
+	if err != nil {
+		t.Fatalf("Failed to get the data path: %v", err)
+	}
+	if updatedPath == dataPath {
+		t.Errorf("Expected an update to publish a new data path, got %v again", dataPath)
+	}
+	doTestSecretDataInVolume(updatedPath, secret, t)
+
+	cleaner, err := plugin.NewCleaner("test_volume_name", testPodUID, &mount.FakeMounter{})
+	if err != nil {
+		t.Fatalf("Failed to make a new Cleaner: %v", err)
+	}
+	if cleanerPath, err := cleaner.(*secretVolumeCleaner).GetDataPath(); err != nil || cleanerPath != updatedPath {
+		t.Errorf("Expected the cleaner to find data path %v, got %v, %v", updatedPath, cleanerPath, err)
+	}
+
+	copied := newBuilder("test_volume_copied", api.SecretProjectionCopy)
+	if err := copied.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if dataPath, err := copied.GetDataPath(); err != nil || dataPath != copied.GetPath() {
+		t.Errorf("Expected the data path of a copied volume to be %v, got %v, %v", copied.GetPath(), dataPath, err)
+	}
+}
+
+func TestPluginBestEffort(t *testing.T) {
+	var (
+		testPodUID    = types.UID("test_pod_uid94")
+		testNamespace = "test_secret_namespace"
+		testName      = "test_secret_name"
+
+		secret = secret(testNamespace, testName)
+		client = &testclient.Fake{ReactFn: func(testclient.Action) (runtime.Object, error) {
+			return &secret, nil
+		}}
+		items = []api.KeyToPath{
+			{Key: "data-1", Path: "one"},
+			{Key: "missing", Path: "missing"},
+			{KeyPattern: "extra-*", Path: "extra"},
+		}
+	)
+
+	rootDir, plugin := newTestPlugin(t, client)
+	defer os.RemoveAll(rootDir)
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{UID: testPodUID, Namespace: testNamespace}}
+	newBuilder := func(volumeName string, bestEffort bool) *secretVolumeBuilder {
+		spec := volumeSpec(volumeName, testName)
+		spec.Secret.Items = items
+		spec.Secret.BestEffort = bestEffort
+		builder, err := plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, &mount.FakeMounter{})
+		if err != nil {
+			t.Fatalf("Failed to make a new Builder: %v", err)
+		}
+		return builder.(*secretVolumeBuilder)
+	}
+
+	if err := newBuilder("test_volume_strict", false).SetUp(); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("Expected a volume that is not best-effort to fail on the missing key, got: %v", err)
+	}
+
+	builder := newBuilder("test_volume_name", true)
+	volumePath := builder.GetPath()
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to setup volume: %v", err)
+	}
+	if data, err := ioutil.ReadFile(path.Join(volumePath, "one")); err != nil || string(data) != string(secret.Data["data-1"]) {
+		t.Errorf("Expected the present key to be projected, got %q, %v", data, err)
+	}
+	if expected := []string{"extra-*", "missing"}; !reflect.DeepEqual(builder.SkippedKeys(), expected) {
+		t.Errorf("Expected skipped keys %v, got %v", expected, builder.SkippedKeys())
+	}
+
+	secret.Data["missing"] = []byte("found")
+	if err := builder.SetUp(); err != nil {
+		t.Fatalf("Failed to refresh volume: %v", err)
+	}
+	if data, err := ioutil.ReadFile(path.Join(volumePath, "missing")); err != nil || string(data) != "found" {
+		t.Errorf("Expected a key that appeared to be projected, got %q, %v", data, err)
+	}
+	if expected := []string{"extra-*"}; !reflect.DeepEqual(builder.SkippedKeys(), expected) {
+		t.Errorf("Expected skipped keys %v, got %v", expected, builder.SkippedKeys())
 	}
 }
@@ -0,0 +1,86 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/golang/glog"
+)
+
+// secretTypeTLS is the conventional type of secrets holding a TLS
+// certificate and its key.  The API does not define it yet, but accepts it
+// as it does any other type.
+const secretTypeTLS api.SecretType = "kubernetes.io/tls"
+
+// secretTypeKeys are the keys that secrets of each type are expected to
+// hold.  Secrets of other types may hold anything.
+var secretTypeKeys = map[api.SecretType][]string{
+	api.SecretTypeDockercfg:           {api.DockerConfigKey},
+	api.SecretTypeServiceAccountToken: {api.ServiceAccountTokenKey},
+	secretTypeTLS:                     {"tls.crt", "tls.key"},
+}
+
+// typeMismatches describes each key that the type of secret calls for but
+// that secret lacks or, if perKey is set, that payload does not project to
+// a file of its own.
+func typeMismatches(secret *api.Secret, payload map[string]fileProjection, perKey bool) []string {
+	var mismatches []string
+	for _, key := range secretTypeKeys[secret.Type] {
+		if _, found := secret.Data[key]; !found {
+			mismatches = append(mismatches, fmt.Sprintf("has no key %q", key))
+			continue
+		}
+		if !perKey {
+			continue
+		}
+		projected := false
+		for _, file := range payload {
+			if file.key == key {
+				projected = true
+				break
+			}
+		}
+		if !projected {
+			mismatches = append(mismatches, fmt.Sprintf("does not project key %q", key))
+		}
+	}
+	return mismatches
+}
+
+// checkSecretType checks the payload projected from secret against the type
+// of secret, as configured for the volume.  A mismatch fails the setup or
+// is only logged and recorded as an event.
+func (b *secretVolumeBuilder) checkSecretType(secret *api.Secret, payload map[string]fileProjection) error {
+	if b.typeCheck == "" || b.typeCheck == api.SecretTypeCheckNone {
+		return nil
+	}
+	mismatches := typeMismatches(secret, payload, b.bundlePath == "" && b.archivePath == "")
+	if len(mismatches) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("secret %v/%v of type %v %v", b.pod.Namespace, b.secretName, secret.Type, strings.Join(mismatches, ", "))
+	if b.typeCheck == api.SecretTypeCheckError {
+		glog.Errorf("Secret does not match its type: %v", b.logFields("type", secret.Type, "mismatches", len(mismatches)))
+		return fmt.Errorf("Cannot setup secret volume %v: %v", b.volName, message)
+	}
+	glog.Warningf("Secret does not match its type: %v", b.logFields("type", secret.Type, "mismatches", len(mismatches)))
+	b.plugin.recordSecretEvent(&b.pod, b.volName, "Secret volume %v: %v", b.volName, message)
+	return nil
+}
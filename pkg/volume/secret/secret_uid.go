@@ -0,0 +1,93 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// secretUIDFileName is the name of the file in the meta dir of a volume
+// that holds the UID of the secret written by its last successful setup.
+const secretUIDFileName = "secretUID"
+
+// SecretUID returns the UID of the secret whose contents the volume
+// currently holds, as of its last successful setup.  A secret deleted and
+// created again under the same name has a new UID, so comparing it with
+// the live secret shows whether the volume holds the data of a secret that
+// is gone.  For a volume with additional sources, it is the UIDs of all of
+// the secrets, in order, separated by commas.  It returns "" if the volume
+// has never been set up, lacks a secret, or the UID cannot be read.
+func (sv *secretVolume) SecretUID() string {
+	data, err := ioutil.ReadFile(path.Join(sv.getMetaDir(), secretUIDFileName))
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		glog.Warningf("Couldn't read the secret UID of secret volume %v: %v", sv.volName, err)
+		return ""
+	}
+	return string(data)
+}
+
+// recordSecretUID persists uid, the UIDs of the secrets just written to the
+// volume, unless it matches previous.  An empty uid is not kept.  Failures
+// are only logged; without a UID the next refresh cannot tell a replaced
+// secret from an updated one, which only matters for secrets whose version
+// is the same.
+func (b *secretVolumeBuilder) recordSecretUID(uid, previous string) {
+	if uid == previous {
+		return
+	}
+	var err error
+	if uid == "" {
+		err = os.Remove(path.Join(b.getMetaDir(), secretUIDFileName))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = b.writeMetaFile(secretUIDFileName, []byte(uid))
+	}
+	if err != nil {
+		glog.Errorf("Couldn't persist the secret UID of secret volume: %v", b.logFields("uid", uid, "err", err))
+	}
+}
+
+// secretReplaced returns true if the secrets of the volume, whose UIDs are
+// uid, are not the ones it was last written from, whose UIDs are previous:
+// one was deleted and created again under the same name.  Nothing written
+// from the old secret is trusted then.  It is false if either is unknown.
+func (b *secretVolumeBuilder) secretReplaced(uid, previous string) bool {
+	if uid == "" || previous == "" || uid == previous {
+		return false
+	}
+	glog.Warningf("Secret of volume was replaced by another of the same name; rewriting the volume in full: %v",
+		b.logFields("uid", uid, "previousUID", previous))
+	b.plugin.recordSecretEvent(&b.pod, b.volName, "Secret of volume %v was replaced by another of the same name; rewriting the volume", b.volName)
+	return true
+}
+
+// cachedSecretReplaced returns true if uid, the UID of the cached secret at
+// index i of secretNames, is not that of the secret the volume was last
+// written from.
+func (b *secretVolumeBuilder) cachedSecretReplaced(i int, uid string) bool {
+	previous := strings.Split(b.SecretUID(), ",")
+	return uid != "" && i < len(previous) && previous[i] != "" && previous[i] != uid
+}
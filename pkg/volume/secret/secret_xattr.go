@@ -0,0 +1,52 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"sort"
+
+	"github.com/golang/glog"
+)
+
+// setExtendedAttributes sets the extended attributes of file on the file at
+// p as it is written, before it is published.  If the filesystem does not
+// support them, they are left unset on this and every later file of the
+// write with a warning, unless the writer is strict about them.
+func (w *atomicWriter) setExtendedAttributes(p string, file fileProjection) error {
+	if len(file.xattrs) == 0 || w.xattrsUnsupported {
+		return nil
+	}
+	names := make([]string, 0, len(file.xattrs))
+	for name := range file.xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := setXattr(p, name, file.xattrs[name])
+		if err == nil {
+			continue
+		}
+		if isXattrUnsupported(err) && !w.xattrStrict {
+			glog.Warningf("%s: extended attributes are not supported in %v; leaving them unset: %v", w.logContext, w.targetDir, err)
+			w.xattrsUnsupported = true
+			return nil
+		}
+		glog.Errorf("%s: error setting extended attribute %v of %v: %v", w.logContext, name, p, err)
+		return err
+	}
+	return nil
+}
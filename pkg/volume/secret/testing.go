@@ -0,0 +1,86 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/testclient"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/runtime"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume/empty_dir"
+)
+
+// FakePlugin is the secret volume plugin wired to a fake API server and a
+// fake volume host rooted in a temporary directory.  It is useful for
+// testing code that sets up and tears down secret volumes.
+type FakePlugin struct {
+	// Plugin is the secret volume plugin.
+	Plugin volume.VolumePlugin
+	// RootDir is the root directory of the fake volume host.
+	RootDir string
+	// Client serves secrets to the plugin.  Replace its ReactFn to stub the
+	// answers of the API server; its Actions record the requests made.
+	Client *testclient.Fake
+	// Mounter is given to the builders and cleaners made by the FakePlugin.
+	// It defaults to a mount.FakeMounter.
+	Mounter mount.Interface
+}
+
+// NewFakePlugin returns a FakePlugin whose API server holds secrets.  The
+// caller should call Cleanup once done with it.
+func NewFakePlugin(secrets ...*api.Secret) (*FakePlugin, error) {
+	rootDir, err := ioutil.TempDir("", "secret_volume_test.")
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]runtime.Object, 0, len(secrets))
+	for _, secret := range secrets {
+		objects = append(objects, secret)
+	}
+	client := testclient.NewSimpleFake(objects...)
+	host := volume.NewFakeVolumeHost(rootDir, client, empty_dir.ProbeVolumePlugins())
+
+	pluginMgr := volume.VolumePluginMgr{}
+	pluginMgr.InitPlugins(ProbeVolumePlugins(), host)
+	plugin, err := pluginMgr.FindPluginByName(secretPluginName)
+	if err != nil {
+		os.RemoveAll(rootDir)
+		return nil, err
+	}
+	return &FakePlugin{Plugin: plugin, RootDir: rootDir, Client: client, Mounter: &mount.FakeMounter{}}, nil
+}
+
+// NewBuilder returns a builder of the secret volume spec for pod.
+func (f *FakePlugin) NewBuilder(spec *api.Volume, pod *api.Pod) (volume.Builder, error) {
+	return f.Plugin.NewBuilder(volume.NewSpecFromVolume(spec), pod, volume.VolumeOptions{}, f.Mounter)
+}
+
+// NewCleaner returns a cleaner of the secret volume named volName of the pod
+// with the given UID.
+func (f *FakePlugin) NewCleaner(volName string, podUID types.UID) (volume.Cleaner, error) {
+	return f.Plugin.NewCleaner(volName, podUID, f.Mounter)
+}
+
+// Cleanup removes everything written under the root directory.
+func (f *FakePlugin) Cleanup() error {
+	return os.RemoveAll(f.RootDir)
+}
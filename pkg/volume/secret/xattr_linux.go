@@ -0,0 +1,43 @@
+// +build linux
+
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"os"
+	"syscall"
+)
+
+// setXattr sets the extended attribute name of the file at p, following
+// symlinks.  Tests replace it to simulate a filesystem without extended
+// attributes.
+var setXattr = func(p, name, value string) error {
+	if err := syscall.Setxattr(p, name, []byte(value), 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: p, Err: err}
+	}
+	return nil
+}
+
+// isXattrUnsupported returns true if err means that the filesystem does not
+// support extended attributes, or not those of the user namespace.
+func isXattrUnsupported(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return err == syscall.EOPNOTSUPP
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/types"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/mount"
@@ -31,11 +32,18 @@ import (
 type fakeVolumeHost struct {
 	rootDir    string
 	kubeClient client.Interface
+	recorder   record.EventRecorder
 	pluginMgr  VolumePluginMgr
 }
 
 func NewFakeVolumeHost(rootDir string, kubeClient client.Interface, plugins []VolumePlugin) *fakeVolumeHost {
-	host := &fakeVolumeHost{rootDir: rootDir, kubeClient: kubeClient}
+	return NewFakeVolumeHostWithRecorder(rootDir, kubeClient, plugins, &record.FakeRecorder{})
+}
+
+// NewFakeVolumeHostWithRecorder is like NewFakeVolumeHost, but events
+// recorded by plugins are passed to the given recorder.
+func NewFakeVolumeHostWithRecorder(rootDir string, kubeClient client.Interface, plugins []VolumePlugin, recorder record.EventRecorder) *fakeVolumeHost {
+	host := &fakeVolumeHost{rootDir: rootDir, kubeClient: kubeClient, recorder: recorder}
 	host.pluginMgr.InitPlugins(plugins, host)
 	return host
 }
@@ -56,6 +64,10 @@ func (f *fakeVolumeHost) GetKubeClient() client.Interface {
 	return f.kubeClient
 }
 
+func (f *fakeVolumeHost) GetRecorder() record.EventRecorder {
+	return f.recorder
+}
+
 func (f *fakeVolumeHost) NewWrapperBuilder(spec *Spec, pod *api.Pod, opts VolumeOptions, mounter mount.Interface) (Builder, error) {
 	plug, err := f.pluginMgr.FindPluginBySpec(spec)
 	if err != nil {
@@ -131,6 +143,14 @@ func (fv *FakeVolume) IsReadOnly() bool {
 	return false
 }
 
+func (fv *FakeVolume) GetAttributes() Attributes {
+	return Attributes{
+		ReadOnly:        false,
+		Managed:         true,
+		SupportsSELinux: true,
+	}
+}
+
 func (fv *FakeVolume) GetPath() string {
 	return path.Join(fv.Plugin.Host.GetPodVolumeDir(fv.PodUID, util.EscapeQualifiedNameForDisk(fv.Plugin.PluginName), fv.VolName))
 }
@@ -17,9 +17,11 @@ limitations under the License.
 package volume
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 )
 
 // Volume represents a directory used by pods or hosts on a node.
@@ -29,6 +31,23 @@ type Volume interface {
 	GetPath() string
 }
 
+// Metrics describes how much space the contents of a volume consume.
+type Metrics struct {
+	// Bytes is the total size of the files in the volume.
+	Bytes int64
+	// Files is the number of files in the volume.
+	Files int64
+	// Labels attribute the usage, for example to the namespace and pod
+	// of the volume, when it is exported.  May be nil.
+	Labels map[string]string
+}
+
+// MetricsProvider is implemented by volumes that can report their usage.
+type MetricsProvider interface {
+	// GetMetrics returns the usage of the volume as of its last setup.
+	GetMetrics() (*Metrics, error)
+}
+
 // Builder interface provides methods to set up/mount the volume.
 type Builder interface {
 	// Uses Interface to provide the path for Docker binds.
@@ -43,7 +62,60 @@ type Builder interface {
 	SetUpAt(dir string) error
 	// IsReadOnly is a flag that gives the builder's ReadOnly attribute.
 	// All persistent volumes have a private readOnly flag in their builders.
+	// Deprecated: use GetAttributes().ReadOnly.
 	IsReadOnly() bool
+	// GetAttributes returns the attributes of the builder, which callers
+	// use to decide how to mount the volume.  The kubelet mounts a volume
+	// whose ReadOnly attribute is set read-only into every container.
+	GetAttributes() Attributes
+}
+
+// CancelableBuilder is implemented by builders whose setup can be aborted,
+// for example because the pod was deleted or the kubelet is shutting down.
+// Callers that do not need to abort a setup use the Builder methods.
+type CancelableBuilder interface {
+	Builder
+	// SetUpAtWithCancel is like SetUpAt, but gives up promptly once stopCh
+	// is closed, removing whatever it had written, and returns
+	// ErrSetUpCanceled.
+	SetUpAtWithCancel(dir string, stopCh <-chan struct{}) error
+}
+
+// ReconcilingBuilder is implemented by builders that can bring the contents
+// of a volume that is already set up up to date more cheaply than SetUp.
+// The kubelet reconciles the volumes it has already set up when it resyncs
+// their pod.
+type ReconcilingBuilder interface {
+	Builder
+	// Reconcile updates the contents of the volume at its path, assuming
+	// it is set up.  If it is not, Reconcile sets it up as SetUp would.
+	Reconcile() error
+}
+
+// ErrSetUpCanceled is returned by SetUpAtWithCancel when the setup was
+// aborted.
+var ErrSetUpCanceled = errors.New("volume setup was canceled")
+
+// RetryAfterError is implemented by errors from a setup that suggest how
+// long to wait before the setup is retried, so that errors unlikely to go
+// away soon can be retried less often than transient ones.  The kubelet
+// does not sync the pod of the volume again before the suggested wait.
+type RetryAfterError interface {
+	error
+	// RetryAfter returns the suggested wait before the next attempt.
+	RetryAfter() time.Duration
+}
+
+// Attributes represents the attributes of a builder.
+type Attributes struct {
+	// ReadOnly is true if containers cannot write to the volume.
+	ReadOnly bool
+	// Managed is true if the plugin owns the contents of the volume, so
+	// that their ownership and permissions may be changed.
+	Managed bool
+	// SupportsSELinux is true if the files in the volume can be given an
+	// SELinux label.
+	SupportsSELinux bool
 }
 
 // Cleaner interface provides methods to cleanup/unmount the volumes.
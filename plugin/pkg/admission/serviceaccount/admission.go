@@ -158,7 +158,7 @@ func (s *serviceAccount) Admit(a admission.Attributes) (err error) {
 			return admission.NewForbidden(a, fmt.Errorf("A mirror pod may not reference service accounts"))
 		}
 		for _, volume := range pod.Spec.Volumes {
-			if volume.VolumeSource.Secret != nil {
+			if len(volumeSecrets(volume.VolumeSource)) != 0 {
 				return admission.NewForbidden(a, fmt.Errorf("A mirror pod may not reference secrets"))
 			}
 		}
@@ -298,13 +298,19 @@ func (s *serviceAccount) limitSecretReferences(serviceAccount *api.ServiceAccoun
 		mountableSecrets.Insert(s.Name)
 	}
 	for _, volume := range pod.Spec.Volumes {
-		source := volume.VolumeSource
-		if source.Secret == nil {
-			continue
-		}
-		secretName := source.Secret.SecretName
-		if !mountableSecrets.Has(secretName) {
-			return fmt.Errorf("Volume with secret.secretName=\"%s\" is not allowed because service account %s does not reference that secret", secretName, serviceAccount.Name)
+		for _, source := range volumeSecrets(volume.VolumeSource) {
+			if len(source.SecretSelector) > 0 {
+				if err := s.limitSelectedSecrets(serviceAccount, source.SecretSelector, mountableSecrets); err != nil {
+					return err
+				}
+			} else if !mountableSecrets.Has(source.SecretName) {
+				return fmt.Errorf("Volume with secret.secretName=\"%s\" is not allowed because service account %s does not reference that secret", source.SecretName, serviceAccount.Name)
+			}
+			for i, projection := range source.Sources {
+				if !mountableSecrets.Has(projection.SecretName) {
+					return fmt.Errorf("Volume with secret.sources[%d].secretName=\"%s\" is not allowed because service account %s does not reference that secret", i, projection.SecretName, serviceAccount.Name)
+				}
+			}
 		}
 	}
 
@@ -321,6 +327,44 @@ func (s *serviceAccount) limitSecretReferences(serviceAccount *api.ServiceAccoun
 	return nil
 }
 
+// limitSelectedSecrets ensures that every secret that selector currently
+// matches in the namespace of the service account is one it references.
+// The selector is resolved again each time the volume is refreshed, so this
+// only holds for the secrets that exist when the pod is created.
+func (s *serviceAccount) limitSelectedSecrets(serviceAccount *api.ServiceAccount, selector map[string]string, mountableSecrets util.StringSet) error {
+	selectorString := labels.Set(selector).String()
+	if s.client == nil {
+		return fmt.Errorf("Volume with secret.secretSelector=\"%s\" is not allowed because the secrets it selects cannot be listed", selectorString)
+	}
+	list, err := s.client.Secrets(serviceAccount.Namespace).List(labels.SelectorFromSet(labels.Set(selector)), fields.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the secrets selected by secret.secretSelector=\"%s\": %v", selectorString, err)
+	}
+	for _, secret := range list.Items {
+		if !mountableSecrets.Has(secret.Name) {
+			return fmt.Errorf("Volume with secret.secretSelector=\"%s\" is not allowed because it selects secret %s, which service account %s does not reference", selectorString, secret.Name, serviceAccount.Name)
+		}
+	}
+	return nil
+}
+
+// volumeSecrets returns the secret sources of a volume: its secret source,
+// if it has one, and the secrets it projects, if it is a projected volume.
+func volumeSecrets(source api.VolumeSource) []*api.SecretVolumeSource {
+	secrets := []*api.SecretVolumeSource{}
+	if source.Secret != nil {
+		secrets = append(secrets, source.Secret)
+	}
+	if source.Projected != nil {
+		for _, projection := range source.Projected.Sources {
+			if projection.Secret != nil {
+				secrets = append(secrets, projection.Secret)
+			}
+		}
+	}
+	return secrets
+}
+
 func (s *serviceAccount) mountServiceAccountToken(serviceAccount *api.ServiceAccount, pod *api.Pod) error {
 	// Find the name of a referenced ServiceAccountToken secret we can mount
 	serviceAccountToken, err := s.getReferencedServiceAccountToken(serviceAccount)
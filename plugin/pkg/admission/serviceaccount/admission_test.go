@@ -18,6 +18,7 @@ package serviceaccount
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/admission"
@@ -123,6 +124,28 @@ func TestRejectsMirrorPodWithSecretVolumes(t *testing.T) {
 	}
 }
 
+func TestRejectsMirrorPodWithProjectedSecretVolumes(t *testing.T) {
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Annotations: map[string]string{
+				kubelet.ConfigMirrorAnnotationKey: "true",
+			},
+		},
+		Spec: api.PodSpec{
+			Volumes: []api.Volume{
+				{VolumeSource: api.VolumeSource{Projected: &api.ProjectedVolumeSource{
+					Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{}}},
+				}}},
+			},
+		},
+	}
+	attrs := admission.NewAttributesRecord(pod, "Pod", "myns", "myname", string(api.ResourcePods), "", admission.Create, nil)
+	err := NewServiceAccount(nil).Admit(attrs)
+	if err == nil {
+		t.Errorf("Expected a mirror pod to be prevented from referencing a projected secret")
+	}
+}
+
 func TestAssignsDefaultServiceAccountAndToleratesMissingAPIToken(t *testing.T) {
 	ns := "myns"
 
@@ -428,6 +451,131 @@ func TestRejectsUnreferencedSecretVolumes(t *testing.T) {
 	}
 }
 
+func TestRejectsUnreferencedProjectedSecretVolumes(t *testing.T) {
+	ns := "myns"
+
+	admit := NewServiceAccount(nil)
+	admit.LimitSecretReferences = true
+	admit.RequireAPIToken = false
+
+	// Add the default service account for the ns into the cache
+	admit.serviceAccounts.Add(&api.ServiceAccount{
+		ObjectMeta: api.ObjectMeta{
+			Name:      DefaultServiceAccountName,
+			Namespace: ns,
+		},
+	})
+
+	pod := &api.Pod{
+		Spec: api.PodSpec{
+			Volumes: []api.Volume{
+				{VolumeSource: api.VolumeSource{Projected: &api.ProjectedVolumeSource{
+					Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{SecretName: "foo"}}},
+				}}},
+			},
+		},
+	}
+	attrs := admission.NewAttributesRecord(pod, "Pod", ns, "myname", string(api.ResourcePods), "", admission.Create, nil)
+	err := admit.Admit(attrs)
+	if err == nil {
+		t.Errorf("Expected rejection for projecting a secret the service account does not reference")
+	}
+}
+
+func TestRejectsUnreferencedSecretVolumeSources(t *testing.T) {
+	ns := "myns"
+
+	admit := NewServiceAccount(nil)
+	admit.LimitSecretReferences = true
+	admit.RequireAPIToken = false
+
+	// Add the default service account for the ns with a secret reference into the cache
+	admit.serviceAccounts.Add(&api.ServiceAccount{
+		ObjectMeta: api.ObjectMeta{
+			Name:      DefaultServiceAccountName,
+			Namespace: ns,
+		},
+		Secrets: []api.ObjectReference{
+			{Name: "foo"},
+		},
+	})
+
+	sources := []api.SecretProjection{{SecretName: "foo"}, {SecretName: "bar"}}
+	for _, volumeSource := range []api.VolumeSource{
+		{Secret: &api.SecretVolumeSource{SecretName: "foo", Sources: sources}},
+		{Projected: &api.ProjectedVolumeSource{
+			Sources: []api.VolumeProjection{{Secret: &api.SecretVolumeSource{SecretName: "foo", Sources: sources}}},
+		}},
+	} {
+		pod := &api.Pod{
+			Spec: api.PodSpec{
+				Volumes: []api.Volume{{VolumeSource: volumeSource}},
+			},
+		}
+		attrs := admission.NewAttributesRecord(pod, "Pod", ns, "myname", string(api.ResourcePods), "", admission.Create, nil)
+		err := admit.Admit(attrs)
+		if err == nil || !strings.Contains(err.Error(), `sources[1].secretName="bar"`) {
+			t.Errorf("Expected rejection for a source secret the service account does not reference, got: %v", err)
+		}
+	}
+}
+
+func TestLimitsSelectedSecretVolumes(t *testing.T) {
+	ns := "myns"
+	selected := func(names ...string) *api.SecretList {
+		list := &api.SecretList{}
+		for _, name := range names {
+			list.Items = append(list.Items, api.Secret{ObjectMeta: api.ObjectMeta{Name: name, Namespace: ns, Labels: map[string]string{"app": "db"}}})
+		}
+		return list
+	}
+
+	testCases := []struct {
+		name      string
+		selected  *api.SecretList
+		expectErr string
+	}{
+		{name: "referenced", selected: selected("foo")},
+		{name: "no match", selected: selected()},
+		{name: "unreferenced", selected: selected("foo", "bar"), expectErr: `secret.secretSelector="app=db" is not allowed because it selects secret bar`},
+	}
+	for _, tc := range testCases {
+		admit := NewServiceAccount(testclient.NewSimpleFake(tc.selected))
+		admit.LimitSecretReferences = true
+		admit.RequireAPIToken = false
+
+		// Add the default service account for the ns with a secret reference into the cache
+		admit.serviceAccounts.Add(&api.ServiceAccount{
+			ObjectMeta: api.ObjectMeta{
+				Name:      DefaultServiceAccountName,
+				Namespace: ns,
+			},
+			Secrets: []api.ObjectReference{
+				{Name: "foo"},
+			},
+		})
+
+		pod := &api.Pod{
+			Spec: api.PodSpec{
+				Volumes: []api.Volume{
+					{VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{SecretSelector: map[string]string{"app": "db"}}}},
+				},
+			},
+		}
+		attrs := admission.NewAttributesRecord(pod, "Pod", ns, "myname", string(api.ResourcePods), "", admission.Create, nil)
+		err := admit.Admit(attrs)
+		if tc.expectErr == "" {
+			if err != nil {
+				t.Errorf("%v: expected the pod to be admitted, got: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+			t.Errorf("%v: expected an error containing %q, got: %v", tc.name, tc.expectErr, err)
+		}
+	}
+}
+
 func TestAllowsReferencedImagePullSecrets(t *testing.T) {
 	ns := "myns"
 